@@ -1,67 +1,254 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
 
 	"github.com/hidimpu/transfersystem/internal/api"
+	"github.com/hidimpu/transfersystem/internal/config"
 	"github.com/hidimpu/transfersystem/internal/db"
+	"github.com/hidimpu/transfersystem/internal/grpcapi"
+	"github.com/hidimpu/transfersystem/internal/health"
+	"github.com/hidimpu/transfersystem/internal/lifecycle"
+	"github.com/hidimpu/transfersystem/internal/metrics"
 	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/repository/memory"
 	"github.com/hidimpu/transfersystem/internal/service"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+	"github.com/hidimpu/transfersystem/internal/utils"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to an optional YAML config file")
+	storage := flag.String("storage", "", "storage backend, postgres or memory (overrides STORAGE)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: transfersystem [-config file] [-storage backend] [migrate <command>]\n\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s\n", migrateUsage)
 	}
+	flag.Parse()
 
-	dbConn, err := db.InitDB()
+	cfg, err := config.Load(*configPath, func(c *config.Config) {
+		if *storage != "" {
+			c.Storage = *storage
+		}
+	})
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
+	// Configure logging first: services and repositories capture the global
+	// logger when they are constructed. Once installed, output from the
+	// standard log package is routed through it as well.
+	logger := utils.NewLogger(cfg.Log.LoggerOptions())
+	utils.SetGlobalLogger(logger)
+	ctx := context.Background()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
 	if err != nil {
-		log.Fatal("Failed to connect to DB:", err)
+		log.Fatal("Failed to initialise tracing:", err)
 	}
-	log.Println("database connection established")
-	defer dbConn.Close()
 
-	// Initialize repositories (Data Access Layer)
-	accountRepo := repository.NewAccountRepository(dbConn)
-	transactionRepo := repository.NewTransactionRepository(dbConn)
+	if args := flag.Args(); len(args) > 0 && args[0] != "migrate" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Lifecycle: readiness state, supervised background workers and the
+	// readiness checks reported by /readyz
+	state := lifecycle.NewState()
+	workers := lifecycle.NewWorkers()
+	checker := health.NewChecker(cfg.Server.HealthCheckTimeout)
+	checker.Add("draining", health.Draining(state))
+	checker.Add("workers", health.Workers(workers))
+
+	// Initialize storage (Data Access Layer)
+	var (
+		accountStore     repository.AccountStore
+		transactionStore repository.TransactionStore
+		snapshotStore    repository.SnapshotStore
+		reconStore       repository.ReconciliationStore
+		paymentStore     repository.PaymentFileStore
+		unitOfWork       repository.UnitOfWork
+		closeStorage     func() error
+	)
+	switch cfg.Storage {
+	case config.StorageMemory:
+		if len(flag.Args()) > 0 {
+			log.Fatal("migrate requires postgres storage")
+		}
+		store := memory.NewStore()
+		accountStore = store.Accounts()
+		transactionStore = store.Transactions()
+		snapshotStore = store.Snapshots()
+		reconStore = store.Reconciliation()
+		paymentStore = store.PaymentFiles()
+		unitOfWork = store.UnitOfWork()
+		logger.LogWarning(ctx, "STARTUP", "using in-memory storage; all data is lost on exit")
+
+	default:
+		dbConn, err := db.InitDB(cfg.Database)
+		if err != nil {
+			log.Fatal("Failed to connect to DB:", err)
+		}
+		logger.LogInfo(ctx, "STARTUP", "database connection established")
+		closeStorage = dbConn.Close
+
+		migrator, err := db.NewMigrator(dbConn)
+		if err != nil {
+			log.Fatal("Failed to load migrations:", err)
+		}
+
+		if args := flag.Args(); len(args) > 0 {
+			err := runMigrate(ctx, migrator, args[1:], os.Stdout)
+			dbConn.Close()
+			if err != nil {
+				log.Fatal("Migration failed: ", err)
+			}
+			return
+		}
+
+		if cfg.Database.AutoMigrate {
+			n, err := migrator.Up(ctx)
+			if err != nil {
+				log.Fatal("Auto-migration failed: ", err)
+			}
+			logger.LogInfo(ctx, "STARTUP", fmt.Sprintf("auto-migrate applied %d migration(s)", n))
+		}
+
+		if cfg.Features.Metrics {
+			if err := metrics.RegisterDBStats(dbConn, "transfersystem"); err != nil {
+				log.Fatal("Failed to register DB metrics:", err)
+			}
+		}
+
+		checker.Add("database", health.Database(dbConn))
+		checker.Add("migrations", health.Migrations(migrator.PendingCount))
+
+		accountRepo := repository.NewAccountRepository(dbConn)
+		transactionRepo := repository.NewTransactionRepository(dbConn)
+		reconRepo := repository.NewReconciliationRepository(dbConn)
+		paymentRepo := repository.NewPaymentFileRepository(dbConn)
+		txRunner := db.NewTxRunner(dbConn, db.RetryPolicy{
+			MaxAttempts: cfg.Database.RetryMaxAttempts,
+			BaseDelay:   cfg.Database.RetryBaseDelay,
+			MaxDelay:    cfg.Database.RetryMaxDelay,
+		})
+		isolation, _ := cfg.Transfer.Isolation() // validated by config.Load
+		accountStore = accountRepo
+		transactionStore = transactionRepo
+		snapshotStore = repository.NewSnapshotRepository(dbConn)
+		reconStore = reconRepo
+		paymentStore = paymentRepo
+		unitOfWork = repository.NewPostgresUnitOfWork(txRunner, accountRepo, transactionRepo, reconRepo, paymentRepo, isolation)
+		logger.LogInfo(ctx, "STARTUP", "database locks: FOR UPDATE with "+cfg.Transfer.IsolationLevel+" isolation")
+	}
 
 	// Initialize services (Business Logic Layer)
-	accountService := service.NewAccountService(accountRepo)
-	transactionService := service.NewTransactionService(dbConn, accountRepo, transactionRepo)
+	accountService := service.NewAccountService(accountStore)
+	transactionService := service.NewTransactionService(unitOfWork, accountStore, transactionStore, cfg.Transfer)
+	ledgerService := service.NewLedgerService(accountStore, transactionStore, snapshotStore)
+	snapshotService := service.NewSnapshotService(snapshotStore, cfg.Snapshot)
+	statementService := service.NewStatementService(accountStore, transactionStore, snapshotStore, cfg.Transfer.Currency)
+	reconciliationService := service.NewReconciliationService(reconStore, accountStore, transactionStore, transactionService, cfg.Reconciliation)
+	paymentService := service.NewPaymentService(paymentStore, transactionService)
 
 	// Initialize handlers (Controller Layer)
 	transactionHandler := api.NewTransactionHandler(transactionService)
 
-	// Setup router (View Layer)
-	r := chi.NewRouter()
-
-	// Account routes
-	r.Route("/accounts", func(r chi.Router) {
-		r.Post("/", api.CreateAccountServiceHandler(accountService))
-		r.Get("/{account_id}", api.GetAccountServiceHandler(accountService))
+	r, err := newRouter(routerDeps{
+		accountService:     accountService,
+		transactionHandler: transactionHandler,
+		ledger:             ledgerService,
+		statements:         statementService,
+		reconciliation:     reconciliationService,
+		payments:           paymentService,
+		checker:            checker,
+		maxBodyBytes:       cfg.Server.MaxBodyBytes,
+		maxImportBytes:     cfg.Server.MaxImportBytes,
+		currency:           cfg.Transfer.Currency,
+		metrics:            cfg.Features.Metrics,
 	})
+	if err != nil {
+		log.Fatal("Failed to build router: ", err)
+	}
 
-	// Transaction routes
-	r.Route("/transactions", func(r chi.Router) {
-		r.Post("/", transactionHandler.TransferFunds)
-	})
+	srv := &http.Server{
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Fatalf("HTTP server failed: %v", err)
 	}
 
-	log.Printf("server listening on port %s", port)
-	log.Println("database locks: FOR UPDATE with SERIALIZABLE isolation")
-	log.Println("architecture: MVC with clear separation of concerns")
-	log.Println("concurrency: row-level locking with atomic transactions")
+	logger.LogInfo(ctx, "STARTUP", "server listening on port "+cfg.Server.Port)
 
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatalf("HTTP server failed: %v", err)
+	// The gRPC API runs as a supervised worker on its own port, so a failing
+	// gRPC listener is reported by /readyz
+	var grpcServer *grpc.Server
+	if cfg.Server.GRPCPort != "" {
+		grpcLn, err := net.Listen("tcp", ":"+cfg.Server.GRPCPort)
+		if err != nil {
+			log.Fatalf("gRPC server failed: %v", err)
+		}
+		grpcServer = grpcapi.NewGRPCServer(grpcapi.NewServer(accountService, transactionService))
+		workers.Go("grpc", func(context.Context) error { return grpcServer.Serve(grpcLn) })
+		logger.LogInfo(ctx, "STARTUP", "gRPC server listening on port "+cfg.Server.GRPCPort)
+	}
+	if cfg.Snapshot.Enabled {
+		workers.Go("snapshots", snapshotService.Schedule)
+		logger.LogInfo(ctx, "STARTUP", "balance snapshots scheduled in time zone "+cfg.Snapshot.Timezone)
+	}
+	logger.LogInfo(ctx, "STARTUP", "architecture: MVC with clear separation of concerns")
+	logger.LogInfo(ctx, "STARTUP", "concurrency: row-level locking with atomic transactions")
+
+	// The first SIGINT/SIGTERM starts a graceful shutdown; once it has been
+	// received the default handlers are restored so a second one exits
+	// immediately
+	signalCtx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signalCtx.Done()
+		stopSignals()
+	}()
+
+	// After the HTTP server drains: drain gRPC calls, stop workers before
+	// closing the pool they use, and flush spans last so shutdown work is
+	// still exported
+	var hooks []lifecycle.Hook
+	if grpcServer != nil {
+		hooks = append(hooks, lifecycle.Hook{Name: "stop gRPC server", Fn: func(ctx context.Context) error {
+			return grpcapi.Shutdown(ctx, grpcServer)
+		}})
+	}
+	hooks = append(hooks, lifecycle.Hook{Name: "stop background workers", Fn: workers.Stop})
+	if closeStorage != nil {
+		hooks = append(hooks, lifecycle.Hook{Name: "close database pool", Fn: func(context.Context) error {
+			return closeStorage()
+		}})
+	}
+	hooks = append(hooks, lifecycle.Hook{Name: "flush traces", Fn: shutdownTracing})
+
+	err = lifecycle.Serve(signalCtx, srv, ln, lifecycle.ServeOptions{
+		State:           state,
+		DrainDelay:      cfg.Server.DrainDelay,
+		ShutdownTimeout: cfg.Server.ShutdownTimeout,
+		Hooks:           hooks,
+	})
+	if err != nil {
+		log.Fatalf("Shutdown failed: %v", err)
 	}
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/hidimpu/transfersystem/internal/db"
+)
+
+const migrateUsage = `usage: transfersystem migrate <command>
+
+commands:
+  status          list migrations and whether they are applied
+  up              apply all pending migrations
+  down [N]        roll back the last N migrations (default 1)
+  to <version>    migrate up or down to exactly <version> (0 = empty schema)`
+
+// runMigrate implements the migrate subcommand
+func runMigrate(ctx context.Context, migrator *db.Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n%s", migrateUsage)
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, st := range statuses {
+			state, at := "pending", "-"
+			if st.Applied {
+				state, at = "applied", st.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, state, at)
+		}
+		return w.Flush()
+
+	case "up":
+		n, err := migrator.Up(ctx)
+		fmt.Fprintf(out, "applied %d migration(s)\n", n)
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+			steps = parsed
+		}
+		n, err := migrator.Down(ctx, steps)
+		fmt.Fprintf(out, "rolled back %d migration(s)\n", n)
+		return err
+
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("missing target version\n%s", migrateUsage)
+		}
+		target, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid target version %q", args[1])
+		}
+		n, err := migrator.To(ctx, target)
+		fmt.Fprintf(out, "ran %d migration step(s) to reach version %d\n", n, target)
+		return err
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
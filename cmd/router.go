@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hidimpu/transfersystem/internal/api"
+	"github.com/hidimpu/transfersystem/internal/health"
+	"github.com/hidimpu/transfersystem/internal/metrics"
+	"github.com/hidimpu/transfersystem/internal/service"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+)
+
+// routerDeps holds everything the HTTP router is built from
+type routerDeps struct {
+	accountService     service.AccountService
+	transactionHandler *api.TransactionHandler
+	ledger             *service.LedgerService
+	statements         *service.StatementService
+	reconciliation     *service.ReconciliationService
+	payments           *service.PaymentService
+	checker            *health.Checker
+	maxBodyBytes       int64
+	maxImportBytes     int64
+	currency           string
+	metrics            bool
+}
+
+// newRouter builds the HTTP router (View Layer). Every route registered here
+// must be described in internal/api/openapi.yaml; router_test.go fails on
+// drift.
+func newRouter(d routerDeps) (*chi.Mux, error) {
+	spec, err := api.LoadOpenAPISpec()
+	if err != nil {
+		return nil, err
+	}
+	validate, err := api.ValidateRequests(spec)
+	if err != nil {
+		return nil, err
+	}
+	specHandler, err := api.OpenAPIHandler(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	r := chi.NewRouter()
+	// Set before the subrouters are mounted so they inherit them
+	r.NotFound(api.NotFoundHandler)
+	r.MethodNotAllowed(api.MethodNotAllowedHandler)
+	r.Use(api.RequestID)
+	r.Use(tracing.Middleware)
+	if d.metrics {
+		r.Use(metrics.Middleware)
+	}
+	r.Use(api.MaxBodySize(d.maxBodyBytes, map[string]int64{
+		"/accounts/import":           d.maxImportBytes,
+		"/transactions/import":       d.maxImportBytes,
+		"/reconciliation/statements": d.maxImportBytes,
+	}))
+	r.Use(validate)
+
+	// Observability routes
+	r.Get("/healthz", api.LivenessHandler())
+	r.Get("/readyz", api.ReadinessHandler(d.checker))
+	if d.metrics {
+		r.Method(http.MethodGet, "/metrics", metrics.Handler())
+	}
+	r.Get("/openapi.json", specHandler)
+
+	// Account routes
+	r.Route("/accounts", func(r chi.Router) {
+		r.Post("/", api.CreateAccountServiceHandler(d.accountService))
+		r.Post("/import", api.ImportAccountsServiceHandler(d.accountService, d.currency))
+		r.Get("/export", api.ExportAccountsHandler(d.ledger))
+		r.Post("/balances", api.GetBalancesAsOfServiceHandler(d.accountService))
+		r.Get("/{account_id}", api.GetAccountServiceHandler(d.accountService))
+		r.Get("/{account_id}/balance", api.GetBalanceAsOfServiceHandler(d.accountService))
+		r.Get("/{account_id}/statements", api.GetStatementHandler(d.statements))
+	})
+
+	// Transaction routes
+	r.Route("/transactions", func(r chi.Router) {
+		r.Post("/", d.transactionHandler.TransferFunds)
+		r.Post("/import", api.ImportPaymentsHandler(d.payments, d.currency))
+		r.Get("/export", api.ExportTransactionsHandler(d.ledger))
+		r.Get("/search", d.transactionHandler.SearchTransactions)
+	})
+
+	// Reconciliation routes
+	r.Route("/reconciliation", func(r chi.Router) {
+		r.Post("/statements", api.ImportExternalStatementHandler(d.reconciliation, d.currency))
+		r.Get("/statements/{statement_id}", api.GetExternalStatementHandler(d.reconciliation))
+		r.Post("/statements/{statement_id}/match", api.MatchExternalStatementHandler(d.reconciliation))
+		r.Get("/lines", api.ListExternalLinesHandler(d.reconciliation))
+		r.Post("/lines/{line_id}/match", api.LinkExternalLineHandler(d.reconciliation))
+		r.Delete("/lines/{line_id}/match", api.UnlinkExternalLineHandler(d.reconciliation))
+		r.Post("/lines/{line_id}/adjustment", api.AdjustExternalLineHandler(d.reconciliation))
+	})
+
+	return r, nil
+}
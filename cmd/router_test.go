@@ -0,0 +1,769 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/api"
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/health"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository/memory"
+	"github.com/hidimpu/transfersystem/internal/service"
+)
+
+// newTestRouter builds the full router over an empty in-memory store
+func newTestRouter(t *testing.T, maxBodyBytes int64) *chi.Mux {
+	t.Helper()
+	store := memory.NewStore()
+	transactions := service.NewTransactionService(store.UnitOfWork(), store.Accounts(), store.Transactions(), config.TransferConfig{})
+	r, err := newRouter(routerDeps{
+		accountService:     service.NewAccountService(store.Accounts()),
+		transactionHandler: api.NewTransactionHandler(transactions),
+		ledger:             service.NewLedgerService(store.Accounts(), store.Transactions(), store.Snapshots()),
+		statements:         service.NewStatementService(store.Accounts(), store.Transactions(), store.Snapshots(), "USD"),
+		reconciliation: service.NewReconciliationService(store.Reconciliation(), store.Accounts(), store.Transactions(), transactions,
+			config.ReconciliationConfig{DateWindowDays: 3, AutoMatchConfidence: 80}),
+		payments:       service.NewPaymentService(store.PaymentFiles(), transactions),
+		checker:        health.NewChecker(time.Second),
+		maxBodyBytes:   maxBodyBytes,
+		maxImportBytes: 4 * maxBodyBytes,
+		currency:       "USD",
+		metrics:        true, // register every optional route
+	})
+	require.NoError(t, err)
+	return r
+}
+
+// TestRouter_MatchesOpenAPISpec fails when a route is added to or removed from
+// newRouter without updating internal/api/openapi.yaml, or vice versa
+func TestRouter_MatchesOpenAPISpec(t *testing.T) {
+	r := newTestRouter(t, 1<<20)
+
+	var registered []string
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		registered = append(registered, method+" "+route)
+		return nil
+	})
+	require.NoError(t, err)
+
+	spec, err := api.LoadOpenAPISpec()
+	require.NoError(t, err)
+	var documented []string
+	for path, item := range spec.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	sort.Strings(registered)
+	sort.Strings(documented)
+	assert.Equal(t, documented, registered, "routes in cmd/router.go and internal/api/openapi.yaml have drifted")
+}
+
+// TestRouter_ErrorsAreProblems checks that every kind of failure, from the
+// router, the validator, the handlers and the services, is reported as
+// application/problem+json
+func TestRouter_ErrorsAreProblems(t *testing.T) {
+	r := newTestRouter(t, 256)
+	for _, body := range []string{`{"account_id": 1, "initial_balance": "10.00"}`, `{"account_id": 2, "initial_balance": "0"}`} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(body)))
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantDetails map[string]any
+	}{
+		{name: "unknown route", method: http.MethodGet, path: "/nope", wantStatus: http.StatusNotFound, wantCode: api.CodeNotFound},
+		{name: "wrong method", method: http.MethodDelete, path: "/accounts/1", wantStatus: http.StatusMethodNotAllowed, wantCode: api.CodeMethodNotAllowed},
+		{name: "malformed JSON", method: http.MethodPost, path: "/transactions", body: `{`, wantStatus: http.StatusBadRequest, wantCode: api.CodeInvalidJSON},
+		{
+			name:        "invalid field",
+			method:      http.MethodPost,
+			path:        "/transactions",
+			body:        `{"source_account_id": 1, "destination_account_id": 2, "amount": "ten"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    api.CodeInvalidRequest,
+			wantDetails: map[string]any{"field": "amount"},
+		},
+		{
+			name:        "body too large",
+			method:      http.MethodPost,
+			path:        "/accounts",
+			body:        `{"account_id": 3, "initial_balance": "` + strings.Repeat("9", 300) + `"}`,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantCode:    api.CodeRequestTooLarge,
+			wantDetails: map[string]any{"limit_bytes": float64(256)},
+		},
+		{
+			name:        "account not found",
+			method:      http.MethodGet,
+			path:        "/accounts/42",
+			wantStatus:  http.StatusNotFound,
+			wantCode:    model.ErrAccountNotFound.Code(),
+			wantDetails: map[string]any{"account_id": float64(42)},
+		},
+		{
+			name:        "account exists",
+			method:      http.MethodPost,
+			path:        "/accounts",
+			body:        `{"account_id": 1, "initial_balance": "1"}`,
+			wantStatus:  http.StatusConflict,
+			wantCode:    model.ErrAccountExists.Code(),
+			wantDetails: map[string]any{"account_id": float64(1)},
+		},
+		{
+			name:        "insufficient funds",
+			method:      http.MethodPost,
+			path:        "/transactions",
+			body:        `{"source_account_id": 1, "destination_account_id": 2, "amount": "25.5"}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    model.ErrInsufficientFunds.Code(),
+			wantDetails: map[string]any{"account_id": float64(1), "available_balance": "10", "requested_amount": "25.5"},
+		},
+		{
+			name:        "invalid description",
+			method:      http.MethodPost,
+			path:        "/transactions",
+			body:        `{"source_account_id": 1, "destination_account_id": 2, "amount": "1", "description": "bell\u0007"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    model.ErrInvalidDescription.Code(),
+			wantDetails: map[string]any{"field": "description", "reason": "cannot contain control characters"},
+		},
+		{
+			name:        "NUL in account metadata",
+			method:      http.MethodPost,
+			path:        "/accounts",
+			body:        `{"account_id": 3, "initial_balance": "1", "metadata": {"note": "a\u0000b"}}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    model.ErrInvalidMetadata.Code(),
+			wantDetails: map[string]any{"field": "metadata.note", "reason": "values cannot contain control characters"},
+		},
+		{
+			name:        "control character in transfer metadata key",
+			method:      http.MethodPost,
+			path:        "/transactions",
+			body:        `{"source_account_id": 1, "destination_account_id": 2, "amount": "1", "metadata": {"a\tb": "x"}}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    model.ErrInvalidMetadata.Code(),
+			wantDetails: map[string]any{"field": "metadata.a\tb", "reason": "keys cannot contain control characters"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("X-Request-ID", "test-"+strings.ReplaceAll(tt.name, " ", "-"))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, api.ProblemContentType, rec.Header().Get("Content-Type"))
+			var p map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+			assert.Equal(t, tt.wantCode, p["code"])
+			assert.Equal(t, float64(tt.wantStatus), p["status"])
+			assert.Equal(t, req.Header.Get("X-Request-ID"), p["request_id"])
+			assert.NotEmpty(t, p["detail"])
+			details, _ := p["details"].(map[string]any)
+			for k, v := range tt.wantDetails {
+				assert.Equal(t, v, details[k], k)
+			}
+		})
+	}
+}
+
+func TestRouter_ImportAccounts(t *testing.T) {
+	r := newTestRouter(t, 256)
+	post := func(query, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/accounts/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Rejected as a whole: nothing is created, every failing row is listed
+	csvBody := "account_id,initial_balance,metadata\n1,10.00,\"{\"\"ref\"\":\"\"A-1\"\"}\"\n2,-1,\n3\n"
+	rec := post("?all_or_nothing=true", "text/csv", csvBody)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	var p struct {
+		Code    string `json:"code"`
+		Details struct {
+			Failed int                    `json:"failed"`
+			Errors []model.ImportRowError `json:"errors"`
+		} `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(t, model.ErrImportRejected.Code(), p.Code)
+	assert.Equal(t, 2, p.Details.Failed)
+	require.Len(t, p.Details.Errors, 2)
+	assert.Equal(t, 3, p.Details.Errors[0].Line)
+	assert.Equal(t, model.ErrNegativeBalance.Code(), p.Details.Errors[0].Code)
+	assert.Equal(t, model.ErrInvalidImportRow.Code(), p.Details.Errors[1].Code)
+
+	// Partial: valid rows are created
+	rec = post("", "text/csv", csvBody)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var report model.AccountImportReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, 3, report.Rows)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 2, report.Failed)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/accounts/1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"account_id": 1, "balance": "10", "status": "active", "metadata": {"ref": "A-1"}}`, rec.Body.String())
+
+	// NDJSON, over the regular body limit but within the import limit
+	ndjson := `{"account_id": 1, "initial_balance": "1"}` + "\n" + `{"account_id": 4, "initial_balance": "1"}` + "\n" +
+		`{"account_id": 5, "initial_balance": "1", "metadata": {"note": "` + strings.Repeat("x", 300) + `"}}` + "\n"
+	rec = post("", "application/x-ndjson", ndjson)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	report = model.AccountImportReport{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, 2, report.Created)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, model.ErrAccountExists.Code(), report.Errors[0].Code)
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    string
+	}{
+		{"unsupported content type", "", "application/json", `[]`, http.StatusUnsupportedMediaType, api.CodeUnsupportedMediaType},
+		{"invalid flag", "?dry_run=maybe", "text/csv", csvBody, http.StatusBadRequest, api.CodeInvalidRequest},
+		{"unreadable file", "", "text/csv", "id,balance\n", http.StatusBadRequest, model.ErrInvalidImport.Code()},
+		{"over the import limit", "", "text/csv", "account_id,initial_balance\n" + strings.Repeat("9,1\n", 300), http.StatusRequestEntityTooLarge, api.CodeRequestTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := post(tt.query, tt.contentType, tt.body)
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.wantCode, problemCode(t, rec))
+		})
+	}
+}
+
+// problemCode returns the code of a problem response
+func TestRouter_Export(t *testing.T) {
+	r := newTestRouter(t, 1024)
+	do := func(method, path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, body := range []string{
+		`{"account_id": 1, "initial_balance": "100"}`,
+		`{"account_id": 2, "initial_balance": "100"}`,
+		`{"account_id": 3, "initial_balance": "100"}`,
+	} {
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/accounts", body).Code)
+	}
+	for _, body := range []string{
+		`{"source_account_id": 1, "destination_account_id": 2, "amount": "10"}`,
+		`{"source_account_id": 2, "destination_account_id": 3, "amount": "5", "reference": "INV-7", "metadata": {"order": "A-17", "channel": "web"}}`,
+	} {
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/transactions", body).Code)
+	}
+
+	rec := do(http.MethodGet, "/accounts/export", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="accounts.csv"`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "account_id,balance,status,metadata\n1,90,active,\n2,105,active,\n3,105,active,\n", rec.Body.String())
+
+	rec = do(http.MethodGet, "/transactions/export?format=ndjson&account_id=3", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 1)
+	var txn model.Transaction
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &txn))
+	assert.Equal(t, int64(2), txn.SourceAccountID)
+
+	// gzip when accepted
+	rec = do(http.MethodGet, "/transactions/export", "", "Accept-Encoding", "br, gzip")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	plain, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(plain), "\n"), "header and two rows")
+
+	// An empty range still has a header
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+	rec = do(http.MethodGet, "/transactions/export?from="+tomorrow, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "id,source_account_id,destination_account_id,amount,created_at,reversal_of,reference,description,metadata\n", rec.Body.String())
+	rec = do(http.MethodGet, "/transactions/export?format=ndjson&to="+tomorrow, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 2, strings.Count(rec.Body.String(), "\n"))
+
+	// Reference and metadata filters
+	rec = do(http.MethodGet, "/transactions/export?reference=INV-7&metadata=order:A-17&metadata=channel:web", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `,INV-7,,"{""channel"":""web"",""order"":""A-17""}"`)
+	assert.Equal(t, 2, strings.Count(rec.Body.String(), "\n"), "header and one row")
+	rec = do(http.MethodGet, "/transactions/export?metadata=order:A-18", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, strings.Count(rec.Body.String(), "\n"), "header only")
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"unknown format", "/accounts/export?format=xml", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"bad account", "/transactions/export?account_id=0", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"bad date", "/transactions/export?from=yesterday", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"bad metadata", "/transactions/export?metadata=order", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"NUL in metadata", "/transactions/export?metadata=order:A%00", http.StatusBadRequest, model.ErrInvalidMetadata.Code()},
+		{"empty range", "/transactions/export?from=2024-03-02&to=2024-03-01", http.StatusBadRequest, model.ErrInvalidDateRange.Code()},
+		{"unknown account", "/transactions/export?account_id=99", http.StatusNotFound, model.ErrAccountNotFound.Code()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(http.MethodGet, tt.path, "")
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.wantCode, problemCode(t, rec))
+		})
+	}
+}
+
+func TestRouter_BalancesAsOf(t *testing.T) {
+	r := newTestRouter(t, 1024)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/accounts", `{"account_id": 1, "initial_balance": "100"}`).Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/accounts", `{"account_id": 2, "initial_balance": "0"}`).Code)
+	between := time.Now().UTC().Format(time.RFC3339Nano)
+	time.Sleep(time.Millisecond)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/transactions", `{"source_account_id": 1, "destination_account_id": 2, "amount": "40"}`).Code)
+
+	rec := do(http.MethodGet, "/accounts/1/balance?as_of="+between, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var balance model.AccountBalance
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &balance))
+	assert.Equal(t, "100", balance.Balance.String())
+
+	rec = do(http.MethodGet, "/accounts/1/balance", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &balance))
+	assert.Equal(t, "60", balance.Balance.String())
+
+	rec = do(http.MethodPost, "/accounts/balances", `{"account_ids": [2, 1, 3]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var bulk model.BalancesAsOf
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &bulk))
+	require.Len(t, bulk.Balances, 2)
+	assert.Equal(t, "60", bulk.Balances[0].Balance.String())
+	assert.Equal(t, "40", bulk.Balances[1].Balance.String())
+	assert.Equal(t, []int64{3}, bulk.NotFound)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"before the account opened", http.MethodGet, "/accounts/1/balance?as_of=2000-01-01", "", http.StatusNotFound, model.ErrAccountNotOpen.Code()},
+		{"unknown account", http.MethodGet, "/accounts/9/balance", "", http.StatusNotFound, model.ErrAccountNotFound.Code()},
+		{"future", http.MethodGet, "/accounts/1/balance?as_of=2999-01-01", "", http.StatusBadRequest, model.ErrAsOfInFuture.Code()},
+		{"bad as_of", http.MethodGet, "/accounts/1/balance?as_of=soon", "", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"no accounts", http.MethodPost, "/accounts/balances", `{"account_ids": []}`, http.StatusBadRequest, api.CodeInvalidRequest},
+		{"bad account ID", http.MethodPost, "/accounts/balances", `{"account_ids": [0]}`, http.StatusBadRequest, api.CodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.wantCode, problemCode(t, rec))
+		})
+	}
+}
+
+func TestRouter_Statement(t *testing.T) {
+	r := newTestRouter(t, 1024)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/accounts", `{"account_id": 1, "initial_balance": "100"}`).Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/accounts", `{"account_id": 2, "initial_balance": "0"}`).Code)
+	// 17 characters as an MT940 amount, over its 15
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/accounts", `{"account_id": 3, "initial_balance": "10000000000000"}`).Code)
+	for _, body := range []string{
+		`{"source_account_id": 1, "destination_account_id": 2, "amount": "40"}`,
+		`{"source_account_id": 2, "destination_account_id": 1, "amount": "15.5"}`,
+	} {
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/transactions", body).Code)
+	}
+
+	rec := do(http.MethodGet, "/accounts/1/statements?from=2000-01-01", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("Content-Disposition"))
+	var st model.Statement
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &st))
+	require.Len(t, st.Entries, 2)
+	assert.Equal(t, "100", st.OpeningBalance.String())
+	assert.Equal(t, "40", st.TotalDebits.String())
+	assert.Equal(t, "15.5", st.TotalCredits.String())
+
+	// The closing balance of a statement ending now is the current balance
+	rec = do(http.MethodGet, "/accounts/1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var account model.Account
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &account))
+	assert.True(t, account.Balance.Equal(st.ClosingBalance), "closing %s, balance %s", st.ClosingBalance, account.Balance)
+
+	for format, contentType := range map[string]string{
+		"csv":     "text/csv; charset=utf-8",
+		"text":    "text/plain; charset=utf-8",
+		"camt053": "application/xml",
+		"mt940":   "text/plain; charset=us-ascii",
+		"ofx":     "application/x-ofx",
+	} {
+		rec := do(http.MethodGet, "/accounts/2/statements?from=2000-01-01&format="+format, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Header().Get("Content-Disposition"), `attachment; filename="statement-2-2000-01-01.`)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"unknown format", "/accounts/1/statements?format=pdf", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"bad from", "/accounts/1/statements?from=yesterday", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"empty range", "/accounts/1/statements?from=2024-03-02&to=2024-03-01", http.StatusBadRequest, model.ErrInvalidDateRange.Code()},
+		{"before the account opened", "/accounts/1/statements?from=2000-01-01&to=2000-02-01", http.StatusNotFound, model.ErrAccountNotOpen.Code()},
+		{"unknown account", "/accounts/9/statements", http.StatusNotFound, model.ErrAccountNotFound.Code()},
+		{"amount too long for mt940", "/accounts/3/statements?from=2000-01-01&format=mt940", http.StatusUnprocessableEntity, model.ErrStatementUnrenderable.Code()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(http.MethodGet, tt.path, "")
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.wantCode, problemCode(t, rec))
+		})
+	}
+}
+
+func TestRouter_ImportPayments(t *testing.T) {
+	r := newTestRouter(t, 1024)
+	do := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, body := range []string{
+		`{"account_id": 1, "initial_balance": "100"}`,
+		`{"account_id": 2, "initial_balance": "0"}`,
+	} {
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/accounts", "application/json", body).Code)
+	}
+	transfer := func(e2e, dst, amount string) string {
+		return `<CdtTrfTxInf><PmtId><EndToEndId>` + e2e + `</EndToEndId></PmtId>` +
+			`<Amt><InstdAmt Ccy="USD">` + amount + `</InstdAmt></Amt>` +
+			`<CdtrAcct><Id><Othr><Id>` + dst + `</Id></Othr></Id></CdtrAcct></CdtTrfTxInf>`
+	}
+	file := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"><CstmrCdtTrfInitn>
+<GrpHdr><MsgId>BATCH-1</MsgId><CreDtTm>2026-03-31T09:15:00Z</CreDtTm><NbOfTxs>3</NbOfTxs></GrpHdr>
+<PmtInf><PmtInfId>P1</PmtInfId><PmtMtd>TRF</PmtMtd><DbtrAcct><Id><Othr><Id>1</Id></Othr></Id></DbtrAcct>` +
+		transfer("E1", "2", "60") + transfer("E2", "2", "60") + transfer("E3", "3", "1") +
+		`</PmtInf></CstmrCdtTrfInitn></Document>`
+
+	rec := do(http.MethodPost, "/transactions/import", "application/xml", file)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/xml", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.002.001.03">`)
+	assert.Contains(t, body, "<OrgnlMsgId>BATCH-1</OrgnlMsgId>")
+	assert.Contains(t, body, "<GrpSts>PART</GrpSts>")
+	assert.Equal(t, 1, strings.Count(body, "<TxSts>ACSC</TxSts>"))
+	assert.Contains(t, body, "<Cd>AM04</Cd>")
+	assert.Contains(t, body, "<Cd>AC03</Cd>")
+
+	balance := func(t *testing.T) string {
+		t.Helper()
+		rec := do(http.MethodGet, "/accounts/2", "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var account model.Account
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &account))
+		return account.Balance.String()
+	}
+	assert.Equal(t, "60", balance(t))
+
+	// The same file again gets the same report and books nothing
+	again := do(http.MethodPost, "/transactions/import", "application/xml", file)
+	require.Equal(t, http.StatusOK, again.Code, again.Body.String())
+	assert.Equal(t, body, again.Body.String())
+	assert.Equal(t, "60", balance(t))
+
+	// Another file under the same MsgId
+	rec = do(http.MethodPost, "/transactions/import", "application/xml", strings.Replace(file, `"USD">1<`, `"USD">2<`, 1))
+	assert.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+	assert.Equal(t, model.ErrDuplicatePaymentFile.Code(), problemCode(t, rec))
+
+	// A client that goes away does not stop the file half way
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	second := strings.NewReplacer("BATCH-1", "BATCH-2", "<NbOfTxs>3<", "<NbOfTxs>1<").Replace(file)
+	second = strings.Replace(second, transfer("E1", "2", "60")+transfer("E2", "2", "60")+transfer("E3", "3", "1"), transfer("E4", "2", "30"), 1)
+	req := httptest.NewRequest(http.MethodPost, "/transactions/import", strings.NewReader(second))
+	req.Header.Set("Content-Type", "application/xml")
+	r.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	assert.Equal(t, "90", balance(t))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    string
+	}{
+		{"json", "application/json", `{}`, http.StatusUnsupportedMediaType, api.CodeUnsupportedMediaType},
+		{"not a pain.001", "text/xml", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"/>`, http.StatusBadRequest, model.ErrInvalidImport.Code()},
+		{"malformed", "application/xml", `<Document>`, http.StatusBadRequest, model.ErrInvalidImport.Code()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(http.MethodPost, "/transactions/import", tt.contentType, tt.body)
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.wantCode, problemCode(t, rec))
+		})
+	}
+}
+
+func TestRouter_Reconciliation(t *testing.T) {
+	r := newTestRouter(t, 1024)
+	do := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	for _, body := range []string{
+		`{"account_id": 1, "initial_balance": "100"}`,
+		`{"account_id": 2, "initial_balance": "0"}`,
+	} {
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/accounts", "application/json", body).Code)
+	}
+	rec := do(http.MethodPost, "/transactions", "application/json", `{"source_account_id": 1, "destination_account_id": 2, "amount": "40"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	file := "booking_date,amount,reference,description\n" + today + ",-40,TXN-1,Payout\n" + today + ",15,,Interest\n"
+	rec = do(http.MethodPost, "/reconciliation/statements?account_id=1", "text/csv", file)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var st model.ExternalStatement
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &st))
+	require.Len(t, st.Lines, 2)
+	assert.Equal(t, model.LineMatched, st.Lines[0].Status)
+	assert.Equal(t, int64(1), st.Lines[0].Match.TransactionID)
+	assert.Equal(t, model.LineUnmatched, st.Lines[1].Status)
+
+	rec = do(http.MethodPost, "/reconciliation/statements?account_id=1", "text/csv", file)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, model.ErrStatementAlreadyImported.Code(), problemCode(t, rec))
+
+	rec = do(http.MethodGet, "/reconciliation/lines?account_id=1&status=unmatched", "", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var lines []model.ExternalLine
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &lines))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "Interest", lines[0].Description)
+
+	lineID := strconv.FormatInt(lines[0].ID, 10)
+	rec = do(http.MethodPost, "/reconciliation/lines/"+lineID+"/adjustment", "application/json", `{"counterparty_account_id": 2}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var line model.ExternalLine
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &line))
+	assert.Equal(t, model.MatchAdjustment, line.Match.Method)
+
+	matchedID := strconv.FormatInt(st.Lines[0].ID, 10)
+	rec = do(http.MethodDelete, "/reconciliation/lines/"+matchedID+"/match", "", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodPost, "/reconciliation/lines/"+matchedID+"/match", "application/json", `{"transaction_id": 1}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = do(http.MethodGet, "/reconciliation/statements/"+strconv.FormatInt(st.ID, 10), "", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &st))
+	assert.Equal(t, model.MatchManual, st.Lines[0].Match.Method)
+	assert.Equal(t, model.LineMatched, st.Lines[1].Status)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    string
+	}{
+		{"json statement", http.MethodPost, "/reconciliation/statements?account_id=1", "application/json", `{}`, http.StatusUnsupportedMediaType, api.CodeUnsupportedMediaType},
+		{"unreadable statement", http.MethodPost, "/reconciliation/statements?account_id=1", "text/csv", "amount\n1\n", http.StatusBadRequest, model.ErrInvalidImport.Code()},
+		{"unknown account", http.MethodPost, "/reconciliation/statements?account_id=9", "text/csv", file, http.StatusNotFound, model.ErrAccountNotFound.Code()},
+		{"unknown statement", http.MethodGet, "/reconciliation/statements/99", "", "", http.StatusNotFound, model.ErrExternalStatementNotFound.Code()},
+		{"bad status", http.MethodGet, "/reconciliation/lines?account_id=1&status=open", "", "", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"line already matched", http.MethodPost, "/reconciliation/lines/" + matchedID + "/match", "application/json", `{"transaction_id": 1}`, http.StatusConflict, model.ErrLineAlreadyMatched.Code()},
+		{"wrong amount", http.MethodPost, "/reconciliation/lines/" + lineID + "/match", "application/json", `{"transaction_id": 1}`, http.StatusUnprocessableEntity, model.ErrMatchMismatch.Code()},
+		{"unknown line", http.MethodDelete, "/reconciliation/lines/99/match", "", "", http.StatusNotFound, model.ErrExternalLineNotFound.Code()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.path, tt.contentType, tt.body)
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.wantCode, problemCode(t, rec))
+		})
+	}
+}
+
+func problemCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	require.Equal(t, api.ProblemContentType, rec.Header().Get("Content-Type"))
+	var p struct {
+		Code string `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	return p.Code
+}
+
+func TestRouter_SearchTransactions(t *testing.T) {
+	r := newTestRouter(t, 1024)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/accounts", `{"account_id": 1, "initial_balance": "100"}`).Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/accounts", `{"account_id": 2, "initial_balance": "100"}`).Code)
+	for _, body := range []string{
+		`{"source_account_id": 1, "destination_account_id": 2, "amount": "40", "reference": "INV-1", "description": "Invoice March"}`,
+		`{"source_account_id": 2, "destination_account_id": 1, "amount": "15.5"}`,
+		`{"source_account_id": 1, "destination_account_id": 2, "amount": "7.25", "description": "invoice April"}`,
+	} {
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, "/transactions", body).Code)
+	}
+	search := func(query string) model.TransactionPage {
+		t.Helper()
+		rec := do(http.MethodGet, "/transactions/search?"+query, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page model.TransactionPage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		return page
+	}
+	ids := func(page model.TransactionPage) []int64 {
+		ids := []int64{}
+		for _, hit := range page.Transactions {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	page := search("q=invoice&account_id=1&counterparty_id=2&min_amount=5&max_amount=50&from=2000-01-01&type=transfer&status=booked&sort=amount")
+	assert.Equal(t, []int64{3, 1}, ids(page))
+	assert.Equal(t, "INV-1", page.Transactions[1].Reference)
+	assert.Equal(t, model.TransactionBooked, page.Transactions[1].Status)
+
+	page = search("limit=2")
+	assert.Equal(t, []int64{3, 2}, ids(page))
+	require.NotEmpty(t, page.NextCursor)
+	page = search("limit=2&cursor=" + page.NextCursor)
+	assert.Equal(t, []int64{1}, ids(page))
+	assert.Empty(t, page.NextCursor)
+	assert.Equal(t, []int64{}, ids(search("q=rent")))
+
+	cursor := model.CursorAfter(model.SortNewestFirst, &model.Transaction{ID: 3}).String()
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"unknown sort", "/transactions/search?sort=id", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"bad amount", "/transactions/search?min_amount=ten", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"bad date", "/transactions/search?to=tomorrow", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"limit too large", "/transactions/search?limit=201", http.StatusBadRequest, api.CodeInvalidRequest},
+		{"amount range", "/transactions/search?min_amount=10&max_amount=1", http.StatusBadRequest, model.ErrInvalidSearch.Code()},
+		{"malformed cursor", "/transactions/search?cursor=abc", http.StatusBadRequest, model.ErrInvalidCursor.Code()},
+		{"cursor of another sort", "/transactions/search?sort=amount&cursor=" + cursor, http.StatusBadRequest, model.ErrInvalidCursor.Code()},
+		{"unknown account", "/transactions/search?counterparty_id=9", http.StatusNotFound, model.ErrAccountNotFound.Code()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(http.MethodGet, tt.path, "")
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.wantCode, problemCode(t, rec))
+		})
+	}
+	// Several invalid parameters report the first in a fixed order
+	for i := 0; i < 20; i++ {
+		rec := do(http.MethodGet, "/transactions/search?to=tomorrow&max_amount=x&min_amount=y&counterparty_id=z&account_id=-1", "")
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		var p struct {
+			Details struct {
+				Field string `json:"field"`
+			} `json:"details"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+		require.Equal(t, "account_id", p.Details.Field)
+	}
+}
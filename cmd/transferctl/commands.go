@@ -0,0 +1,635 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/exporter"
+	"github.com/hidimpu/transfersystem/internal/importer"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/service"
+)
+
+// ctl holds what the commands run against. Mutations go through the
+// services; bulk reads use the stores directly.
+type ctl struct {
+	accounts     service.AccountService
+	transactions *service.TransactionService
+	ledger       *service.LedgerService
+	snapshots    *service.SnapshotService
+	accountStore repository.AccountStore
+	txnStore     repository.TransactionStore
+	out          *printer
+	stderr       io.Writer
+	stdin        io.Reader
+	// currency is the ledger currency imported rows must match
+	currency string
+}
+
+// run dispatches a command
+func (c *ctl) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing command", errUsage)
+	}
+	switch args[0] {
+	case "accounts":
+		return c.runAccounts(ctx, args[1:])
+	case "transactions":
+		return c.runTransactions(ctx, args[1:])
+	case "reconcile":
+		return c.reconcile(ctx, args[1:])
+	case "snapshots":
+		return c.runSnapshots(ctx, args[1:])
+	case "export":
+		return c.export(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+}
+
+func (c *ctl) runAccounts(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing accounts command", errUsage)
+	}
+	switch args[0] {
+	case "create":
+		return c.createAccount(ctx, args[1:])
+	case "import":
+		return c.importAccounts(ctx, args[1:])
+	case "show":
+		return c.showAccounts(ctx, args[1:])
+	case "list":
+		return c.listAccounts(ctx, args[1:])
+	case "balance":
+		return c.balancesAsOf(ctx, args[1:])
+	case "freeze":
+		return c.setAccountStatus(ctx, "accounts freeze", model.AccountFrozen, args[1:])
+	case "unfreeze":
+		return c.setAccountStatus(ctx, "accounts unfreeze", model.AccountActive, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown accounts command %q", errUsage, args[0])
+	}
+}
+
+func (c *ctl) runTransactions(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing transactions command", errUsage)
+	}
+	switch args[0] {
+	case "list":
+		return c.listTransactions(ctx, args[1:])
+	case "show":
+		return c.showTransaction(ctx, args[1:])
+	case "reverse":
+		return c.reverse(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown transactions command %q", errUsage, args[0])
+	}
+}
+
+func (c *ctl) createAccount(ctx context.Context, args []string) error {
+	fs, dryRun := c.mutatingFlags("accounts create", "<id> <initial-balance>")
+	positional, err := parseFlags(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	id, err := parseID("account ID", positional[0])
+	if err != nil {
+		return err
+	}
+	balance, err := decimal.NewFromString(positional[1])
+	if err != nil {
+		return fmt.Errorf("%w: invalid initial balance %q", errUsage, positional[1])
+	}
+
+	acc := &model.Account{ID: id, Balance: balance, Status: model.AccountActive}
+	if *dryRun {
+		err = c.accounts.ValidateAccount(ctx, acc)
+	} else {
+		err = c.accounts.CreateAccount(ctx, acc)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.mutation(*dryRun, fmt.Sprintf("create account %d with balance %s", id, balance), "account", acc)
+}
+
+func (c *ctl) importAccounts(ctx context.Context, args []string) error {
+	fs, dryRun := c.mutatingFlags("accounts import", "<file|->")
+	formatName := fs.String("format", "", "csv or ndjson (default: from the file extension)")
+	allOrNothing := fs.Bool("all-or-nothing", false, "create nothing unless every row is valid")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	path := positional[0]
+	if *formatName == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			*formatName = "csv"
+		case ".ndjson", ".jsonl":
+			*formatName = "ndjson"
+		default:
+			return fmt.Errorf("%w: cannot tell the format of %q, pass -format", errUsage, path)
+		}
+	}
+	format, err := importer.ParseFormat(*formatName)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	in := c.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	rows, err := importer.ParseAccounts(in, format, c.currency)
+	if err != nil {
+		return err
+	}
+
+	report, err := c.accounts.ImportAccounts(ctx, rows, model.AccountImportOptions{AllOrNothing: *allOrNothing, DryRun: *dryRun})
+	if report != nil {
+		if printErr := c.out.importReport(report); printErr != nil {
+			return printErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d row(s) failed", report.Failed, report.Rows)
+	}
+	return nil
+}
+
+func (c *ctl) showAccounts(ctx context.Context, args []string) error {
+	fs := newFlagSet("accounts show", "<id>...", c.stderr)
+	positional, err := parseFlags(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("%w: missing account ID", errUsage)
+	}
+
+	accounts := make([]*model.Account, 0, len(positional))
+	for _, arg := range positional {
+		id, err := parseID("account ID", arg)
+		if err != nil {
+			return err
+		}
+		acc, err := c.accounts.GetAccountByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("account %d: %w", id, err)
+		}
+		accounts = append(accounts, acc)
+	}
+	return c.out.accounts(accounts)
+}
+
+func (c *ctl) listAccounts(ctx context.Context, args []string) error {
+	fs := newFlagSet("accounts list", "", c.stderr)
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	stream := c.out.accountStream()
+	if err := c.accountStore.StreamAccounts(ctx, stream.add); err != nil {
+		return err
+	}
+	return stream.close()
+}
+
+func (c *ctl) balancesAsOf(ctx context.Context, args []string) error {
+	fs := newFlagSet("accounts balance", "<id>...", c.stderr)
+	asOf := time.Now()
+	fs.Func("as-of", "point in time (RFC 3339 or YYYY-MM-DD, default now)", timeFlag(&asOf))
+	positional, err := parseFlags(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("%w: missing account ID", errUsage)
+	}
+	ids := make([]int64, 0, len(positional))
+	for _, arg := range positional {
+		id, err := parseID("account ID", arg)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	balances, err := c.accounts.GetBalancesAsOf(ctx, ids, asOf)
+	if err != nil {
+		return err
+	}
+	if err := c.out.balances(balances); err != nil {
+		return err
+	}
+	if len(balances.NotFound) > 0 {
+		return fmt.Errorf("%d account(s) did not exist at %s: %v", len(balances.NotFound), asOf.Format(time.RFC3339), balances.NotFound)
+	}
+	return nil
+}
+
+func (c *ctl) setAccountStatus(ctx context.Context, name string, status model.AccountStatus, args []string) error {
+	fs, dryRun := c.mutatingFlags(name, "<id>")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID("account ID", positional[0])
+	if err != nil {
+		return err
+	}
+
+	var acc *model.Account
+	if *dryRun {
+		acc, err = c.accounts.GetAccountByID(ctx, id)
+		if err == nil {
+			acc.Status = status
+		}
+	} else {
+		acc, err = c.accounts.SetAccountStatus(ctx, id, status)
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.mutation(*dryRun, fmt.Sprintf("set account %d to %s", id, status), "account", acc)
+}
+
+// transactionFilter narrows transactions list. The embedded filter is
+// applied by the store; the rest is checked per row.
+type transactionFilter struct {
+	model.TransactionFilter
+	minAmount      decimal.Decimal
+	maxAmount      decimal.Decimal
+	reversals      bool
+	hasMin, hasMax bool
+}
+
+func (f *transactionFilter) match(txn *model.Transaction) bool {
+	switch {
+	case f.hasMin && txn.Amount.LessThan(f.minAmount):
+		return false
+	case f.hasMax && txn.Amount.GreaterThan(f.maxAmount):
+		return false
+	case f.reversals && txn.ReversalOf == nil:
+		return false
+	}
+	return true
+}
+
+func (c *ctl) listTransactions(ctx context.Context, args []string) error {
+	fs := newFlagSet("transactions list", "", c.stderr)
+	var f transactionFilter
+	fs.Int64Var(&f.AccountID, "account", 0, "only transactions to or from this account")
+	fs.Func("min", "only amounts >= this", decimalFlag(&f.minAmount, &f.hasMin))
+	fs.Func("max", "only amounts <= this", decimalFlag(&f.maxAmount, &f.hasMax))
+	fs.Func("since", "only transactions at or after this time (RFC 3339 or YYYY-MM-DD)", timeFlag(&f.From))
+	fs.Func("until", "only transactions before this time (RFC 3339 or YYYY-MM-DD)", timeFlag(&f.To))
+	fs.StringVar(&f.Reference, "reference", "", "only transactions with this reference")
+	fs.Func("metadata", "only transactions with this metadata `key=value` (repeatable)", metadataFlag(&f.Metadata))
+	fs.BoolVar(&f.reversals, "reversals", false, "only reversals")
+	limit := fs.Int("limit", 100, "maximum number of transactions, 0 for all")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if f.AccountID != 0 {
+		if _, err := c.accounts.GetAccountByID(ctx, f.AccountID); err != nil {
+			return fmt.Errorf("account %d: %w", f.AccountID, err)
+		}
+	}
+
+	// Transactions stream oldest first; keep only the newest limit matches
+	var matched []*model.Transaction
+	err := c.txnStore.StreamTransactions(ctx, f.TransactionFilter, func(txn *model.Transaction) error {
+		if !f.match(txn) {
+			return nil
+		}
+		matched = append(matched, txn)
+		if *limit > 0 && len(matched) > *limit {
+			matched = matched[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slices.Reverse(matched)
+	return c.out.transactions(matched)
+}
+
+func (c *ctl) showTransaction(ctx context.Context, args []string) error {
+	fs := newFlagSet("transactions show", "<id>", c.stderr)
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID("transaction ID", positional[0])
+	if err != nil {
+		return err
+	}
+	txn, err := c.transactions.GetTransactionByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("transaction %d: %w", id, err)
+	}
+	return c.out.transactions([]*model.Transaction{txn})
+}
+
+func (c *ctl) reverse(ctx context.Context, args []string) error {
+	fs, dryRun := c.mutatingFlags("transactions reverse", "<id>")
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID("transaction ID", positional[0])
+	if err != nil {
+		return err
+	}
+
+	reversal, err := c.transactions.Reverse(ctx, id, *dryRun)
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("reverse transaction %d: move %s from account %d back to account %d",
+		id, reversal.Amount, reversal.SourceAccountID, reversal.DestinationAccountID)
+	return c.out.mutation(*dryRun, summary, "transaction", reversal)
+}
+
+func (c *ctl) reconcile(ctx context.Context, args []string) error {
+	fs := newFlagSet("reconcile", "", c.stderr)
+	var opts model.ReconcileOptions
+	fs.BoolVar(&opts.Full, "full", false, "check every transaction instead of starting from the latest balance snapshot")
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	report, err := c.ledger.Reconcile(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := c.out.reconciliation(report); err != nil {
+		return err
+	}
+	if !report.Balanced() {
+		return fmt.Errorf("ledger does not reconcile: %d account(s) with discrepancies", len(report.Discrepancies))
+	}
+	return nil
+}
+
+func (c *ctl) runSnapshots(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing snapshots command", errUsage)
+	}
+	switch args[0] {
+	case "run":
+		return c.runSnapshot(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown snapshots command %q", errUsage, args[0])
+	}
+}
+
+func (c *ctl) runSnapshot(ctx context.Context, args []string) error {
+	fs, dryRun := c.mutatingFlags("snapshots run", "")
+	var date time.Time
+	fs.Func("date", "business date to snapshot, YYYY-MM-DD (default: every ended day not yet snapshotted)", func(s string) error {
+		d, err := time.Parse(model.BusinessDateLayout, s)
+		if err != nil {
+			return fmt.Errorf("invalid date %q, want YYYY-MM-DD", s)
+		}
+		date = d
+		return nil
+	})
+	if _, err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	if *dryRun {
+		periods, err := c.snapshots.Due(ctx, date)
+		if err != nil {
+			return err
+		}
+		dates := make([]string, 0, len(periods))
+		for _, p := range periods {
+			dates = append(dates, p.Date.Format(model.BusinessDateLayout))
+		}
+		summary := "snapshot no business day"
+		if len(dates) > 0 {
+			summary = "snapshot " + strings.Join(dates, ", ")
+		}
+		return c.out.mutation(true, summary, "business_dates", dates)
+	}
+	if date.IsZero() {
+		runs, err := c.snapshots.CatchUp(ctx)
+		if printErr := c.out.snapshotRuns(runs); printErr != nil && err == nil {
+			err = printErr
+		}
+		return err
+	}
+	run, err := c.snapshots.Run(ctx, date)
+	if err != nil {
+		return err
+	}
+	return c.out.snapshotRuns([]*model.SnapshotRun{run})
+}
+
+func (c *ctl) export(ctx context.Context, args []string) error {
+	fs := newFlagSet("export", "accounts|transactions", c.stderr)
+	formatName := fs.String("format", "", "csv or ndjson (default: from the -out extension, else csv)")
+	outPath := fs.String("out", "", "file to write instead of standard output")
+	gzipped := fs.Bool("gzip", false, "gzip the output (implied by an -out name ending in .gz)")
+	var filter model.TransactionFilter
+	fs.Int64Var(&filter.AccountID, "account", 0, "transactions: only those to or from this account")
+	fs.Func("from", "transactions: only those at or after this time (RFC 3339 or YYYY-MM-DD)", timeFlag(&filter.From))
+	fs.Func("to", "transactions: only those before this time (RFC 3339 or YYYY-MM-DD)", timeFlag(&filter.To))
+	fs.StringVar(&filter.Reference, "reference", "", "transactions: only those with this reference")
+	fs.Func("metadata", "transactions: only those with this metadata `key=value` (repeatable)", metadataFlag(&filter.Metadata))
+	positional, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	what := positional[0]
+	if what != "accounts" && what != "transactions" {
+		return fmt.Errorf("%w: unknown export %q, want accounts or transactions", errUsage, what)
+	}
+	if what == "accounts" && !filter.IsZero() {
+		return fmt.Errorf("%w: -account, -from, -to, -reference and -metadata only apply to transactions", errUsage)
+	}
+
+	name := strings.ToLower(*outPath)
+	if strings.HasSuffix(name, ".gz") {
+		*gzipped = true
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	if *formatName == "" {
+		*formatName = "csv"
+		if ext := filepath.Ext(name); ext == ".ndjson" || ext == ".jsonl" {
+			*formatName = "ndjson"
+		}
+	}
+	format, err := exporter.ParseFormat(*formatName)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	var w io.Writer = c.out.w
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	var gz *gzip.Writer
+	if *gzipped {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	var count int
+	if what == "accounts" {
+		ew := exporter.NewAccountWriter(w, format)
+		err = c.ledger.ExportAccounts(ctx, ew.Write)
+		count = ew.Count()
+		if err == nil {
+			err = ew.Flush()
+		}
+	} else {
+		ew := exporter.NewTransactionWriter(w, format)
+		err = c.ledger.ExportTransactions(ctx, filter, ew.Write)
+		count = ew.Count()
+		if err == nil {
+			err = ew.Flush()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	if *outPath != "" {
+		fmt.Fprintf(c.stderr, "exported %d %s to %s\n", count, what, *outPath)
+	}
+	return nil
+}
+
+// mutatingFlags returns a flag set with the -dry-run flag
+func (c *ctl) mutatingFlags(name, args string) (*flag.FlagSet, *bool) {
+	fs := newFlagSet(name, args, c.stderr)
+	return fs, fs.Bool("dry-run", false, "run every check but change nothing")
+}
+
+// newFlagSet creates the flag set of a command
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: transferctl %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args, allowing flags before and after the positional
+// arguments, and checks that exactly want positional arguments were given
+// (any number when want is negative)
+func parseFlags(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if want >= 0 && len(positional) != want {
+		return nil, fmt.Errorf("%w: %s takes %d argument(s), got %d", errUsage, fs.Name(), want, len(positional))
+	}
+	return positional, nil
+}
+
+// parseID parses a positive account or transaction ID
+func parseID(what, s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: invalid %s %q", errUsage, what, s)
+	}
+	return id, nil
+}
+
+// decimalFlag parses a decimal flag value into dst and records that it was set
+func decimalFlag(dst *decimal.Decimal, set *bool) func(string) error {
+	return func(s string) error {
+		d, err := decimal.NewFromString(s)
+		if err != nil {
+			return fmt.Errorf("invalid decimal %q", s)
+		}
+		*dst, *set = d, true
+		return nil
+	}
+}
+
+// metadataFlag adds a key=value pair to dst
+func metadataFlag(dst *model.Metadata) func(string) error {
+	return func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid metadata %q, want key=value", s)
+		}
+		if *dst == nil {
+			*dst = model.Metadata{}
+		}
+		(*dst)[key] = value
+		return nil
+	}
+}
+
+// timeFlag parses an RFC 3339 timestamp or a UTC date into dst
+func timeFlag(dst *time.Time) func(string) error {
+	return func(s string) error {
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if t, err := time.Parse(layout, s); err == nil {
+				*dst = t
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid time %q, want RFC 3339 or YYYY-MM-DD", s)
+	}
+}
+
+// optionalID renders a nullable ID, empty when nil
+func optionalID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
@@ -0,0 +1,143 @@
+// Command transferctl is the administrative CLI for transfersystem. It talks
+// to the database directly through the same services as the API, so every
+// business rule still applies; run it with the API's configuration.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/db"
+	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/service"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+const usage = `usage: transferctl [-config file] [-output table|json] [-v] <command> [flags] [args]
+
+commands:
+  accounts create <id> <initial-balance> [-dry-run]   create an account
+  accounts import <file|-> [-format csv|ndjson] [-all-or-nothing] [-dry-run]
+                                                      create accounts in bulk
+  accounts show <id>...                               show accounts and balances
+  accounts list                                       list every account
+  accounts balance <id>... [-as-of time]              balances at a point in time
+  accounts freeze <id> [-dry-run]                     block transfers to and from an account
+  accounts unfreeze <id> [-dry-run]                   allow transfers again
+  transactions list [filters]                         list transactions, newest first
+  transactions show <id>                              show one transaction
+  transactions reverse <id> [-dry-run]                move a transaction's amount back
+  reconcile [-full]                                   check balances against the ledger
+  snapshots run [-date YYYY-MM-DD] [-dry-run]         snapshot end-of-day balances
+  export accounts|transactions [-format csv|ndjson] [-out file] [-gzip]
+         [-account id] [-from time] [-to time]       stream an export (filters: transactions only)
+
+Mutating commands accept -dry-run: every check runs, nothing is written.
+Run "transferctl <command> -h" for the flags of a command.`
+
+// errUsage marks errors caused by invalid command-line input (exit code 2)
+var errUsage = errors.New("usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run parses the global flags, connects to the database and executes the
+// command, returning the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("transferctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", os.Getenv("CONFIG_FILE"), "path to an optional YAML config file")
+	output := flags.String("output", outputTable, "output format, table or json")
+	verbose := flags.Bool("v", false, "log at the configured level instead of warnings only")
+	flags.Usage = func() { fmt.Fprintln(stderr, usage) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *output != outputTable && *output != outputJSON {
+		fmt.Fprintf(stderr, "transferctl: unknown output format %q\n", *output)
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "transferctl: invalid configuration: %v\n", err)
+		return 1
+	}
+	if cfg.Storage != config.StoragePostgres {
+		fmt.Fprintf(stderr, "transferctl: needs postgres storage, got %q\n", cfg.Storage)
+		return 1
+	}
+
+	// Logs go to stderr so they never mix with command output
+	logOpts := cfg.Log.LoggerOptions()
+	logOpts.Output = stderr
+	if !*verbose {
+		logOpts.Level = max(logOpts.Level, slog.LevelWarn)
+	}
+	utils.SetGlobalLogger(utils.NewLogger(logOpts))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dbConn, err := db.InitDB(cfg.Database)
+	if err != nil {
+		fmt.Fprintf(stderr, "transferctl: connecting to database: %v\n", err)
+		return 1
+	}
+	defer dbConn.Close()
+
+	accountRepo := repository.NewAccountRepository(dbConn)
+	transactionRepo := repository.NewTransactionRepository(dbConn)
+	snapshotRepo := repository.NewSnapshotRepository(dbConn)
+	txRunner := db.NewTxRunner(dbConn, db.RetryPolicy{
+		MaxAttempts: cfg.Database.RetryMaxAttempts,
+		BaseDelay:   cfg.Database.RetryBaseDelay,
+		MaxDelay:    cfg.Database.RetryMaxDelay,
+	})
+	isolation, _ := cfg.Transfer.Isolation() // validated by config.Load
+	uow := repository.NewPostgresUnitOfWork(txRunner, accountRepo, transactionRepo, repository.NewReconciliationRepository(dbConn),
+		repository.NewPaymentFileRepository(dbConn), isolation)
+
+	c := &ctl{
+		accounts:     service.NewAccountService(accountRepo),
+		transactions: service.NewTransactionService(uow, accountRepo, transactionRepo, cfg.Transfer),
+		ledger:       service.NewLedgerService(accountRepo, transactionRepo, snapshotRepo),
+		snapshots:    service.NewSnapshotService(snapshotRepo, cfg.Snapshot),
+		accountStore: accountRepo,
+		txnStore:     transactionRepo,
+		out:          newPrinter(stdout, *output),
+		stderr:       stderr,
+		stdin:        os.Stdin,
+		currency:     cfg.Transfer.Currency,
+	}
+	return exitCode(c.run(ctx, flags.Args()), stderr)
+}
+
+// exitCode reports err on stderr and maps it to an exit code
+func exitCode(err error, stderr io.Writer) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "transferctl: %v\n", err)
+		return 2
+	default:
+		fmt.Fprintf(stderr, "transferctl: %v\n", err)
+		return 1
+	}
+}
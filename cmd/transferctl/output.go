@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printer renders command results as aligned tables or as JSON
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// newPrinter creates a printer writing format to w
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{w: w, json: format == outputJSON}
+}
+
+// accounts prints accounts with their balance and status
+func (p *printer) accounts(accounts []*model.Account) error {
+	if p.json {
+		return p.encode(nonNil(accounts))
+	}
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tBALANCE\tSTATUS")
+	for _, acc := range accounts {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", acc.ID, acc.Balance.StringFixed(2), acc.Status)
+	}
+	return w.Flush()
+}
+
+// accountStreamFlushRows is how many table rows are aligned together before
+// being written out, so streamed listings never buffer the whole table
+const accountStreamFlushRows = 1024
+
+// accountStream prints accounts one at a time as they are read, in the same
+// formats as accounts. Table column widths are settled per block of rows.
+type accountStream struct {
+	p     *printer
+	table *tabwriter.Writer
+	rows  int
+}
+
+// accountStream starts a streamed account listing; call close when done
+func (p *printer) accountStream() *accountStream {
+	s := &accountStream{p: p}
+	if !p.json {
+		s.table = tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(s.table, "ACCOUNT\tBALANCE\tSTATUS")
+	}
+	return s
+}
+
+// add prints acc
+func (s *accountStream) add(acc *model.Account) error {
+	s.rows++
+	if s.p.json {
+		// Laid out as the indented array accounts would encode
+		body, err := json.MarshalIndent(acc, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		sep := ",\n  "
+		if s.rows == 1 {
+			sep = "[\n  "
+		}
+		_, err = fmt.Fprintf(s.p.w, "%s%s", sep, body)
+		return err
+	}
+	fmt.Fprintf(s.table, "%d\t%s\t%s\n", acc.ID, acc.Balance.StringFixed(2), acc.Status)
+	if s.rows%accountStreamFlushRows == 0 {
+		return s.table.Flush()
+	}
+	return nil
+}
+
+// close finishes the listing
+func (s *accountStream) close() error {
+	if !s.p.json {
+		return s.table.Flush()
+	}
+	if s.rows == 0 {
+		_, err := fmt.Fprintln(s.p.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprint(s.p.w, "\n]\n")
+	return err
+}
+
+// balances prints point-in-time balances
+func (p *printer) balances(b *model.BalancesAsOf) error {
+	if p.json {
+		return p.encode(b)
+	}
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tBALANCE\tAS OF")
+	for _, balance := range b.Balances {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", balance.AccountID, balance.Balance.StringFixed(2), balance.AsOf.UTC().Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+// transactions prints transactions, one per row
+func (p *printer) transactions(txns []*model.Transaction) error {
+	if p.json {
+		return p.encode(nonNil(txns))
+	}
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFROM\tTO\tAMOUNT\tCREATED AT\tREVERSAL OF\tREFERENCE")
+	for _, txn := range txns {
+		reversalOf := optionalID(txn.ReversalOf)
+		if reversalOf == "" {
+			reversalOf = "-"
+		}
+		reference := txn.Reference
+		if reference == "" {
+			reference = "-"
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\t%s\t%s\n", txn.ID, txn.SourceAccountID, txn.DestinationAccountID,
+			txn.Amount.StringFixed(2), txn.CreatedAt.UTC().Format(time.RFC3339), reversalOf, reference)
+	}
+	return w.Flush()
+}
+
+// mutation reports the outcome of a mutating command. Dry runs say what
+// would have happened.
+func (p *printer) mutation(dryRun bool, summary, kind string, value any) error {
+	if p.json {
+		return p.encode(map[string]any{"dry_run": dryRun, kind: value})
+	}
+	if dryRun {
+		_, err := fmt.Fprintf(p.w, "dry run: would %s\n", summary)
+		return err
+	}
+	_, err := fmt.Fprintf(p.w, "done: %s\n", summary)
+	return err
+}
+
+// importReport prints the outcome of a bulk import and every rejected row
+func (p *printer) importReport(report *model.AccountImportReport) error {
+	if p.json {
+		return p.encode(report)
+	}
+	verb := "created"
+	if report.DryRun {
+		verb = "would create"
+	}
+	fmt.Fprintf(p.w, "rows: %d, %s: %d, failed: %d\n", report.Rows, verb, report.Created, report.Failed)
+	if len(report.Errors) == 0 {
+		return nil
+	}
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tACCOUNT\tCODE\tMESSAGE")
+	for _, e := range report.Errors {
+		account := "-"
+		if e.AccountID != 0 {
+			account = fmt.Sprint(e.AccountID)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", e.Line, account, e.Code, e.Message)
+	}
+	return w.Flush()
+}
+
+// reconciliation prints a ledger reconciliation report
+func (p *printer) reconciliation(report *model.LedgerReconciliation) error {
+	if p.json {
+		return p.encode(report)
+	}
+	if report.Snapshot != "" {
+		fmt.Fprintf(p.w, "checked since snapshot: %s\n", report.Snapshot)
+	}
+	fmt.Fprintf(p.w, "accounts checked: %d\ntotal balance: %s\n", report.AccountsChecked, report.TotalBalance.StringFixed(2))
+	if report.Balanced() {
+		_, err := fmt.Fprintln(p.w, "ledger balanced")
+		return err
+	}
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tBALANCE\tEXPECTED\tDIFFERENCE")
+	for _, d := range report.Discrepancies {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", d.AccountID,
+			d.Balance.StringFixed(2), d.Expected.StringFixed(2), d.Difference.StringFixed(2))
+	}
+	return w.Flush()
+}
+
+// snapshotRuns prints completed balance snapshot runs
+func (p *printer) snapshotRuns(runs []*model.SnapshotRun) error {
+	if p.json {
+		return p.encode(nonNil(runs))
+	}
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tACCOUNTS\tPERIOD END\tCOMPLETED")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", run.BusinessDate.Format(model.BusinessDateLayout), run.Accounts,
+			run.PeriodEnd.Format(time.RFC3339), run.CompletedAt.UTC().Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func (p *printer) encode(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// nonNil turns a nil slice into an empty one so JSON output is [] not null
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/repository/memory"
+	"github.com/hidimpu/transfersystem/internal/service"
+)
+
+// newTestCtl wires a ctl to a fresh in-memory store seeded with accounts 1
+// and 2 holding 100.00 each and one transfer of 30.00 from 1 to 2
+func newTestCtl(t *testing.T, format string) (*ctl, *bytes.Buffer, *memory.Store) {
+	t.Helper()
+	store := memory.NewStore()
+	accounts := service.NewAccountService(store.Accounts())
+	txns := service.NewTransactionService(store.UnitOfWork(), store.Accounts(), store.Transactions(), config.TransferConfig{})
+
+	ctx := context.Background()
+	for _, id := range []int64{1, 2} {
+		require.NoError(t, accounts.CreateAccount(ctx, &model.Account{ID: id, Balance: decimal.NewFromInt(100)}))
+	}
+	require.NoError(t, txns.Transfer(ctx, 1, 2, decimal.NewFromInt(30)))
+
+	var out bytes.Buffer
+	return &ctl{
+		accounts:     accounts,
+		transactions: txns,
+		ledger:       service.NewLedgerService(store.Accounts(), store.Transactions(), store.Snapshots()),
+		snapshots:    service.NewSnapshotService(store.Snapshots(), config.Default().Snapshot),
+		accountStore: store.Accounts(),
+		txnStore:     store.Transactions(),
+		out:          newPrinter(&out, format),
+		stderr:       &bytes.Buffer{},
+		stdin:        strings.NewReader(""),
+		currency:     "USD",
+	}, &out, store
+}
+
+func balance(t *testing.T, store *memory.Store, id int64) string {
+	t.Helper()
+	b, err := store.Accounts().GetBalance(context.Background(), id)
+	require.NoError(t, err)
+	return b.StringFixed(2)
+}
+
+func TestCtl_DryRunChangesNothing(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+	}{
+		{"create", []string{"accounts", "create", "3", "50", "-dry-run"}, "dry run: would create account 3 with balance 50"},
+		{"freeze", []string{"accounts", "freeze", "-dry-run", "1"}, "dry run: would set account 1 to frozen"},
+		{"reverse", []string{"transactions", "reverse", "1", "-dry-run"}, "dry run: would reverse transaction 1: move 30 from account 2 back to account 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, out, store := newTestCtl(t, outputTable)
+			require.NoError(t, c.run(ctx, tt.args))
+			assert.Equal(t, tt.output+"\n", out.String())
+
+			accounts, err := store.Accounts().GetAll(ctx)
+			require.NoError(t, err)
+			require.Len(t, accounts, 2)
+			for _, acc := range accounts {
+				assert.Equal(t, model.AccountActive, acc.Status)
+			}
+			assert.Equal(t, "70.00", balance(t, store, 1))
+			txns, err := store.Transactions().GetAll(ctx)
+			require.NoError(t, err)
+			assert.Len(t, txns, 1)
+		})
+	}
+}
+
+func TestCtl_DryRunReportsFailures(t *testing.T) {
+	ctx := context.Background()
+	c, _, _ := newTestCtl(t, outputTable)
+
+	assert.ErrorIs(t, c.run(ctx, []string{"accounts", "create", "1", "50", "-dry-run"}), model.ErrAccountExists)
+	assert.ErrorIs(t, c.run(ctx, []string{"accounts", "freeze", "9", "-dry-run"}), model.ErrAccountNotFound)
+	assert.ErrorIs(t, c.run(ctx, []string{"transactions", "reverse", "9", "-dry-run"}), model.ErrTransactionNotFound)
+}
+
+func TestCtl_FreezeReverseReconcile(t *testing.T) {
+	ctx := context.Background()
+	c, out, store := newTestCtl(t, outputTable)
+
+	require.NoError(t, c.run(ctx, []string{"accounts", "freeze", "2"}))
+	assert.ErrorIs(t, c.transactions.Transfer(ctx, 1, 2, decimal.NewFromInt(5)), model.ErrAccountFrozen)
+
+	require.NoError(t, c.run(ctx, []string{"transactions", "reverse", "1"}))
+	assert.Equal(t, "100.00", balance(t, store, 1))
+	assert.Equal(t, "100.00", balance(t, store, 2))
+	assert.ErrorIs(t, c.run(ctx, []string{"transactions", "reverse", "1"}), model.ErrAlreadyReversed)
+
+	require.NoError(t, c.run(ctx, []string{"accounts", "unfreeze", "2"}))
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"accounts", "show", "1", "2"}))
+	assert.Equal(t, "ACCOUNT  BALANCE  STATUS\n1        100.00   active\n2        100.00   active\n", out.String())
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"transactions", "list", "-reversals"}))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, []string{"2", "2", "1", "30.00"}, strings.Fields(lines[1])[:4])
+	assert.Equal(t, "1", strings.Fields(lines[1])[5])
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"reconcile"}))
+	assert.Contains(t, out.String(), "ledger balanced")
+}
+
+func TestCtl_TransactionsListFilters(t *testing.T) {
+	ctx := context.Background()
+	c, out, _ := newTestCtl(t, outputJSON)
+	require.NoError(t, c.transactions.Transfer(ctx, 2, 1, decimal.NewFromInt(5)))
+	require.NoError(t, c.accounts.CreateAccount(ctx, &model.Account{ID: 3, Balance: decimal.NewFromInt(10)}))
+	require.NoError(t, c.transactions.Transfer(ctx, 3, 2, decimal.NewFromInt(1)))
+
+	tests := []struct {
+		name string
+		args []string
+		ids  []int64
+	}{
+		{"all, newest first", nil, []int64{3, 2, 1}},
+		{"by account", []string{"-account", "3"}, []int64{3}},
+		{"amount range", []string{"-min", "2", "-max", "10"}, []int64{2}},
+		{"limit", []string{"-limit", "2"}, []int64{3, 2}},
+		{"until the epoch", []string{"-until", "1970-01-02"}, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			require.NoError(t, c.run(ctx, append([]string{"transactions", "list"}, tt.args...)))
+			var txns []model.Transaction
+			require.NoError(t, json.Unmarshal(out.Bytes(), &txns))
+			ids := []int64{}
+			for _, txn := range txns {
+				ids = append(ids, txn.ID)
+			}
+			assert.Equal(t, tt.ids, ids)
+		})
+	}
+}
+
+func TestCtl_JSONOutput(t *testing.T) {
+	ctx := context.Background()
+	c, out, _ := newTestCtl(t, outputJSON)
+
+	require.NoError(t, c.run(ctx, []string{"transactions", "reverse", "1", "-dry-run"}))
+	var result struct {
+		DryRun      bool              `json:"dry_run"`
+		Transaction model.Transaction `json:"transaction"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, int64(2), result.Transaction.SourceAccountID)
+	require.NotNil(t, result.Transaction.ReversalOf)
+	assert.Equal(t, int64(1), *result.Transaction.ReversalOf)
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"reconcile"}))
+	var report model.LedgerReconciliation
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, 2, report.AccountsChecked)
+	assert.True(t, report.TotalBalance.Equal(decimal.NewFromInt(200)))
+}
+
+func TestCtl_AccountsListStreams(t *testing.T) {
+	ctx := context.Background()
+	for _, format := range []string{outputTable, outputJSON} {
+		t.Run(format, func(t *testing.T) {
+			c, out, store := newTestCtl(t, format)
+			require.NoError(t, c.run(ctx, []string{"accounts", "list"}))
+
+			// Streaming must print exactly what the buffered printer would
+			accounts, err := store.Accounts().GetAll(ctx)
+			require.NoError(t, err)
+			var want bytes.Buffer
+			require.NoError(t, newPrinter(&want, format).accounts(accounts))
+			assert.Equal(t, want.String(), out.String())
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		var out bytes.Buffer
+		stream := newPrinter(&out, outputJSON).accountStream()
+		require.NoError(t, stream.close())
+		assert.Equal(t, "[]\n", out.String())
+	})
+}
+
+func TestCtl_Export(t *testing.T) {
+	ctx := context.Background()
+	c, out, _ := newTestCtl(t, outputTable)
+	require.NoError(t, c.transactions.Transfer(ctx, 2, 1, decimal.NewFromInt(5)))
+
+	require.NoError(t, c.run(ctx, []string{"export", "accounts"}))
+	assert.Equal(t, "account_id,balance,status,metadata\n1,75,active,\n2,125,active,\n", out.String())
+
+	// The format follows the extension; .gz compresses
+	path := filepath.Join(t.TempDir(), "txns.ndjson.gz")
+	require.NoError(t, c.run(ctx, []string{"export", "transactions", "-account", "1", "-out", path}))
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	dec := json.NewDecoder(zr)
+	var ids []int64
+	for dec.More() {
+		var txn model.Transaction
+		require.NoError(t, dec.Decode(&txn))
+		ids = append(ids, txn.ID)
+	}
+	assert.Equal(t, []int64{1, 2}, ids, "oldest first")
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"export", "transactions", "-from", "2000-01-01", "-to", "2000-01-02"}))
+	assert.Equal(t, "id,source_account_id,destination_account_id,amount,created_at,reversal_of,reference,description,metadata\n", out.String())
+
+	assert.ErrorIs(t, c.run(ctx, []string{"export", "transactions", "-account", "9"}), model.ErrAccountNotFound)
+	assert.ErrorIs(t, c.run(ctx, []string{"export", "transactions", "-from", "2000-01-02", "-to", "2000-01-01"}), model.ErrInvalidDateRange)
+	assert.ErrorIs(t, c.run(ctx, []string{"export", "accounts", "-account", "1"}), errUsage)
+	assert.ErrorIs(t, c.run(ctx, []string{"export", "accounts", "-metadata", "order=A-17"}), errUsage)
+	assert.ErrorIs(t, c.run(ctx, []string{"export", "transactions", "-metadata", "order"}), errUsage)
+	assert.ErrorIs(t, c.run(ctx, []string{"export", "accounts", "-format", "xml"}), errUsage)
+}
+
+func TestCtl_AccountBalance(t *testing.T) {
+	ctx := context.Background()
+	c, out, _ := newTestCtl(t, outputTable)
+
+	require.NoError(t, c.run(ctx, []string{"accounts", "balance", "2", "1"}))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"1", "70.00"}, strings.Fields(lines[1])[:2])
+	assert.Equal(t, []string{"2", "130.00"}, strings.Fields(lines[2])[:2])
+
+	err := c.run(ctx, []string{"accounts", "balance", "-as-of", "2000-01-01", "1"})
+	assert.ErrorContains(t, err, "1 account(s) did not exist")
+	assert.ErrorIs(t, c.run(ctx, []string{"accounts", "balance", "-as-of", "2999-01-01", "1"}), model.ErrAsOfInFuture)
+}
+
+func TestCtl_ImportAccounts(t *testing.T) {
+	ctx := context.Background()
+	csvPath := filepath.Join(t.TempDir(), "accounts.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("account_id,initial_balance,currency\n3,10,USD\n1,5,USD\n4,1,EUR\n"), 0o600))
+
+	c, out, store := newTestCtl(t, outputTable)
+	exists := func(id int64) bool {
+		ok, err := store.Accounts().Exists(ctx, id)
+		require.NoError(t, err)
+		return ok
+	}
+
+	err := c.run(ctx, []string{"accounts", "import", "-dry-run", csvPath})
+	assert.EqualError(t, err, "2 of 3 row(s) failed")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "rows: 3, would create: 1, failed: 2", lines[0])
+	assert.Equal(t, []string{"3", "1", "ACCOUNT_EXISTS"}, strings.Fields(lines[2])[:3])
+	assert.Equal(t, []string{"4", "4", "CURRENCY_MISMATCH"}, strings.Fields(lines[3])[:3])
+	assert.False(t, exists(3))
+
+	assert.ErrorIs(t, c.run(ctx, []string{"accounts", "import", "-all-or-nothing", csvPath}), model.ErrImportRejected)
+	assert.False(t, exists(3))
+
+	assert.Error(t, c.run(ctx, []string{"accounts", "import", csvPath}))
+	assert.True(t, exists(3))
+
+	// NDJSON from standard input, reported as JSON
+	c, out, _ = newTestCtl(t, outputJSON)
+	c.stdin = strings.NewReader(`{"account_id": 5, "initial_balance": "2.5", "metadata": {"ref": "X"}}` + "\n")
+	require.NoError(t, c.run(ctx, []string{"accounts", "import", "-format", "ndjson", "-"}))
+	var report model.AccountImportReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, 1, report.Created)
+	assert.Empty(t, report.Errors)
+	acc, err := c.accounts.GetAccountByID(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, model.Metadata{"ref": "X"}, acc.Metadata)
+}
+
+func TestCtl_UsageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no command", nil},
+		{"unknown command", []string{"frobnicate"}},
+		{"unknown accounts command", []string{"accounts", "delete", "1"}},
+		{"missing argument", []string{"accounts", "freeze"}},
+		{"extra argument", []string{"transactions", "show", "1", "2"}},
+		{"invalid ID", []string{"accounts", "show", "abc"}},
+		{"invalid balance", []string{"accounts", "create", "3", "lots"}},
+		{"unknown flag", []string{"reconcile", "-fix"}},
+		{"invalid time", []string{"transactions", "list", "-since", "yesterday"}},
+		{"unknown export", []string{"export", "balances"}},
+		{"unknown export format", []string{"export", "accounts", "-format", "xml"}},
+		{"import format unknown", []string{"accounts", "import", "accounts.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, _ := newTestCtl(t, outputTable)
+			err := c.run(context.Background(), tt.args)
+			assert.ErrorIs(t, err, errUsage)
+			assert.Equal(t, 2, exitCode(err, &bytes.Buffer{}))
+		})
+	}
+}
+
+func TestCtl_Snapshots(t *testing.T) {
+	ctx := context.Background()
+	c, out, store := newTestCtl(t, outputTable)
+
+	require.NoError(t, c.run(ctx, []string{"snapshots", "run", "-date", "2024-03-01", "-dry-run"}))
+	assert.Equal(t, "dry run: would snapshot 2024-03-01\n", out.String())
+	_, err := store.Snapshots().GetRun(ctx, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, repository.ErrSnapshotRunNotFound, "a dry run writes nothing")
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"snapshots", "run", "-date", "2024-03-01"}))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, []string{"2024-03-01", "0", "2024-03-02T00:00:00Z"}, strings.Fields(lines[1])[:3], "accounts opened later are not snapshotted")
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"snapshots", "run", "-date", "2024-03-01", "-dry-run"}))
+	assert.Equal(t, "dry run: would snapshot no business day\n", out.String(), "the day is already done")
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"reconcile"}))
+	assert.Contains(t, out.String(), "checked since snapshot: 2024-03-01")
+	assert.Contains(t, out.String(), "ledger balanced")
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"reconcile", "-full"}))
+	assert.NotContains(t, out.String(), "snapshot")
+
+	assert.ErrorIs(t, c.run(ctx, []string{"snapshots", "run", "-date", "yesterday"}), errUsage)
+	assert.ErrorIs(t, c.run(ctx, []string{"snapshots", "run", "-date", time.Now().UTC().Format(time.DateOnly)}), service.ErrBusinessDayOpen)
+	assert.ErrorIs(t, c.run(ctx, []string{"snapshots", "run", "-dry-run", "-date", time.Now().UTC().Format(time.DateOnly)}), service.ErrBusinessDayOpen)
+}
@@ -2,10 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hidimpu/transfersystem/internal/importer"
 	"github.com/hidimpu/transfersystem/internal/model"
 	"github.com/hidimpu/transfersystem/internal/service"
 	"github.com/hidimpu/transfersystem/internal/utils"
@@ -23,50 +26,41 @@ func CreateAccountServiceHandler(accountService service.AccountService) http.Han
 
 		// Define a lightweight DTO that matches the exercise specification.
 		var req struct {
-			AccountID      int64  `json:"account_id"`
-			InitialBalance string `json:"initial_balance"`
+			AccountID      int64          `json:"account_id"`
+			InitialBalance string         `json:"initial_balance"`
+			Metadata       model.Metadata `json:"metadata"`
 		}
 
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			logger.LogError("API_ACCOUNT_CREATE", "JSON_DECODE_ERROR", "Invalid JSON payload", err)
-			http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		if problem, err := decodeJSON(r, &req); problem != nil {
+			logger.LogError(r.Context(), "API_ACCOUNT_CREATE", "JSON_DECODE_ERROR", problem.Detail, err)
+			WriteProblem(w, r, problem)
 			return
 		}
 
 		if req.InitialBalance == "" {
-			logger.LogWarning("API_ACCOUNT_CREATE", "Missing initial_balance")
-			http.Error(w, "initial_balance is required", http.StatusBadRequest)
+			logger.LogWarning(r.Context(), "API_ACCOUNT_CREATE", "Missing initial_balance")
+			WriteProblem(w, r, invalidField("initial_balance", "is required"))
 			return
 		}
 
 		balance, err := decimal.NewFromString(req.InitialBalance)
 		if err != nil {
-			logger.LogError("API_ACCOUNT_CREATE", "BALANCE_PARSE_ERROR", "Invalid initial_balance format", err)
-			http.Error(w, "Invalid initial_balance format", http.StatusBadRequest)
+			logger.LogError(r.Context(), "API_ACCOUNT_CREATE", "BALANCE_PARSE_ERROR", "Invalid initial_balance format", err)
+			WriteProblem(w, r, invalidField("initial_balance", "must be a decimal string"))
 			return
 		}
 
 		acc := model.Account{
-			ID:      req.AccountID,
-			Balance: balance,
+			ID:       req.AccountID,
+			Balance:  balance,
+			Metadata: req.Metadata,
 		}
 
 		if err := accountService.CreateAccount(r.Context(), &acc); err != nil {
-			// Map service errors to appropriate HTTP status codes using error enums
-			var statusCode int
-			var errorMessage string
-
-			switch err := err.(type) {
-			case model.AccountError:
-				statusCode = err.HTTPStatus()
-				errorMessage = err.Error()
-			default:
-				statusCode = http.StatusInternalServerError
-				errorMessage = "Failed to create account"
-			}
-
-			logger.LogError("API_ACCOUNT_CREATE", "CREATE_ERROR", errorMessage, err)
-			http.Error(w, errorMessage, statusCode)
+			// Typed service errors carry their own status and code
+			problem := ProblemFromError(err, "Failed to create account")
+			logger.LogError(r.Context(), "API_ACCOUNT_CREATE", "CREATE_ERROR", problem.Detail, err)
+			WriteProblem(w, r, problem)
 			return
 		}
 
@@ -82,32 +76,170 @@ func GetAccountServiceHandler(accountService service.AccountService) http.Handle
 		accountIDStr := chi.URLParam(r, "account_id")
 		accountID, err := strconv.ParseInt(accountIDStr, 10, 64)
 		if err != nil {
-			logger.LogError("API_ACCOUNT_GET", "PARSE_ERROR", "Invalid account ID format", err)
-			http.Error(w, "Invalid account ID format", http.StatusBadRequest)
+			logger.LogError(r.Context(), "API_ACCOUNT_GET", "PARSE_ERROR", "Invalid account ID format", err)
+			WriteProblem(w, r, invalidField("account_id", "must be an integer"))
 			return
 		}
 
 		acc, err := accountService.GetAccountByID(r.Context(), accountID)
 		if err != nil {
-			// Map service errors to appropriate HTTP status codes using error enums
-			var statusCode int
-			var errorMessage string
-
-			switch err := err.(type) {
-			case model.AccountError:
-				statusCode = err.HTTPStatus()
-				errorMessage = err.Error()
-			default:
-				statusCode = http.StatusInternalServerError
-				errorMessage = "Failed to retrieve account"
+			// Typed service errors carry their own status and code
+			problem := ProblemFromError(err, "Failed to retrieve account")
+			logger.LogError(r.Context(), "API_ACCOUNT_GET", "GET_ERROR", problem.Detail, err)
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(acc)
+	}
+}
+
+// ImportAccountsServiceHandler creates accounts in bulk from a CSV
+// (text/csv) or NDJSON (application/x-ndjson) body. Rows are validated like
+// POST /accounts; the response reports every rejected row.
+//
+//   - Query: all_or_nothing=true creates nothing unless every row is valid
+//     (422 IMPORT_REJECTED otherwise); dry_run=true only validates.
+//   - Response: 200 with the import report.
+func ImportAccountsServiceHandler(accountService service.AccountService, currency string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := utils.GlobalLogger
+
+		format, ok := importer.FormatFromContentType(r.Header.Get("Content-Type"))
+		if !ok {
+			WriteProblem(w, r, NewProblem(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+				"import body must be text/csv or application/x-ndjson"))
+			return
+		}
+
+		var opts model.AccountImportOptions
+		for name, dst := range map[string]*bool{"all_or_nothing": &opts.AllOrNothing, "dry_run": &opts.DryRun} {
+			if raw := r.URL.Query().Get(name); raw != "" {
+				v, err := strconv.ParseBool(raw)
+				if err != nil {
+					WriteProblem(w, r, invalidField(name, "must be a boolean"))
+					return
+				}
+				*dst = v
 			}
+		}
 
-			logger.LogError("API_ACCOUNT_GET", "GET_ERROR", errorMessage, err)
-			http.Error(w, errorMessage, statusCode)
+		rows, err := importer.ParseAccounts(r.Body, format, currency)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				WriteProblem(w, r, requestTooLarge(maxBytesErr.Limit))
+				return
+			}
+			problem := ProblemFromError(err, "Failed to read import")
+			logger.LogWarning(r.Context(), "API_ACCOUNT_IMPORT", "Unreadable import: "+err.Error())
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		report, err := accountService.ImportAccounts(r.Context(), rows, opts)
+		if err != nil {
+			problem := ProblemFromError(err, "Failed to import accounts")
+			if report != nil {
+				problem.With("errors", report.Errors)
+			}
+			logger.LogError(r.Context(), "API_ACCOUNT_IMPORT", "IMPORT_ERROR", problem.Detail, err)
+			WriteProblem(w, r, problem)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(acc)
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// GetBalanceAsOfServiceHandler returns an account's balance at a point in
+// time, computed from the running balances recorded with its transactions.
+//
+//   - Query: as_of, an RFC 3339 timestamp or a YYYY-MM-DD date (UTC
+//     midnight); defaults to now.
+//   - Response: {"account_id": 1, "balance": "100.5", "as_of": "..."}.
+func GetBalanceAsOfServiceHandler(accountService service.AccountService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := utils.GlobalLogger
+
+		accountID, err := strconv.ParseInt(chi.URLParam(r, "account_id"), 10, 64)
+		if err != nil {
+			logger.LogError(r.Context(), "API_ACCOUNT_BALANCE", "PARSE_ERROR", "Invalid account ID format", err)
+			WriteProblem(w, r, invalidField("account_id", "must be an integer"))
+			return
+		}
+		asOf, problem := asOfParam(r.URL.Query().Get("as_of"))
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		balance, err := accountService.GetBalanceAsOf(r.Context(), accountID, asOf)
+		if err != nil {
+			problem := ProblemFromError(err, "Failed to compute balance")
+			logger.LogError(r.Context(), "API_ACCOUNT_BALANCE", "BALANCE_ERROR", problem.Detail, err)
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(balance)
+	}
+}
+
+// GetBalancesAsOfServiceHandler returns the balances of many accounts at one
+// point in time, e.g. for month-end reporting.
+//
+//   - Request body: {"as_of": "2026-03-31T23:59:59Z", "account_ids": [1, 2]};
+//     as_of defaults to now.
+//   - Response: the balances in account ID order, plus the IDs of accounts
+//     that did not exist at as_of in not_found.
+func GetBalancesAsOfServiceHandler(accountService service.AccountService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := utils.GlobalLogger
+
+		var req struct {
+			AsOf       string  `json:"as_of"`
+			AccountIDs []int64 `json:"account_ids"`
+		}
+		if problem, err := decodeJSON(r, &req); problem != nil {
+			logger.LogError(r.Context(), "API_ACCOUNT_BALANCES", "JSON_DECODE_ERROR", problem.Detail, err)
+			WriteProblem(w, r, problem)
+			return
+		}
+		if len(req.AccountIDs) == 0 {
+			WriteProblem(w, r, invalidField("account_ids", "is required"))
+			return
+		}
+		asOf, problem := asOfParam(req.AsOf)
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		balances, err := accountService.GetBalancesAsOf(r.Context(), req.AccountIDs, asOf)
+		if err != nil {
+			problem := ProblemFromError(err, "Failed to compute balances")
+			logger.LogError(r.Context(), "API_ACCOUNT_BALANCES", "BALANCE_ERROR", problem.Detail, err)
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(balances)
+	}
+}
+
+// asOfParam parses an as_of value, defaulting to now
+func asOfParam(raw string) (time.Time, *Problem) {
+	if raw == "" {
+		return time.Now().UTC(), nil
+	}
+	asOf, err := parseTimeParam(raw)
+	if err != nil {
+		return time.Time{}, invalidField("as_of", "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
 	}
+	return asOf, nil
 }
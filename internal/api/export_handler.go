@@ -0,0 +1,277 @@
+package api
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/exporter"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/service"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// exportFlushRows is how many rows are buffered before they are pushed to
+// the client
+const exportFlushRows = 1000
+
+// ExportAccountsHandler streams every account as CSV or NDJSON.
+//
+//   - Query: format=csv|ndjson (default csv).
+//   - Response: 200 with the export as an attachment, gzip-encoded when the
+//     client accepts it.
+func ExportAccountsHandler(ledger *service.LedgerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, problem := exportFormat(r)
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+		streamExport(w, r, "API_ACCOUNT_EXPORT", "accounts", format, exporter.NewAccountWriter,
+			func(fn func(*model.Account) error) error {
+				return ledger.ExportAccounts(r.Context(), fn)
+			})
+	}
+}
+
+// ExportTransactionsHandler streams transactions, oldest first, as CSV or
+// NDJSON.
+//
+//   - Query: format=csv|ndjson (default csv); account_id limits the export
+//     to one account; from (inclusive) and to (exclusive) bound created_at
+//     and take RFC 3339 timestamps or YYYY-MM-DD dates (UTC midnight).
+//   - Response: 200 with the export as an attachment, gzip-encoded when the
+//     client accepts it.
+func ExportTransactionsHandler(ledger *service.LedgerService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, problem := exportFormat(r)
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+		filter, problem := transactionFilter(r)
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+		streamExport(w, r, "API_TRANSACTION_EXPORT", "transactions", format, exporter.NewTransactionWriter,
+			func(fn func(*model.Transaction) error) error {
+				return ledger.ExportTransactions(r.Context(), filter, fn)
+			})
+	}
+}
+
+// streamExport encodes the records produced by export into the response.
+// An error before the first byte is sent becomes a problem response; after
+// that the status is already on the wire, so the connection is aborted and
+// the client sees a truncated body instead of a complete-looking file.
+func streamExport[T any](w http.ResponseWriter, r *http.Request, op, name string, format exporter.Format,
+	newWriter func(io.Writer, exporter.Format) *exporter.Writer[T], export func(func(T) error) error) {
+	logger := utils.GlobalLogger
+
+	out := &exportResponse{
+		w:        w,
+		r:        r,
+		rc:       http.NewResponseController(w),
+		format:   format,
+		filename: name + format.Extension(),
+		gzip:     acceptsGzip(r),
+	}
+	ew := newWriter(out, format)
+	err := export(func(v T) error {
+		if err := ew.Write(v); err != nil {
+			return err
+		}
+		if ew.Count()%exportFlushRows == 0 {
+			if err := ew.Flush(); err != nil {
+				return err
+			}
+			return out.flush()
+		}
+		return nil
+	})
+	if err == nil {
+		if err = ew.Flush(); err == nil {
+			err = out.close()
+		}
+	}
+
+	switch {
+	case err == nil:
+		logger.LogInfo(r.Context(), op, fmt.Sprintf("Exported %d %s as %s", ew.Count(), name, format))
+	case !out.started:
+		problem := ProblemFromError(err, "Failed to export "+name)
+		logger.LogError(r.Context(), op, "EXPORT_ERROR", problem.Detail, err)
+		WriteProblem(w, r, problem)
+	default:
+		logger.LogError(r.Context(), op, "EXPORT_ABORTED", fmt.Sprintf("Export aborted after %d %s", ew.Count(), name), err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// exportResponse is the io.Writer exports are encoded into. The status and
+// headers are only sent with the first byte, so failures before then can
+// still be reported as problems.
+type exportResponse struct {
+	w        http.ResponseWriter
+	r        *http.Request
+	rc       *http.ResponseController
+	format   exporter.Format
+	filename string
+	gzip     bool
+	gz       *gzip.Writer
+	started  bool
+}
+
+func (e *exportResponse) Write(p []byte) (int, error) {
+	e.start()
+	if e.gz != nil {
+		return e.gz.Write(p)
+	}
+	return e.w.Write(p)
+}
+
+// start sends the status and headers
+func (e *exportResponse) start() {
+	if e.started {
+		return
+	}
+	e.started = true
+
+	h := e.w.Header()
+	h.Set("Content-Type", e.format.ContentType())
+	h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename))
+	h.Add("Vary", "Accept-Encoding")
+	if e.gzip {
+		h.Set("Content-Encoding", "gzip")
+		e.gz = gzip.NewWriter(e.w)
+	}
+	// Exports may outlast the server's write timeout; the client going away
+	// still cancels the request context and stops the query
+	if err := e.rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		utils.GlobalLogger.LogWarning(e.r.Context(), "API_EXPORT", "Cannot lift write deadline: "+err.Error())
+	}
+	e.w.WriteHeader(http.StatusOK)
+}
+
+// flush pushes everything written so far to the client
+func (e *exportResponse) flush() error {
+	if e.gz != nil {
+		if err := e.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := e.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// close finishes the response, sending the headers of an empty export
+func (e *exportResponse) close() error {
+	e.start()
+	if e.gz != nil {
+		if err := e.gz.Close(); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+// exportFormat reads the format query parameter
+func exportFormat(r *http.Request) (exporter.Format, *Problem) {
+	raw := r.URL.Query().Get("format")
+	if raw == "" {
+		return exporter.FormatCSV, nil
+	}
+	format, err := exporter.ParseFormat(raw)
+	if err != nil {
+		return "", invalidField("format", "must be csv or ndjson")
+	}
+	return format, nil
+}
+
+// transactionFilter reads the account_id, from, to, reference and metadata
+// query parameters. Each metadata parameter is a key:value pair.
+func transactionFilter(r *http.Request) (model.TransactionFilter, *Problem) {
+	q := r.URL.Query()
+	var filter model.TransactionFilter
+	if raw := q.Get("account_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			return filter, invalidField("account_id", "must be a positive integer")
+		}
+		filter.AccountID = id
+	}
+	if problem := parseTimeParams(q, timeParam{"from", &filter.From}, timeParam{"to", &filter.To}); problem != nil {
+		return filter, problem
+	}
+	filter.Reference = q.Get("reference")
+	for _, raw := range q["metadata"] {
+		key, value, ok := strings.Cut(raw, ":")
+		if !ok || key == "" {
+			return filter, invalidField("metadata", "must be key:value")
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = model.Metadata{}
+		}
+		filter.Metadata[key] = value
+	}
+	if err := filter.Metadata.Validate(); err != nil {
+		return filter, ProblemFromError(err, "Invalid metadata filter")
+	}
+	return filter, nil
+}
+
+// timeParam is a time query parameter and where it is parsed to
+type timeParam struct {
+	name string
+	dst  *time.Time
+}
+
+// parseTimeParams parses the time parameters present in q, in order, and
+// reports the first invalid one
+func parseTimeParams(q url.Values, params ...timeParam) *Problem {
+	for _, p := range params {
+		if raw := q.Get(p.name); raw != "" {
+			t, err := parseTimeParam(raw)
+			if err != nil {
+				return invalidField(p.name, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			}
+			*p.dst = t
+		}
+	}
+	return nil
+}
+
+// parseTimeParam parses an RFC 3339 timestamp or a date, taken as UTC
+// midnight
+func parseTimeParam(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		raw, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		q, err := strconv.ParseFloat(raw, 64)
+		return err == nil && q > 0
+	}
+	return false
+}
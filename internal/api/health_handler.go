@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hidimpu/transfersystem/internal/health"
+)
+
+// LivenessHandler reports that the process is up and able to serve HTTP. It
+// deliberately checks no dependencies: a failing database should take the
+// instance out of rotation, not get it restarted.
+func LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, health.Report{Status: health.StatusOK})
+	}
+}
+
+// ReadinessHandler runs the readiness checks and returns the per-component
+// breakdown, with 503 if any component is failing (including while the
+// process is draining)
+func ReadinessHandler(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Run(r.Context())
+		status := http.StatusOK
+		if !report.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+		writeHealth(w, status, report)
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, report health.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
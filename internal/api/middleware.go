@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// RequestID assigns every request an ID, taken from the X-Request-ID header
+// when the caller supplies a sane one and generated otherwise. The ID is
+// echoed in the response and stored in the request context so that every
+// service and repository log line for the request carries it.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(utils.RequestIDHeader)
+		if !utils.ValidRequestID(requestID) {
+			requestID = utils.NewRequestID()
+		}
+
+		w.Header().Set(utils.RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(utils.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// MaxBodySize caps request bodies at limit bytes, or at the limit overrides
+// gives for the request path; reading a larger body fails and is reported to
+// the client as a 413 problem
+func MaxBodySize(limit int64, overrides map[string]int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pathLimit := limit
+			if override, ok := overrides[strings.TrimSuffix(r.URL.Path, "/")]; ok {
+				pathLimit = override
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, pathLimit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+
+	"github.com/hidimpu/transfersystem/internal/importer"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// openAPISpec is the maintained API contract. Every route registered on the
+// router must be described here; a test in cmd fails on drift.
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+func init() {
+	// Import bodies are parsed row by row by their handler, which reports
+	// each bad row; the validator only checks that the content type is
+	// accepted. (The built-in text/csv decoder rejects ragged files outright.)
+	// Payment files likewise get a pain.002 report rather than a problem.
+	openapi3filter.RegisterBodyDecoder(importer.ContentTypeCSV, openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder(importer.ContentTypeNDJSON, openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder(importer.ContentTypeXML, openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/xml", openapi3filter.FileBodyDecoder)
+}
+
+// LoadOpenAPISpec parses and validates the embedded OpenAPI document
+func LoadOpenAPISpec() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	spec, err := loader.LoadFromData(openAPISpec)
+	if err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI spec: %w", err)
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %w", err)
+	}
+	return spec, nil
+}
+
+// OpenAPIHandler serves the spec as JSON
+func OpenAPIHandler(spec *openapi3.T) (http.HandlerFunc, error) {
+	body, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("encoding OpenAPI spec: %w", err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}, nil
+}
+
+// ValidateRequests rejects requests that do not match spec with a 400
+// problem naming the offending field before they reach the handlers. Requests for paths the spec does not describe are
+// passed through so the router can answer 404/405 as usual.
+func ValidateRequests(spec *openapi3.T) (func(http.Handler) http.Handler, error) {
+	router, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		return nil, fmt.Errorf("building OpenAPI router: %w", err)
+	}
+	opts := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		// Never inject defaults into the body the handlers decode
+		SkipSettingDefaults: true,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// chi serves /accounts/ and /accounts alike; the spec lists the
+			// canonical form without the trailing slash
+			lookup := r
+			if p := r.URL.Path; len(p) > 1 && strings.HasSuffix(p, "/") {
+				lookup = r.Clone(r.Context())
+				lookup.URL.Path = strings.TrimSuffix(p, "/")
+			}
+
+			route, pathParams, err := router.FindRoute(lookup)
+			if err != nil {
+				// Unknown path or method: let the router respond
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Bodies without a Content-Type are treated as JSON
+			if r.ContentLength != 0 && r.Header.Get("Content-Type") == "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    opts,
+			}
+			if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+				problem := validationProblem(err)
+				utils.GlobalLogger.LogWarning(r.Context(), "API_VALIDATION", fmt.Sprintf("%s %s rejected: %s", r.Method, route.Path, problem.Detail))
+				WriteProblem(w, r, problem)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// validationProblem describes a validation failure: an oversized, empty or
+// unparseable body is reported like a handler decode failure, a body of the
+// wrong media type as 415, anything else as an invalid field
+func validationProblem(err error) *Problem {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return requestTooLarge(maxBytesErr.Limit)
+	}
+	var reqErr *openapi3filter.RequestError
+	if errors.As(err, &reqErr) && reqErr.Parameter == nil {
+		// kin-openapi only signals this through the reason text
+		if reqErr.Err == nil && strings.HasPrefix(reqErr.Reason, "header Content-Type has unexpected value") {
+			return NewProblem(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "unsupported Content-Type "+strconv.Quote(reqErr.Input.Request.Header.Get("Content-Type")))
+		}
+		if errors.Is(err, openapi3filter.ErrInvalidRequired) {
+			return NewProblem(http.StatusBadRequest, CodeInvalidJSON, "request body is empty")
+		}
+		var parseErr *openapi3filter.ParseError
+		if errors.As(err, &parseErr) {
+			return NewProblem(http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON")
+		}
+	}
+	field, reason := describeValidationError(err)
+	return invalidField(field, reason)
+}
+
+// describeValidationError reduces a validation error to the offending field
+// (a parameter name or a body field path) and a short reason
+func describeValidationError(err error) (field, reason string) {
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		var routeErr *routers.RouteError
+		if errors.As(err, &routeErr) {
+			return "request", routeErr.Reason
+		}
+		return "request", err.Error()
+	}
+
+	field = "body"
+	if reqErr.Parameter != nil {
+		field = reqErr.Parameter.Name
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		if ptr := schemaErr.JSONPointer(); len(ptr) > 0 {
+			field = strings.Join(ptr, ".")
+		}
+		if reqErr.Parameter == nil && (schemaErr.SchemaField == "properties" || schemaErr.SchemaField == "additionalProperties") {
+			// Unsupported properties are reported against the parent object;
+			// name the unknown property instead
+			if obj, ok := schemaErr.Value.(map[string]any); ok {
+				if names := unknownProperties(obj, schemaErr.Schema); len(names) > 0 {
+					return names[0], "unknown field"
+				}
+			}
+		}
+		return field, schemaErr.Reason
+	}
+
+	var parseErr *openapi3filter.ParseError
+	if errors.As(err, &parseErr) {
+		return field, parseErr.Reason
+	}
+	if reqErr.Reason != "" {
+		return field, reqErr.Reason
+	}
+	return field, reqErr.Error()
+}
+
+// unknownProperties lists, sorted, the keys of obj that schema does not declare
+func unknownProperties(obj map[string]any, schema *openapi3.Schema) []string {
+	var unknown []string
+	for name := range obj {
+		if _, ok := schema.Properties[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRequests(t *testing.T) {
+	spec, err := LoadOpenAPISpec()
+	require.NoError(t, err)
+	validate, err := ValidateRequests(spec)
+	require.NoError(t, err)
+
+	reached := false
+	handler := validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		// The body must still be readable by the handler after validation
+		var body map[string]any
+		if r.Body != nil && r.ContentLength != 0 {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name:       "valid transfer",
+			method:     http.MethodPost,
+			path:       "/transactions",
+			body:       `{"source_account_id": 1, "destination_account_id": 2, "amount": "100.12345"}`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "trailing slash is validated too",
+			method:     http.MethodPost,
+			path:       "/transactions/",
+			body:       `{"source_account_id": 1, "destination_account_id": 2, "amount": "ten"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeInvalidRequest,
+		},
+		{
+			name:       "negative amount is left to the service",
+			method:     http.MethodPost,
+			path:       "/transactions",
+			body:       `{"source_account_id": 1, "destination_account_id": 2, "amount": "-5"}`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:        "unknown field",
+			method:      http.MethodPost,
+			path:        "/transactions",
+			body:        `{"source_account_id": 1, "destination_account_id": 2, "amount": "1", "memo": "x"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeInvalidRequest,
+			wantMessage: "memo: unknown field",
+		},
+		{
+			name:        "amount as number",
+			method:      http.MethodPost,
+			path:        "/transactions",
+			body:        `{"source_account_id": 1, "destination_account_id": 2, "amount": 100}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeInvalidRequest,
+			wantMessage: "amount",
+		},
+		{
+			name:        "malformed decimal",
+			method:      http.MethodPost,
+			path:        "/accounts",
+			body:        `{"account_id": 1, "initial_balance": "1e5"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeInvalidRequest,
+			wantMessage: "initial_balance",
+		},
+		{
+			name:        "account ID as string",
+			method:      http.MethodPost,
+			path:        "/accounts",
+			body:        `{"account_id": "1", "initial_balance": "1"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeInvalidRequest,
+			wantMessage: "account_id",
+		},
+		{
+			name:        "missing required field",
+			method:      http.MethodPost,
+			path:        "/accounts",
+			body:        `{"account_id": 1}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeInvalidRequest,
+			wantMessage: "initial_balance",
+		},
+		{
+			name:        "non-numeric path parameter",
+			method:      http.MethodGet,
+			path:        "/accounts/abc",
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeInvalidRequest,
+			wantMessage: "account_id",
+		},
+		{
+			name:        "malformed JSON",
+			method:      http.MethodPost,
+			path:        "/accounts",
+			body:        `{"account_id": 1,`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeInvalidJSON,
+			wantMessage: "not valid JSON",
+		},
+		{
+			name:       "valid path parameter",
+			method:     http.MethodGet,
+			path:       "/accounts/42",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "undocumented path passes through",
+			method:     http.MethodGet,
+			path:       "/nope",
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+			var req *http.Request
+			if tt.body != "" {
+				req = httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			} else {
+				req = httptest.NewRequest(tt.method, tt.path, nil)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.wantStatus != http.StatusBadRequest, reached)
+			if tt.wantStatus == http.StatusBadRequest {
+				p := problemBody(t, rec)
+				assert.Equal(t, tt.wantCode, p.Code)
+				assert.Contains(t, p.Detail, tt.wantMessage)
+				if tt.wantCode == CodeInvalidRequest {
+					assert.NotEmpty(t, p.Details["field"])
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	spec, err := LoadOpenAPISpec()
+	require.NoError(t, err)
+	handler, err := OpenAPIHandler(spec)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+}
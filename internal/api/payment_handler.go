@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/hidimpu/transfersystem/internal/importer"
+	"github.com/hidimpu/transfersystem/internal/service"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// ImportPaymentsHandler executes an ISO 20022 pain.001 credit-transfer
+// initiation (application/xml or text/xml). Every credit transfer is booked
+// like POST /transactions; instructions in currencies other than currency
+// are rejected. A file is executed once per GrpHdr/MsgId: resubmitting it
+// books nothing more and answers the report of the first execution.
+//
+//   - Response: 200 with a pain.002 status report giving the status of the
+//     batch and of every payment, rejected or not. A NbOfTxs or CtrlSum
+//     that does not match the file rejects every payment. Files that are
+//     not a readable pain.001 get a 400 INVALID_IMPORT problem; a different
+//     file under a MsgId already used gets 409 DUPLICATE_MESSAGE_ID.
+func ImportPaymentsHandler(payments *service.PaymentService, currency string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := utils.GlobalLogger
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || (mediaType != importer.ContentTypeXML && mediaType != "text/xml") {
+			WriteProblem(w, r, NewProblem(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+				"payment files must be application/xml"))
+			return
+		}
+
+		batch, err := importer.ParsePain001(r.Body, currency)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				WriteProblem(w, r, requestTooLarge(maxBytesErr.Limit))
+				return
+			}
+			problem := ProblemFromError(err, "Failed to read payment file")
+			logger.LogWarning(r.Context(), "API_PAYMENT_IMPORT", "Unreadable payment file: "+err.Error())
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		// Once started the batch runs to the end even if the client goes
+		// away, so its report is stored for the client's retry
+		report, err := payments.ExecutePayments(context.WithoutCancel(r.Context()), batch, importer.WritePain002)
+		if err != nil {
+			problem := ProblemFromError(err, "Failed to execute payment file")
+			logger.LogError(r.Context(), "API_PAYMENT_IMPORT", "IMPORT_ERROR", problem.Detail, err)
+			WriteProblem(w, r, problem)
+			return
+		}
+		w.Header().Set("Content-Type", importer.ContentTypeXML)
+		if _, err := w.Write(report); err != nil {
+			logger.LogError(r.Context(), "API_PAYMENT_IMPORT", "WRITE_ERROR", "Failed to write payment status report", err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// ProblemContentType is the media type of every error response (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// Stable codes for errors raised by the HTTP layer itself. Service errors use
+// the Code() of their model.TransferError or model.AccountError.
+const (
+	CodeInvalidJSON      = "INVALID_JSON"
+	CodeInvalidRequest   = "INVALID_REQUEST"
+	CodeRequestTooLarge  = "REQUEST_TOO_LARGE"
+	CodeNotFound         = "NOT_FOUND"
+	CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	// CodeUnsupportedMediaType is a body in a format the route does not accept
+	CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	CodeInternal             = "INTERNAL"
+)
+
+// retryAfterSeconds is the Retry-After hint sent with retryable errors
+const retryAfterSeconds = 1
+
+// Problem is an RFC 7807 problem details object. Code is the stable,
+// machine-readable identifier clients should match on; Detail is for humans
+// and may change.
+type Problem struct {
+	Type      string             `json:"type"`
+	Title     string             `json:"title"`
+	Status    int                `json:"status"`
+	Detail    string             `json:"detail,omitempty"`
+	Instance  string             `json:"instance,omitempty"`
+	Code      string             `json:"code"`
+	RequestID string             `json:"request_id,omitempty"`
+	Details   model.ErrorDetails `json:"details,omitempty"`
+
+	retryable bool
+}
+
+// NewProblem creates a problem with the given status, code and message
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// With adds a structured detail to the problem
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Details == nil {
+		p.Details = model.ErrorDetails{}
+	}
+	p.Details[key] = value
+	return p
+}
+
+// invalidField reports a request field that is missing or malformed
+func invalidField(field, reason string) *Problem {
+	return NewProblem(http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("invalid request: %s: %s", field, reason)).
+		With("field", field).
+		With("reason", reason)
+}
+
+// requestTooLarge reports a body over the MaxBodySize limit
+func requestTooLarge(limit int64) *Problem {
+	return NewProblem(http.StatusRequestEntityTooLarge, CodeRequestTooLarge, fmt.Sprintf("request body exceeds %d bytes", limit)).
+		With("limit_bytes", limit)
+}
+
+// ProblemFromError converts a service error into a problem. Typed errors keep
+// their status, code, message and details; anything else becomes a 500 with
+// fallback as the message so internals are not leaked.
+func ProblemFromError(err error, fallback string) *Problem {
+	var p *Problem
+	var transferErr model.TransferError
+	var accountErr model.AccountError
+	switch {
+	case errors.As(err, &transferErr):
+		p = NewProblem(transferErr.HTTPStatus(), transferErr.Code(), transferErr.Error())
+		p.retryable = transferErr.Retryable()
+	case errors.As(err, &accountErr):
+		p = NewProblem(accountErr.HTTPStatus(), accountErr.Code(), accountErr.Error())
+	default:
+		return NewProblem(http.StatusInternalServerError, CodeInternal, fallback)
+	}
+	for k, v := range model.DetailsOf(err) {
+		p.With(k, v)
+	}
+	return p
+}
+
+// WriteProblem writes p as application/problem+json, stamping it with the
+// request ID and path
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.RequestID = utils.RequestIDFromContext(r.Context())
+	p.Instance = r.URL.Path
+	if p.retryable {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// NotFoundHandler answers unknown routes with a problem
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, NewProblem(http.StatusNotFound, CodeNotFound, fmt.Sprintf("no route for %s", r.URL.Path)))
+}
+
+// MethodNotAllowedHandler answers known routes called with the wrong method
+// with a problem
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, NewProblem(http.StatusMethodNotAllowed, CodeMethodNotAllowed, fmt.Sprintf("method %s not allowed for %s", r.Method, r.URL.Path)))
+}
+
+// decodeJSON decodes the request body into dst, describing any failure as a
+// problem: 413 for bodies over the MaxBodySize limit, 400 otherwise
+func decodeJSON(r *http.Request, dst any) (*Problem, error) {
+	err := json.NewDecoder(r.Body).Decode(dst)
+	if err == nil {
+		return nil, nil
+	}
+
+	var (
+		maxBytesErr *http.MaxBytesError
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return requestTooLarge(maxBytesErr.Limit), err
+	case errors.As(err, &syntaxErr):
+		return NewProblem(http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON").
+			With("offset", syntaxErr.Offset), err
+	case errors.As(err, &typeErr):
+		return invalidField(typeErr.Field, fmt.Sprintf("must be %s", typeErr.Type.String())), err
+	case errors.Is(err, io.EOF):
+		return NewProblem(http.StatusBadRequest, CodeInvalidJSON, "request body is empty"), err
+	default:
+		return NewProblem(http.StatusBadRequest, CodeInvalidJSON, "request body is not valid JSON"), err
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+func TestProblemFromError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantDetail  string
+		wantDetails model.ErrorDetails
+	}{
+		{
+			name:       "transfer error",
+			err:        model.ErrSameAccountTransfer,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "SAME_ACCOUNT_TRANSFER",
+			wantDetail: "cannot transfer to same account",
+		},
+		{
+			name:        "wrapped error keeps its details",
+			err:         fmt.Errorf("transfer: %w", model.WithDetails(model.ErrInsufficientFunds, model.ErrorDetails{"available_balance": "5"})),
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    "INSUFFICIENT_FUNDS",
+			wantDetail:  "insufficient funds",
+			wantDetails: model.ErrorDetails{"available_balance": "5"},
+		},
+		{
+			name:       "account error",
+			err:        model.ErrAccountExists,
+			wantStatus: http.StatusConflict,
+			wantCode:   "ACCOUNT_EXISTS",
+			wantDetail: "account already exists",
+		},
+		{
+			name:       "untyped error is not leaked",
+			err:        errors.New("pq: connection refused"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   CodeInternal,
+			wantDetail: "fallback",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ProblemFromError(tt.err, "fallback")
+			assert.Equal(t, tt.wantStatus, p.Status)
+			assert.Equal(t, tt.wantCode, p.Code)
+			assert.Equal(t, tt.wantDetail, p.Detail)
+			assert.Equal(t, tt.wantDetails, p.Details)
+			assert.Equal(t, http.StatusText(tt.wantStatus), p.Title)
+		})
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/transactions", nil)
+	req = req.WithContext(utils.WithRequestID(req.Context(), "req-1"))
+	rec := httptest.NewRecorder()
+
+	WriteProblem(rec, req, ProblemFromError(model.ErrTransferConflict, ""))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Service Unavailable",
+		"status": 503,
+		"detail": "transfer conflicted with concurrent updates, please retry",
+		"instance": "/transactions",
+		"code": "TRANSFER_CONFLICT",
+		"request_id": "req-1"
+	}`, rec.Body.String())
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		limit       int64
+		wantStatus  int
+		wantCode    string
+		wantDetails model.ErrorDetails
+	}{
+		{name: "empty body", body: "", wantStatus: http.StatusBadRequest, wantCode: CodeInvalidJSON},
+		{name: "truncated", body: `{"amount": "1"`, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidJSON},
+		{
+			name:        "syntax error",
+			body:        `{"amount": }`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeInvalidJSON,
+			wantDetails: model.ErrorDetails{"offset": int64(12)},
+		},
+		{
+			name:        "wrong type",
+			body:        `{"account_id": "1"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    CodeInvalidRequest,
+			wantDetails: model.ErrorDetails{"field": "account_id", "reason": "must be int64"},
+		},
+		{
+			name:        "too large",
+			body:        `{"amount": "1000000"}`,
+			limit:       8,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantCode:    CodeRequestTooLarge,
+			wantDetails: model.ErrorDetails{"limit_bytes": int64(8)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(tt.body))
+			if tt.limit > 0 {
+				req.Body = http.MaxBytesReader(httptest.NewRecorder(), req.Body, tt.limit)
+			}
+			var dst struct {
+				AccountID int64  `json:"account_id"`
+				Amount    string `json:"amount"`
+			}
+			p, err := decodeJSON(req, &dst)
+			require.Error(t, err)
+			require.NotNil(t, p)
+			assert.Equal(t, tt.wantStatus, p.Status)
+			assert.Equal(t, tt.wantCode, p.Code)
+			assert.Equal(t, tt.wantDetails, p.Details)
+		})
+	}
+
+	var dst map[string]any
+	p, err := decodeJSON(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a": 1}`)), &dst)
+	assert.Nil(t, p)
+	assert.NoError(t, err)
+}
+
+// problemBody decodes a problem+json response
+func problemBody(t *testing.T, rec *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	require.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	return p
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hidimpu/transfersystem/internal/importer"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/service"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// ImportExternalStatementHandler records a statement received from a
+// partner bank for the ledger account mirroring the account it holds, in
+// CSV (text/csv) or camt.053 (application/xml or text/xml), and matches its
+// lines with the account's transactions.
+//
+//   - Query: account_id (required).
+//   - Response: 201 with the statement and the state of every line. A file
+//     already imported for the account gets 409 STATEMENT_ALREADY_IMPORTED;
+//     a file with any unreadable line gets 400 INVALID_IMPORT.
+func ImportExternalStatementHandler(recon *service.ReconciliationService, currency string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := utils.GlobalLogger
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		format, ok := importer.ExternalFormatFromContentType(mediaType)
+		if err != nil || !ok {
+			WriteProblem(w, r, NewProblem(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+				"statements must be text/csv or application/xml"))
+			return
+		}
+		accountID, err := strconv.ParseInt(r.URL.Query().Get("account_id"), 10, 64)
+		if err != nil {
+			WriteProblem(w, r, invalidField("account_id", "must be an integer"))
+			return
+		}
+
+		st, err := importer.ParseExternalStatement(r.Body, format, currency)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				WriteProblem(w, r, requestTooLarge(maxBytesErr.Limit))
+				return
+			}
+			problem := ProblemFromError(err, "Failed to read statement")
+			logger.LogWarning(r.Context(), "API_RECONCILIATION_IMPORT", "Unreadable statement: "+err.Error())
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		st, err = recon.ImportStatement(r.Context(), accountID, st)
+		if err != nil {
+			problem := ProblemFromError(err, "Failed to import statement")
+			logger.LogError(r.Context(), "API_RECONCILIATION_IMPORT", "IMPORT_ERROR", problem.Detail, err)
+			WriteProblem(w, r, problem)
+			return
+		}
+		writeJSON(w, http.StatusCreated, st)
+	}
+}
+
+// GetExternalStatementHandler returns an imported statement with the state
+// of its lines
+func GetExternalStatementHandler(recon *service.ReconciliationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statementID, problem := idParam(r, "statement_id")
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+		st, err := recon.Statement(r.Context(), statementID)
+		if err != nil {
+			reconciliationProblem(w, r, "API_RECONCILIATION_STATEMENT", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+	}
+}
+
+// MatchExternalStatementHandler runs matching again over the unmatched lines
+// of a statement, for transactions booked since it was imported.
+//
+//   - Response: 200 with the number of lines matched by this run and the
+//     number still unmatched.
+func MatchExternalStatementHandler(recon *service.ReconciliationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statementID, problem := idParam(r, "statement_id")
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+		report, err := recon.MatchStatement(r.Context(), statementID)
+		if err != nil {
+			reconciliationProblem(w, r, "API_RECONCILIATION_MATCH", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// ListExternalLinesHandler lists the external lines of an account for
+// review.
+//
+//   - Query: account_id (required); status=matched|unmatched.
+//   - Response: 200 with the lines by statement and file order. Unmatched
+//     lines list their candidate transactions, best first.
+func ListExternalLinesHandler(recon *service.ReconciliationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		accountID, err := strconv.ParseInt(q.Get("account_id"), 10, 64)
+		if err != nil {
+			WriteProblem(w, r, invalidField("account_id", "must be an integer"))
+			return
+		}
+		status := model.ExternalLineStatus(q.Get("status"))
+		switch status {
+		case "", model.LineMatched, model.LineUnmatched:
+		default:
+			WriteProblem(w, r, invalidField("status", "must be matched or unmatched"))
+			return
+		}
+
+		lines, err := recon.Lines(r.Context(), accountID, status)
+		if err != nil {
+			reconciliationProblem(w, r, "API_RECONCILIATION_LINES", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, lines)
+	}
+}
+
+// LinkExternalLineHandler matches a line with a transaction chosen by a
+// reviewer.
+//
+//   - Request body: {"transaction_id": 42}
+//   - Response: 200 with the matched line. A transaction that does not move
+//     the line's amount on its account gets 422 MATCH_MISMATCH.
+func LinkExternalLineHandler(recon *service.ReconciliationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lineID, problem := idParam(r, "line_id")
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+		var req struct {
+			TransactionID int64 `json:"transaction_id"`
+		}
+		if problem, _ := decodeJSON(r, &req); problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+		if req.TransactionID <= 0 {
+			WriteProblem(w, r, invalidField("transaction_id", "is required"))
+			return
+		}
+
+		line, err := recon.Link(r.Context(), lineID, req.TransactionID)
+		if err != nil {
+			reconciliationProblem(w, r, "API_RECONCILIATION_LINK", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, line)
+	}
+}
+
+// UnlinkExternalLineHandler clears the match of a line
+func UnlinkExternalLineHandler(recon *service.ReconciliationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lineID, problem := idParam(r, "line_id")
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+		line, err := recon.Unlink(r.Context(), lineID)
+		if err != nil {
+			reconciliationProblem(w, r, "API_RECONCILIATION_UNLINK", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, line)
+	}
+}
+
+// AdjustExternalLineHandler books the transfer an unmatched line is missing
+// from the ledger, between the line's account and a counterparty account,
+// and matches the line with it.
+//
+//   - Request body: {"counterparty_account_id": 900}
+//   - Response: 201 with the matched line; the adjusting transaction is in
+//     its match. Transfer errors are reported as by POST /transactions.
+func AdjustExternalLineHandler(recon *service.ReconciliationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lineID, problem := idParam(r, "line_id")
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+		var req struct {
+			CounterpartyAccountID int64 `json:"counterparty_account_id"`
+		}
+		if problem, _ := decodeJSON(r, &req); problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+		if req.CounterpartyAccountID <= 0 {
+			WriteProblem(w, r, invalidField("counterparty_account_id", "is required"))
+			return
+		}
+
+		line, err := recon.Adjust(r.Context(), lineID, req.CounterpartyAccountID)
+		if err != nil {
+			reconciliationProblem(w, r, "API_RECONCILIATION_ADJUST", err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, line)
+	}
+}
+
+// idParam reads a positive integer path parameter
+func idParam(r *http.Request, name string) (int64, *Problem) {
+	id, err := strconv.ParseInt(chi.URLParam(r, name), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, invalidField(name, "must be a positive integer")
+	}
+	return id, nil
+}
+
+// reconciliationProblem reports a service error, logging it under op
+func reconciliationProblem(w http.ResponseWriter, r *http.Request, op string, err error) {
+	problem := ProblemFromError(err, "Reconciliation failed")
+	if problem.Status >= http.StatusInternalServerError {
+		utils.GlobalLogger.LogError(r.Context(), op, "RECONCILIATION_ERROR", problem.Detail, err)
+	} else {
+		utils.GlobalLogger.LogWarning(r.Context(), op, problem.Detail)
+	}
+	WriteProblem(w, r, problem)
+}
+
+// writeJSON writes v as a JSON response with status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hidimpu/transfersystem/internal/service"
+	"github.com/hidimpu/transfersystem/internal/statement"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// GetStatementHandler renders an account statement.
+//
+//   - Query: from (inclusive) and to (exclusive) take RFC 3339 timestamps or
+//     YYYY-MM-DD dates (UTC midnight). to defaults to now and from to the
+//     start of the calendar month before to, so a bare request is the
+//     current month to date. format=json|csv|text|camt053|mt940|ofx
+//     (default json).
+//   - Response: 200 with the statement; every format but JSON is an
+//     attachment. 422 when an amount or ID does not fit the format's
+//     fields (MT940 amounts are at most 15 characters).
+func GetStatementHandler(statements *service.StatementService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := utils.GlobalLogger
+
+		accountID, err := strconv.ParseInt(chi.URLParam(r, "account_id"), 10, 64)
+		if err != nil {
+			WriteProblem(w, r, invalidField("account_id", "must be an integer"))
+			return
+		}
+		format, from, to, problem := statementParams(r)
+		if problem != nil {
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		st, err := statements.Statement(r.Context(), accountID, from, to)
+		if err != nil {
+			problem := ProblemFromError(err, "Failed to build statement")
+			logger.LogError(r.Context(), "API_ACCOUNT_STATEMENT", "STATEMENT_ERROR", problem.Detail, err)
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		// Render before writing anything, so a statement the format cannot
+		// hold still gets a problem response
+		var body bytes.Buffer
+		if err := statement.Render(&body, format, st); err != nil {
+			problem := ProblemFromError(err, "Failed to render statement")
+			logger.LogWarning(r.Context(), "API_ACCOUNT_STATEMENT", "Unrenderable statement: "+err.Error())
+			WriteProblem(w, r, problem)
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		if format != statement.FormatJSON {
+			filename := fmt.Sprintf("statement-%d-%s%s", accountID, st.From.UTC().Format(time.DateOnly), format.Extension())
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		}
+		if _, err := body.WriteTo(w); err != nil {
+			logger.LogError(r.Context(), "API_ACCOUNT_STATEMENT", "WRITE_ERROR", "Failed to write statement", err)
+		}
+	}
+}
+
+// statementParams reads the format, from and to query parameters
+func statementParams(r *http.Request) (statement.Format, time.Time, time.Time, *Problem) {
+	q := r.URL.Query()
+	format := statement.FormatJSON
+	if raw := q.Get("format"); raw != "" {
+		f, err := statement.ParseFormat(raw)
+		if err != nil {
+			return "", time.Time{}, time.Time{}, invalidField("format", "must be json, csv, text, camt053, mt940 or ofx")
+		}
+		format = f
+	}
+
+	to := time.Now().UTC()
+	if raw := q.Get("to"); raw != "" {
+		t, err := parseTimeParam(raw)
+		if err != nil {
+			return "", time.Time{}, time.Time{}, invalidField("to", "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		}
+		to = t
+	}
+	y, m, _ := to.UTC().Add(-time.Nanosecond).Date()
+	from := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	if raw := q.Get("from"); raw != "" {
+		t, err := parseTimeParam(raw)
+		if err != nil {
+			return "", time.Time{}, time.Time{}, invalidField("from", "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		}
+		from = t
+	}
+	return format, from, to, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/hidimpu/transfersystem/internal/model"
 	"github.com/hidimpu/transfersystem/internal/service"
@@ -28,66 +29,155 @@ func (h *TransactionHandler) TransferFunds(w http.ResponseWriter, r *http.Reques
 		SourceAccountID      int64  `json:"source_account_id"`
 		DestinationAccountID int64  `json:"destination_account_id"`
 		Amount               string `json:"amount"`
+		model.TransferDetails
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.LogError("API_TRANSFER", "JSON_DECODE_ERROR", "Invalid JSON payload", err)
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+	if problem, err := decodeJSON(r, &req); problem != nil {
+		h.logger.LogError(r.Context(), "API_TRANSFER", "JSON_DECODE_ERROR", problem.Detail, err)
+		WriteProblem(w, r, problem)
 		return
 	}
 
 	// Validate required fields
 	if req.SourceAccountID == 0 {
-		h.logger.LogWarning("API_TRANSFER", "Missing source_account_id")
-		http.Error(w, "source_account_id is required", http.StatusBadRequest)
+		h.logger.LogWarning(r.Context(), "API_TRANSFER", "Missing source_account_id")
+		WriteProblem(w, r, invalidField("source_account_id", "is required"))
 		return
 	}
 	if req.DestinationAccountID == 0 {
-		h.logger.LogWarning("API_TRANSFER", "Missing destination_account_id")
-		http.Error(w, "destination_account_id is required", http.StatusBadRequest)
+		h.logger.LogWarning(r.Context(), "API_TRANSFER", "Missing destination_account_id")
+		WriteProblem(w, r, invalidField("destination_account_id", "is required"))
 		return
 	}
 	if req.Amount == "" {
-		h.logger.LogWarning("API_TRANSFER", "Missing amount")
-		http.Error(w, "amount is required", http.StatusBadRequest)
+		h.logger.LogWarning(r.Context(), "API_TRANSFER", "Missing amount")
+		WriteProblem(w, r, invalidField("amount", "is required"))
 		return
 	}
 
 	amt, err := decimal.NewFromString(req.Amount)
 	if err != nil {
-		h.logger.LogError("API_TRANSFER", "AMOUNT_PARSE_ERROR", "Invalid amount format", err)
-		http.Error(w, "Invalid amount format", http.StatusBadRequest)
+		h.logger.LogError(r.Context(), "API_TRANSFER", "AMOUNT_PARSE_ERROR", "Invalid amount format", err)
+		WriteProblem(w, r, invalidField("amount", "must be a decimal string"))
 		return
 	}
 
-	if err := h.service.Transfer(r.Context(), req.SourceAccountID, req.DestinationAccountID, amt); err != nil {
-		// Map service errors to appropriate HTTP status codes using error enums
-		var statusCode int
-		var errorMessage string
-
-		switch err := err.(type) {
-		case model.TransferError:
-			statusCode = err.HTTPStatus()
-			errorMessage = err.Error()
-		case model.AccountError:
-			statusCode = err.HTTPStatus()
-			errorMessage = err.Error()
-		default:
-			statusCode = http.StatusInternalServerError
-			errorMessage = "Internal server error"
-		}
-
-		h.logger.LogError("API_TRANSFER", "TRANSFER_ERROR", errorMessage, err)
-		http.Error(w, errorMessage, statusCode)
+	txn, err := h.service.TransferWithDetails(r.Context(), req.SourceAccountID, req.DestinationAccountID, amt, req.TransferDetails)
+	if err != nil {
+		// Typed service errors carry their own status and code; retryable
+		// ones also get a Retry-After header
+		problem := ProblemFromError(err, "Internal server error")
+		h.logger.LogError(r.Context(), "API_TRANSFER", "TRANSFER_ERROR", problem.Detail, err)
+		WriteProblem(w, r, problem)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Transfer completed successfully",
-		"amount":  amt.String(),
-		"from":    fmt.Sprintf("%d", req.SourceAccountID),
-		"to":      fmt.Sprintf("%d", req.DestinationAccountID),
+	json.NewEncoder(w).Encode(struct {
+		Message       string `json:"message"`
+		Amount        string `json:"amount"`
+		From          string `json:"from"`
+		To            string `json:"to"`
+		TransactionID int64  `json:"transaction_id"`
+		model.TransferDetails
+	}{
+		Message:         "Transfer completed successfully",
+		Amount:          amt.String(),
+		From:            fmt.Sprintf("%d", req.SourceAccountID),
+		To:              fmt.Sprintf("%d", req.DestinationAccountID),
+		TransactionID:   txn.ID,
+		TransferDetails: txn.TransferDetails,
 	})
 }
+
+// SearchTransactions finds transactions of every account for support
+// staff.
+//
+//   - Query: account_id and counterparty_id (each to or from the account;
+//     both for transfers between the two), min_amount and max_amount
+//     (inclusive), from (inclusive) and to (exclusive) on created_at,
+//     reference (exact), q (text in the reference or description),
+//     type=transfer|reversal, status=booked|reversed,
+//     sort=-created_at|created_at|-amount|amount (default -created_at),
+//     limit (default 50, at most 200) and cursor.
+//   - Response: 200 with a page of transactions and, unless it is the last
+//     page, the next_cursor to pass for the next one with the same filters.
+func (h *TransactionHandler) SearchTransactions(w http.ResponseWriter, r *http.Request) {
+	search, problem := transactionSearch(r)
+	if problem != nil {
+		h.logger.LogWarning(r.Context(), "API_TRANSACTION_SEARCH", problem.Detail)
+		WriteProblem(w, r, problem)
+		return
+	}
+
+	page, err := h.service.SearchTransactions(r.Context(), search)
+	if err != nil {
+		problem := ProblemFromError(err, "Failed to search transactions")
+		if problem.Status >= http.StatusInternalServerError {
+			h.logger.LogError(r.Context(), "API_TRANSACTION_SEARCH", "SEARCH_ERROR", problem.Detail, err)
+		} else {
+			h.logger.LogWarning(r.Context(), "API_TRANSACTION_SEARCH", problem.Detail)
+		}
+		WriteProblem(w, r, problem)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// transactionSearch reads the query parameters of a transaction search.
+// Values are only parsed here; the service validates them.
+func transactionSearch(r *http.Request) (model.TransactionSearch, *Problem) {
+	q := r.URL.Query()
+	search := model.TransactionSearch{
+		Reference: q.Get("reference"),
+		Text:      q.Get("q"),
+		Type:      model.TransactionType(q.Get("type")),
+		Status:    model.TransactionStatus(q.Get("status")),
+		Sort:      model.TransactionSort(q.Get("sort")),
+	}
+	// Parameters are checked in a fixed order, so a query with several
+	// invalid ones always reports the same one
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{{"account_id", &search.AccountID}, {"counterparty_id", &search.CounterpartyID}} {
+		if raw := q.Get(p.name); raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || id <= 0 {
+				return search, invalidField(p.name, "must be a positive integer")
+			}
+			*p.dst = id
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *decimal.NullDecimal
+	}{{"min_amount", &search.MinAmount}, {"max_amount", &search.MaxAmount}} {
+		if raw := q.Get(p.name); raw != "" {
+			d, err := decimal.NewFromString(raw)
+			if err != nil {
+				return search, invalidField(p.name, "must be a decimal string")
+			}
+			*p.dst = decimal.NewNullDecimal(d)
+		}
+	}
+	if problem := parseTimeParams(q, timeParam{"from", &search.From}, timeParam{"to", &search.To}); problem != nil {
+		return search, problem
+	}
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return search, invalidField("limit", "must be a positive integer")
+		}
+		search.Limit = n
+	}
+	if raw := q.Get("cursor"); raw != "" {
+		cursor, err := model.ParseSearchCursor(raw)
+		if err != nil {
+			return search, ProblemFromError(err, "Invalid cursor")
+		}
+		search.After = cursor
+	}
+	return search, nil
+}
@@ -1,28 +1,512 @@
 package config
 
 import (
-	"log"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+
+	"github.com/hidimpu/transfersystem/internal/utils"
 )
 
+// Config is the complete, typed application configuration.
+//
+// Values are resolved in increasing order of precedence:
+//  1. built-in defaults (Default)
+//  2. the optional YAML file passed to Load
+//  3. environment variables, including those loaded from a .env file
+//  4. overrides passed to Load (command-line flags)
 type Config struct {
-	DBUrl string
-	Port  string
+	// Storage selects the storage backend: StoragePostgres or StorageMemory
+	Storage  string         `yaml:"storage"`
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Transfer TransferConfig `yaml:"transfer"`
+	Log      LogConfig      `yaml:"log"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+	Features FeatureConfig  `yaml:"features"`
+	Snapshot SnapshotConfig `yaml:"snapshots"`
+	// Reconciliation tunes matching of external statements
+	Reconciliation ReconciliationConfig `yaml:"reconciliation"`
+}
+
+// Supported storage backends
+const (
+	StoragePostgres = "postgres"
+	// StorageMemory keeps everything in process memory; intended for local
+	// development and tests only
+	StorageMemory = "memory"
+)
+
+// ServerConfig configures the HTTP and gRPC servers
+type ServerConfig struct {
+	Port         string        `yaml:"port"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	MaxBodyBytes int64         `yaml:"max_body_bytes"`
+	// MaxImportBytes replaces MaxBodyBytes for bulk import uploads
+	MaxImportBytes int64 `yaml:"max_import_bytes"`
+	// DrainDelay is how long the server keeps serving after readiness turns
+	// unhealthy on shutdown, so load balancers can stop routing to it
+	DrainDelay time.Duration `yaml:"drain_delay"`
+	// ShutdownTimeout bounds draining in-flight requests and stopping
+	// background work once the listener is closed
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// HealthCheckTimeout bounds each dependency check behind /readyz
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout"`
+	// GRPCPort serves the gRPC API; empty disables it
+	GRPCPort string `yaml:"grpc_port"`
+}
+
+// DatabaseConfig configures the PostgreSQL connection pool
+type DatabaseConfig struct {
+	URL             string        `yaml:"url"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+	// AutoMigrate applies pending embedded migrations on startup
+	AutoMigrate bool `yaml:"auto_migrate"`
+	// RetryMaxAttempts bounds how often a unit of work is attempted when it
+	// hits a serialization failure or deadlock (including the first attempt)
+	RetryMaxAttempts int           `yaml:"retry_max_attempts"`
+	RetryBaseDelay   time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay    time.Duration `yaml:"retry_max_delay"`
+}
+
+// TransferConfig configures transfer processing
+type TransferConfig struct {
+	// IsolationLevel is one of serializable, repeatable_read or read_committed
+	IsolationLevel string `yaml:"isolation_level"`
+	// MaxAmount caps a single transfer; empty or zero means unlimited
+	MaxAmount string `yaml:"max_amount"`
+	// Currency is the ISO 4217 code every account is held in
+	Currency string `yaml:"currency"`
+}
+
+// LogConfig configures the structured logger
+type LogConfig struct {
+	Format   string         `yaml:"format"`
+	Level    string         `yaml:"level"`
+	Sampling map[string]int `yaml:"sampling"`
+}
+
+// TracingConfig configures OpenTelemetry tracing
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter"`
+	ServiceName string `yaml:"service_name"`
+}
+
+// FeatureConfig toggles optional functionality
+type FeatureConfig struct {
+	Metrics bool `yaml:"metrics"`
+}
+
+// SnapshotConfig configures the end-of-day balance snapshot job
+type SnapshotConfig struct {
+	// Enabled runs the job in the API process after each business day ends
+	Enabled bool `yaml:"enabled"`
+	// Timezone is the IANA zone business days are counted in
+	Timezone string `yaml:"timezone"`
+	// Delay waits past midnight before snapshotting, so transfers that
+	// committed just before the day ended are visible
+	Delay time.Duration `yaml:"delay"`
+	// BatchSize is how many accounts are snapshotted per statement
+	BatchSize int `yaml:"batch_size"`
+}
+
+// ReconciliationConfig configures how external statement lines are matched
+// with transactions
+type ReconciliationConfig struct {
+	// DateWindowDays is how many days apart a line and a transaction may be
+	// booked and still match
+	DateWindowDays int `yaml:"date_window_days"`
+	// AutoMatchConfidence is the confidence, 50 to 100, from which a line is
+	// matched without review
+	AutoMatchConfidence int `yaml:"auto_match_confidence"`
 }
 
-func LoadConfig() Config {
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL not set")
+// Default returns the configuration used when nothing is overridden
+func Default() Config {
+	return Config{
+		Storage: StoragePostgres,
+		Server: ServerConfig{
+			Port:         "8080",
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 15 * time.Second,
+			IdleTimeout:  60 * time.Second,
+			MaxBodyBytes: 1 << 20,
+
+			MaxImportBytes: 32 << 20,
+
+			DrainDelay:      5 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+
+			HealthCheckTimeout: time.Second,
+
+			GRPCPort: "50051",
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectTimeout:  5 * time.Second,
+
+			RetryMaxAttempts: 5,
+			RetryBaseDelay:   10 * time.Millisecond,
+			RetryMaxDelay:    500 * time.Millisecond,
+		},
+		Transfer: TransferConfig{
+			IsolationLevel: "serializable",
+			Currency:       "USD",
+		},
+		Log: LogConfig{
+			Format:   utils.LogFormatJSON,
+			Level:    "info",
+			Sampling: map[string]int{"GET_ATTEMPT": 10},
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "transfersystem",
+		},
+		Features: FeatureConfig{
+			Metrics: true,
+		},
+		Snapshot: SnapshotConfig{
+			Timezone:  "UTC",
+			Delay:     5 * time.Minute,
+			BatchSize: 1000,
+		},
+		Reconciliation: ReconciliationConfig{
+			DateWindowDays:      3,
+			AutoMatchConfidence: 80,
+		},
 	}
+}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080" // fallback
+// Load reads the .env file (if present), the YAML file at path (if path is
+// non-empty) and the environment, applies overrides, then validates the result
+func Load(path string, overrides ...func(*Config)) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("loading .env file: %w", err)
 	}
 
-	return Config{
-		DBUrl: dbURL,
-		Port:  port,
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		override(&cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// applyEnv overrides fields from environment variables
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	e := envReader{lookup: lookup}
+
+	e.string("STORAGE", &c.Storage)
+
+	e.string("PORT", &c.Server.Port)
+	if v, ok := lookup("GRPC_PORT"); ok {
+		// An explicitly empty GRPC_PORT disables the gRPC server
+		c.Server.GRPCPort = v
+	}
+	e.duration("HTTP_READ_TIMEOUT", &c.Server.ReadTimeout)
+	e.duration("HTTP_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	e.duration("HTTP_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	e.int64("HTTP_MAX_BODY_BYTES", &c.Server.MaxBodyBytes)
+	e.int64("HTTP_MAX_IMPORT_BYTES", &c.Server.MaxImportBytes)
+	e.duration("HTTP_DRAIN_DELAY", &c.Server.DrainDelay)
+	e.duration("HTTP_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	e.duration("HEALTH_CHECK_TIMEOUT", &c.Server.HealthCheckTimeout)
+
+	// DATABASE_URL is accepted for backwards compatibility; DB_URL wins
+	e.string("DATABASE_URL", &c.Database.URL)
+	e.string("DB_URL", &c.Database.URL)
+	e.int("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	e.int("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+	e.duration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime)
+	e.duration("DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime)
+	e.duration("DB_CONNECT_TIMEOUT", &c.Database.ConnectTimeout)
+	e.bool("DB_AUTO_MIGRATE", &c.Database.AutoMigrate)
+	e.int("DB_RETRY_MAX_ATTEMPTS", &c.Database.RetryMaxAttempts)
+	e.duration("DB_RETRY_BASE_DELAY", &c.Database.RetryBaseDelay)
+	e.duration("DB_RETRY_MAX_DELAY", &c.Database.RetryMaxDelay)
+
+	e.string("TRANSFER_ISOLATION_LEVEL", &c.Transfer.IsolationLevel)
+	e.string("TRANSFER_MAX_AMOUNT", &c.Transfer.MaxAmount)
+	e.string("TRANSFER_CURRENCY", &c.Transfer.Currency)
+
+	e.string("LOG_FORMAT", &c.Log.Format)
+	e.string("LOG_LEVEL", &c.Log.Level)
+	if raw, ok := lookup("LOG_SAMPLING"); ok {
+		sampling, err := utils.ParseLogSampling(raw)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("LOG_SAMPLING: %w", err))
+		} else {
+			c.Log.Sampling = sampling
+		}
+	}
+
+	e.string("OTEL_TRACES_EXPORTER", &c.Tracing.Exporter)
+	e.string("OTEL_SERVICE_NAME", &c.Tracing.ServiceName)
+
+	e.bool("FEATURE_METRICS", &c.Features.Metrics)
+
+	e.bool("SNAPSHOT_ENABLED", &c.Snapshot.Enabled)
+	e.string("SNAPSHOT_TIMEZONE", &c.Snapshot.Timezone)
+	e.duration("SNAPSHOT_DELAY", &c.Snapshot.Delay)
+	e.int("SNAPSHOT_BATCH_SIZE", &c.Snapshot.BatchSize)
+
+	e.int("RECONCILIATION_DATE_WINDOW_DAYS", &c.Reconciliation.DateWindowDays)
+	e.int("RECONCILIATION_AUTO_MATCH_CONFIDENCE", &c.Reconciliation.AutoMatchConfidence)
+
+	return errors.Join(e.errs...)
+}
+
+// Validate checks that the configuration is complete and consistent
+func (c *Config) Validate() error {
+	var errs []error
+
+	if !validPort(c.Server.Port) {
+		errs = append(errs, fmt.Errorf("server.port must be a number between 1 and 65535, got %q", c.Server.Port))
+	}
+	if c.Server.GRPCPort != "" {
+		if !validPort(c.Server.GRPCPort) {
+			errs = append(errs, fmt.Errorf("server.grpc_port must be empty or a number between 1 and 65535, got %q", c.Server.GRPCPort))
+		} else if c.Server.GRPCPort == c.Server.Port {
+			errs = append(errs, errors.New("server.grpc_port must differ from server.port"))
+		}
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 {
+		errs = append(errs, errors.New("server timeouts cannot be negative"))
+	}
+	if c.Server.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("server.max_body_bytes must be positive"))
+	}
+	if c.Server.MaxImportBytes <= 0 {
+		errs = append(errs, errors.New("server.max_import_bytes must be positive"))
+	}
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, errors.New("server.drain_delay cannot be negative"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Server.HealthCheckTimeout <= 0 {
+		errs = append(errs, errors.New("server.health_check_timeout must be positive"))
+	}
+
+	switch c.Storage {
+	case StoragePostgres:
+		if c.Database.URL == "" {
+			errs = append(errs, errors.New("database.url (DB_URL) is required"))
+		}
+	case StorageMemory:
+	default:
+		errs = append(errs, fmt.Errorf("storage must be %s or %s, got %q", StoragePostgres, StorageMemory, c.Storage))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database pool sizes cannot be negative"))
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.max_idle_conns cannot exceed database.max_open_conns"))
+	}
+	if c.Database.ConnectTimeout <= 0 {
+		errs = append(errs, errors.New("database.connect_timeout must be positive"))
+	}
+	if c.Database.RetryMaxAttempts < 1 {
+		errs = append(errs, errors.New("database.retry_max_attempts must be at least 1"))
+	}
+	if c.Database.RetryBaseDelay < 0 || c.Database.RetryMaxDelay < c.Database.RetryBaseDelay {
+		errs = append(errs, errors.New("database.retry_base_delay must be non-negative and not exceed database.retry_max_delay"))
+	}
+
+	if _, err := c.Transfer.Isolation(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.Transfer.Limit(); err != nil {
+		errs = append(errs, err)
+	}
+	if !validCurrency(c.Transfer.Currency) {
+		errs = append(errs, fmt.Errorf("transfer.currency must be a three-letter ISO 4217 code, got %q", c.Transfer.Currency))
+	}
+
+	if !strings.EqualFold(c.Log.Format, utils.LogFormatJSON) && !strings.EqualFold(c.Log.Format, utils.LogFormatText) {
+		errs = append(errs, fmt.Errorf("log.format must be json or text, got %q", c.Log.Format))
+	}
+	if _, err := utils.ParseLogLevel(c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+
+	if _, err := c.Snapshot.Location(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Snapshot.Delay < 0 {
+		errs = append(errs, errors.New("snapshots.delay cannot be negative"))
+	}
+	if c.Snapshot.BatchSize < 1 {
+		errs = append(errs, errors.New("snapshots.batch_size must be at least 1"))
+	}
+
+	if c.Reconciliation.DateWindowDays < 0 {
+		errs = append(errs, errors.New("reconciliation.date_window_days cannot be negative"))
+	}
+	if c.Reconciliation.AutoMatchConfidence < 50 || c.Reconciliation.AutoMatchConfidence > 100 {
+		errs = append(errs, fmt.Errorf("reconciliation.auto_match_confidence must be between 50 and 100, got %d", c.Reconciliation.AutoMatchConfidence))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validCurrency reports whether s looks like an ISO 4217 code
+func validCurrency(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func validPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 1 && port <= 65535
+}
+
+// Isolation maps the configured isolation level to its database/sql value
+func (t TransferConfig) Isolation() (sql.IsolationLevel, error) {
+	switch strings.ToLower(t.IsolationLevel) {
+	case "serializable":
+		return sql.LevelSerializable, nil
+	case "repeatable_read":
+		return sql.LevelRepeatableRead, nil
+	case "read_committed":
+		return sql.LevelReadCommitted, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("transfer.isolation_level must be serializable, repeatable_read or read_committed, got %q", t.IsolationLevel)
+	}
+}
+
+// Limit returns the maximum amount for a single transfer; zero means unlimited
+func (t TransferConfig) Limit() (decimal.Decimal, error) {
+	if t.MaxAmount == "" {
+		return decimal.Zero, nil
+	}
+	limit, err := decimal.NewFromString(t.MaxAmount)
+	if err != nil || limit.IsNegative() {
+		return decimal.Zero, fmt.Errorf("transfer.max_amount must be a non-negative decimal, got %q", t.MaxAmount)
+	}
+	return limit, nil
+}
+
+// Location returns the time zone business days are counted in
+func (s SnapshotConfig) Location() (*time.Location, error) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil || s.Timezone == "" || s.Timezone == "Local" {
+		return nil, fmt.Errorf("snapshots.timezone must be an IANA time zone such as UTC or Europe/Berlin, got %q", s.Timezone)
+	}
+	return loc, nil
+}
+
+// LoggerOptions converts the log settings into utils.LoggerOptions
+func (l LogConfig) LoggerOptions() utils.LoggerOptions {
+	opts := utils.DefaultLoggerOptions()
+	opts.Format = strings.ToLower(l.Format)
+	if level, err := utils.ParseLogLevel(l.Level); err == nil {
+		opts.Level = level
+	}
+	opts.Sampling = l.Sampling
+	return opts
+}
+
+// envReader applies typed environment overrides and collects parse errors
+type envReader struct {
+	lookup func(string) (string, bool)
+	errs   []error
+}
+
+func (e *envReader) string(key string, dst *string) {
+	if v, ok := e.lookup(key); ok && v != "" {
+		*dst = v
+	}
+}
+
+func (e *envReader) int(key string, dst *int) {
+	if v, ok := e.lookup(key); ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid integer %q", key, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) int64(key string, dst *int64) {
+	if v, ok := e.lookup(key); ok && v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid integer %q", key, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) duration(key string, dst *time.Duration) {
+	if v, ok := e.lookup(key); ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid duration %q", key, v))
+			return
+		}
+		*dst = d
+	}
+}
+
+func (e *envReader) bool(key string, dst *bool) {
+	if v, ok := e.lookup(key); ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: invalid boolean %q", key, v))
+			return
+		}
+		*dst = b
 	}
 }
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lookupFrom(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestDefault_IsValidOnceURLIsSet(t *testing.T) {
+	cfg := Default()
+	assert.Error(t, cfg.Validate())
+
+	cfg.Database.URL = "postgres://localhost/transfersystem"
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestApplyEnv_OverridesDefaults(t *testing.T) {
+	cfg := Default()
+	err := cfg.applyEnv(lookupFrom(map[string]string{
+		"DB_URL":                   "postgres://db/transfersystem",
+		"PORT":                     "9090",
+		"DB_MAX_OPEN_CONNS":        "50",
+		"DB_CONNECT_TIMEOUT":       "2s",
+		"TRANSFER_ISOLATION_LEVEL": "repeatable_read",
+		"TRANSFER_MAX_AMOUNT":      "1000.5",
+		"TRANSFER_CURRENCY":        "EUR",
+		"LOG_SAMPLING":             "GET_ATTEMPT=100",
+		"FEATURE_METRICS":          "false",
+		"SNAPSHOT_ENABLED":         "true",
+		"SNAPSHOT_TIMEZONE":        "Europe/Berlin",
+
+		"RECONCILIATION_DATE_WINDOW_DAYS": "5",
+	}))
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, "9090", cfg.Server.Port)
+	assert.Equal(t, 50, cfg.Database.MaxOpenConns)
+	assert.Equal(t, 2*time.Second, cfg.Database.ConnectTimeout)
+	assert.Equal(t, map[string]int{"GET_ATTEMPT": 100}, cfg.Log.Sampling)
+	assert.False(t, cfg.Features.Metrics)
+	assert.Equal(t, "EUR", cfg.Transfer.Currency)
+	assert.True(t, cfg.Snapshot.Enabled)
+	assert.Equal(t, 5, cfg.Reconciliation.DateWindowDays)
+
+	loc, err := cfg.Snapshot.Location()
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", loc.String())
+
+	isolation, err := cfg.Transfer.Isolation()
+	require.NoError(t, err)
+	assert.Equal(t, sql.LevelRepeatableRead, isolation)
+
+	limit, err := cfg.Transfer.Limit()
+	require.NoError(t, err)
+	assert.Equal(t, "1000.5", limit.String())
+}
+
+func TestApplyEnv_DBURLTakesPrecedenceOverDatabaseURL(t *testing.T) {
+	cfg := Default()
+	require.NoError(t, cfg.applyEnv(lookupFrom(map[string]string{
+		"DATABASE_URL": "postgres://legacy",
+		"DB_URL":       "postgres://current",
+	})))
+	assert.Equal(t, "postgres://current", cfg.Database.URL)
+}
+
+func TestApplyEnv_ReportsParseErrors(t *testing.T) {
+	cfg := Default()
+	err := cfg.applyEnv(lookupFrom(map[string]string{
+		"DB_MAX_OPEN_CONNS":  "many",
+		"DB_CONNECT_TIMEOUT": "soon",
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DB_MAX_OPEN_CONNS")
+	assert.Contains(t, err.Error(), "DB_CONNECT_TIMEOUT")
+}
+
+func TestValidate_RejectsInconsistentValues(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "postgres://localhost/transfersystem"
+	cfg.Database.MaxOpenConns = 5
+	cfg.Database.MaxIdleConns = 10
+	cfg.Transfer.IsolationLevel = "chaos"
+	cfg.Transfer.MaxAmount = "-1"
+	cfg.Log.Format = "xml"
+	cfg.Server.Port = "http"
+	cfg.Server.ShutdownTimeout = 0
+	cfg.Server.GRPCPort = "grpc"
+	cfg.Transfer.Currency = "usd"
+	cfg.Snapshot.Timezone = "Mars/Olympus"
+	cfg.Snapshot.BatchSize = 0
+	cfg.Reconciliation.AutoMatchConfidence = 101
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, field := range []string{"max_idle_conns", "isolation_level", "max_amount", "log.format", "server.port", "shutdown_timeout", "grpc_port", "transfer.currency", "snapshots.timezone", "snapshots.batch_size", "reconciliation.auto_match_confidence"} {
+		assert.Contains(t, err.Error(), field)
+	}
+}
+
+func TestLoad_ReadsYAMLThenEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  port: "7070"
+  read_timeout: 3s
+database:
+  url: postgres://yaml/transfersystem
+  max_open_conns: 10
+  max_idle_conns: 5
+log:
+  level: debug
+`), 0o600))
+
+	t.Setenv("DB_URL", "")
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("PORT", "6060")
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "6060", cfg.Server.Port)
+	assert.Equal(t, 3*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, "postgres://yaml/transfersystem", cfg.Database.URL)
+	assert.Equal(t, 10, cfg.Database.MaxOpenConns)
+	assert.Equal(t, "debug", cfg.Log.Level)
+}
+
+func TestValidate_StorageBackend(t *testing.T) {
+	cfg := Default()
+	cfg.Storage = StorageMemory
+	assert.NoError(t, cfg.Validate(), "memory storage does not need a database URL")
+
+	cfg.Storage = "sqlite"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "storage")
+}
+
+func TestLoad_OverridesTakePrecedenceOverEnv(t *testing.T) {
+	t.Setenv("STORAGE", StoragePostgres)
+	t.Setenv("DB_URL", "")
+	t.Setenv("DATABASE_URL", "")
+
+	_, err := Load("")
+	require.Error(t, err, "postgres storage without a URL is invalid")
+
+	cfg, err := Load("", func(c *Config) { c.Storage = StorageMemory })
+	require.NoError(t, err)
+	assert.Equal(t, StorageMemory, cfg.Storage)
+}
+
+func TestApplyEnv_EmptyGRPCPortDisablesGRPC(t *testing.T) {
+	cfg := Default()
+	require.NoError(t, cfg.applyEnv(lookupFrom(map[string]string{"GRPC_PORT": ""})))
+	assert.Empty(t, cfg.Server.GRPCPort)
+
+	cfg.Database.URL = "postgres://localhost/transfersystem"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Server.GRPCPort = cfg.Server.Port
+	assert.ErrorContains(t, cfg.Validate(), "grpc_port must differ")
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	migrator, err := NewMigrator(nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrator.migrations)
+
+	for i, mig := range migrator.migrations {
+		assert.NotEmpty(t, mig.Up, "migration %d up", mig.Version)
+		assert.NotEmpty(t, mig.Down, "migration %d down", mig.Version)
+		if i > 0 {
+			assert.Greater(t, mig.Version, migrator.migrations[i-1].Version)
+		}
+	}
+}
+
+func TestLoadMigrations_Validation(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		expectedErr string
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"0002_b.up.sql":   {Data: []byte("B")},
+				"0002_b.down.sql": {Data: []byte("-B")},
+				"0001_a.up.sql":   {Data: []byte("A")},
+				"0001_a.down.sql": {Data: []byte("-A")},
+			},
+		},
+		{
+			name:        "missing down file",
+			files:       fstest.MapFS{"0001_a.up.sql": {Data: []byte("A")}},
+			expectedErr: "needs both up and down",
+		},
+		{
+			name:        "bad file name",
+			files:       fstest.MapFS{"init.sql": {Data: []byte("A")}},
+			expectedErr: "invalid migration file name",
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"0001_a.up.sql":   {Data: []byte("A")},
+				"0001_b.down.sql": {Data: []byte("-B")},
+			},
+			expectedErr: "conflicting names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := LoadMigrations(tt.files)
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, migrations, 2)
+			assert.Equal(t, int64(1), migrations[0].Version)
+			assert.Equal(t, "-A", migrations[0].Down)
+			assert.Equal(t, int64(2), migrations[1].Version)
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}}
+	now := time.Now()
+
+	versions := func(steps []planStep) (out []int64) {
+		for _, s := range steps {
+			v := s.migration.Version
+			if !s.up {
+				v = -v
+			}
+			out = append(out, v)
+		}
+		return out
+	}
+
+	t.Run("up from empty", func(t *testing.T) {
+		steps := plan(migrations, map[int64]time.Time{}, 3)
+		assert.Equal(t, []int64{1, 2, 3}, versions(steps))
+	})
+
+	t.Run("down to version 1", func(t *testing.T) {
+		applied := map[int64]time.Time{1: now, 2: now, 3: now}
+		steps := plan(migrations, applied, 1)
+		assert.Equal(t, []int64{-3, -2}, versions(steps))
+	})
+
+	t.Run("fills gaps", func(t *testing.T) {
+		applied := map[int64]time.Time{1: now, 3: now}
+		steps := plan(migrations, applied, 3)
+		assert.Equal(t, []int64{2}, versions(steps))
+	})
+
+	t.Run("already at target", func(t *testing.T) {
+		applied := map[int64]time.Time{1: now, 2: now}
+		assert.Empty(t, plan(migrations, applied, 2))
+	})
+}
@@ -18,4 +18,4 @@ CREATE TABLE IF NOT EXISTS transactions (
 -- Create indexes for better performance
 CREATE INDEX IF NOT EXISTS idx_transactions_source ON transactions(source_account_id);
 CREATE INDEX IF NOT EXISTS idx_transactions_destination ON transactions(destination_account_id);
-CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at); 
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS reversal_of;
+ALTER TABLE accounts DROP COLUMN IF EXISTS opening_balance;
+ALTER TABLE accounts DROP COLUMN IF EXISTS status;
@@ -0,0 +1,20 @@
+-- Frozen accounts can neither send nor receive transfers
+ALTER TABLE accounts
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+        CONSTRAINT accounts_status_check CHECK (status IN ('active', 'frozen'));
+
+-- The balance each account was created with, so the ledger can be
+-- reconciled: balance = opening_balance + credits - debits. Existing
+-- accounts are backfilled from their recorded transactions.
+ALTER TABLE accounts ADD COLUMN opening_balance DECIMAL(20,5);
+UPDATE accounts a SET opening_balance = a.balance
+    - COALESCE((SELECT SUM(amount) FROM transactions WHERE destination_account_id = a.account_id), 0)
+    + COALESCE((SELECT SUM(amount) FROM transactions WHERE source_account_id = a.account_id), 0);
+ALTER TABLE accounts ALTER COLUMN opening_balance SET NOT NULL;
+
+-- A reversal moves the amount of an earlier transaction back and points at
+-- it; the unique constraint stops a transaction being reversed twice
+ALTER TABLE transactions
+    ADD COLUMN reversal_of BIGINT
+        CONSTRAINT transactions_reversal_of_key UNIQUE
+        REFERENCES transactions(id);
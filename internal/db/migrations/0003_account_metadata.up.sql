@@ -0,0 +1,2 @@
+-- Caller-supplied key/value data, e.g. a partner's own account reference
+ALTER TABLE accounts ADD COLUMN metadata JSONB;
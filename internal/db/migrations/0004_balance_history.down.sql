@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transactions_destination_created;
+DROP INDEX IF EXISTS idx_transactions_source_created;
+ALTER TABLE transactions DROP COLUMN IF EXISTS destination_balance_after;
+ALTER TABLE transactions DROP COLUMN IF EXISTS source_balance_after;
+ALTER TABLE accounts DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,35 @@
+-- When each account was opened. Accounts created before this migration
+-- keep NULL: their opening date is unknown, so they count as open at any
+-- point in time.
+ALTER TABLE accounts ADD COLUMN created_at TIMESTAMPTZ;
+ALTER TABLE accounts ALTER COLUMN created_at SET DEFAULT now();
+
+-- The balance of both accounts right after each transaction, so the
+-- balance at any point in time is one index lookup instead of a sum over
+-- the account's history. Existing rows are backfilled from the opening
+-- balances; per account, transaction IDs follow the order transfers
+-- committed in because both accounts are locked while the ID is assigned.
+ALTER TABLE transactions
+    ADD COLUMN source_balance_after DECIMAL(20,5),
+    ADD COLUMN destination_balance_after DECIMAL(20,5);
+
+WITH movements AS (
+    SELECT id, destination_account_id AS account_id, amount AS delta, FALSE AS is_source FROM transactions
+    UNION ALL
+    SELECT id, source_account_id, -amount, TRUE FROM transactions
+), running AS (
+    SELECT m.id, m.is_source,
+           a.opening_balance + SUM(m.delta) OVER (PARTITION BY m.account_id ORDER BY m.id) AS balance_after
+    FROM movements m JOIN accounts a ON a.account_id = m.account_id
+)
+UPDATE transactions t SET
+    source_balance_after = (SELECT r.balance_after FROM running r WHERE r.id = t.id AND r.is_source),
+    destination_balance_after = (SELECT r.balance_after FROM running r WHERE r.id = t.id AND NOT r.is_source);
+
+ALTER TABLE transactions
+    ALTER COLUMN source_balance_after SET NOT NULL,
+    ALTER COLUMN destination_balance_after SET NOT NULL;
+
+-- Latest transaction of an account at or before a point in time
+CREATE INDEX idx_transactions_source_created ON transactions (source_account_id, created_at DESC, id DESC);
+CREATE INDEX idx_transactions_destination_created ON transactions (destination_account_id, created_at DESC, id DESC);
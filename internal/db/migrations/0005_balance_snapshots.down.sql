@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS snapshot_runs;
+DROP TABLE IF EXISTS balance_snapshots;
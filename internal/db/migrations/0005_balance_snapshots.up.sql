@@ -0,0 +1,32 @@
+-- Each account's balances and activity for one business day: the
+-- transactions created at or after the day's start and before period_end.
+-- Period bounds are TIMESTAMP, like transactions.created_at, so they compare
+-- directly. The primary key's order lets a run resume from its highest
+-- account ID.
+CREATE TABLE balance_snapshots (
+    account_id BIGINT NOT NULL REFERENCES accounts(account_id),
+    business_date DATE NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    opening_balance DECIMAL(20,5) NOT NULL,
+    closing_balance DECIMAL(20,5) NOT NULL,
+    total_debits DECIMAL(20,5) NOT NULL,
+    total_credits DECIMAL(20,5) NOT NULL,
+    debit_count INT NOT NULL,
+    credit_count INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (business_date, account_id)
+);
+
+-- An account's snapshots, latest first: period_end grows with
+-- business_date, so this also finds the latest snapshot ending at or
+-- before a point in time
+CREATE INDEX idx_balance_snapshots_account_date ON balance_snapshots (account_id, business_date DESC);
+
+-- Business days whose snapshots cover every account open at period_end
+CREATE TABLE snapshot_runs (
+    business_date DATE PRIMARY KEY,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    accounts INT NOT NULL,
+    completed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
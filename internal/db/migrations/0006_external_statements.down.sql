@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS external_statement_lines;
+DROP TABLE IF EXISTS external_statements;
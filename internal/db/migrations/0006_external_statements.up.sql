@@ -0,0 +1,36 @@
+-- Statements received from partner banks for the accounts they hold for
+-- us. account_id is the ledger account mirroring the external one. digest
+-- is the SHA-256 of the file, so a file is imported once per account.
+CREATE TABLE external_statements (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES accounts(account_id),
+    format VARCHAR(16) NOT NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    digest CHAR(64) NOT NULL,
+    imported_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (account_id, digest)
+);
+
+-- One booked entry of an external statement per row. A matched line names
+-- the transaction that accounts for it; the unique constraint keeps a
+-- transaction from accounting for two lines.
+CREATE TABLE external_statement_lines (
+    id BIGSERIAL PRIMARY KEY,
+    statement_id BIGINT NOT NULL REFERENCES external_statements(id),
+    account_id BIGINT NOT NULL REFERENCES accounts(account_id),
+    line_no INT NOT NULL,
+    booked_at TIMESTAMPTZ NOT NULL,
+    amount DECIMAL(20,5) NOT NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    transaction_id BIGINT UNIQUE REFERENCES transactions(id),
+    match_method VARCHAR(16),
+    match_confidence SMALLINT,
+    matched_at TIMESTAMPTZ,
+    UNIQUE (statement_id, line_no),
+    CHECK ((transaction_id IS NULL) = (match_method IS NULL))
+);
+
+-- The review queue: an account's unmatched lines by booking date
+CREATE INDEX idx_external_lines_unmatched ON external_statement_lines (account_id, booked_at)
+    WHERE transaction_id IS NULL;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_transactions_metadata;
+DROP INDEX IF EXISTS idx_transactions_reference;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS metadata,
+    DROP COLUMN IF EXISTS description,
+    DROP COLUMN IF EXISTS reference;
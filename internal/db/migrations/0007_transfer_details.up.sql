@@ -0,0 +1,11 @@
+-- Caller-supplied transfer details. Limits are checked by the service; the
+-- column sizes only back them up.
+ALTER TABLE transactions
+    ADD COLUMN reference VARCHAR(35),
+    ADD COLUMN description VARCHAR(140),
+    ADD COLUMN metadata JSONB;
+
+-- History filters: a reference within an account's transactions, and
+-- metadata containment (metadata @> '{"key": "value"}')
+CREATE INDEX idx_transactions_reference ON transactions (reference) WHERE reference IS NOT NULL;
+CREATE INDEX idx_transactions_metadata ON transactions USING GIN (metadata jsonb_path_ops);
@@ -0,0 +1,7 @@
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at);
+DROP INDEX IF EXISTS idx_transactions_amount_id;
+DROP INDEX IF EXISTS idx_transactions_created_id;
+DROP INDEX IF EXISTS idx_transactions_description_trgm;
+DROP INDEX IF EXISTS idx_transactions_reference_trgm;
+
+-- pg_trgm is left installed; other schemas in the database may use it
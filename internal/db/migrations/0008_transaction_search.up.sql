@@ -0,0 +1,12 @@
+-- Transaction search. pg_trgm lets the case-insensitive substring match on
+-- reference and description (ILIKE '%text%') use an index.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_transactions_reference_trgm ON transactions USING GIN (reference gin_trgm_ops);
+CREATE INDEX idx_transactions_description_trgm ON transactions USING GIN (description gin_trgm_ops);
+
+-- Keyset pagination over the whole ledger, by time or by amount. The first
+-- supersedes the single-column created_at index.
+CREATE INDEX idx_transactions_created_id ON transactions (created_at DESC, id DESC);
+CREATE INDEX idx_transactions_amount_id ON transactions (amount, id);
+DROP INDEX IF EXISTS idx_transactions_created_at;
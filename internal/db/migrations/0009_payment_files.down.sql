@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS payment_file_transfers;
+DROP TABLE IF EXISTS payment_files;
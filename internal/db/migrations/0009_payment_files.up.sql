@@ -0,0 +1,23 @@
+-- Payment files (pain.001) received on POST /transactions/import, keyed by
+-- the client's GrpHdr/MsgId so a file is executed once. digest is the
+-- SHA-256 of the first file received under the message ID. report is the
+-- pain.002 answered once every payment was processed; resubmissions get it
+-- back instead of being executed again.
+CREATE TABLE payment_files (
+    message_id TEXT PRIMARY KEY,
+    digest CHAR(64) NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    report BYTEA,
+    completed_at TIMESTAMPTZ,
+    CHECK ((report IS NULL) = (completed_at IS NULL))
+);
+
+-- The transfer booked for each payment of a file, by its position in the
+-- file. Rows are written in the transfer's own database transaction, so an
+-- interrupted file resumes without booking a payment twice.
+CREATE TABLE payment_file_transfers (
+    message_id TEXT NOT NULL REFERENCES payment_files(message_id),
+    instruction INT NOT NULL,
+    transaction_id BIGINT NOT NULL UNIQUE REFERENCES transactions(id),
+    PRIMARY KEY (message_id, instruction)
+);
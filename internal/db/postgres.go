@@ -4,27 +4,31 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"os"
-	"time"
 
 	_ "github.com/lib/pq"
+
+	"github.com/hidimpu/transfersystem/internal/config"
 )
 
-// InitDB initialises a PostgreSQL connection using the DB_URL environment variable.
-// It validates that DB_URL is set and performs an initial ping so that startup
-// fails fast if the database is unreachable.
-func InitDB() (*sql.DB, error) {
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		return nil, fmt.Errorf("DB_URL environment variable is not set")
+// InitDB initialises a PostgreSQL connection pool from the database config.
+// It applies the configured pool limits and performs an initial ping so that
+// startup fails fast if the database is unreachable.
+func InitDB(cfg config.DatabaseConfig) (*sql.DB, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("database URL is not set")
 	}
 
-	db, err := sql.Open("postgres", dbURL)
+	db, err := sql.Open("postgres", cfg.URL)
 	if err != nil {
 		return nil, fmt.Errorf("opening database connection: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/hidimpu/transfersystem/internal/metrics"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// Postgres SQLSTATE codes that indicate the unit of work can safely be retried
+const (
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+)
+
+// ErrRetryBudgetExhausted is returned (wrapped) by TxRunner.Run when every
+// attempt failed with a retryable error
+var ErrRetryBudgetExhausted = errors.New("transaction retry budget exhausted")
+
+// RetryPolicy bounds how often and how quickly a unit of work is retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first
+	MaxAttempts int
+	// BaseDelay is the backoff ceiling before the first retry; it doubles
+	// on every further retry up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// backoff returns a full-jitter delay for the given retry (1-based)
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	ceiling := p.MaxDelay
+	if retry <= 30 {
+		ceiling = p.BaseDelay << (retry - 1)
+	}
+	if ceiling <= 0 || (p.MaxDelay > 0 && ceiling > p.MaxDelay) {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// TxFunc is a unit of work executed inside a database transaction. It may be
+// invoked several times, so it must not have side effects outside tx.
+type TxFunc func(ctx context.Context, tx *sql.Tx) error
+
+// TxRunner runs units of work in database transactions and transparently
+// retries them when Postgres reports a serialization failure or deadlock
+type TxRunner struct {
+	db     *sql.DB
+	policy RetryPolicy
+	logger *utils.Logger
+}
+
+// NewTxRunner creates a transaction runner with the given retry policy
+func NewTxRunner(db *sql.DB, policy RetryPolicy) *TxRunner {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &TxRunner{db: db, policy: policy, logger: utils.GlobalLogger}
+}
+
+// DB returns the underlying connection pool
+func (r *TxRunner) DB() *sql.DB {
+	return r.db
+}
+
+// Run executes fn in a transaction with the given options and commits it.
+// Retryable failures (from fn or from COMMIT) roll back and re-run the whole
+// unit of work with jittered exponential backoff. Once the budget is spent
+// the last error is returned wrapped in ErrRetryBudgetExhausted; any other
+// error is returned as-is after rolling back.
+func (r *TxRunner) Run(ctx context.Context, operation string, opts *sql.TxOptions, fn TxFunc) error {
+	return retry(ctx, r.policy, func(attempt int) error {
+		return r.runOnce(ctx, operation, opts, fn)
+	}, func(retry int, err error, delay time.Duration) {
+		code, _ := RetryableCode(err)
+		metrics.ObserveTransactionRetry(operation)
+		r.logger.LogWarning(ctx, "TX_RETRY", fmt.Sprintf("%s: retry %d/%d after %s (SQLSTATE %s)",
+			operation, retry, r.policy.MaxAttempts-1, delay, code))
+	}, func(attempts int, err error) {
+		metrics.ObserveTransactionRetryExhausted(operation)
+		r.logger.LogError(ctx, "TX_RETRY", "RETRY_EXHAUSTED",
+			fmt.Sprintf("%s: giving up after %d attempts", operation, attempts), err)
+	})
+}
+
+// runOnce executes a single attempt: BEGIN, fn, COMMIT (or ROLLBACK)
+func (r *TxRunner) runOnce(ctx context.Context, operation string, opts *sql.TxOptions, fn TxFunc) (retErr error) {
+	logOp := strings.ToUpper(operation) + "_DB"
+
+	tx, err := r.db.BeginTx(ctx, opts)
+	if err != nil {
+		r.logger.LogError(ctx, logOp, "DB_CONNECTION_ERROR", "Failed to begin transaction", err)
+		return err
+	}
+	start := time.Now()
+
+	defer func() {
+		if code, ok := RetryableCode(retErr); ok {
+			metrics.ObserveSerializationFailure(operation, code)
+		}
+		metrics.ObserveDBTransaction(operation, start, retErr == nil)
+	}()
+
+	if err := fn(ctx, tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			r.logger.LogError(ctx, logOp, "TRANSACTION_ROLLBACK_ERROR", "Rollback failed", rbErr)
+		}
+		r.logger.LogError(ctx, logOp, "TRANSACTION_ROLLBACK", "Transaction rolled back", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.LogError(ctx, logOp, "TRANSACTION_COMMIT_ERROR", "Commit failed", err)
+		return err
+	}
+	r.logger.LogInfo(ctx, logOp, "Transaction committed successfully")
+	return nil
+}
+
+// retry calls attempt until it succeeds, fails with a non-retryable error,
+// ctx is done or policy.MaxAttempts is reached
+func retry(
+	ctx context.Context,
+	policy RetryPolicy,
+	attempt func(attempt int) error,
+	onRetry func(retry int, err error, delay time.Duration),
+	onExhausted func(attempts int, err error),
+) error {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for n := 1; ; n++ {
+		err = attempt(n)
+		if err == nil {
+			return nil
+		}
+		if _, ok := RetryableCode(err); !ok {
+			return err
+		}
+		if n >= maxAttempts {
+			onExhausted(n, err)
+			return fmt.Errorf("%w after %d attempts: %w", ErrRetryBudgetExhausted, n, err)
+		}
+
+		delay := policy.backoff(n)
+		onRetry(n, err, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// RetryableCode reports whether err (or anything it wraps) is a Postgres
+// serialization failure or deadlock, returning the SQLSTATE code
+func RetryableCode(err error) (string, bool) {
+	var pgErr *pq.Error
+	if !errors.As(err, &pgErr) {
+		return "", false
+	}
+	switch pgErr.Code {
+	case codeSerializationFailure, codeDeadlockDetected:
+		return string(pgErr.Code), true
+	}
+	return "", false
+}
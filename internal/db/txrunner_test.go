@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func noopRetry(int, error, time.Duration) {}
+func noopExhausted(int, error)            {}
+
+func TestRetryableCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		code     string
+		expected bool
+	}{
+		{name: "serialization failure", err: &pq.Error{Code: "40001"}, code: "40001", expected: true},
+		{name: "deadlock", err: &pq.Error{Code: "40P01"}, code: "40P01", expected: true},
+		{name: "wrapped", err: fmt.Errorf("debit: %w", &pq.Error{Code: "40001"}), code: "40001", expected: true},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}, expected: false},
+		{name: "plain error", err: errors.New("boom"), expected: false},
+		{name: "nil", err: nil, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, ok := RetryableCode(tt.err)
+			assert.Equal(t, tt.expected, ok)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+}
+
+func TestRetry_RetriesUntilSuccess(t *testing.T) {
+	var attempts, retries int
+	err := retry(context.Background(), RetryPolicy{MaxAttempts: 5}, func(int) error {
+		attempts++
+		if attempts < 3 {
+			return &pq.Error{Code: "40001"}
+		}
+		return nil
+	}, func(int, error, time.Duration) { retries++ }, noopExhausted)
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 2, retries)
+}
+
+func TestRetry_StopsOnNonRetryableError(t *testing.T) {
+	boom := errors.New("boom")
+	var attempts int
+	err := retry(context.Background(), RetryPolicy{MaxAttempts: 5}, func(int) error {
+		attempts++
+		return boom
+	}, noopRetry, noopExhausted)
+
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetry_ExhaustsBudget(t *testing.T) {
+	var attempts, exhausted int
+	err := retry(context.Background(), RetryPolicy{MaxAttempts: 3}, func(int) error {
+		attempts++
+		return &pq.Error{Code: "40P01"}
+	}, noopRetry, func(int, error) { exhausted++ })
+
+	assert.ErrorIs(t, err, ErrRetryBudgetExhausted)
+	var pgErr *pq.Error
+	assert.ErrorAs(t, err, &pgErr)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 1, exhausted)
+}
+
+func TestRetry_HonoursContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+
+	err := retry(ctx, policy, func(int) error {
+		return &pq.Error{Code: "40001"}
+	}, func(int, error, time.Duration) { cancel() }, noopExhausted)
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRetryPolicy_BackoffIsBounded(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for retry := 1; retry <= 10; retry++ {
+		for i := 0; i < 50; i++ {
+			delay := policy.backoff(retry)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, policy.MaxDelay)
+		}
+	}
+	assert.Equal(t, time.Duration(0), RetryPolicy{}.backoff(1))
+}
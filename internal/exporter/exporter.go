@@ -0,0 +1,158 @@
+// Package exporter encodes accounts and transactions for bulk export, one
+// record at a time, so exports of any size stream in constant memory.
+package exporter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// Format is the encoding of an export
+type Format string
+
+const (
+	// FormatCSV is RFC 4180 CSV with a header row
+	FormatCSV Format = "csv"
+	// FormatNDJSON is one JSON object per line
+	FormatNDJSON Format = "ndjson"
+)
+
+// Content types of the supported formats
+const (
+	ContentTypeCSV    = "text/csv; charset=utf-8"
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// ParseFormat accepts a format name ("csv", "ndjson", "jsonl")
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q, want csv or ndjson", s)
+	}
+}
+
+// ContentType returns the media type of f
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return ContentTypeCSV
+	}
+	return ContentTypeNDJSON
+}
+
+// Extension returns the file extension of f, including the dot
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Writer encodes records of type T. Output is buffered; call Flush to push
+// it to the underlying writer, and always once after the last record.
+type Writer[T any] struct {
+	buf   *bufio.Writer
+	csv   *csv.Writer
+	json  *json.Encoder
+	row   func(T) []string
+	count int
+}
+
+// NewAccountWriter returns a Writer for accounts. CSV columns are
+// account_id, balance, status and metadata (a JSON object).
+func NewAccountWriter(w io.Writer, f Format) *Writer[*model.Account] {
+	return newWriter(w, f, []string{"account_id", "balance", "status", "metadata"}, accountRow)
+}
+
+// NewTransactionWriter returns a Writer for transactions. CSV columns are
+// id, source_account_id, destination_account_id, amount, created_at (RFC
+// 3339, UTC), reversal_of, reference, description and metadata (a JSON
+// object).
+func NewTransactionWriter(w io.Writer, f Format) *Writer[*model.Transaction] {
+	return newWriter(w, f, []string{"id", "source_account_id", "destination_account_id", "amount", "created_at", "reversal_of",
+		"reference", "description", "metadata"}, transactionRow)
+}
+
+// newWriter buffers w and, for CSV, writes the header row so that even an
+// empty export is a valid file
+func newWriter[T any](w io.Writer, f Format, header []string, row func(T) []string) *Writer[T] {
+	ew := &Writer[T]{buf: bufio.NewWriter(w), row: row}
+	if f == FormatCSV {
+		ew.csv = csv.NewWriter(ew.buf)
+		ew.csv.Write(header)
+	} else {
+		ew.json = json.NewEncoder(ew.buf)
+	}
+	return ew
+}
+
+// Write encodes one record
+func (w *Writer[T]) Write(v T) error {
+	var err error
+	if w.csv != nil {
+		err = w.csv.Write(w.row(v))
+	} else {
+		err = w.json.Encode(v)
+	}
+	if err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+// Flush writes any buffered output to the underlying writer
+func (w *Writer[T]) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.buf.Flush()
+}
+
+// Count returns the number of records written
+func (w *Writer[T]) Count() int {
+	return w.count
+}
+
+func accountRow(acc *model.Account) []string {
+	return []string{strconv.FormatInt(acc.ID, 10), acc.Balance.String(), string(acc.Status), metadataColumn(acc.Metadata)}
+}
+
+func transactionRow(txn *model.Transaction) []string {
+	reversalOf := ""
+	if txn.ReversalOf != nil {
+		reversalOf = strconv.FormatInt(*txn.ReversalOf, 10)
+	}
+	return []string{
+		strconv.FormatInt(txn.ID, 10),
+		strconv.FormatInt(txn.SourceAccountID, 10),
+		strconv.FormatInt(txn.DestinationAccountID, 10),
+		txn.Amount.String(),
+		txn.CreatedAt.UTC().Format(time.RFC3339Nano),
+		reversalOf,
+		txn.Reference,
+		txn.Description,
+		metadataColumn(txn.Metadata),
+	}
+}
+
+// metadataColumn encodes metadata as a JSON object, empty when there is none
+func metadataColumn(m model.Metadata) string {
+	if len(m) == 0 {
+		return ""
+	}
+	// A map of strings always encodes
+	b, _ := json.Marshal(m)
+	return string(b)
+}
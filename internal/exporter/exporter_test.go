@@ -0,0 +1,88 @@
+package exporter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestTransactionWriter(t *testing.T) {
+	reversalOf := int64(1)
+	txns := []*model.Transaction{
+		{ID: 1, SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.RequireFromString("30.5"), CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{ID: 2, SourceAccountID: 2, DestinationAccountID: 1, Amount: decimal.RequireFromString("30.5"), CreatedAt: time.Date(2024, 3, 2, 0, 0, 0, 0, time.FixedZone("CET", 3600)), ReversalOf: &reversalOf,
+			TransferDetails: model.TransferDetails{Reference: "INV-7", Description: "Refund, \"late\"", Metadata: model.Metadata{"order": "A-17"}}},
+	}
+
+	tests := []struct {
+		name   string
+		format Format
+		txns   []*model.Transaction
+		want   string
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			txns:   txns,
+			want: "id,source_account_id,destination_account_id,amount,created_at,reversal_of,reference,description,metadata\n" +
+				"1,1,2,30.5,2024-03-01T12:00:00Z,,,,\n" +
+				`2,2,1,30.5,2024-03-01T23:00:00Z,1,INV-7,"Refund, ""late""","{""order"":""A-17""}"` + "\n",
+		},
+		{
+			name:   "empty csv still has a header",
+			format: FormatCSV,
+			want:   "id,source_account_id,destination_account_id,amount,created_at,reversal_of,reference,description,metadata\n",
+		},
+		{
+			name:   "ndjson",
+			format: FormatNDJSON,
+			txns:   txns[:1],
+			want:   `{"id":1,"source_account_id":1,"destination_account_id":2,"amount":"30.5","created_at":"2024-03-01T12:00:00Z"}` + "\n",
+		},
+		{
+			name:   "empty ndjson",
+			format: FormatNDJSON,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewTransactionWriter(&buf, tt.format)
+			for _, txn := range tt.txns {
+				require.NoError(t, w.Write(txn))
+			}
+			require.NoError(t, w.Flush())
+			assert.Equal(t, tt.want, buf.String())
+			assert.Equal(t, len(tt.txns), w.Count())
+		})
+	}
+}
+
+func TestAccountWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewAccountWriter(&buf, FormatCSV)
+	require.NoError(t, w.Write(&model.Account{ID: 1, Balance: decimal.NewFromInt(100), Status: model.AccountActive, Metadata: model.Metadata{"ref": "A-1"}}))
+	require.NoError(t, w.Write(&model.Account{ID: 2, Balance: decimal.Zero, Status: model.AccountFrozen}))
+
+	assert.Empty(t, buf.String(), "output is buffered until Flush")
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "account_id,balance,status,metadata\n"+
+		"1,100,active,\"{\"\"ref\"\":\"\"A-1\"\"}\"\n"+
+		"2,0,frozen,\n", buf.String())
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"csv": FormatCSV, "CSV": FormatCSV, "ndjson": FormatNDJSON, "jsonl": FormatNDJSON} {
+		got, err := ParseFormat(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, err := ParseFormat("xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// ErrorDomain is the google.rpc.ErrorInfo domain attached to every error
+const ErrorDomain = "transfersystem"
+
+// retryDelay is the RetryInfo hint sent with retryable errors, matching the
+// HTTP API's Retry-After
+const retryDelay = time.Second
+
+// codeForHTTPStatus maps the HTTP status of a domain error to a gRPC code
+func codeForHTTPStatus(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// statusError converts a service error into a gRPC status error carrying an
+// ErrorInfo detail with the stable error code, and RetryInfo when the call
+// may be retried
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var (
+		code      codes.Code
+		reason    string
+		msg       string
+		retryable bool
+	)
+	var transferErr model.TransferError
+	var accountErr model.AccountError
+	switch {
+	case errors.As(err, &transferErr):
+		code, reason, msg = codeForHTTPStatus(transferErr.HTTPStatus()), transferErr.Code(), transferErr.Error()
+		retryable = transferErr.Retryable()
+	case errors.As(err, &accountErr):
+		code, reason, msg = codeForHTTPStatus(accountErr.HTTPStatus()), accountErr.Code(), accountErr.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		code, reason, msg = codes.DeadlineExceeded, "DEADLINE_EXCEEDED", "deadline exceeded"
+	case errors.Is(err, context.Canceled):
+		code, reason, msg = codes.Canceled, "CANCELED", "request canceled"
+	default:
+		code, reason, msg = codes.Internal, "INTERNAL", "internal error"
+	}
+
+	st, detailErr := status.New(code, msg).WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: ErrorDomain, Metadata: errorMetadata(err)})
+	if detailErr != nil {
+		return status.Error(code, msg)
+	}
+	if retryable {
+		if withRetry, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryDelay)}); err == nil {
+			st = withRetry
+		}
+	}
+	return st.Err()
+}
+
+// errorMetadata renders the structured details attached to err as ErrorInfo
+// metadata, the gRPC counterpart of the HTTP problem's details
+func errorMetadata(err error) map[string]string {
+	details := model.DetailsOf(err)
+	if len(details) == 0 {
+		return nil
+	}
+	metadata := make(map[string]string, len(details))
+	for k, v := range details {
+		metadata[k] = fmt.Sprint(v)
+	}
+	return metadata
+}
+
+// invalidArgument reports a malformed request field, mirroring the HTTP
+// API's 400 responses for missing or unparseable fields
+func invalidArgument(field, description string) error {
+	st, err := status.New(codes.InvalidArgument, description).WithDetails(
+		&errdetails.ErrorInfo{Reason: "INVALID_REQUEST", Domain: ErrorDomain, Metadata: map[string]string{"field": field}},
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: description}}},
+	)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, description)
+	}
+	return st.Err()
+}
@@ -0,0 +1,228 @@
+// Package grpcapi serves the TransferService gRPC API on top of the same
+// services as the HTTP handlers in internal/api
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	pb "github.com/hidimpu/transfersystem/internal/pb/transfersystem/v1"
+	"github.com/hidimpu/transfersystem/internal/service"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// Page size bounds for ListTransactions
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// requestIDMetadataKey carries request IDs in both directions, like the
+// X-Request-ID header on the HTTP API
+const requestIDMetadataKey = "x-request-id"
+
+// Server implements pb.TransferServiceServer
+type Server struct {
+	pb.UnimplementedTransferServiceServer
+
+	accountService     service.AccountService
+	transactionService *service.TransactionService
+	logger             *utils.Logger
+}
+
+// NewServer creates the gRPC service implementation
+func NewServer(accountService service.AccountService, transactionService *service.TransactionService) *Server {
+	return &Server{
+		accountService:     accountService,
+		transactionService: transactionService,
+		logger:             utils.GlobalLogger,
+	}
+}
+
+// NewGRPCServer creates a *grpc.Server with tracing, request IDs, panic
+// recovery and error logging installed, and registers srv on it
+func NewGRPCServer(srv *Server, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(srv.requestIDInterceptor, srv.recoveryInterceptor),
+	}, opts...)
+	s := grpc.NewServer(opts...)
+	pb.RegisterTransferServiceServer(s, srv)
+	return s
+}
+
+// Shutdown stops s gracefully, waiting for in-flight calls until ctx expires
+// and then cancelling whatever is left
+func Shutdown(ctx context.Context, s *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return fmt.Errorf("draining gRPC server: %w", ctx.Err())
+	}
+}
+
+// requestIDInterceptor reuses the caller's x-request-id when it is sane,
+// generates one otherwise, and echoes it in the response header
+func (s *Server) requestIDInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIDMetadataKey); len(ids) > 0 && utils.ValidRequestID(ids[0]) {
+			requestID = ids[0]
+		}
+	}
+	if requestID == "" {
+		requestID = utils.NewRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadataKey, requestID))
+	ctx = utils.WithRequestID(ctx, requestID)
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		s.logger.LogWarning(ctx, "GRPC", fmt.Sprintf("%s failed: %s", info.FullMethod, status.Convert(err).Message()))
+	}
+	return resp, err
+}
+
+// recoveryInterceptor turns a panicking handler into an Internal error
+func (s *Server) recoveryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.LogError(ctx, "GRPC", "PANIC", info.FullMethod+" panicked", fmt.Errorf("%v", r))
+			err = statusError(fmt.Errorf("panic: %v", r))
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// CreateAccount opens an account with an initial balance
+func (s *Server) CreateAccount(ctx context.Context, req *pb.CreateAccountRequest) (*pb.CreateAccountResponse, error) {
+	if req.GetInitialBalance() == "" {
+		return nil, invalidArgument("initial_balance", "initial_balance is required")
+	}
+	balance, err := decimal.NewFromString(req.GetInitialBalance())
+	if err != nil {
+		return nil, invalidArgument("initial_balance", "invalid initial_balance format")
+	}
+
+	account := &model.Account{ID: req.GetAccountId(), Balance: balance}
+	if err := s.accountService.CreateAccount(ctx, account); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.CreateAccountResponse{Account: toPBAccount(account)}, nil
+}
+
+// GetAccount returns an account and its current balance
+func (s *Server) GetAccount(ctx context.Context, req *pb.GetAccountRequest) (*pb.GetAccountResponse, error) {
+	account, err := s.accountService.GetAccountByID(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.GetAccountResponse{Account: toPBAccount(account)}, nil
+}
+
+// Transfer atomically moves funds between two accounts
+func (s *Server) Transfer(ctx context.Context, req *pb.TransferRequest) (*pb.TransferResponse, error) {
+	if req.GetSourceAccountId() == 0 {
+		return nil, invalidArgument("source_account_id", "source_account_id is required")
+	}
+	if req.GetDestinationAccountId() == 0 {
+		return nil, invalidArgument("destination_account_id", "destination_account_id is required")
+	}
+	if req.GetAmount() == "" {
+		return nil, invalidArgument("amount", "amount is required")
+	}
+	amount, err := decimal.NewFromString(req.GetAmount())
+	if err != nil {
+		return nil, invalidArgument("amount", "invalid amount format")
+	}
+
+	txn, err := s.transactionService.TransferWithDetails(ctx, req.GetSourceAccountId(), req.GetDestinationAccountId(), amount, model.TransferDetails{
+		Reference:   req.GetReference(),
+		Description: req.GetDescription(),
+		Metadata:    req.GetMetadata(),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.TransferResponse{
+		SourceAccountId:      req.GetSourceAccountId(),
+		DestinationAccountId: req.GetDestinationAccountId(),
+		Amount:               amount.String(),
+		Transaction:          toPBTransaction(txn),
+	}, nil
+}
+
+// ListTransactions pages through an account's transactions, newest first.
+// Page tokens are opaque to callers; internally they are row offsets.
+func (s *Server) ListTransactions(ctx context.Context, req *pb.ListTransactionsRequest) (*pb.ListTransactionsResponse, error) {
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0:
+		return nil, invalidArgument("page_size", "page_size cannot be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	offset := 0
+	if token := req.GetPageToken(); token != "" {
+		n, err := strconv.Atoi(token)
+		if err != nil || n < 0 {
+			return nil, invalidArgument("page_token", "invalid page_token")
+		}
+		offset = n
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	filter := model.TransactionFilter{AccountID: req.GetAccountId(), Reference: req.GetReference(), Metadata: req.GetMetadata()}
+	txns, err := s.transactionService.GetTransactionHistory(ctx, filter, pageSize+1, offset)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &pb.ListTransactionsResponse{}
+	if len(txns) > pageSize {
+		txns = txns[:pageSize]
+		resp.NextPageToken = strconv.Itoa(offset + pageSize)
+	}
+	resp.Transactions = make([]*pb.Transaction, 0, len(txns))
+	for _, txn := range txns {
+		resp.Transactions = append(resp.Transactions, toPBTransaction(txn))
+	}
+	return resp, nil
+}
+
+func toPBTransaction(txn *model.Transaction) *pb.Transaction {
+	return &pb.Transaction{
+		Id:                   txn.ID,
+		SourceAccountId:      txn.SourceAccountID,
+		DestinationAccountId: txn.DestinationAccountID,
+		Amount:               txn.Amount.String(),
+		CreatedAt:            timestamppb.New(txn.CreatedAt),
+		Reference:            txn.Reference,
+		Description:          txn.Description,
+		Metadata:             txn.Metadata,
+	}
+}
+
+func toPBAccount(account *model.Account) *pb.Account {
+	return &pb.Account{AccountId: account.ID, Balance: account.Balance.String()}
+}
@@ -0,0 +1,247 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+	pb "github.com/hidimpu/transfersystem/internal/pb/transfersystem/v1"
+	"github.com/hidimpu/transfersystem/internal/repository/memory"
+	"github.com/hidimpu/transfersystem/internal/service"
+)
+
+// newTestClient serves the API over an in-process bufconn listener backed by
+// an in-memory store
+func newTestClient(t *testing.T) pb.TransferServiceClient {
+	t.Helper()
+
+	store := memory.NewStore()
+	srv := NewServer(
+		service.NewAccountService(store.Accounts()),
+		service.NewTransactionService(store.UnitOfWork(), store.Accounts(), store.Transactions(), config.TransferConfig{}),
+	)
+	grpcServer := NewGRPCServer(srv)
+
+	ln := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(ln)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewTransferServiceClient(conn)
+}
+
+// errorReason returns the gRPC code and ErrorInfo reason of err
+func errorReason(t *testing.T, err error) (codes.Code, string) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	require.True(t, ok, "not a status error: %v", err)
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			assert.Equal(t, ErrorDomain, info.Domain)
+			return st.Code(), info.Reason
+		}
+	}
+	t.Fatalf("no ErrorInfo detail on %v", err)
+	return 0, ""
+}
+
+func TestServer_AccountsAndTransfers(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	for _, id := range []int64{1, 2} {
+		resp, err := client.CreateAccount(ctx, &pb.CreateAccountRequest{AccountId: id, InitialBalance: "100.50"})
+		require.NoError(t, err)
+		assert.Equal(t, "100.5", resp.GetAccount().GetBalance())
+	}
+
+	var header metadata.MD
+	transfer, err := client.Transfer(metadata.AppendToOutgoingContext(ctx, "x-request-id", "req-123"),
+		&pb.TransferRequest{SourceAccountId: 1, DestinationAccountId: 2, Amount: "40.25", Reference: "INV-7", Metadata: map[string]string{"order": "A-17"}},
+		grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, "40.25", transfer.GetAmount())
+	assert.Equal(t, []string{"req-123"}, header.Get("x-request-id"))
+	assert.Equal(t, int64(1), transfer.GetTransaction().GetId())
+	assert.Equal(t, "INV-7", transfer.GetTransaction().GetReference())
+	_, err = client.Transfer(ctx, &pb.TransferRequest{SourceAccountId: 2, DestinationAccountId: 1, Amount: "1"})
+	require.NoError(t, err)
+
+	list, err := client.ListTransactions(ctx, &pb.ListTransactionsRequest{AccountId: 1, Metadata: map[string]string{"order": "A-17"}})
+	require.NoError(t, err)
+	require.Len(t, list.GetTransactions(), 1)
+	assert.Equal(t, map[string]string{"order": "A-17"}, list.GetTransactions()[0].GetMetadata())
+
+	got, err := client.GetAccount(ctx, &pb.GetAccountRequest{AccountId: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "139.75", got.GetAccount().GetBalance())
+}
+
+func TestServer_ListTransactionsPaginates(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	for _, id := range []int64{1, 2} {
+		_, err := client.CreateAccount(ctx, &pb.CreateAccountRequest{AccountId: id, InitialBalance: "100"})
+		require.NoError(t, err)
+	}
+	for i := 1; i <= 5; i++ {
+		_, err := client.Transfer(ctx, &pb.TransferRequest{SourceAccountId: 1, DestinationAccountId: 2, Amount: fmt.Sprint(i)})
+		require.NoError(t, err)
+	}
+
+	var amounts []string
+	token := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3, "expected exactly three pages")
+		resp, err := client.ListTransactions(ctx, &pb.ListTransactionsRequest{AccountId: 2, PageSize: 2, PageToken: token})
+		require.NoError(t, err)
+		for _, txn := range resp.GetTransactions() {
+			amounts = append(amounts, txn.GetAmount())
+			assert.NotNil(t, txn.GetCreatedAt())
+		}
+		if token = resp.GetNextPageToken(); token == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"5", "4", "3", "2", "1"}, amounts)
+}
+
+func TestServer_ErrorMapping(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	for _, id := range []int64{1, 2} {
+		_, err := client.CreateAccount(ctx, &pb.CreateAccountRequest{AccountId: id, InitialBalance: "10"})
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name       string
+		call       func() error
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			name: "duplicate account",
+			call: func() error {
+				_, err := client.CreateAccount(ctx, &pb.CreateAccountRequest{AccountId: 1, InitialBalance: "1"})
+				return err
+			},
+			wantCode:   codes.AlreadyExists,
+			wantReason: model.ErrAccountExists.Code(),
+		},
+		{
+			name: "unknown account",
+			call: func() error {
+				_, err := client.GetAccount(ctx, &pb.GetAccountRequest{AccountId: 99})
+				return err
+			},
+			wantCode:   codes.NotFound,
+			wantReason: model.ErrAccountNotFound.Code(),
+		},
+		{
+			name: "insufficient funds",
+			call: func() error {
+				_, err := client.Transfer(ctx, &pb.TransferRequest{SourceAccountId: 1, DestinationAccountId: 2, Amount: "11"})
+				return err
+			},
+			wantCode:   codes.FailedPrecondition,
+			wantReason: model.ErrInsufficientFunds.Code(),
+		},
+		{
+			name: "same account",
+			call: func() error {
+				_, err := client.Transfer(ctx, &pb.TransferRequest{SourceAccountId: 1, DestinationAccountId: 1, Amount: "1"})
+				return err
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: model.ErrSameAccountTransfer.Code(),
+		},
+		{
+			name: "unparseable amount",
+			call: func() error {
+				_, err := client.Transfer(ctx, &pb.TransferRequest{SourceAccountId: 1, DestinationAccountId: 2, Amount: "ten"})
+				return err
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: "INVALID_REQUEST",
+		},
+		{
+			name: "history of unknown account",
+			call: func() error {
+				_, err := client.ListTransactions(ctx, &pb.ListTransactionsRequest{AccountId: 99})
+				return err
+			},
+			wantCode:   codes.NotFound,
+			wantReason: model.ErrAccountNotFound.Code(),
+		},
+		{
+			name: "bad page token",
+			call: func() error {
+				_, err := client.ListTransactions(ctx, &pb.ListTransactionsRequest{AccountId: 1, PageToken: "next"})
+				return err
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: "INVALID_REQUEST",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reason := errorReason(t, tt.call())
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestStatusError_Metadata(t *testing.T) {
+	err := statusError(model.WithDetails(model.ErrInsufficientFunds, model.ErrorDetails{"account_id": int64(1), "available_balance": "10"}))
+
+	var info *errdetails.ErrorInfo
+	for _, d := range status.Convert(err).Details() {
+		if i, ok := d.(*errdetails.ErrorInfo); ok {
+			info = i
+		}
+	}
+	require.NotNil(t, info)
+	assert.Equal(t, map[string]string{"account_id": "1", "available_balance": "10"}, info.Metadata)
+}
+
+func TestStatusError_RetryableConflict(t *testing.T) {
+	err := statusError(fmt.Errorf("wrapped: %w", model.ErrTransferConflict))
+	st := status.Convert(err)
+	assert.Equal(t, codes.Unavailable, st.Code())
+
+	var retry *errdetails.RetryInfo
+	for _, d := range st.Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	require.NotNil(t, retry)
+	assert.Equal(t, retryDelay, retry.GetRetryDelay().AsDuration())
+
+	st = status.Convert(statusError(errors.New("connection reset")))
+	assert.Equal(t, codes.Internal, st.Code())
+	assert.Equal(t, "internal error", st.Message(), "internal causes are not leaked")
+}
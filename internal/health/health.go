@@ -0,0 +1,163 @@
+// Package health runs the readiness checks behind /readyz and reports the
+// result per component
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/lifecycle"
+)
+
+// Component and overall statuses
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc reports a component as healthy by returning nil. It must honour
+// ctx, which carries the per-check timeout.
+type CheckFunc func(ctx context.Context) error
+
+// ComponentResult is the outcome of one check
+type ComponentResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Report is the readiness breakdown returned by Checker.Run
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentResult `json:"components,omitempty"`
+}
+
+// Healthy reports whether every component passed
+func (r Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+type namedCheck struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker holds the registered readiness checks
+type Checker struct {
+	timeout time.Duration
+	checks  []namedCheck
+}
+
+// NewChecker creates a Checker that gives each check at most timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check under name, which is the key used in the report
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.checks = append(c.checks, namedCheck{name: name, fn: fn})
+}
+
+// Run executes every check concurrently and collects the results. A check
+// that does not finish within the timeout fails with a timeout error.
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Components: make(map[string]ComponentResult, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check namedCheck) {
+			defer wg.Done()
+			result := c.run(ctx, check.fn)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[check.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(check)
+	}
+	wg.Wait()
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, fn CheckFunc) ComponentResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+
+	result := ComponentResult{Status: StatusOK, DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", c.timeout)
+		}
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Draining fails once graceful shutdown has started
+func Draining(state *lifecycle.State) CheckFunc {
+	return func(context.Context) error {
+		if state.Draining() {
+			return errors.New("shutting down")
+		}
+		return nil
+	}
+}
+
+// Database pings the connection pool
+func Database(db *sql.DB) CheckFunc {
+	return db.PingContext
+}
+
+// Migrations fails while any embedded migration has not been applied
+func Migrations(pending func(ctx context.Context) (int, error)) CheckFunc {
+	return func(ctx context.Context) error {
+		n, err := pending(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%d pending migration(s)", n)
+		}
+		return nil
+	}
+}
+
+// Workers fails if any supervised background worker has stopped
+func Workers(workers *lifecycle.Workers) CheckFunc {
+	return func(context.Context) error {
+		var failed []string
+		for _, s := range workers.Status() {
+			switch {
+			case s.Err != nil:
+				failed = append(failed, fmt.Sprintf("%s: %v", s.Name, s.Err))
+			case !s.Running:
+				failed = append(failed, s.Name+": stopped")
+			}
+		}
+		if len(failed) > 0 {
+			return errors.New(strings.Join(failed, "; "))
+		}
+		return nil
+	}
+}
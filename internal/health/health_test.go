@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/lifecycle"
+)
+
+func TestChecker_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     map[string]CheckFunc
+		wantStatus string
+		wantErrors map[string]string
+	}{
+		{
+			name:       "no checks",
+			wantStatus: StatusOK,
+		},
+		{
+			name: "all healthy",
+			checks: map[string]CheckFunc{
+				"database":   func(context.Context) error { return nil },
+				"migrations": Migrations(func(context.Context) (int, error) { return 0, nil }),
+			},
+			wantStatus: StatusOK,
+		},
+		{
+			name: "one failing component",
+			checks: map[string]CheckFunc{
+				"database":   func(context.Context) error { return errors.New("connection refused") },
+				"migrations": Migrations(func(context.Context) (int, error) { return 0, nil }),
+			},
+			wantStatus: StatusFail,
+			wantErrors: map[string]string{"database": "connection refused"},
+		},
+		{
+			name: "pending migrations",
+			checks: map[string]CheckFunc{
+				"migrations": Migrations(func(context.Context) (int, error) { return 2, nil }),
+			},
+			wantStatus: StatusFail,
+			wantErrors: map[string]string{"migrations": "2 pending migration(s)"},
+		},
+		{
+			name: "check exceeding timeout",
+			checks: map[string]CheckFunc{
+				"database": func(context.Context) error { time.Sleep(time.Second); return nil },
+			},
+			wantStatus: StatusFail,
+			wantErrors: map[string]string{"database": "timed out after 20ms"},
+		},
+		{
+			name: "check honouring context",
+			checks: map[string]CheckFunc{
+				"database": func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+			},
+			wantStatus: StatusFail,
+			wantErrors: map[string]string{"database": "timed out after 20ms"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(20 * time.Millisecond)
+			for name, fn := range tt.checks {
+				c.Add(name, fn)
+			}
+
+			report := c.Run(context.Background())
+			assert.Equal(t, tt.wantStatus, report.Status)
+			require.Len(t, report.Components, len(tt.checks))
+			for name, result := range report.Components {
+				if msg, ok := tt.wantErrors[name]; ok {
+					assert.Equal(t, StatusFail, result.Status, name)
+					assert.Equal(t, msg, result.Error, name)
+				} else {
+					assert.Equal(t, StatusOK, result.Status, name)
+					assert.Empty(t, result.Error, name)
+				}
+			}
+		})
+	}
+}
+
+func TestLifecycleChecks(t *testing.T) {
+	ctx := context.Background()
+
+	state := lifecycle.NewState()
+	assert.NoError(t, Draining(state)(ctx))
+	state.StartDraining()
+	assert.EqualError(t, Draining(state)(ctx), "shutting down")
+
+	workers := lifecycle.NewWorkers()
+	workers.Go("snapshots", func(ctx context.Context) error { <-ctx.Done(); return nil })
+	assert.NoError(t, Workers(workers)(ctx))
+
+	workers.Go("exporter", func(context.Context) error { return errors.New("disk full") })
+	require.Eventually(t, func() bool { return Workers(workers)(ctx) != nil }, time.Second, time.Millisecond)
+	assert.EqualError(t, Workers(workers)(ctx), "exporter: disk full")
+
+	require.NoError(t, workers.Stop(ctx))
+}
@@ -0,0 +1,258 @@
+// Package importer parses bulk import files. Parsing never touches storage:
+// rows come back with either the parsed record or the reason the row is
+// invalid, and the services decide what to do with them.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// Format is the encoding of an import file
+type Format string
+
+const (
+	// FormatCSV is RFC 4180 CSV with a header row
+	FormatCSV Format = "csv"
+	// FormatNDJSON is one JSON object per line
+	FormatNDJSON Format = "ndjson"
+)
+
+// Content types of the supported formats
+const (
+	ContentTypeCSV    = "text/csv"
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// maxLineBytes bounds a single NDJSON line
+const maxLineBytes = 1 << 20
+
+// Account import columns. Currency and metadata are optional.
+const (
+	columnAccountID      = "account_id"
+	columnInitialBalance = "initial_balance"
+	columnCurrency       = "currency"
+	columnMetadata       = "metadata"
+)
+
+// ParseFormat accepts a format name ("csv", "ndjson", "jsonl")
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("unknown import format %q, want csv or ndjson", s)
+	}
+}
+
+// FormatFromContentType maps a Content-Type header to a format
+func FormatFromContentType(contentType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	switch mediaType {
+	case ContentTypeCSV:
+		return FormatCSV, true
+	case ContentTypeNDJSON, "application/jsonl":
+		return FormatNDJSON, true
+	default:
+		return "", false
+	}
+}
+
+// ParseAccounts reads an account import. CSV files need a header naming the
+// account_id and initial_balance columns and optionally currency and
+// metadata (a JSON object); NDJSON lines are objects with the same fields.
+// A row whose currency is set must match currency. Row-level problems are
+// reported on the row; only an unreadable file is an error, wrapping
+// model.ErrInvalidImport.
+func ParseAccounts(r io.Reader, format Format, currency string) ([]model.AccountImportRow, error) {
+	switch format {
+	case FormatCSV:
+		return parseAccountsCSV(r, currency)
+	case FormatNDJSON:
+		return parseAccountsNDJSON(r, currency)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", model.ErrInvalidImport, format)
+	}
+}
+
+func parseAccountsCSV(r io.Reader, currency string) ([]model.AccountImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, invalidImport("file is empty", nil)
+	}
+	if err != nil {
+		return nil, invalidImport(err.Error(), nil)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case columnAccountID, columnInitialBalance, columnCurrency, columnMetadata:
+		default:
+			return nil, invalidImport(fmt.Sprintf("unknown column %q", name), model.ErrorDetails{"column": name})
+		}
+		if _, dup := columns[name]; dup {
+			return nil, invalidImport(fmt.Sprintf("column %q appears twice", name), model.ErrorDetails{"column": name})
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{columnAccountID, columnInitialBalance} {
+		if _, ok := columns[required]; !ok {
+			return nil, invalidImport(fmt.Sprintf("missing column %q", required), model.ErrorDetails{"column": required})
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []model.AccountImportRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, invalidImport(parseErr.Err.Error(), model.ErrorDetails{"line": parseErr.Line})
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, invalidImport(err.Error(), nil)
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			rows = append(rows, model.AccountImportRow{Line: line, Err: model.WithDetails(model.ErrInvalidImportRow, model.ErrorDetails{
+				"reason": fmt.Sprintf("expected %d fields, got %d", len(header), len(record)),
+			})})
+			continue
+		}
+
+		row := model.AccountImportRow{Line: line}
+		row.Account, row.Err = parseAccount(
+			field(record, columnAccountID),
+			field(record, columnInitialBalance),
+			field(record, columnCurrency),
+			field(record, columnMetadata),
+			currency,
+		)
+		rows = append(rows, row)
+	}
+}
+
+// ndjsonAccount is one NDJSON line. Numbers are accepted for account_id and
+// initial_balance alongside the strings the API uses.
+type ndjsonAccount struct {
+	AccountID      json.Number     `json:"account_id"`
+	InitialBalance json.Number     `json:"initial_balance"`
+	Currency       string          `json:"currency"`
+	Metadata       json.RawMessage `json:"metadata"`
+}
+
+func parseAccountsNDJSON(r io.Reader, currency string) ([]model.AccountImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+
+	var rows []model.AccountImportRow
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var rec ndjsonAccount
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rec); err != nil {
+			rows = append(rows, model.AccountImportRow{Line: line, Err: model.WithDetails(model.ErrInvalidImportRow, model.ErrorDetails{
+				"reason": strings.TrimPrefix(err.Error(), "json: "),
+			})})
+			continue
+		}
+
+		metadata := ""
+		if len(rec.Metadata) > 0 && string(rec.Metadata) != "null" {
+			metadata = string(rec.Metadata)
+		}
+		row := model.AccountImportRow{Line: line}
+		row.Account, row.Err = parseAccount(rec.AccountID.String(), rec.InitialBalance.String(), rec.Currency, metadata, currency)
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, invalidImport(fmt.Sprintf("line longer than %d bytes", maxLineBytes), model.ErrorDetails{"line": len(rows) + 1})
+		}
+		return nil, invalidImport(err.Error(), nil)
+	}
+	return rows, nil
+}
+
+// parseAccount converts the fields of one row. Business rules (positive
+// IDs, non-negative balances, metadata limits) are left to AccountService so
+// imports and POST /accounts cannot drift apart.
+func parseAccount(id, balance, rowCurrency, metadata, currency string) (model.Account, error) {
+	var acc model.Account
+
+	if id == "" {
+		return acc, model.WithDetails(model.ErrAccountIDRequired, model.ErrorDetails{"field": columnAccountID})
+	}
+	parsedID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return acc, model.WithDetails(model.ErrInvalidImportRow, model.ErrorDetails{
+			"field": columnAccountID, "reason": "must be an integer",
+		})
+	}
+	acc.ID = parsedID
+
+	if balance == "" {
+		return acc, model.WithDetails(model.ErrInvalidBalance, model.ErrorDetails{"field": columnInitialBalance, "reason": "is required"})
+	}
+	acc.Balance, err = decimal.NewFromString(balance)
+	if err != nil {
+		return acc, model.WithDetails(model.ErrInvalidBalance, model.ErrorDetails{"field": columnInitialBalance, "reason": "must be a decimal"})
+	}
+
+	if rowCurrency != "" && !strings.EqualFold(rowCurrency, currency) {
+		return acc, model.WithDetails(model.ErrCurrencyMismatch, model.ErrorDetails{"currency": rowCurrency, "ledger_currency": currency})
+	}
+
+	if metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &acc.Metadata); err != nil {
+			return acc, model.WithDetails(model.ErrInvalidMetadata, model.ErrorDetails{
+				"field": columnMetadata, "reason": "must be a JSON object of strings",
+			})
+		}
+	}
+	return acc, nil
+}
+
+// invalidImport reports a file that cannot be read at all
+func invalidImport(reason string, details model.ErrorDetails) error {
+	if details == nil {
+		details = model.ErrorDetails{}
+	}
+	details["reason"] = reason
+	return model.WithDetails(fmt.Errorf("%w: %s", model.ErrInvalidImport, reason), details)
+}
@@ -0,0 +1,148 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestParseAccounts(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		// want lists, per row, the line and either the parsed ID and balance
+		// or the error
+		want []wantRow
+	}{
+		{
+			name:   "csv with optional columns",
+			format: FormatCSV,
+			input: "account_id,initial_balance,currency,metadata\n" +
+				"1,100.50,usd,\"{\"\"ref\"\":\"\"A-1\"\"}\"\n" +
+				"\n" +
+				"2, 0 ,,\n",
+			want: []wantRow{
+				{line: 2, id: 1, balance: "100.5", metadata: model.Metadata{"ref": "A-1"}},
+				{line: 4, id: 2, balance: "0"},
+			},
+		},
+		{
+			name:   "csv columns in any order",
+			format: FormatCSV,
+			input:  "\ufeffInitial_Balance,Account_ID\n7,3\n",
+			want:   []wantRow{{line: 2, id: 3, balance: "7"}},
+		},
+		{
+			name:   "csv row errors",
+			format: FormatCSV,
+			input: "account_id,initial_balance,currency,metadata\n" +
+				"x,1,,\n" +
+				",1,,\n" +
+				"4,lots,,\n" +
+				"5,1,EUR,\n" +
+				"6,1,,[1]\n" +
+				"7,1\n",
+			want: []wantRow{
+				{line: 2, err: model.ErrInvalidImportRow},
+				{line: 3, err: model.ErrAccountIDRequired},
+				{line: 4, err: model.ErrInvalidBalance},
+				{line: 5, err: model.ErrCurrencyMismatch},
+				{line: 6, err: model.ErrInvalidMetadata},
+				{line: 7, err: model.ErrInvalidImportRow},
+			},
+		},
+		{
+			name:   "ndjson",
+			format: FormatNDJSON,
+			input: `{"account_id": 1, "initial_balance": "100.50", "currency": "USD", "metadata": {"ref": "A-1"}}` + "\n" +
+				"\n" +
+				`{"account_id": "2", "initial_balance": 3}` + "\n" +
+				`{"account_id": 3, "initial_balance": "1", "colour": "red"}` + "\n" +
+				`{"account_id": 4,` + "\n" +
+				`{"account_id": 5}` + "\n" +
+				`{"account_id": 6, "initial_balance": "1", "metadata": {"n": 1}}`,
+			want: []wantRow{
+				{line: 1, id: 1, balance: "100.5", metadata: model.Metadata{"ref": "A-1"}},
+				{line: 3, id: 2, balance: "3"},
+				{line: 4, err: model.ErrInvalidImportRow},
+				{line: 5, err: model.ErrInvalidImportRow},
+				{line: 6, err: model.ErrInvalidBalance},
+				{line: 7, err: model.ErrInvalidMetadata},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ParseAccounts(strings.NewReader(tt.input), tt.format, "USD")
+			require.NoError(t, err)
+			require.Len(t, rows, len(tt.want))
+			for i, want := range tt.want {
+				row := rows[i]
+				assert.Equal(t, want.line, row.Line, "row %d", i)
+				if want.err != nil {
+					assert.ErrorIs(t, row.Err, want.err, "line %d", want.line)
+					continue
+				}
+				require.NoError(t, row.Err, "line %d", want.line)
+				assert.Equal(t, want.id, row.Account.ID)
+				assert.Equal(t, want.balance, row.Account.Balance.String())
+				assert.Equal(t, want.metadata, row.Account.Metadata)
+			}
+		})
+	}
+}
+
+type wantRow struct {
+	line     int
+	id       int64
+	balance  string
+	metadata model.Metadata
+	err      error
+}
+
+func TestParseAccounts_UnreadableFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+	}{
+		{"empty csv", FormatCSV, ""},
+		{"unknown column", FormatCSV, "account_id,initial_balance,colour\n"},
+		{"missing column", FormatCSV, "account_id\n1\n"},
+		{"duplicate column", FormatCSV, "account_id,initial_balance,account_id\n"},
+		{"bad quoting", FormatCSV, "account_id,initial_balance\n1,\"2\n"},
+		{"ndjson line too long", FormatNDJSON, strings.Repeat(" ", maxLineBytes+1)},
+		{"unknown format", Format("xml"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAccounts(strings.NewReader(tt.input), tt.format, "USD")
+			assert.ErrorIs(t, err, model.ErrInvalidImport)
+		})
+	}
+}
+
+func TestFormatFromContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        Format
+		ok          bool
+	}{
+		{"text/csv", FormatCSV, true},
+		{"text/csv; charset=utf-8", FormatCSV, true},
+		{"application/x-ndjson", FormatNDJSON, true},
+		{"application/json", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := FormatFromContentType(tt.contentType)
+		assert.Equal(t, tt.want, got, tt.contentType)
+		assert.Equal(t, tt.ok, ok, tt.contentType)
+	}
+}
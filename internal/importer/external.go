@@ -0,0 +1,291 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// FormatCAMT053 is an ISO 20022 camt.053 bank-to-customer statement
+const FormatCAMT053 Format = "camt053"
+
+// camt053Namespace prefixes the namespace of every camt.053 version
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001."
+
+// External statement columns. Reference, description and currency are
+// optional.
+const (
+	columnBookingDate = "booking_date"
+	columnAmount      = "amount"
+	columnReference   = "reference"
+	columnDescription = "description"
+)
+
+// ExternalFormatFromContentType maps the Content-Type of an external
+// statement to its format: CSV, or camt.053 for XML
+func ExternalFormatFromContentType(mediaType string) (Format, bool) {
+	switch mediaType {
+	case ContentTypeCSV:
+		return FormatCSV, true
+	case ContentTypeXML, "text/xml":
+		return FormatCAMT053, true
+	default:
+		return "", false
+	}
+}
+
+// ParseExternalStatement reads a statement received from a partner bank,
+// in CSV or camt.053. Unlike account imports a statement is taken whole or
+// not at all: a line that cannot be read, or whose currency is not
+// currency, fails the file with model.ErrInvalidImport naming the line.
+// The returned statement carries the SHA-256 of the file in Digest.
+func ParseExternalStatement(r io.Reader, format Format, currency string) (*model.ExternalStatement, error) {
+	h := sha256.New()
+	tee := io.TeeReader(r, h)
+
+	var st *model.ExternalStatement
+	var err error
+	switch format {
+	case FormatCSV:
+		st, err = parseExternalCSV(tee, currency)
+	case FormatCAMT053:
+		st, err = parseCAMT053(tee, currency)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", model.ErrInvalidImport, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The XML decoder may stop before the end of the file
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return nil, err
+	}
+	if len(st.Lines) == 0 {
+		return nil, invalidImport("statement has no entries", nil)
+	}
+	st.Format = string(format)
+	st.Digest = hex.EncodeToString(h.Sum(nil))
+	return st, nil
+}
+
+// parseExternalCSV reads a CSV statement with a header row. booking_date is
+// a YYYY-MM-DD date or an RFC 3339 timestamp and amount is signed,
+// negative for debits.
+func parseExternalCSV(r io.Reader, currency string) (*model.ExternalStatement, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, invalidImport("file is empty", nil)
+	}
+	if err != nil {
+		return nil, invalidImport(err.Error(), nil)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case columnBookingDate, columnAmount, columnReference, columnDescription, columnCurrency:
+		default:
+			return nil, invalidImport(fmt.Sprintf("unknown column %q", name), model.ErrorDetails{"column": name})
+		}
+		if _, dup := columns[name]; dup {
+			return nil, invalidImport(fmt.Sprintf("column %q appears twice", name), model.ErrorDetails{"column": name})
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{columnBookingDate, columnAmount} {
+		if _, ok := columns[required]; !ok {
+			return nil, invalidImport(fmt.Sprintf("missing column %q", required), model.ErrorDetails{"column": required})
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	st := &model.ExternalStatement{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return st, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, invalidImport(parseErr.Err.Error(), model.ErrorDetails{"line": parseErr.Line})
+		}
+		if err != nil {
+			return nil, invalidImport(err.Error(), nil)
+		}
+		line, _ := cr.FieldPos(0)
+		bookedAt, err := parseBookingDate(field(record, columnBookingDate))
+		if err != nil {
+			return nil, invalidImport("booking_date must be a YYYY-MM-DD date or an RFC 3339 timestamp", model.ErrorDetails{"line": line})
+		}
+		amount, err := decimal.NewFromString(field(record, columnAmount))
+		if err != nil || amount.IsZero() {
+			return nil, invalidImport("amount must be a non-zero decimal", model.ErrorDetails{"line": line})
+		}
+		if c := field(record, columnCurrency); c != "" && !strings.EqualFold(c, currency) {
+			return nil, invalidImport("currency does not match the ledger currency", model.ErrorDetails{"line": line, "currency": c})
+		}
+		st.Lines = append(st.Lines, &model.ExternalLine{
+			LineNo:      len(st.Lines) + 1,
+			BookedAt:    bookedAt,
+			Amount:      amount,
+			Reference:   field(record, columnReference),
+			Description: field(record, columnDescription),
+		})
+	}
+}
+
+// camt053Document holds the parts of a camt.053 BankToCustomerStatement
+// that reconciliation needs. Element names are the same in every version
+// from camt.053.001.02 on.
+type camt053Document struct {
+	XMLName   xml.Name
+	Statement *struct {
+		MsgID string `xml:"GrpHdr>MsgId"`
+		Stmt  []struct {
+			Ntry []camt053ImportEntry `xml:"Ntry"`
+		} `xml:"Stmt"`
+	} `xml:"BkToCstmrStmt"`
+}
+
+type camt053ImportEntry struct {
+	NtryRef string `xml:"NtryRef"`
+	Amt     struct {
+		Ccy   string `xml:"Ccy,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"Amt"`
+	CdtDbtInd string `xml:"CdtDbtInd"`
+	// Sts is a code up to camt.053.001.04 and a Cd element from .05 on
+	Sts struct {
+		Value string `xml:",chardata"`
+		Cd    string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookgDt struct {
+		Dt   string `xml:"Dt"`
+		DtTm string `xml:"DtTm"`
+	} `xml:"BookgDt"`
+	AcctSvcrRef  string   `xml:"AcctSvcrRef"`
+	EndToEndID   []string `xml:"NtryDtls>TxDtls>Refs>EndToEndId"`
+	Ustrd        []string `xml:"NtryDtls>TxDtls>RmtInf>Ustrd"`
+	AddtlNtryInf string   `xml:"AddtlNtryInf"`
+}
+
+// parseCAMT053 reads the booked entries of every Stmt in a camt.053 file;
+// pending and information-only entries are skipped. A line's reference
+// lists the entry's end-to-end IDs, NtryRef and AcctSvcrRef, and its
+// description is AddtlNtryInf or else the unstructured remittance
+// information.
+func parseCAMT053(r io.Reader, currency string) (*model.ExternalStatement, error) {
+	var doc camt053Document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		var syntaxErr *xml.SyntaxError
+		var unmarshalErr xml.UnmarshalError
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil, invalidImport("file has no XML document", nil)
+		case errors.As(err, &syntaxErr), errors.As(err, &unmarshalErr):
+			return nil, invalidImport(err.Error(), nil)
+		default:
+			return nil, err
+		}
+	}
+	if doc.XMLName.Local != "Document" || !strings.HasPrefix(doc.XMLName.Space, camt053Namespace) || doc.Statement == nil {
+		return nil, invalidImport("not a camt.053 BankToCustomerStatement", model.ErrorDetails{"namespace": doc.XMLName.Space})
+	}
+
+	st := &model.ExternalStatement{Reference: strings.TrimSpace(doc.Statement.MsgID)}
+	n := 0
+	for _, stmt := range doc.Statement.Stmt {
+		for _, e := range stmt.Ntry {
+			n++
+			status := strings.TrimSpace(e.Sts.Cd)
+			if status == "" {
+				status = strings.TrimSpace(e.Sts.Value)
+			}
+			if status != "BOOK" {
+				continue
+			}
+			line, err := camt053Line(e, n, currency)
+			if err != nil {
+				return nil, err
+			}
+			line.LineNo = len(st.Lines) + 1
+			st.Lines = append(st.Lines, line)
+		}
+	}
+	return st, nil
+}
+
+// camt053Line converts the nth entry of the file
+func camt053Line(e camt053ImportEntry, n int, currency string) (*model.ExternalLine, error) {
+	bookedAt, err := parseBookingDate(e.BookgDt.DtTm)
+	if e.BookgDt.DtTm == "" {
+		bookedAt, err = parseBookingDate(e.BookgDt.Dt)
+	}
+	if err != nil {
+		return nil, invalidImport("BookgDt must be an ISO 8601 date or date and time", model.ErrorDetails{"entry": n})
+	}
+	amount, err := decimal.NewFromString(strings.TrimSpace(e.Amt.Value))
+	if err != nil || !amount.IsPositive() {
+		return nil, invalidImport("Amt must be a positive decimal", model.ErrorDetails{"entry": n})
+	}
+	if !strings.EqualFold(e.Amt.Ccy, currency) {
+		return nil, invalidImport("currency does not match the ledger currency", model.ErrorDetails{"entry": n, "currency": e.Amt.Ccy})
+	}
+	switch strings.TrimSpace(e.CdtDbtInd) {
+	case "CRDT":
+	case "DBIT":
+		amount = amount.Neg()
+	default:
+		return nil, invalidImport("CdtDbtInd must be CRDT or DBIT", model.ErrorDetails{"entry": n})
+	}
+
+	var refs []string
+	seen := make(map[string]bool)
+	candidates := append([]string{}, e.EndToEndID...)
+	for _, ref := range append(candidates, e.NtryRef, e.AcctSvcrRef) {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || ref == "NOTPROVIDED" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+	description := strings.TrimSpace(e.AddtlNtryInf)
+	if description == "" {
+		description = strings.TrimSpace(strings.Join(e.Ustrd, " "))
+	}
+	return &model.ExternalLine{
+		BookedAt:    bookedAt,
+		Amount:      amount,
+		Reference:   strings.Join(refs, " "),
+		Description: description,
+	}, nil
+}
+
+// parseBookingDate accepts a date, taken as midnight UTC, or a date and
+// time with or without a zone offset
+func parseBookingDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return parseISODateTime(s)
+}
@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/statement"
+)
+
+func TestParseExternalStatement_CSV(t *testing.T) {
+	body := "\ufeffBooking_Date,amount,reference,description,currency\n" +
+		"2026-03-30,-250.00,TXN-17,\"Invoice 2026-114\",usd\n" +
+		"2026-03-31T16:45:00+02:00,99.95,,Card settlement,\n"
+	st, err := ParseExternalStatement(strings.NewReader(body), FormatCSV, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "csv", st.Format)
+	assert.Len(t, st.Digest, 64)
+	require.Len(t, st.Lines, 2)
+	assert.Equal(t, model.ExternalLine{
+		LineNo: 1, BookedAt: time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC), Amount: decimal.RequireFromString("-250.00"),
+		Reference: "TXN-17", Description: "Invoice 2026-114",
+	}, *st.Lines[0])
+	assert.True(t, st.Lines[1].BookedAt.Equal(time.Date(2026, 3, 31, 14, 45, 0, 0, time.UTC)))
+
+	again, err := ParseExternalStatement(strings.NewReader(body), FormatCSV, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, st.Digest, again.Digest, "the digest identifies the file")
+}
+
+func TestParseExternalStatement_CAMT053(t *testing.T) {
+	f, err := os.Open("testdata/camt.053.001.08.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	st, err := ParseExternalStatement(f, FormatCAMT053, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "camt053", st.Format)
+	assert.Equal(t, "STMT-2026-03-31", st.Reference)
+	require.Len(t, st.Lines, 2, "the pending entry is skipped")
+	assert.Equal(t, model.ExternalLine{
+		LineNo: 1, BookedAt: time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC), Amount: decimal.RequireFromString("-250.00"),
+		Reference: "TXN-17 BANK-9001", Description: "Invoice 2026-114",
+	}, *st.Lines[0])
+	assert.Equal(t, 2, st.Lines[1].LineNo)
+	assert.Equal(t, "99.95", st.Lines[1].Amount.String())
+	assert.Equal(t, "E2E-5", st.Lines[1].Reference)
+	assert.Equal(t, "Card settlement", st.Lines[1].Description)
+}
+
+// A camt.053 export of this system can be imported back, with each line
+// referencing its transaction
+func TestParseExternalStatement_OwnExport(t *testing.T) {
+	at := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	require.NoError(t, statement.Render(&buf, statement.FormatCAMT053, &model.Statement{
+		AccountID: 1, Currency: "USD", From: at.AddDate(0, 0, -1), To: at.AddDate(0, 0, 1), GeneratedAt: at,
+		OpeningBalance: decimal.NewFromInt(100), ClosingBalance: decimal.NewFromInt(90),
+		TotalDebits: decimal.NewFromInt(10), TotalCredits: decimal.Zero,
+		Entries: []model.StatementEntry{
+			{TransactionID: 7, BookedAt: at, CounterpartyAccountID: 2, Amount: decimal.NewFromInt(-10), Balance: decimal.NewFromInt(90)},
+		},
+	}))
+
+	st, err := ParseExternalStatement(&buf, FormatCAMT053, "USD")
+	require.NoError(t, err)
+	require.Len(t, st.Lines, 1)
+	assert.Equal(t, "-10", st.Lines[0].Amount.String())
+	assert.Equal(t, "7", st.Lines[0].Reference)
+	assert.True(t, st.Lines[0].BookedAt.Equal(at))
+}
+
+func TestParseExternalStatement_Invalid(t *testing.T) {
+	camt := func(entry string) string {
+		return `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"><BkToCstmrStmt><GrpHdr><MsgId>M</MsgId></GrpHdr>
+			<Stmt>` + entry + `</Stmt></BkToCstmrStmt></Document>`
+	}
+	tests := []struct {
+		name        string
+		format      Format
+		body        string
+		wantDetails model.ErrorDetails
+	}{
+		{"empty file", FormatCSV, "", nil},
+		{"no entries", FormatCSV, "booking_date,amount\n", nil},
+		{"missing column", FormatCSV, "booking_date\n2026-03-30\n", model.ErrorDetails{"column": "amount"}},
+		{"unknown column", FormatCSV, "booking_date,amount,iban\n", model.ErrorDetails{"column": "iban"}},
+		{"bad date", FormatCSV, "booking_date,amount\n2026-03-30,1\n30/03/2026,1\n", model.ErrorDetails{"line": 3}},
+		{"zero amount", FormatCSV, "booking_date,amount\n2026-03-30,0\n", model.ErrorDetails{"line": 2}},
+		{"other currency", FormatCSV, "booking_date,amount,currency\n2026-03-30,1,EUR\n", model.ErrorDetails{"line": 2, "currency": "EUR"}},
+		{"not XML", FormatCAMT053, "booking_date,amount\n", nil},
+		{"other message", FormatCAMT053, `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"/>`,
+			model.ErrorDetails{"namespace": "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"}},
+		{"negative amount", FormatCAMT053, camt(`<Ntry><Amt Ccy="USD">-1</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts><BookgDt><Dt>2026-03-30</Dt></BookgDt></Ntry>`),
+			model.ErrorDetails{"entry": 1}},
+		{"missing indicator", FormatCAMT053, camt(`<Ntry><Amt Ccy="USD">1</Amt><Sts>BOOK</Sts><BookgDt><Dt>2026-03-30</Dt></BookgDt></Ntry>`),
+			model.ErrorDetails{"entry": 1}},
+		{"only pending entries", FormatCAMT053, camt(`<Ntry><Amt Ccy="USD">1</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>PDNG</Sts></Ntry>`), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExternalStatement(strings.NewReader(tt.body), tt.format, "USD")
+			require.ErrorIs(t, err, model.ErrInvalidImport)
+			for k, v := range tt.wantDetails {
+				assert.Equal(t, v, model.DetailsOf(err)[k], k)
+			}
+		})
+	}
+}
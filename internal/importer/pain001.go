@@ -0,0 +1,225 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// ContentTypeXML is the content type of ISO 20022 payment files
+const ContentTypeXML = "application/xml"
+
+// pain001Namespace prefixes the namespace of every pain.001 version
+const pain001Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001."
+
+// pain001Document holds the parts of a pain.001 CustomerCreditTransferInitiation
+// that map to transfers. Element names are the same in every version from
+// pain.001.001.03 on, so one layout reads them all.
+type pain001Document struct {
+	XMLName    xml.Name
+	Initiation *struct {
+		GrpHdr struct {
+			MsgID   string `xml:"MsgId"`
+			CreDtTm string `xml:"CreDtTm"`
+			NbOfTxs string `xml:"NbOfTxs"`
+			CtrlSum string `xml:"CtrlSum"`
+		} `xml:"GrpHdr"`
+		PmtInf []struct {
+			PmtInfID    string         `xml:"PmtInfId"`
+			NbOfTxs     string         `xml:"NbOfTxs"`
+			CtrlSum     string         `xml:"CtrlSum"`
+			DbtrAcct    pain001Account `xml:"DbtrAcct"`
+			CdtTrfTxInf []struct {
+				InstrID    string         `xml:"PmtId>InstrId"`
+				EndToEndID string         `xml:"PmtId>EndToEndId"`
+				InstdAmt   *pain001Amount `xml:"Amt>InstdAmt"`
+				CdtrAcct   pain001Account `xml:"CdtrAcct"`
+				Ustrd      []string       `xml:"RmtInf>Ustrd"`
+			} `xml:"CdtTrfTxInf"`
+		} `xml:"PmtInf"`
+	} `xml:"CstmrCdtTrfInitn"`
+}
+
+// pain001Account is a CashAccount identification. Accounts are identified
+// by their ID under Othr; IBANs are read only to be rejected.
+type pain001Account struct {
+	IBAN  string `xml:"Id>IBAN"`
+	Other string `xml:"Id>Othr>Id"`
+}
+
+// pain001Amount is an ActiveOrHistoricCurrencyAndAmount
+type pain001Amount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ParsePain001 reads an ISO 20022 pain.001 credit-transfer initiation. Each
+// CdtTrfTxInf becomes one instruction moving its InstdAmt from the payment
+// information's DbtrAcct to its CdtrAcct. Instructions that cannot be
+// turned into a transfer (an unknown account scheme, an amount that is not
+// a decimal or not in currency) carry the reason in Err. A NbOfTxs or
+// CtrlSum that does not match the instructions, in the group header or in
+// a payment information block, sets the batch's Err. Only a file that is
+// not a readable pain.001 is an error, wrapping model.ErrInvalidImport.
+// The returned batch carries the SHA-256 of the file in Digest.
+func ParsePain001(r io.Reader, currency string) (*model.PaymentBatch, error) {
+	h := sha256.New()
+	tee := io.TeeReader(r, h)
+
+	var doc pain001Document
+	if err := xml.NewDecoder(tee).Decode(&doc); err != nil {
+		var syntaxErr *xml.SyntaxError
+		var unmarshalErr xml.UnmarshalError
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil, invalidImport("file has no XML document", nil)
+		case errors.As(err, &syntaxErr), errors.As(err, &unmarshalErr):
+			return nil, invalidImport(err.Error(), nil)
+		default:
+			// Read failures, such as a body over the size limit, are
+			// returned as is
+			return nil, err
+		}
+	}
+	if doc.XMLName.Local != "Document" || !strings.HasPrefix(doc.XMLName.Space, pain001Namespace) || doc.Initiation == nil {
+		return nil, invalidImport("not a pain.001 CustomerCreditTransferInitiation", model.ErrorDetails{"namespace": doc.XMLName.Space})
+	}
+	// The XML decoder may stop before the end of the file
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return nil, err
+	}
+	hdr := doc.Initiation.GrpHdr
+	if strings.TrimSpace(hdr.MsgID) == "" {
+		return nil, invalidImport("GrpHdr/MsgId is required", nil)
+	}
+	if strings.TrimSpace(hdr.NbOfTxs) == "" {
+		return nil, invalidImport("GrpHdr/NbOfTxs is required", nil)
+	}
+	createdAt, err := parseISODateTime(hdr.CreDtTm)
+	if err != nil {
+		return nil, invalidImport("GrpHdr/CreDtTm must be an ISO 8601 date and time", model.ErrorDetails{"value": hdr.CreDtTm})
+	}
+
+	batch := &model.PaymentBatch{
+		MessageID:   strings.TrimSpace(hdr.MsgID),
+		MessageName: strings.TrimPrefix(doc.XMLName.Space, "urn:iso:std:iso:20022:tech:xsd:"),
+		Digest:      hex.EncodeToString(h.Sum(nil)),
+		CreatedAt:   createdAt,
+	}
+	total := decimal.Zero
+	for _, info := range doc.Initiation.PmtInf {
+		infoTotal := decimal.Zero
+		for _, tx := range info.CdtTrfTxInf {
+			p := model.PaymentInstruction{
+				PaymentInfoID: strings.TrimSpace(info.PmtInfID),
+				InstructionID: strings.TrimSpace(tx.InstrID),
+				EndToEndID:    strings.TrimSpace(tx.EndToEndID),
+			}
+			if len(tx.Ustrd) > 0 {
+				p.RemittanceInfo = strings.TrimSpace(tx.Ustrd[0])
+			}
+			if tx.InstdAmt != nil {
+				if amount, err := decimal.NewFromString(strings.TrimSpace(tx.InstdAmt.Value)); err == nil {
+					p.Amount = amount
+					infoTotal = infoTotal.Add(amount)
+				}
+			}
+			p.Err = parsePayment(&p, info.DbtrAcct, tx.CdtrAcct, tx.InstdAmt, currency)
+			batch.Payments = append(batch.Payments, p)
+		}
+		total = total.Add(infoTotal)
+		if err := checkTotals(info.NbOfTxs, info.CtrlSum, len(info.CdtTrfTxInf), infoTotal); err != nil && batch.Err == nil {
+			batch.Err = model.WithDetails(err, model.ErrorDetails{"payment_info_id": info.PmtInfID})
+		}
+	}
+	if len(batch.Payments) == 0 {
+		return nil, invalidImport("file has no CdtTrfTxInf", nil)
+	}
+	if err := checkTotals(hdr.NbOfTxs, hdr.CtrlSum, len(batch.Payments), total); err != nil && batch.Err == nil {
+		batch.Err = err
+	}
+	return batch, nil
+}
+
+// parsePayment fills in the accounts of p and checks its amount. Business
+// rules (positive amounts, distinct accounts) are left to
+// TransactionService, as for POST /transactions.
+func parsePayment(p *model.PaymentInstruction, debtor, creditor pain001Account, amount *pain001Amount, currency string) error {
+	var err error
+	if p.SourceAccountID, err = parseAccountID("DbtrAcct", debtor); err != nil {
+		return err
+	}
+	if p.DestinationAccountID, err = parseAccountID("CdtrAcct", creditor); err != nil {
+		return err
+	}
+	if amount == nil {
+		return model.WithDetails(model.ErrNegativeAmount, model.ErrorDetails{"field": "InstdAmt", "reason": "is required"})
+	}
+	if _, err := decimal.NewFromString(strings.TrimSpace(amount.Value)); err != nil {
+		return model.WithDetails(model.ErrNegativeAmount, model.ErrorDetails{"field": "InstdAmt", "reason": "must be a decimal"})
+	}
+	if !strings.EqualFold(amount.Ccy, currency) {
+		return model.WithDetails(model.ErrCurrencyMismatch, model.ErrorDetails{"currency": amount.Ccy, "ledger_currency": currency})
+	}
+	return nil
+}
+
+// parseAccountID reads an account ID from Id/Othr/Id
+func parseAccountID(field string, acc pain001Account) (int64, error) {
+	reason := ""
+	switch {
+	case acc.IBAN != "":
+		reason = "IBANs are not supported, use Othr/Id"
+	case strings.TrimSpace(acc.Other) == "":
+		reason = "Id/Othr/Id is required"
+	default:
+		id, err := strconv.ParseInt(strings.TrimSpace(acc.Other), 10, 64)
+		if err == nil && id > 0 {
+			return id, nil
+		}
+		reason = "Id/Othr/Id must be a positive integer"
+	}
+	return 0, model.WithDetails(model.ErrInvalidAccountIDs, model.ErrorDetails{"field": field, "reason": reason})
+}
+
+// checkTotals compares the declared NbOfTxs and CtrlSum, where present,
+// with the instructions actually found
+func checkTotals(declaredCount, declaredSum string, count int, sum decimal.Decimal) error {
+	declaredCount, declaredSum = strings.TrimSpace(declaredCount), strings.TrimSpace(declaredSum)
+	if declaredCount != "" {
+		if n, err := strconv.Atoi(declaredCount); err != nil || n != count {
+			return model.WithDetails(model.ErrInvalidNumberOfTransactions, model.ErrorDetails{"declared": declaredCount, "actual": count})
+		}
+	}
+	if declaredSum == "" {
+		return nil
+	}
+	if d, err := decimal.NewFromString(declaredSum); err != nil || !d.Equal(sum) {
+		return model.WithDetails(model.ErrInvalidControlSum, model.ErrorDetails{"declared": declaredSum, "actual": sum.String()})
+	}
+	return nil
+}
+
+// parseISODateTime parses an xs:dateTime, with or without a zone offset;
+// times without one are taken as UTC
+func parseISODateTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02T15:04:05.999999999", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date and time %q", s)
+	}
+	return t, nil
+}
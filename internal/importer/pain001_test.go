@@ -0,0 +1,110 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestParsePain001(t *testing.T) {
+	f, err := os.Open("testdata/pain.001.001.03.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	batch, err := ParsePain001(f, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "BATCH-2026-03-31-1", batch.MessageID)
+	assert.Equal(t, "pain.001.001.03", batch.MessageName)
+	assert.Equal(t, time.Date(2026, 3, 31, 9, 15, 0, 0, time.UTC), batch.CreatedAt)
+	assert.NoError(t, batch.Err)
+	raw, err := os.ReadFile("testdata/pain.001.001.03.xml")
+	require.NoError(t, err)
+	sum := sha256.Sum256(raw)
+	assert.Equal(t, hex.EncodeToString(sum[:]), batch.Digest, "the digest covers the whole file")
+
+	require.Len(t, batch.Payments, 4)
+	first := batch.Payments[0]
+	assert.Equal(t, "SALARIES", first.PaymentInfoID)
+	assert.Equal(t, "I-1", first.InstructionID)
+	assert.Equal(t, "E2E-1", first.EndToEndID)
+	assert.Equal(t, "Salary March 2026", first.RemittanceInfo)
+	assert.Equal(t, int64(1), first.SourceAccountID)
+	assert.Equal(t, int64(2), first.DestinationAccountID)
+	assert.Equal(t, "100.25", first.Amount.String())
+	assert.NoError(t, first.Err)
+
+	assert.ErrorIs(t, batch.Payments[1].Err, model.ErrInvalidAccountIDs, "IBAN")
+	assert.Equal(t, model.ErrorDetails{"field": "CdtrAcct", "reason": "IBANs are not supported, use Othr/Id"}, model.DetailsOf(batch.Payments[1].Err))
+	assert.ErrorIs(t, batch.Payments[2].Err, model.ErrCurrencyMismatch)
+
+	last := batch.Payments[3]
+	assert.Equal(t, "REFUNDS", last.PaymentInfoID)
+	assert.Equal(t, int64(2), last.SourceAccountID)
+	assert.NoError(t, last.Err)
+}
+
+func TestParsePain001_Totals(t *testing.T) {
+	doc := func(grpHdr, pmtInf string) string {
+		return `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.09"><CstmrCdtTrfInitn>
+			<GrpHdr><MsgId>M</MsgId><CreDtTm>2026-03-31T09:15:00+02:00</CreDtTm>` + grpHdr + `</GrpHdr>
+			<PmtInf><PmtInfId>P</PmtInfId>` + pmtInf + `<DbtrAcct><Id><Othr><Id>1</Id></Othr></Id></DbtrAcct>
+				<CdtTrfTxInf><PmtId><EndToEndId>E</EndToEndId></PmtId><Amt><InstdAmt Ccy="USD">1.5</InstdAmt></Amt>
+				<CdtrAcct><Id><Othr><Id>2</Id></Othr></Id></CdtrAcct></CdtTrfTxInf>
+			</PmtInf></CstmrCdtTrfInitn></Document>`
+	}
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{"matching totals", doc("<NbOfTxs>1</NbOfTxs><CtrlSum>1.50</CtrlSum>", "<NbOfTxs>1</NbOfTxs>"), nil},
+		{"group count", doc("<NbOfTxs>2</NbOfTxs>", ""), model.ErrInvalidNumberOfTransactions},
+		{"group sum", doc("<NbOfTxs>1</NbOfTxs><CtrlSum>2</CtrlSum>", ""), model.ErrInvalidControlSum},
+		{"payment information count", doc("<NbOfTxs>1</NbOfTxs>", "<NbOfTxs>3</NbOfTxs>"), model.ErrInvalidNumberOfTransactions},
+		{"payment information sum", doc("<NbOfTxs>1</NbOfTxs>", "<CtrlSum>1</CtrlSum>"), model.ErrInvalidControlSum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, err := ParsePain001(strings.NewReader(tt.input), "USD")
+			require.NoError(t, err)
+			assert.Equal(t, "pain.001.001.09", batch.MessageName)
+			if tt.wantErr == nil {
+				assert.NoError(t, batch.Err)
+				return
+			}
+			assert.ErrorIs(t, batch.Err, tt.wantErr)
+		})
+	}
+}
+
+func TestParsePain001_InvalidFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"not xml", "account_id,initial_balance\n"},
+		{"other message", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"><BkToCstmrStmt/></Document>`},
+		{"no namespace", `<Document><CstmrCdtTrfInitn/></Document>`},
+		{"no message ID", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"><CstmrCdtTrfInitn>
+			<GrpHdr><CreDtTm>2026-03-31T09:15:00</CreDtTm><NbOfTxs>0</NbOfTxs></GrpHdr></CstmrCdtTrfInitn></Document>`},
+		{"no transfers", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"><CstmrCdtTrfInitn>
+			<GrpHdr><MsgId>M</MsgId><CreDtTm>2026-03-31T09:15:00</CreDtTm><NbOfTxs>0</NbOfTxs></GrpHdr></CstmrCdtTrfInitn></Document>`},
+		{"bad creation time", `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"><CstmrCdtTrfInitn>
+			<GrpHdr><MsgId>M</MsgId><CreDtTm>yesterday</CreDtTm><NbOfTxs>0</NbOfTxs></GrpHdr></CstmrCdtTrfInitn></Document>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePain001(strings.NewReader(tt.input), "USD")
+			assert.ErrorIs(t, err, model.ErrInvalidImport)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package importer
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// pain002Namespace is the ISO 20022 CustomerPaymentStatusReportV03 namespace
+const pain002Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.002.001.03"
+
+// Payment status codes. Transfers are booked as they are accepted, so an
+// accepted payment is settled (ACSC) straight away.
+const (
+	statusSettled  = "ACSC"
+	statusPartial  = "PART"
+	statusRejected = "RJCT"
+)
+
+// maxAdditionalInfo is the length of a Max105Text
+const maxAdditionalInfo = 105
+
+// statusReasons maps transfer errors to ISO 20022 ExternalStatusReason1
+// codes. Errors without an entry are reported as MS03 (reason not
+// specified).
+var statusReasons = []struct {
+	err  error
+	code string
+}{
+	{model.ErrInvalidAccountIDs, "AC01"},           // IncorrectAccountNumber
+	{model.ErrSourceAccountNotFound, "AC02"},       // InvalidDebtorAccountNumber
+	{model.ErrDestAccountNotFound, "AC03"},         // InvalidCreditorAccountNumber
+	{model.ErrAccountFrozen, "AC06"},               // BlockedAccount
+	{model.ErrSameAccountTransfer, "AG01"},         // TransactionForbidden
+	{model.ErrAmountExceedsLimit, "AM02"},          // NotAllowedAmount
+	{model.ErrCurrencyMismatch, "AM03"},            // NotAllowedCurrency
+	{model.ErrInsufficientFunds, "AM04"},           // InsufficientFunds
+	{model.ErrNegativeAmount, "AM12"},              // InvalidAmount
+	{model.ErrInvalidControlSum, "AM16"},           // InvalidControlSum
+	{model.ErrInvalidNumberOfTransactions, "AM18"}, // InvalidNumberOfTransactions
+}
+
+type pain002Document struct {
+	XMLName xml.Name `xml:"Document"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Report  struct {
+		GrpHdr struct {
+			MsgID   string `xml:"MsgId"`
+			CreDtTm string `xml:"CreDtTm"`
+		} `xml:"GrpHdr"`
+		OrgnlGrpInfAndSts struct {
+			OrgnlMsgID   string             `xml:"OrgnlMsgId"`
+			OrgnlMsgNmID string             `xml:"OrgnlMsgNmId"`
+			OrgnlCreDtTm string             `xml:"OrgnlCreDtTm"`
+			OrgnlNbOfTxs int                `xml:"OrgnlNbOfTxs"`
+			GrpSts       string             `xml:"GrpSts"`
+			StsRsnInf    *pain002StatusInfo `xml:"StsRsnInf"`
+		} `xml:"OrgnlGrpInfAndSts"`
+		OrgnlPmtInfAndSts []*pain002PaymentInfo `xml:"OrgnlPmtInfAndSts"`
+	} `xml:"CstmrPmtStsRpt"`
+}
+
+type pain002StatusInfo struct {
+	Reason   string `xml:"Rsn>Cd"`
+	AddtlInf string `xml:"AddtlInf"`
+}
+
+type pain002PaymentInfo struct {
+	OrgnlPmtInfID string          `xml:"OrgnlPmtInfId"`
+	OrgnlNbOfTxs  int             `xml:"OrgnlNbOfTxs"`
+	PmtInfSts     string          `xml:"PmtInfSts"`
+	TxInfAndSts   []pain002TxInfo `xml:"TxInfAndSts"`
+	accepted      int
+}
+
+type pain002TxInfo struct {
+	OrgnlInstrID    string             `xml:"OrgnlInstrId,omitempty"`
+	OrgnlEndToEndID string             `xml:"OrgnlEndToEndId,omitempty"`
+	TxSts           string             `xml:"TxSts"`
+	StsRsnInf       *pain002StatusInfo `xml:"StsRsnInf"`
+}
+
+// WritePain002 writes report as an ISO 20022 pain.002.001.03 payment status
+// report. The group and every payment information block are ACSC when all
+// of their payments were booked, RJCT when none was and PART otherwise.
+// Rejected payments carry the ISO reason code and, as additional
+// information, the error code and message of POST /transactions.
+func WritePain002(w io.Writer, report *model.PaymentReport) error {
+	doc := pain002Document{Xmlns: pain002Namespace}
+	rpt := &doc.Report
+	rpt.GrpHdr.MsgID = report.MessageID
+	rpt.GrpHdr.CreDtTm = report.CreatedAt.UTC().Format(time.RFC3339Nano)
+
+	grp := &rpt.OrgnlGrpInfAndSts
+	grp.OrgnlMsgID = report.Batch.MessageID
+	grp.OrgnlMsgNmID = report.Batch.MessageName
+	grp.OrgnlCreDtTm = report.Batch.CreatedAt.Format(time.RFC3339Nano)
+	grp.OrgnlNbOfTxs = len(report.Results)
+	grp.GrpSts = groupStatus(report.Accepted, len(report.Results))
+	if report.Batch.Err != nil {
+		grp.StsRsnInf = statusInfo(report.Batch.Err)
+	}
+
+	infos := make(map[string]*pain002PaymentInfo)
+	for _, res := range report.Results {
+		info, ok := infos[res.Instruction.PaymentInfoID]
+		if !ok {
+			info = &pain002PaymentInfo{OrgnlPmtInfID: res.Instruction.PaymentInfoID}
+			infos[info.OrgnlPmtInfID] = info
+			rpt.OrgnlPmtInfAndSts = append(rpt.OrgnlPmtInfAndSts, info)
+		}
+		tx := pain002TxInfo{
+			OrgnlInstrID:    res.Instruction.InstructionID,
+			OrgnlEndToEndID: res.Instruction.EndToEndID,
+			TxSts:           statusSettled,
+		}
+		if res.Err != nil {
+			tx.TxSts = statusRejected
+			tx.StsRsnInf = statusInfo(res.Err)
+		} else {
+			info.accepted++
+		}
+		info.TxInfAndSts = append(info.TxInfAndSts, tx)
+	}
+	for _, info := range rpt.OrgnlPmtInfAndSts {
+		info.OrgnlNbOfTxs = len(info.TxInfAndSts)
+		info.PmtInfSts = groupStatus(info.accepted, info.OrgnlNbOfTxs)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// groupStatus returns the status of a group of n payments of which
+// accepted were booked
+func groupStatus(accepted, n int) string {
+	switch accepted {
+	case n:
+		return statusSettled
+	case 0:
+		return statusRejected
+	default:
+		return statusPartial
+	}
+}
+
+// statusReason returns the ISO 20022 status reason code for err
+func statusReason(err error) string {
+	for _, r := range statusReasons {
+		if errors.Is(err, r.err) {
+			return r.code
+		}
+	}
+	return "MS03" // NotSpecifiedReasonAgentGenerated
+}
+
+// statusInfo describes why a payment or the whole batch was rejected
+func statusInfo(err error) *pain002StatusInfo {
+	info := err.Error()
+	if code := model.CodeOf(err); code != "" {
+		info = code + ": " + info
+	}
+	if len(info) > maxAdditionalInfo {
+		info = info[:maxAdditionalInfo]
+	}
+	return &pain002StatusInfo{Reason: statusReason(err), AddtlInf: info}
+}
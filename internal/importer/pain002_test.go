@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// pain002Report is the parts of a pain.002 the tests check
+type pain002Report struct {
+	XMLName xml.Name `xml:"urn:iso:std:iso:20022:tech:xsd:pain.002.001.03 Document"`
+	MsgID   string   `xml:"CstmrPmtStsRpt>GrpHdr>MsgId"`
+	Group   struct {
+		OrgnlMsgID   string `xml:"OrgnlMsgId"`
+		OrgnlMsgNmID string `xml:"OrgnlMsgNmId"`
+		OrgnlNbOfTxs int    `xml:"OrgnlNbOfTxs"`
+		GrpSts       string `xml:"GrpSts"`
+		Reason       string `xml:"StsRsnInf>Rsn>Cd"`
+	} `xml:"CstmrPmtStsRpt>OrgnlGrpInfAndSts"`
+	PaymentInfos []struct {
+		OrgnlPmtInfID string `xml:"OrgnlPmtInfId"`
+		OrgnlNbOfTxs  int    `xml:"OrgnlNbOfTxs"`
+		PmtInfSts     string `xml:"PmtInfSts"`
+		Txs           []struct {
+			OrgnlInstrID    string `xml:"OrgnlInstrId"`
+			OrgnlEndToEndID string `xml:"OrgnlEndToEndId"`
+			TxSts           string `xml:"TxSts"`
+			Reason          string `xml:"StsRsnInf>Rsn>Cd"`
+			AddtlInf        string `xml:"StsRsnInf>AddtlInf"`
+		} `xml:"TxInfAndSts"`
+	} `xml:"CstmrPmtStsRpt>OrgnlPmtInfAndSts"`
+}
+
+func TestWritePain002(t *testing.T) {
+	batch := &model.PaymentBatch{MessageID: "BATCH-1", MessageName: "pain.001.001.03", CreatedAt: time.Date(2026, 3, 31, 9, 15, 0, 0, time.UTC)}
+	instruction := func(info, e2e string) model.PaymentInstruction {
+		return model.PaymentInstruction{PaymentInfoID: info, EndToEndID: e2e, Amount: decimal.NewFromInt(1)}
+	}
+	report := &model.PaymentReport{
+		MessageID: "REPORT-1",
+		CreatedAt: time.Date(2026, 3, 31, 9, 16, 0, 0, time.UTC),
+		Batch:     batch,
+		Results: []model.PaymentResult{
+			{Instruction: instruction("A", "E1")},
+			{Instruction: instruction("A", "E2"), Err: model.WithDetails(model.ErrInsufficientFunds, model.ErrorDetails{"account_id": 1})},
+			{Instruction: instruction("B", "E3"), Err: model.ErrDestAccountNotFound},
+		},
+		Accepted: 1,
+		Rejected: 2,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePain002(&buf, report))
+	var got pain002Report
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &got), buf.String())
+
+	assert.Equal(t, "REPORT-1", got.MsgID)
+	assert.Equal(t, "BATCH-1", got.Group.OrgnlMsgID)
+	assert.Equal(t, "pain.001.001.03", got.Group.OrgnlMsgNmID)
+	assert.Equal(t, 3, got.Group.OrgnlNbOfTxs)
+	assert.Equal(t, "PART", got.Group.GrpSts)
+	assert.Empty(t, got.Group.Reason)
+
+	require.Len(t, got.PaymentInfos, 2)
+	a, b := got.PaymentInfos[0], got.PaymentInfos[1]
+	assert.Equal(t, "A", a.OrgnlPmtInfID)
+	assert.Equal(t, 2, a.OrgnlNbOfTxs)
+	assert.Equal(t, "PART", a.PmtInfSts)
+	require.Len(t, a.Txs, 2)
+	assert.Equal(t, "E1", a.Txs[0].OrgnlEndToEndID)
+	assert.Equal(t, "ACSC", a.Txs[0].TxSts)
+	assert.Empty(t, a.Txs[0].Reason)
+	assert.Equal(t, "RJCT", a.Txs[1].TxSts)
+	assert.Equal(t, "AM04", a.Txs[1].Reason)
+	assert.Equal(t, "INSUFFICIENT_FUNDS: insufficient funds", a.Txs[1].AddtlInf)
+	assert.Equal(t, "RJCT", b.PmtInfSts)
+	assert.Equal(t, "AC03", b.Txs[0].Reason)
+	assert.NotContains(t, buf.String(), "<OrgnlInstrId>", "absent references are omitted")
+}
+
+func TestWritePain002_RejectedBatch(t *testing.T) {
+	batch := &model.PaymentBatch{MessageID: "BATCH-1", Err: model.ErrInvalidControlSum}
+	report := &model.PaymentReport{
+		Batch:    batch,
+		Results:  []model.PaymentResult{{Instruction: model.PaymentInstruction{PaymentInfoID: "A"}, Err: batch.Err}},
+		Rejected: 1,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePain002(&buf, report))
+	var got pain002Report
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "RJCT", got.Group.GrpSts)
+	assert.Equal(t, "AM16", got.Group.Reason)
+	assert.Equal(t, "AM16", got.PaymentInfos[0].Txs[0].Reason)
+}
+
+func TestStatusReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{model.ErrInvalidAccountIDs, "AC01"},
+		{model.WithDetails(model.ErrSourceAccountNotFound, model.ErrorDetails{"account_id": 9}), "AC02"},
+		{model.ErrAccountFrozen, "AC06"},
+		{model.ErrSameAccountTransfer, "AG01"},
+		{model.ErrAmountExceedsLimit, "AM02"},
+		{model.ErrZeroAmount, "AM12"},
+		{model.ErrInvalidNumberOfTransactions, "AM18"},
+		{model.ErrTransferConflict, "MS03"},
+		{errors.New("boom"), "MS03"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.want, statusReason(tt.err))
+		})
+	}
+}
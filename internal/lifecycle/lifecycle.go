@@ -0,0 +1,122 @@
+// Package lifecycle coordinates process startup and graceful shutdown: the
+// draining flag reported by readiness checks, supervised background workers
+// and the ordered shutdown of the HTTP server and its dependencies.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// State records whether the process has started draining. Readiness checks
+// report unhealthy once draining begins so load balancers stop routing new
+// traffic while in-flight requests complete.
+type State struct {
+	draining atomic.Bool
+}
+
+// NewState creates a State that is not draining
+func NewState() *State {
+	return &State{}
+}
+
+// StartDraining marks the process as draining. It is safe to call repeatedly.
+func (s *State) StartDraining() {
+	s.draining.Store(true)
+}
+
+// Draining reports whether StartDraining has been called
+func (s *State) Draining() bool {
+	return s.draining.Load()
+}
+
+// Hook is a named shutdown step, such as stopping workers or closing the
+// database pool
+type Hook struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+// ServeOptions configures Serve
+type ServeOptions struct {
+	// State is flipped to draining as soon as shutdown begins
+	State *State
+	// DrainDelay is how long the server keeps accepting requests after
+	// readiness turns unhealthy, giving load balancers time to notice
+	DrainDelay time.Duration
+	// ShutdownTimeout bounds the time spent draining in-flight requests and
+	// running Hooks; connections still open afterwards are closed forcibly
+	ShutdownTimeout time.Duration
+	// Hooks run in order after the HTTP server has stopped
+	Hooks []Hook
+}
+
+// Serve serves srv on ln until ctx is cancelled (typically by SIGINT or
+// SIGTERM), then shuts down gracefully:
+//
+//  1. readiness flips to unhealthy,
+//  2. after DrainDelay the listener is closed and in-flight requests are
+//     drained, up to ShutdownTimeout,
+//  3. the hooks run in order under the same deadline.
+//
+// It returns the server error that stopped it, if any, joined with any
+// shutdown errors.
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, opts ServeOptions) error {
+	logger := utils.GlobalLogger
+	if opts.State == nil {
+		opts.State = NewState()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	var errs []error
+	select {
+	case err := <-serveErr:
+		// The server failed on its own; still release dependencies
+		logger.LogError(ctx, "SHUTDOWN", "SERVE_ERROR", "HTTP server stopped unexpectedly", err)
+		errs = append(errs, fmt.Errorf("serving HTTP: %w", err))
+		opts.State.StartDraining()
+		opts.DrainDelay = 0
+	case <-ctx.Done():
+		logger.LogInfo(context.Background(), "SHUTDOWN", "shutdown signal received, draining")
+		opts.State.StartDraining()
+	}
+
+	if opts.DrainDelay > 0 {
+		time.Sleep(opts.DrainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.LogError(shutdownCtx, "SHUTDOWN", "DRAIN_TIMEOUT", "in-flight requests did not finish before the shutdown deadline", err)
+		srv.Close()
+		errs = append(errs, fmt.Errorf("draining HTTP server: %w", err))
+	} else {
+		logger.LogInfo(shutdownCtx, "SHUTDOWN", fmt.Sprintf("HTTP server drained in %s", time.Since(start).Round(time.Millisecond)))
+	}
+
+	for _, hook := range opts.Hooks {
+		if err := hook.Fn(shutdownCtx); err != nil {
+			logger.LogError(shutdownCtx, "SHUTDOWN", "HOOK_ERROR", "shutdown step failed: "+hook.Name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", hook.Name, err))
+			continue
+		}
+		logger.LogDebug(shutdownCtx, "SHUTDOWN", "shutdown step completed: "+hook.Name)
+	}
+
+	logger.LogInfo(shutdownCtx, "SHUTDOWN", "shutdown complete")
+	return errors.Join(errs...)
+}
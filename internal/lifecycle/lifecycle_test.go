@@ -0,0 +1,152 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	state := NewState()
+	var order []string
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, srv, ln, ServeOptions{
+			State:           state,
+			ShutdownTimeout: 5 * time.Second,
+			Hooks: []Hook{
+				{Name: "first", Fn: func(context.Context) error { order = append(order, "first"); return nil }},
+				{Name: "second", Fn: func(context.Context) error { order = append(order, "second"); return nil }},
+			},
+		})
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	assert.False(t, state.Draining())
+	cancel()
+	require.Eventually(t, state.Draining, time.Second, time.Millisecond, "readiness flips as soon as shutdown begins")
+
+	// New connections are refused while the in-flight request is allowed to finish
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, time.Second, 5*time.Millisecond)
+
+	close(release)
+	assert.Equal(t, "done", <-body)
+	require.NoError(t, <-served)
+	assert.Equal(t, []string{"first", "second"}, order)
+}
+
+func TestServe_ShutdownDeadlineAndHookErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done() // never finishes on its own
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hookRan := false
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, srv, ln, ServeOptions{
+			ShutdownTimeout: 50 * time.Millisecond,
+			Hooks: []Hook{
+				{Name: "failing", Fn: func(context.Context) error { return errors.New("boom") }},
+				{Name: "after failure", Fn: func(context.Context) error { hookRan = true; return nil }},
+			},
+		})
+	}()
+
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+	cancel()
+
+	err = <-served
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "failing: boom")
+	assert.True(t, hookRan, "later hooks still run after a failure")
+}
+
+func TestWorkers(t *testing.T) {
+	w := NewWorkers()
+	stopped := make(chan struct{})
+	w.Go("ticker", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	})
+	w.Go("broken", func(ctx context.Context) error { panic("nil map") })
+	w.Go("failed", func(ctx context.Context) error { return errors.New("lost connection") })
+
+	require.Eventually(t, func() bool {
+		for _, s := range w.Status() {
+			if s.Name != "ticker" && s.Running {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+
+	status := w.Status()
+	require.Len(t, status, 3)
+	assert.Equal(t, "broken", status[0].Name)
+	assert.ErrorContains(t, status[0].Err, "panic: nil map")
+	assert.ErrorContains(t, status[1].Err, "lost connection")
+	assert.True(t, status[2].Running)
+
+	require.NoError(t, w.Stop(context.Background()))
+	<-stopped
+	status = w.Status()
+	assert.False(t, status[2].Running)
+	assert.NoError(t, status[2].Err, "returning after cancellation is a clean stop")
+}
+
+func TestWorkers_StopHonoursDeadline(t *testing.T) {
+	w := NewWorkers()
+	release := make(chan struct{})
+	defer close(release)
+	w.Go("stuck", func(context.Context) error { <-release; return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Stop(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,109 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// WorkerFunc is the body of a background worker. It must return promptly
+// once ctx is cancelled. A nil return after cancellation is a clean stop;
+// any other return marks the worker as failed.
+type WorkerFunc func(ctx context.Context) error
+
+// WorkerStatus describes a supervised worker
+type WorkerStatus struct {
+	Name    string
+	Running bool
+	// Err is the error the worker exited with, if it failed
+	Err error
+}
+
+// Workers supervises background goroutines so they can be stopped together
+// during shutdown and their health reported by readiness checks
+type Workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	status map[string]*WorkerStatus
+}
+
+// NewWorkers creates an empty worker group
+func NewWorkers() *Workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Workers{
+		ctx:    ctx,
+		cancel: cancel,
+		status: make(map[string]*WorkerStatus),
+	}
+}
+
+// Go starts fn in its own goroutine under the given name. Names must be
+// unique within the group.
+func (w *Workers) Go(name string, fn WorkerFunc) {
+	w.mu.Lock()
+	w.status[name] = &WorkerStatus{Name: name, Running: true}
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		err := w.run(fn)
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.status[name].Running = false
+		if err != nil && w.ctx.Err() == nil {
+			w.status[name].Err = err
+			utils.GlobalLogger.LogError(context.Background(), "WORKER", "WORKER_FAILED", "background worker "+name+" stopped", err)
+		}
+	}()
+}
+
+// run invokes fn, converting a panic into an error so that one broken worker
+// cannot take the process down
+func (w *Workers) run(fn WorkerFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(w.ctx)
+}
+
+// Stop cancels every worker and waits for them to return, or for ctx to
+// expire
+func (w *Workers) Stop(ctx context.Context) error {
+	w.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for background workers: %w", ctx.Err())
+	}
+}
+
+// Status returns a snapshot of every worker, sorted by name
+func (w *Workers) Status() []WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	out := make([]WorkerStatus, 0, len(w.status))
+	for _, s := range w.status {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
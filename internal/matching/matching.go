@@ -0,0 +1,169 @@
+// Package matching pairs the lines of an external statement with ledger
+// transactions. It only scores and pairs what it is given; loading lines
+// and transactions and recording matches is left to the services.
+package matching
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// Weights of the evidence behind a confidence score; they add up to 100.
+// A transaction is only a candidate when its amount is the line's, so every
+// candidate scores at least amountWeight.
+const (
+	amountWeight    = 50
+	dateWeight      = 30
+	referenceWeight = 20
+)
+
+// Options tune the engine
+type Options struct {
+	// DateWindowDays is how many days a line's booking date may be from a
+	// transaction's; the date score falls off linearly across the window
+	DateWindowDays int
+	// AutoMatchConfidence is the score from which a line is matched without
+	// review
+	AutoMatchConfidence int
+}
+
+// Score rates entry, a transaction as seen from the line's account, as the
+// counterpart of line. ok is false when it cannot be: a different amount
+// or direction, or booking dates more than the window apart.
+func Score(line *model.ExternalLine, entry model.StatementEntry, windowDays int) (_ model.MatchCandidate, ok bool) {
+	if !line.Amount.Equal(entry.Amount) {
+		return model.MatchCandidate{}, false
+	}
+	days := daysApart(line.BookedAt, entry.BookedAt)
+	if days > windowDays {
+		return model.MatchCandidate{}, false
+	}
+	c := model.MatchCandidate{
+		TransactionID:  entry.TransactionID,
+		BookedAt:       entry.BookedAt,
+		DaysApart:      days,
+		ReferenceMatch: references(line.Reference, entry.TransactionID),
+	}
+	c.Confidence = amountWeight + dateWeight*(windowDays+1-days)/(windowDays+1)
+	if c.ReferenceMatch {
+		c.Confidence += referenceWeight
+	}
+	return c, true
+}
+
+// Candidates returns every entry that could be line's counterpart, best
+// first
+func Candidates(line *model.ExternalLine, entries []model.StatementEntry, windowDays int) []model.MatchCandidate {
+	var candidates []model.MatchCandidate
+	for _, e := range entries {
+		if c, ok := Score(line, e, windowDays); ok {
+			candidates = append(candidates, c)
+		}
+	}
+	sortCandidates(candidates)
+	return candidates
+}
+
+// AutoMatch pairs lines with entries one to one, best scores first, and
+// returns the transaction chosen for each matched line. A pair is only
+// made when it scores at least opts.AutoMatchConfidence and is
+// unambiguous: no other unpaired entry scores as well for the line, and no
+// other unpaired line scores as well for the entry. Everything else is left
+// for review.
+func AutoMatch(lines []*model.ExternalLine, entries []model.StatementEntry, opts Options) map[int64]model.MatchCandidate {
+	type pair struct {
+		line      *model.ExternalLine
+		candidate model.MatchCandidate
+	}
+	var pairs []pair
+	for _, line := range lines {
+		for _, c := range Candidates(line, entries, opts.DateWindowDays) {
+			if c.Confidence >= opts.AutoMatchConfidence {
+				pairs = append(pairs, pair{line, c})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].candidate.Confidence != pairs[j].candidate.Confidence {
+			return pairs[i].candidate.Confidence > pairs[j].candidate.Confidence
+		}
+		return pairs[i].line.LineNo < pairs[j].line.LineNo
+	})
+
+	matched := make(map[int64]model.MatchCandidate)
+	usedTxns := make(map[int64]bool)
+	for i, p := range pairs {
+		if _, done := matched[p.line.ID]; done || usedTxns[p.candidate.TransactionID] {
+			continue
+		}
+		ambiguous := false
+		for j, other := range pairs {
+			if i == j || other.candidate.Confidence != p.candidate.Confidence {
+				continue
+			}
+			_, otherLineDone := matched[other.line.ID]
+			sameLine := other.line == p.line && !usedTxns[other.candidate.TransactionID]
+			sameTxn := other.candidate.TransactionID == p.candidate.TransactionID && !otherLineDone
+			if sameLine || sameTxn {
+				ambiguous = true
+				break
+			}
+		}
+		if ambiguous {
+			continue
+		}
+		matched[p.line.ID] = p.candidate
+		usedTxns[p.candidate.TransactionID] = true
+	}
+	return matched
+}
+
+// sortCandidates orders candidates by confidence, then by closeness in
+// time, then by transaction ID
+func sortCandidates(candidates []model.MatchCandidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Confidence != b.Confidence {
+			return a.Confidence > b.Confidence
+		}
+		if a.DaysApart != b.DaysApart {
+			return a.DaysApart < b.DaysApart
+		}
+		return a.TransactionID < b.TransactionID
+	})
+}
+
+// daysApart counts the calendar days between the UTC dates of a and b
+func daysApart(a, b time.Time) int {
+	d := int(utcDate(a).Sub(utcDate(b)).Hours() / 24)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func utcDate(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// references reports whether reference names the transaction: one of its
+// alphanumeric tokens is the transaction ID, as in the NtryRef of a
+// camt.053 export or the //reference of an MT940 one
+func references(reference string, transactionID int64) bool {
+	id := strconv.FormatInt(transactionID, 10)
+	tokens := strings.FieldsFunc(reference, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, token := range tokens {
+		if token == id || strings.TrimLeft(token, "0") == id {
+			return true
+		}
+	}
+	return false
+}
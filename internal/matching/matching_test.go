@@ -0,0 +1,121 @@
+package matching
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+var day = time.Date(2026, 3, 10, 9, 30, 0, 0, time.UTC)
+
+func entry(id int64, amount string, bookedAt time.Time) model.StatementEntry {
+	return model.StatementEntry{TransactionID: id, Amount: decimal.RequireFromString(amount), BookedAt: bookedAt}
+}
+
+func TestScore(t *testing.T) {
+	line := &model.ExternalLine{BookedAt: day, Amount: decimal.RequireFromString("-12.50"), Reference: "E2E TXN-0042"}
+	tests := []struct {
+		name           string
+		entry          model.StatementEntry
+		wantOK         bool
+		wantConfidence int
+	}{
+		{"same day and reference", entry(42, "-12.5", day.Add(10*time.Hour)), true, 100},
+		{"same day", entry(7, "-12.5", day), true, 80},
+		{"a day apart", entry(7, "-12.5", day.AddDate(0, 0, -1)), true, 72},
+		{"edge of the window", entry(42, "-12.5", day.AddDate(0, 0, 3)), true, 77},
+		{"outside the window", entry(42, "-12.5", day.AddDate(0, 0, 4)), false, 0},
+		{"other direction", entry(42, "12.5", day), false, 0},
+		{"other amount", entry(42, "-12.51", day), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := Score(line, tt.entry, 3)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantConfidence, c.Confidence)
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		reference string
+		want      bool
+	}{
+		{"42", true},
+		{"TXN-000042", true},
+		{"//42 refund", true},
+		{"TXN-142", false},
+		{"420", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reference, func(t *testing.T) {
+			assert.Equal(t, tt.want, references(tt.reference, 42))
+		})
+	}
+}
+
+func TestAutoMatch(t *testing.T) {
+	opts := Options{DateWindowDays: 3, AutoMatchConfidence: 80}
+	line := func(id int64, amount, reference string) *model.ExternalLine {
+		return &model.ExternalLine{ID: id, LineNo: int(id), BookedAt: day, Amount: decimal.RequireFromString(amount), Reference: reference}
+	}
+
+	tests := []struct {
+		name    string
+		lines   []*model.ExternalLine
+		entries []model.StatementEntry
+		want    map[int64]int64 // line ID to transaction ID
+	}{
+		{
+			name:    "best score wins",
+			lines:   []*model.ExternalLine{line(1, "10", "")},
+			entries: []model.StatementEntry{entry(5, "10", day.AddDate(0, 0, 1)), entry(6, "10", day)},
+			want:    map[int64]int64{1: 6},
+		},
+		{
+			name:    "tie on one line is left for review",
+			lines:   []*model.ExternalLine{line(1, "10", "")},
+			entries: []model.StatementEntry{entry(5, "10", day), entry(6, "10", day)},
+			want:    map[int64]int64{},
+		},
+		{
+			name:    "reference breaks the tie",
+			lines:   []*model.ExternalLine{line(1, "10", "ref 6")},
+			entries: []model.StatementEntry{entry(5, "10", day), entry(6, "10", day)},
+			want:    map[int64]int64{1: 6},
+		},
+		{
+			name:    "tie on one transaction is left for review",
+			lines:   []*model.ExternalLine{line(1, "10", ""), line(2, "10", "")},
+			entries: []model.StatementEntry{entry(5, "10", day)},
+			want:    map[int64]int64{},
+		},
+		{
+			name:    "a paired transaction frees the other line",
+			lines:   []*model.ExternalLine{line(1, "10", "5"), line(2, "10", "")},
+			entries: []model.StatementEntry{entry(5, "10", day), entry(6, "10", day)},
+			want:    map[int64]int64{1: 5, 2: 6},
+		},
+		{
+			name:    "below the threshold",
+			lines:   []*model.ExternalLine{line(1, "10", "")},
+			entries: []model.StatementEntry{entry(5, "10", day.AddDate(0, 0, 2))},
+			want:    map[int64]int64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[int64]int64)
+			for lineID, c := range AutoMatch(tt.lines, tt.entries, opts) {
+				got[lineID] = c.TransactionID
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
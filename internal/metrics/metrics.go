@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+const namespace = "transfersystem"
+
+// Transfer outcome labels that are not derived from a TransferError
+const (
+	OutcomeSuccess  = "success"
+	OutcomeInternal = "internal_error"
+)
+
+// Registry holds every collector exposed on /metrics. A dedicated registry
+// (instead of the global default) keeps tests isolated and the output stable.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestsTotal counts HTTP requests by method, route pattern and status
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes HTTP request latency by method, route pattern and status
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// TransfersTotal counts transfer outcomes, labelled by TransferError on failure
+	TransfersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transfers",
+		Name:      "total",
+		Help:      "Total number of transfers by outcome (success or the TransferError returned).",
+	}, []string{"outcome"})
+
+	// DBTransactionDuration observes how long database transactions stay open
+	DBTransactionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "transaction_duration_seconds",
+		Help:      "Duration of database transactions from BEGIN to COMMIT/ROLLBACK.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "result"})
+
+	// DBSerializationFailures counts Postgres serialization failures (40001) and deadlocks (40P01)
+	DBSerializationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "serialization_failures_total",
+		Help:      "Total number of serialization failures and deadlocks reported by Postgres.",
+	}, []string{"operation", "code"})
+
+	// DBRollbacksTotal counts rolled back database transactions
+	DBRollbacksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "rollbacks_total",
+		Help:      "Total number of database transactions that were rolled back.",
+	}, []string{"operation"})
+
+	// DBRetriesTotal counts units of work re-run after a retryable failure
+	DBRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "transaction_retries_total",
+		Help:      "Total number of transaction retries after serialization failures or deadlocks.",
+	}, []string{"operation"})
+
+	// DBRetryExhaustedTotal counts units of work that failed after using the whole retry budget
+	DBRetryExhaustedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "transaction_retry_exhausted_total",
+		Help:      "Total number of transactions abandoned after exhausting the retry budget.",
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		TransfersTotal,
+		DBTransactionDuration,
+		DBSerializationFailures,
+		DBRollbacksTotal,
+		DBRetriesTotal,
+		DBRetryExhaustedTotal,
+	)
+}
+
+// RegisterDBStats exposes the sql.DB connection pool statistics (open, in use,
+// idle connections, wait count and duration) for the given pool
+func RegisterDBStats(db *sql.DB, dbName string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// Handler returns the HTTP handler serving the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Middleware records request counts and latencies for every route. The chi
+// route pattern (e.g. /accounts/{account_id}) is used as the label so that
+// path parameters do not explode label cardinality.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := routePattern(r)
+		labels := prometheus.Labels{
+			"method": r.Method,
+			"route":  route,
+			"status": strconv.Itoa(status),
+		}
+		HTTPRequestsTotal.With(labels).Inc()
+		HTTPRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return "unmatched"
+	}
+	if pattern := rctx.RoutePattern(); pattern != "" {
+		return pattern
+	}
+	return "unmatched"
+}
+
+// ObserveTransfer records the outcome of a transfer. The codes of typed
+// errors are used as the outcome label so alerts can target e.g.
+// INSUFFICIENT_FUNDS; rewording an error message does not rename a series.
+func ObserveTransfer(err error) {
+	TransfersTotal.WithLabelValues(TransferOutcome(err)).Inc()
+}
+
+// TransferOutcome maps a transfer result to its metric label
+func TransferOutcome(err error) string {
+	if err == nil {
+		return OutcomeSuccess
+	}
+	if code := model.CodeOf(err); code != "" {
+		return code
+	}
+	return OutcomeInternal
+}
+
+// ObserveDBTransaction records the duration of a database transaction and
+// counts it as a rollback when it did not commit
+func ObserveDBTransaction(operation string, start time.Time, committed bool) {
+	result := "commit"
+	if !committed {
+		result = "rollback"
+		DBRollbacksTotal.WithLabelValues(operation).Inc()
+	}
+	DBTransactionDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+// ObserveSerializationFailure counts a serialization failure or deadlock
+func ObserveSerializationFailure(operation, code string) {
+	DBSerializationFailures.WithLabelValues(operation, code).Inc()
+}
+
+// ObserveTransactionRetry counts one retry of a unit of work
+func ObserveTransactionRetry(operation string) {
+	DBRetriesTotal.WithLabelValues(operation).Inc()
+}
+
+// ObserveTransactionRetryExhausted counts a unit of work abandoned after its retry budget
+func ObserveTransactionRetryExhausted(operation string) {
+	DBRetryExhaustedTotal.WithLabelValues(operation).Inc()
+}
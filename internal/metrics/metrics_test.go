@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestMiddleware_RecordsRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/accounts/{account_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	before := testutil.ToFloat64(HTTPRequestsTotal.WithLabelValues(http.MethodGet, "/accounts/{account_id}", "404"))
+
+	req := httptest.NewRequest(http.MethodGet, "/accounts/42", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	after := testutil.ToFloat64(HTTPRequestsTotal.WithLabelValues(http.MethodGet, "/accounts/{account_id}", "404"))
+	assert.Equal(t, before+1, after)
+}
+
+func TestTransferOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "success", err: nil, expected: OutcomeSuccess},
+		{name: "insufficient funds", err: model.ErrInsufficientFunds, expected: "INSUFFICIENT_FUNDS"},
+		{name: "wrapped", err: fmt.Errorf("transfer 7: %w", model.ErrInsufficientFunds), expected: "INSUFFICIENT_FUNDS"},
+		{name: "account error", err: model.ErrAccountNotFound, expected: "ACCOUNT_NOT_FOUND"},
+		{name: "untyped error", err: errors.New("boom"), expected: OutcomeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, TransferOutcome(tt.err))
+		})
+	}
+}
+
+func TestHandler_ExposesCollectors(t *testing.T) {
+	ObserveTransfer(model.ErrInsufficientFunds)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.True(t, strings.Contains(body, `transfersystem_transfers_total{outcome="INSUFFICIENT_FUNDS"}`))
+}
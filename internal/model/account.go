@@ -1,8 +1,41 @@
 package model
 
-import "github.com/shopspring/decimal"
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// AccountStatus controls whether an account may take part in transfers
+type AccountStatus string
+
+const (
+	AccountActive AccountStatus = "active"
+	// AccountFrozen accounts can neither send nor receive transfers;
+	// operators may still reverse transfers that touch them
+	AccountFrozen AccountStatus = "frozen"
+)
+
+// Valid reports whether s is a known status
+func (s AccountStatus) Valid() bool {
+	return s == AccountActive || s == AccountFrozen
+}
 
 type Account struct {
 	ID      int64           `json:"account_id"`
 	Balance decimal.Decimal `json:"balance"`
+	Status  AccountStatus   `json:"status,omitempty"`
+	// Metadata is caller-supplied data, such as a partner's own reference
+	Metadata Metadata `json:"metadata,omitempty"`
+	// OpeningBalance is the balance the account was created with; ledger
+	// reconciliation checks Balance against it plus the recorded transfers
+	OpeningBalance decimal.Decimal `json:"-"`
+	// CreatedAt is when the account was opened; zero for accounts opened
+	// before it was recorded, which count as open at any point in time
+	CreatedAt time.Time `json:"-"`
+}
+
+// OpenAt reports whether the account existed at t
+func (a *Account) OpenAt(t time.Time) bool {
+	return a.CreatedAt.IsZero() || !a.CreatedAt.After(t)
 }
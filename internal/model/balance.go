@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MaxBalanceQueryAccounts bounds the accounts in one bulk as-of query
+const MaxBalanceQueryAccounts = 1000
+
+// AccountBalance is an account's balance at a point in time
+type AccountBalance struct {
+	AccountID int64           `json:"account_id"`
+	Balance   decimal.Decimal `json:"balance"`
+	AsOf      time.Time       `json:"as_of"`
+}
+
+// BalancesAsOf is the answer to a bulk as-of query. Accounts that do not
+// exist, or were not yet open at AsOf, are listed in NotFound.
+type BalancesAsOf struct {
+	AsOf     time.Time        `json:"as_of"`
+	Balances []AccountBalance `json:"balances"`
+	NotFound []int64          `json:"not_found"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import "errors"
+
+// ErrorDetails is structured context attached to an error, such as the
+// offending field or the balance a transfer was short of. Values must be
+// JSON-encodable.
+type ErrorDetails map[string]any
+
+// detailedError wraps an error with ErrorDetails without changing its
+// message or identity: errors.Is and errors.As still see the wrapped error
+type detailedError struct {
+	err     error
+	details ErrorDetails
+}
+
+func (e *detailedError) Error() string { return e.err.Error() }
+
+func (e *detailedError) Unwrap() error { return e.err }
+
+// WithDetails attaches details to err. It returns nil when err is nil.
+func WithDetails(err error, details ErrorDetails) error {
+	if err == nil {
+		return nil
+	}
+	return &detailedError{err: err, details: details}
+}
+
+// DetailsOf returns the details attached anywhere in err's chain, merged
+// with the outermost value winning, or nil when there are none
+func DetailsOf(err error) ErrorDetails {
+	var merged ErrorDetails
+	for err != nil {
+		var d *detailedError
+		if !errors.As(err, &d) {
+			break
+		}
+		for k, v := range d.details {
+			if merged == nil {
+				merged = ErrorDetails{}
+			}
+			if _, ok := merged[k]; !ok {
+				merged[k] = v
+			}
+		}
+		err = d.err
+	}
+	return merged
+}
@@ -1,5 +1,7 @@
 package model
 
+import "errors"
+
 // TransferError represents different types of transfer errors
 type TransferError string
 
@@ -19,6 +21,9 @@ const (
 	// Balance errors
 	ErrInsufficientFunds TransferError = "insufficient funds"
 
+	// Limit errors
+	ErrAmountExceedsLimit TransferError = "transfer amount exceeds limit"
+
 	// Transaction errors
 	ErrFailedDebit     TransferError = "failed to debit source account"
 	ErrFailedCredit    TransferError = "failed to credit destination account"
@@ -26,6 +31,37 @@ const (
 
 	// Service errors
 	ErrServiceUnavailable TransferError = "service temporarily unavailable"
+
+	// Concurrency errors: the transfer kept conflicting with concurrent
+	// transfers and was abandoned after the retry budget; safe to retry
+	ErrTransferConflict TransferError = "transfer conflicted with concurrent updates, please retry"
+
+	// Account state errors
+	ErrAccountFrozen TransferError = "account is frozen"
+
+	// Reversal errors
+	ErrTransactionNotFound TransferError = "transaction not found"
+	ErrAlreadyReversed     TransferError = "transaction already reversed"
+
+	// Query errors
+	ErrInvalidDateRange TransferError = "from must be before to"
+	ErrInvalidSearch    TransferError = "invalid transaction search"
+	ErrInvalidCursor    TransferError = "invalid or expired cursor"
+
+	// Payment file errors: the declared totals of a payment batch do not
+	// match its instructions, so none of them is executed
+	ErrInvalidNumberOfTransactions TransferError = "number of transactions does not match the declared count"
+	ErrInvalidControlSum           TransferError = "sum of amounts does not match the declared control sum"
+
+	// Payment file idempotency errors. A message ID names one file; a
+	// payment of a file is booked at most once.
+	ErrDuplicatePaymentFile TransferError = "message ID already used by another payment file"
+	ErrPaymentAlreadyBooked TransferError = "payment already booked"
+	ErrFailedPaymentFile    TransferError = "failed to record payment file"
+
+	// Transfer details errors
+	ErrInvalidReference   TransferError = "invalid reference"
+	ErrInvalidDescription TransferError = "invalid description"
 )
 
 // Error returns the string representation of the error
@@ -43,6 +79,35 @@ const (
 	ErrNegativeBalance     AccountError = "account balance cannot be negative"
 	ErrFailedCreateAccount AccountError = "failed to create account"
 	ErrFailedGetAccount    AccountError = "failed to retrieve account"
+	ErrInvalidStatus       AccountError = "invalid account status"
+	ErrFailedUpdateAccount AccountError = "failed to update account"
+	ErrInvalidBalance      AccountError = "initial balance must be a decimal"
+	ErrInvalidMetadata     AccountError = "invalid metadata"
+	ErrCurrencyMismatch    AccountError = "currency does not match the ledger currency"
+
+	// Balance history errors
+	ErrAccountNotOpen  AccountError = "account was not open at the requested time"
+	ErrAsOfInFuture    AccountError = "as_of must not be in the future"
+	ErrTooManyAccounts AccountError = "too many accounts requested"
+
+	// Statement errors
+	ErrFailedStatement       AccountError = "failed to build statement"
+	ErrStatementUnrenderable AccountError = "statement cannot be represented in the requested format"
+
+	// Bulk import errors
+	ErrInvalidImport    AccountError = "invalid import file"
+	ErrInvalidImportRow AccountError = "invalid import row"
+	ErrImportRejected   AccountError = "import rejected, no accounts were created"
+
+	// External statement reconciliation errors
+	ErrExternalStatementNotFound AccountError = "external statement not found"
+	ErrExternalLineNotFound      AccountError = "external statement line not found"
+	ErrStatementAlreadyImported  AccountError = "external statement already imported"
+	ErrLineAlreadyMatched        AccountError = "external statement line is already matched"
+	ErrLineNotMatched            AccountError = "external statement line is not matched"
+	ErrTransactionAlreadyMatched AccountError = "transaction is already matched to an external statement line"
+	ErrMatchMismatch             AccountError = "transaction does not match the external statement line"
+	ErrFailedReconciliation      AccountError = "failed to reconcile external statement"
 )
 
 // Error returns the string representation of the error
@@ -53,31 +118,183 @@ func (e AccountError) Error() string {
 // HTTPStatus returns the appropriate HTTP status code for the error
 func (e TransferError) HTTPStatus() int {
 	switch e {
-	case ErrSameAccountTransfer, ErrNegativeAmount, ErrInvalidAccountIDs:
+	case ErrSameAccountTransfer, ErrNegativeAmount, ErrInvalidAccountIDs, ErrInvalidDateRange,
+		ErrInvalidNumberOfTransactions, ErrInvalidControlSum, ErrInvalidReference, ErrInvalidDescription,
+		ErrInvalidSearch, ErrInvalidCursor:
 		return 400 // Bad Request
-	case ErrSourceAccountNotFound, ErrDestAccountNotFound:
+	case ErrSourceAccountNotFound, ErrDestAccountNotFound, ErrTransactionNotFound:
 		return 404 // Not Found
-	case ErrInsufficientFunds:
+	case ErrAlreadyReversed, ErrDuplicatePaymentFile, ErrPaymentAlreadyBooked:
+		return 409 // Conflict
+	case ErrInsufficientFunds, ErrAmountExceedsLimit, ErrAccountFrozen:
 		return 422 // Unprocessable Entity
-	case ErrFailedDebit, ErrFailedCredit, ErrFailedRecordTxn, ErrServiceUnavailable:
+	case ErrFailedDebit, ErrFailedCredit, ErrFailedRecordTxn, ErrServiceUnavailable, ErrFailedPaymentFile:
 		return 500 // Internal Server Error
+	case ErrTransferConflict:
+		return 503 // Service Unavailable (retryable)
 	default:
 		return 500 // Internal Server Error
 	}
 }
 
+// Retryable reports whether the client may safely resubmit the same request
+func (e TransferError) Retryable() bool {
+	return e == ErrTransferConflict
+}
+
 // HTTPStatus returns the appropriate HTTP status code for the error
 func (e AccountError) HTTPStatus() int {
 	switch e {
-	case ErrAccountIDRequired, ErrNegativeBalance:
+	case ErrAccountIDRequired, ErrNegativeBalance, ErrInvalidStatus, ErrInvalidBalance,
+		ErrInvalidMetadata, ErrCurrencyMismatch, ErrInvalidImport, ErrInvalidImportRow,
+		ErrAsOfInFuture, ErrTooManyAccounts:
 		return 400 // Bad Request
-	case ErrAccountNotFound:
+	case ErrAccountNotFound, ErrAccountNotOpen, ErrExternalStatementNotFound, ErrExternalLineNotFound:
 		return 404 // Not Found
-	case ErrAccountExists:
+	case ErrAccountExists, ErrStatementAlreadyImported, ErrLineAlreadyMatched, ErrLineNotMatched,
+		ErrTransactionAlreadyMatched:
 		return 409 // Conflict
-	case ErrFailedCreateAccount, ErrFailedGetAccount:
+	case ErrImportRejected, ErrStatementUnrenderable, ErrMatchMismatch:
+		return 422 // Unprocessable Entity
+	case ErrFailedCreateAccount, ErrFailedGetAccount, ErrFailedUpdateAccount, ErrFailedStatement,
+		ErrFailedReconciliation:
 		return 500 // Internal Server Error
 	default:
 		return 500 // Internal Server Error
 	}
 }
+
+// Code returns a stable, machine-readable identifier for the error. Unlike
+// the message it never changes, so clients can match on it.
+func (e TransferError) Code() string {
+	switch e {
+	case ErrSameAccountTransfer:
+		return "SAME_ACCOUNT_TRANSFER"
+	case ErrNegativeAmount:
+		return "INVALID_AMOUNT"
+	case ErrInvalidAccountIDs:
+		return "INVALID_ACCOUNT_IDS"
+	case ErrSourceAccountNotFound:
+		return "SOURCE_ACCOUNT_NOT_FOUND"
+	case ErrDestAccountNotFound:
+		return "DESTINATION_ACCOUNT_NOT_FOUND"
+	case ErrInsufficientFunds:
+		return "INSUFFICIENT_FUNDS"
+	case ErrAmountExceedsLimit:
+		return "AMOUNT_EXCEEDS_LIMIT"
+	case ErrFailedDebit:
+		return "DEBIT_FAILED"
+	case ErrFailedCredit:
+		return "CREDIT_FAILED"
+	case ErrFailedRecordTxn:
+		return "RECORD_TRANSACTION_FAILED"
+	case ErrServiceUnavailable:
+		return "SERVICE_UNAVAILABLE"
+	case ErrTransferConflict:
+		return "TRANSFER_CONFLICT"
+	case ErrAccountFrozen:
+		return "ACCOUNT_FROZEN"
+	case ErrTransactionNotFound:
+		return "TRANSACTION_NOT_FOUND"
+	case ErrAlreadyReversed:
+		return "ALREADY_REVERSED"
+	case ErrInvalidDateRange:
+		return "INVALID_DATE_RANGE"
+	case ErrInvalidSearch:
+		return "INVALID_SEARCH"
+	case ErrInvalidCursor:
+		return "INVALID_CURSOR"
+	case ErrInvalidNumberOfTransactions:
+		return "INVALID_NUMBER_OF_TRANSACTIONS"
+	case ErrInvalidControlSum:
+		return "INVALID_CONTROL_SUM"
+	case ErrDuplicatePaymentFile:
+		return "DUPLICATE_MESSAGE_ID"
+	case ErrPaymentAlreadyBooked:
+		return "PAYMENT_ALREADY_BOOKED"
+	case ErrFailedPaymentFile:
+		return "PAYMENT_FILE_FAILED"
+	case ErrInvalidReference:
+		return "INVALID_REFERENCE"
+	case ErrInvalidDescription:
+		return "INVALID_DESCRIPTION"
+	default:
+		return "TRANSFER_FAILED"
+	}
+}
+
+// Code returns a stable, machine-readable identifier for the error
+func (e AccountError) Code() string {
+	switch e {
+	case ErrAccountIDRequired:
+		return "INVALID_ACCOUNT_ID"
+	case ErrAccountNotFound:
+		return "ACCOUNT_NOT_FOUND"
+	case ErrAccountExists:
+		return "ACCOUNT_EXISTS"
+	case ErrNegativeBalance:
+		return "NEGATIVE_BALANCE"
+	case ErrFailedCreateAccount:
+		return "ACCOUNT_CREATE_FAILED"
+	case ErrFailedGetAccount:
+		return "ACCOUNT_GET_FAILED"
+	case ErrInvalidStatus:
+		return "INVALID_ACCOUNT_STATUS"
+	case ErrFailedUpdateAccount:
+		return "ACCOUNT_UPDATE_FAILED"
+	case ErrInvalidBalance:
+		return "INVALID_BALANCE"
+	case ErrInvalidMetadata:
+		return "INVALID_METADATA"
+	case ErrCurrencyMismatch:
+		return "CURRENCY_MISMATCH"
+	case ErrInvalidImport:
+		return "INVALID_IMPORT"
+	case ErrInvalidImportRow:
+		return "INVALID_IMPORT_ROW"
+	case ErrImportRejected:
+		return "IMPORT_REJECTED"
+	case ErrAccountNotOpen:
+		return "ACCOUNT_NOT_OPEN"
+	case ErrAsOfInFuture:
+		return "AS_OF_IN_FUTURE"
+	case ErrTooManyAccounts:
+		return "TOO_MANY_ACCOUNTS"
+	case ErrFailedStatement:
+		return "STATEMENT_FAILED"
+	case ErrStatementUnrenderable:
+		return "STATEMENT_UNRENDERABLE"
+	case ErrExternalStatementNotFound:
+		return "EXTERNAL_STATEMENT_NOT_FOUND"
+	case ErrExternalLineNotFound:
+		return "EXTERNAL_LINE_NOT_FOUND"
+	case ErrStatementAlreadyImported:
+		return "STATEMENT_ALREADY_IMPORTED"
+	case ErrLineAlreadyMatched:
+		return "LINE_ALREADY_MATCHED"
+	case ErrLineNotMatched:
+		return "LINE_NOT_MATCHED"
+	case ErrTransactionAlreadyMatched:
+		return "TRANSACTION_ALREADY_MATCHED"
+	case ErrMatchMismatch:
+		return "MATCH_MISMATCH"
+	case ErrFailedReconciliation:
+		return "RECONCILIATION_FAILED"
+	default:
+		return "ACCOUNT_ERROR"
+	}
+}
+
+// CodeOf returns the stable code of the first TransferError or AccountError
+// in err's chain, or "" if there is none
+func CodeOf(err error) string {
+	var transferErr TransferError
+	if errors.As(err, &transferErr) {
+		return transferErr.Code()
+	}
+	var accountErr AccountError
+	if errors.As(err, &accountErr) {
+		return accountErr.Code()
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCodes_AreStableAndUnique(t *testing.T) {
+	codePattern := regexp.MustCompile(`^[A-Z][A-Z_]*[A-Z]$`)
+	errs := []interface {
+		error
+		Code() string
+		HTTPStatus() int
+	}{
+		ErrSameAccountTransfer, ErrNegativeAmount, ErrInvalidAccountIDs,
+		ErrSourceAccountNotFound, ErrDestAccountNotFound, ErrInsufficientFunds,
+		ErrAmountExceedsLimit, ErrFailedDebit, ErrFailedCredit, ErrFailedRecordTxn,
+		ErrServiceUnavailable, ErrTransferConflict, ErrAccountFrozen,
+		ErrTransactionNotFound, ErrAlreadyReversed, ErrInvalidDateRange,
+		ErrInvalidNumberOfTransactions, ErrInvalidControlSum, ErrInvalidReference, ErrInvalidDescription,
+		ErrInvalidSearch, ErrInvalidCursor, ErrDuplicatePaymentFile, ErrPaymentAlreadyBooked, ErrFailedPaymentFile,
+		ErrAccountIDRequired, ErrAccountNotFound, ErrAccountExists,
+		ErrNegativeBalance, ErrFailedCreateAccount, ErrFailedGetAccount,
+		ErrInvalidStatus, ErrFailedUpdateAccount, ErrInvalidBalance,
+		ErrInvalidMetadata, ErrCurrencyMismatch, ErrInvalidImport,
+		ErrInvalidImportRow, ErrImportRejected, ErrAccountNotOpen,
+		ErrAsOfInFuture, ErrTooManyAccounts, ErrFailedStatement,
+		ErrStatementUnrenderable, ErrExternalStatementNotFound, ErrExternalLineNotFound,
+		ErrStatementAlreadyImported, ErrLineAlreadyMatched, ErrLineNotMatched,
+		ErrTransactionAlreadyMatched, ErrMatchMismatch, ErrFailedReconciliation,
+	}
+
+	seen := make(map[string]error)
+	for _, err := range errs {
+		code := err.Code()
+		assert.Regexp(t, codePattern, code, err.Error())
+		if prev, dup := seen[code]; dup {
+			t.Errorf("code %s used by both %q and %q", code, prev, err)
+		}
+		seen[code] = err
+	}
+
+	assert.Equal(t, "INSUFFICIENT_FUNDS", ErrInsufficientFunds.Code())
+	assert.Equal(t, ErrNegativeAmount.Code(), ErrZeroAmount.Code())
+	assert.Equal(t, "ACCOUNT_NOT_FOUND", ErrAccountNotFound.Code())
+}
+
+func TestWithDetails(t *testing.T) {
+	assert.Nil(t, WithDetails(nil, ErrorDetails{"a": 1}))
+	assert.Nil(t, DetailsOf(ErrInsufficientFunds))
+
+	inner := WithDetails(ErrInsufficientFunds, ErrorDetails{"available_balance": "5", "account_id": int64(1)})
+	outer := WithDetails(fmt.Errorf("debit: %w", inner), ErrorDetails{"account_id": int64(2)})
+
+	assert.Equal(t, "debit: insufficient funds", outer.Error())
+	assert.ErrorIs(t, outer, ErrInsufficientFunds)
+	var transferErr TransferError
+	assert.True(t, errors.As(outer, &transferErr))
+	assert.Equal(t, ErrorDetails{"available_balance": "5", "account_id": int64(2)}, DetailsOf(outer))
+	assert.Equal(t, "INSUFFICIENT_FUNDS", CodeOf(outer))
+	assert.Equal(t, "", CodeOf(errors.New("boom")))
+}
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// TransactionFilter narrows a transaction listing. Zero fields match
+// everything.
+type TransactionFilter struct {
+	// AccountID matches transactions to or from the account
+	AccountID int64
+	// From and To bound CreatedAt to [From, To)
+	From time.Time
+	To   time.Time
+	// Reference matches transactions with exactly this reference
+	Reference string
+	// Metadata matches transactions whose metadata holds every one of these
+	// keys with the same value
+	Metadata Metadata
+}
+
+// Validate rejects non-positive account IDs and empty date ranges
+func (f TransactionFilter) Validate() error {
+	if f.AccountID < 0 {
+		return WithDetails(ErrInvalidAccountIDs, ErrorDetails{"field": "account_id"})
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return WithDetails(ErrInvalidDateRange, ErrorDetails{
+			"from": f.From.Format(time.RFC3339),
+			"to":   f.To.Format(time.RFC3339),
+		})
+	}
+	return nil
+}
+
+// Match reports whether txn passes the filter
+func (f TransactionFilter) Match(txn *Transaction) bool {
+	switch {
+	case f.AccountID != 0 && txn.SourceAccountID != f.AccountID && txn.DestinationAccountID != f.AccountID:
+		return false
+	case !f.From.IsZero() && txn.CreatedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !txn.CreatedAt.Before(f.To):
+		return false
+	case f.Reference != "" && txn.Reference != f.Reference:
+		return false
+	}
+	for k, v := range f.Metadata {
+		if got, ok := txn.Metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// IsZero reports whether the filter matches every transaction
+func (f TransactionFilter) IsZero() bool {
+	return f.AccountID == 0 && f.From.IsZero() && f.To.IsZero() && f.Reference == "" && len(f.Metadata) == 0
+}
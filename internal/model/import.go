@@ -0,0 +1,39 @@
+package model
+
+// AccountImportRow is one row of a bulk account import. Err is set when the
+// row could not be parsed; Account is then incomplete.
+type AccountImportRow struct {
+	// Line is the 1-based line of the row in the import file
+	Line    int
+	Account Account
+	Err     error
+}
+
+// AccountImportOptions controls how a bulk account import is applied
+type AccountImportOptions struct {
+	// AllOrNothing creates no account at all if any row fails
+	AllOrNothing bool
+	// DryRun runs every check, including for IDs already taken, but creates
+	// nothing
+	DryRun bool
+}
+
+// ImportRowError reports why one row of an import was not applied
+type ImportRowError struct {
+	Line      int          `json:"line"`
+	AccountID int64        `json:"account_id,omitempty"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   ErrorDetails `json:"details,omitempty"`
+}
+
+// AccountImportReport summarises a bulk account import. Created counts the
+// accounts created, or that would be created on a dry run.
+type AccountImportReport struct {
+	Rows         int              `json:"rows"`
+	Created      int              `json:"created"`
+	Failed       int              `json:"failed"`
+	AllOrNothing bool             `json:"all_or_nothing"`
+	DryRun       bool             `json:"dry_run"`
+	Errors       []ImportRowError `json:"errors"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "github.com/shopspring/decimal"
+
+// BalanceDiscrepancy is an account whose stored balance does not equal its
+// starting balance plus the net of the transactions recorded since
+type BalanceDiscrepancy struct {
+	AccountID  int64           `json:"account_id"`
+	Balance    decimal.Decimal `json:"balance"`
+	Expected   decimal.Decimal `json:"expected"`
+	Difference decimal.Decimal `json:"difference"`
+}
+
+// LedgerReconciliation is the result of checking every account balance
+// against the transaction ledger
+type LedgerReconciliation struct {
+	// Snapshot is the business date of the balance snapshot the check
+	// started from; empty when it started from the opening balances
+	Snapshot        string               `json:"snapshot,omitempty"`
+	AccountsChecked int                  `json:"accounts_checked"`
+	TotalBalance    decimal.Decimal      `json:"total_balance"`
+	Discrepancies   []BalanceDiscrepancy `json:"discrepancies"`
+}
+
+// Balanced reports whether no discrepancies were found
+func (r *LedgerReconciliation) Balanced() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// ReconcileOptions controls a ledger reconciliation
+type ReconcileOptions struct {
+	// Full checks every transaction from the opening balances instead of
+	// starting from the latest balance snapshot
+	Full bool
+}
@@ -0,0 +1,46 @@
+package model
+
+import "fmt"
+
+// Metadata limits, chosen to keep rows small and indexable
+const (
+	MaxMetadataKeys        = 20
+	MaxMetadataKeyLength   = 40
+	MaxMetadataValueLength = 500
+)
+
+// Metadata is free-form, caller-supplied key/value data attached to a
+// record. The system stores and returns it but never interprets it.
+type Metadata map[string]string
+
+// Validate checks m against the metadata limits, returning ErrInvalidMetadata
+// with the offending key in its details. Keys and values follow the rules
+// of transfer references: valid UTF-8 without control characters, which
+// Postgres JSONB would reject or NUL-terminate.
+func (m Metadata) Validate() error {
+	if len(m) > MaxMetadataKeys {
+		return WithDetails(ErrInvalidMetadata, ErrorDetails{
+			"field":  "metadata",
+			"reason": fmt.Sprintf("at most %d keys allowed", MaxMetadataKeys),
+		})
+	}
+	for k, v := range m {
+		var reason string
+		switch {
+		case k == "":
+			reason = "keys cannot be empty"
+		case len(k) > MaxMetadataKeyLength:
+			reason = fmt.Sprintf("keys are limited to %d bytes", MaxMetadataKeyLength)
+		case len(v) > MaxMetadataValueLength:
+			reason = fmt.Sprintf("values are limited to %d bytes", MaxMetadataValueLength)
+		case textProblem(k, MaxMetadataKeyLength) != "":
+			reason = "keys " + textProblem(k, MaxMetadataKeyLength)
+		case textProblem(v, MaxMetadataValueLength) != "":
+			reason = "values " + textProblem(v, MaxMetadataValueLength)
+		default:
+			continue
+		}
+		return WithDetails(ErrInvalidMetadata, ErrorDetails{"field": "metadata." + k, "reason": reason})
+	}
+	return nil
+}
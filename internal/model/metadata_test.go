@@ -0,0 +1,44 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadata_Validate(t *testing.T) {
+	tooMany := Metadata{}
+	for i := 0; i <= MaxMetadataKeys; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+	tests := []struct {
+		name       string
+		metadata   Metadata
+		wantField  string
+		wantReason string
+	}{
+		{"nil", nil, "", ""},
+		{"at the limits", Metadata{strings.Repeat("k", MaxMetadataKeyLength): strings.Repeat("é", MaxMetadataValueLength/2)}, "", ""},
+		{"too many keys", tooMany, "metadata", "at most 20 keys allowed"},
+		{"empty key", Metadata{"": "x"}, "metadata.", "keys cannot be empty"},
+		{"long key", Metadata{strings.Repeat("k", MaxMetadataKeyLength+1): "x"}, "metadata." + strings.Repeat("k", MaxMetadataKeyLength+1), "keys are limited to 40 bytes"},
+		{"long value", Metadata{"k": strings.Repeat("v", MaxMetadataValueLength+1)}, "metadata.k", "values are limited to 500 bytes"},
+		{"NUL in key", Metadata{"a\x00": "x"}, "metadata.a\x00", "keys cannot contain control characters"},
+		{"NUL in value", Metadata{"k": "a\x00b"}, "metadata.k", "values cannot contain control characters"},
+		{"newline in value", Metadata{"k": "a\nb"}, "metadata.k", "values cannot contain control characters"},
+		{"invalid UTF-8 key", Metadata{"\xff": "x"}, "metadata.\xff", "keys must be valid UTF-8"},
+		{"invalid UTF-8 value", Metadata{"k": "\xc3"}, "metadata.k", "values must be valid UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.metadata.Validate()
+			if tt.wantReason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidMetadata)
+			assert.Equal(t, ErrorDetails{"field": tt.wantField, "reason": tt.wantReason}, DetailsOf(err))
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PaymentBatch is a credit-transfer initiation file (ISO 20022 pain.001).
+// Err is set when the batch as a whole is invalid, in which case none of its
+// instructions is executed.
+type PaymentBatch struct {
+	// MessageID and MessageName (e.g. pain.001.001.03) identify the file;
+	// Digest is its SHA-256
+	MessageID   string
+	MessageName string
+	Digest      string
+	CreatedAt   time.Time
+	Payments    []PaymentInstruction
+	Err         error
+}
+
+// PaymentInstruction is one credit transfer of a batch. Err is set when the
+// instruction could not be parsed into a transfer.
+type PaymentInstruction struct {
+	// PaymentInfoID is the payment information block the instruction
+	// belongs to; InstructionID and EndToEndID are the client's references
+	PaymentInfoID        string
+	InstructionID        string
+	EndToEndID           string
+	SourceAccountID      int64
+	DestinationAccountID int64
+	Amount               decimal.Decimal
+	// RemittanceInfo is the first unstructured remittance line, if any
+	RemittanceInfo string
+	Err            error
+}
+
+// TransferDetails returns what the instruction records on its transfer:
+// the end-to-end ID as reference, unless the client left it NOTPROVIDED,
+// and the remittance information as description
+func (p PaymentInstruction) TransferDetails() TransferDetails {
+	d := TransferDetails{Description: p.RemittanceInfo}
+	if p.EndToEndID != "NOTPROVIDED" {
+		d.Reference = p.EndToEndID
+	}
+	return d
+}
+
+// PaymentResult is the outcome of one instruction. Err is nil once the
+// transfer has been booked.
+type PaymentResult struct {
+	Instruction PaymentInstruction
+	Err         error
+}
+
+// PaymentReport is the outcome of every instruction of a batch, in file
+// order
+type PaymentReport struct {
+	// MessageID identifies the report itself
+	MessageID string
+	CreatedAt time.Time
+	Batch     *PaymentBatch
+	Results   []PaymentResult
+	Accepted  int
+	Rejected  int
+}
+
+// PaymentFile records a payment batch by message ID. Report is the status
+// report answered once every payment was processed, nil until then.
+type PaymentFile struct {
+	MessageID  string
+	Digest     string
+	ReceivedAt time.Time
+	Report     []byte
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExternalStatement is a statement received from a partner bank for the
+// account it holds for us. AccountID is the ledger account that mirrors
+// it, so each of its lines should have a counterpart among that account's
+// transactions.
+type ExternalStatement struct {
+	ID        int64  `json:"id"`
+	AccountID int64  `json:"account_id"`
+	Format    string `json:"format"`
+	// Reference is the statement's own identification, such as the
+	// camt.053 message ID; empty when the format has none
+	Reference string `json:"reference"`
+	// Digest is the SHA-256 of the imported file; a file is imported once
+	// per account
+	Digest     string          `json:"digest"`
+	ImportedAt time.Time       `json:"imported_at"`
+	Lines      []*ExternalLine `json:"lines"`
+}
+
+// ExternalLineStatus is whether a line has an internal counterpart
+type ExternalLineStatus string
+
+const (
+	LineUnmatched ExternalLineStatus = "unmatched"
+	LineMatched   ExternalLineStatus = "matched"
+)
+
+// ExternalLine is one booked entry of an external statement
+type ExternalLine struct {
+	ID          int64 `json:"id"`
+	StatementID int64 `json:"statement_id"`
+	AccountID   int64 `json:"account_id"`
+	// LineNo is the 1-based position of the line in its statement
+	LineNo   int       `json:"line_no"`
+	BookedAt time.Time `json:"booked_at"`
+	// Amount is positive when the partner bank credited the account and
+	// negative when it debited it, like StatementEntry.Amount
+	Amount      decimal.Decimal    `json:"amount"`
+	Reference   string             `json:"reference"`
+	Description string             `json:"description"`
+	Status      ExternalLineStatus `json:"status"`
+	Match       *ExternalMatch     `json:"match"`
+	// Candidates are the transactions that could be the line's counterpart,
+	// best first; only listed for unmatched lines under review
+	Candidates []MatchCandidate `json:"candidates,omitempty"`
+}
+
+// MatchMethod is how a line was paired with its transaction
+type MatchMethod string
+
+const (
+	// MatchAuto is a pairing made by the matching engine
+	MatchAuto MatchMethod = "auto"
+	// MatchManual is a pairing made by a reviewer
+	MatchManual MatchMethod = "manual"
+	// MatchAdjustment pairs a line with an adjusting transfer booked for it
+	MatchAdjustment MatchMethod = "adjustment"
+)
+
+// ExternalMatch pairs an external line with the transaction that accounts
+// for it. A transaction is paired with at most one line.
+type ExternalMatch struct {
+	LineID        int64       `json:"-"`
+	TransactionID int64       `json:"transaction_id"`
+	Method        MatchMethod `json:"method"`
+	// Confidence is the matching engine's score, 0 to 100; manual and
+	// adjustment matches are 100
+	Confidence int       `json:"confidence"`
+	MatchedAt  time.Time `json:"matched_at"`
+}
+
+// MatchCandidate is a transaction scored against an external line
+type MatchCandidate struct {
+	TransactionID int64     `json:"transaction_id"`
+	BookedAt      time.Time `json:"booked_at"`
+	// Confidence is 0 to 100: the amount, the distance between the dates
+	// and the reference each contribute
+	Confidence int `json:"confidence"`
+	// ReferenceMatch reports whether the line's reference names the
+	// transaction
+	ReferenceMatch bool `json:"reference_match"`
+	// DaysApart is the number of days between the two booking dates
+	DaysApart int `json:"days_apart"`
+}
+
+// ExternalLineFilter narrows a listing of external lines. Zero fields match
+// everything.
+type ExternalLineFilter struct {
+	AccountID   int64
+	StatementID int64
+	Status      ExternalLineStatus
+}
+
+// Match reports whether line passes the filter
+func (f ExternalLineFilter) Match(line *ExternalLine) bool {
+	switch {
+	case f.AccountID != 0 && line.AccountID != f.AccountID:
+		return false
+	case f.StatementID != 0 && line.StatementID != f.StatementID:
+		return false
+	case f.Status != "" && line.Status != f.Status:
+		return false
+	}
+	return true
+}
+
+// MatchReport summarises a matching run over a statement's lines
+type MatchReport struct {
+	StatementID int64 `json:"statement_id"`
+	// Matched counts the lines matched by this run
+	Matched int `json:"matched"`
+	// Unmatched counts the lines still without a counterpart
+	Unmatched int `json:"unmatched"`
+}
@@ -0,0 +1,277 @@
+package model
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Search limits
+const (
+	DefaultSearchLimit = 50
+	MaxSearchLimit     = 200
+	// MaxSearchTextLength bounds the free-text filter, which can match a
+	// description
+	MaxSearchTextLength = MaxDescriptionLength
+)
+
+// TransactionType tells transfers from the reversals of earlier transfers
+type TransactionType string
+
+const (
+	TransactionTransfer TransactionType = "transfer"
+	TransactionReversal TransactionType = "reversal"
+)
+
+// TransactionStatus is whether a transaction still stands or has been
+// reversed by a later one
+type TransactionStatus string
+
+const (
+	TransactionBooked   TransactionStatus = "booked"
+	TransactionReversed TransactionStatus = "reversed"
+)
+
+// TransactionSort orders search results. Ties are broken by ID in the same
+// direction, so the order is total and cursors are stable.
+type TransactionSort string
+
+const (
+	SortNewestFirst   TransactionSort = "-created_at"
+	SortOldestFirst   TransactionSort = "created_at"
+	SortLargestFirst  TransactionSort = "-amount"
+	SortSmallestFirst TransactionSort = "amount"
+)
+
+// TransactionSearch is a page request over every transaction. Zero fields
+// match everything; set fields must all match.
+type TransactionSearch struct {
+	// AccountID and CounterpartyID each match transactions to or from the
+	// account; together they match transfers between the two
+	AccountID      int64
+	CounterpartyID int64
+	// MinAmount and MaxAmount bound Amount, both inclusive
+	MinAmount decimal.NullDecimal
+	MaxAmount decimal.NullDecimal
+	// From and To bound CreatedAt to [From, To)
+	From time.Time
+	To   time.Time
+	// Reference matches exactly; Text matches a case-insensitive substring
+	// of the reference or the description
+	Reference string
+	Text      string
+	Type      TransactionType
+	Status    TransactionStatus
+	// Sort defaults to SortNewestFirst
+	Sort TransactionSort
+	// After continues a previous page; its sort must be Sort
+	After *SearchCursor
+	Limit int
+}
+
+// Validate checks the search before it reaches a store. Sort must be set.
+func (s TransactionSearch) Validate() error {
+	invalid := func(field, reason string) error {
+		return WithDetails(ErrInvalidSearch, ErrorDetails{"field": field, "reason": reason})
+	}
+	switch {
+	case s.AccountID < 0:
+		return invalid("account_id", "must be a positive integer")
+	case s.CounterpartyID < 0:
+		return invalid("counterparty_id", "must be a positive integer")
+	case s.MinAmount.Valid && s.MaxAmount.Valid && s.MinAmount.Decimal.GreaterThan(s.MaxAmount.Decimal):
+		return invalid("min_amount", "must not exceed max_amount")
+	case s.Limit < 1 || s.Limit > MaxSearchLimit:
+		return invalid("limit", "must be between 1 and "+strconv.Itoa(MaxSearchLimit))
+	}
+	if reason := textProblem(s.Text, MaxSearchTextLength); reason != "" {
+		return invalid("q", reason)
+	}
+	switch s.Type {
+	case "", TransactionTransfer, TransactionReversal:
+	default:
+		return invalid("type", "must be transfer or reversal")
+	}
+	switch s.Status {
+	case "", TransactionBooked, TransactionReversed:
+	default:
+		return invalid("status", "must be booked or reversed")
+	}
+	if !s.Sort.valid() {
+		return invalid("sort", "must be one of created_at, -created_at, amount, -amount")
+	}
+	if s.After != nil && s.After.Sort != s.Sort {
+		return WithDetails(ErrInvalidCursor, ErrorDetails{"reason": "the cursor belongs to another sort order"})
+	}
+	return (TransactionFilter{From: s.From, To: s.To}).Validate()
+}
+
+// Match reports whether hit passes the filters of the search; the cursor
+// and the limit are not considered
+func (s TransactionSearch) Match(hit *TransactionHit) bool {
+	txn := hit.Transaction
+	switch {
+	case s.AccountID != 0 && !txn.involves(s.AccountID):
+		return false
+	case s.CounterpartyID != 0 && !txn.involves(s.CounterpartyID):
+		return false
+	case s.MinAmount.Valid && txn.Amount.LessThan(s.MinAmount.Decimal):
+		return false
+	case s.MaxAmount.Valid && txn.Amount.GreaterThan(s.MaxAmount.Decimal):
+		return false
+	case !s.From.IsZero() && txn.CreatedAt.Before(s.From):
+		return false
+	case !s.To.IsZero() && !txn.CreatedAt.Before(s.To):
+		return false
+	case s.Reference != "" && txn.Reference != s.Reference:
+		return false
+	case s.Text != "" && !containsFold(txn.Reference, s.Text) && !containsFold(txn.Description, s.Text):
+		return false
+	case s.Type != "" && hit.Type != s.Type:
+		return false
+	case s.Status != "" && hit.Status != s.Status:
+		return false
+	}
+	return true
+}
+
+func (t *Transaction) involves(accountID int64) bool {
+	return t.SourceAccountID == accountID || t.DestinationAccountID == accountID
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func (s TransactionSort) valid() bool {
+	switch s {
+	case SortNewestFirst, SortOldestFirst, SortLargestFirst, SortSmallestFirst:
+		return true
+	}
+	return false
+}
+
+// Descending reports whether the sort puts larger values first
+func (s TransactionSort) Descending() bool {
+	return strings.HasPrefix(string(s), "-")
+}
+
+// ByAmount reports whether the sort is by amount rather than by time
+func (s TransactionSort) ByAmount() bool {
+	return strings.TrimPrefix(string(s), "-") == "amount"
+}
+
+// Less reports whether a comes before b in the sort order
+func (s TransactionSort) Less(a, b *Transaction) bool {
+	var cmp int
+	if s.ByAmount() {
+		cmp = a.Amount.Cmp(b.Amount)
+	} else {
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if cmp == 0 {
+		cmp = compareIDs(a.ID, b.ID)
+	}
+	if s.Descending() {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func compareIDs(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// TransactionHit is a transaction found by a search, with its type and
+// status
+type TransactionHit struct {
+	*Transaction
+	Type   TransactionType   `json:"type"`
+	Status TransactionStatus `json:"status"`
+	// ReversedBy is the ID of the reversal of a reversed transaction
+	ReversedBy *int64 `json:"reversed_by,omitempty"`
+}
+
+// NewTransactionHit derives the type and status of txn; reversedBy is the
+// ID of its reversal, if any
+func NewTransactionHit(txn *Transaction, reversedBy *int64) *TransactionHit {
+	hit := &TransactionHit{Transaction: txn, Type: TransactionTransfer, Status: TransactionBooked, ReversedBy: reversedBy}
+	if txn.ReversalOf != nil {
+		hit.Type = TransactionReversal
+	}
+	if reversedBy != nil {
+		hit.Status = TransactionReversed
+	}
+	return hit
+}
+
+// TransactionPage is one page of search results. NextCursor is empty on the
+// last page.
+type TransactionPage struct {
+	Transactions []*TransactionHit `json:"transactions"`
+	NextCursor   string            `json:"next_cursor,omitempty"`
+}
+
+// SearchCursor is the position of the last transaction of a page in its
+// sort order; the next page starts right after it
+type SearchCursor struct {
+	Sort      TransactionSort
+	CreatedAt time.Time
+	Amount    decimal.Decimal
+	ID        int64
+}
+
+// CursorAfter returns the cursor that continues a search after txn
+func CursorAfter(sort TransactionSort, txn *Transaction) *SearchCursor {
+	return &SearchCursor{Sort: sort, CreatedAt: txn.CreatedAt, Amount: txn.Amount, ID: txn.ID}
+}
+
+// Precedes reports whether txn comes after the cursor's position
+func (c *SearchCursor) Precedes(txn *Transaction) bool {
+	return c.Sort.Less(&Transaction{ID: c.ID, CreatedAt: c.CreatedAt, Amount: c.Amount}, txn)
+}
+
+// String encodes the cursor as an opaque URL-safe token
+func (c *SearchCursor) String() string {
+	key := c.CreatedAt.UTC().Format(time.RFC3339Nano)
+	if c.Sort.ByAmount() {
+		key = c.Amount.String()
+	}
+	raw := strings.Join([]string{string(c.Sort), key, strconv.FormatInt(c.ID, 10)}, "|")
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseSearchCursor decodes a token made by SearchCursor.String
+func ParseSearchCursor(token string) (*SearchCursor, error) {
+	invalid := WithDetails(ErrInvalidCursor, ErrorDetails{"reason": "malformed cursor"})
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, invalid
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, invalid
+	}
+	c := &SearchCursor{Sort: TransactionSort(parts[0])}
+	if c.ID, err = strconv.ParseInt(parts[2], 10, 64); err != nil || !c.Sort.valid() {
+		return nil, invalid
+	}
+	if c.Sort.ByAmount() {
+		c.Amount, err = decimal.NewFromString(parts[1])
+	} else {
+		c.CreatedAt, err = time.Parse(time.RFC3339Nano, parts[1])
+	}
+	if err != nil {
+		return nil, invalid
+	}
+	return c, nil
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionSearch_Validate(t *testing.T) {
+	valid := func() TransactionSearch { return TransactionSearch{Sort: SortNewestFirst, Limit: 10} }
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		modify    func(s *TransactionSearch)
+		wantErr   error
+		wantField string
+	}{
+		{"valid", func(s *TransactionSearch) {}, nil, ""},
+		{"all filters", func(s *TransactionSearch) {
+			*s = TransactionSearch{AccountID: 1, CounterpartyID: 2, MinAmount: decimal.NewNullDecimal(decimal.NewFromInt(1)),
+				MaxAmount: decimal.NewNullDecimal(decimal.NewFromInt(1)), From: at, To: at.AddDate(0, 0, 1), Reference: "INV-7",
+				Text: "invoice", Type: TransactionReversal, Status: TransactionBooked, Sort: SortSmallestFirst, Limit: MaxSearchLimit}
+		}, nil, ""},
+		{"negative account", func(s *TransactionSearch) { s.AccountID = -1 }, ErrInvalidSearch, "account_id"},
+		{"amount range", func(s *TransactionSearch) {
+			s.MinAmount, s.MaxAmount = decimal.NewNullDecimal(decimal.NewFromInt(2)), decimal.NewNullDecimal(decimal.NewFromInt(1))
+		}, ErrInvalidSearch, "min_amount"},
+		{"limit too large", func(s *TransactionSearch) { s.Limit = MaxSearchLimit + 1 }, ErrInvalidSearch, "limit"},
+		{"text too long", func(s *TransactionSearch) { s.Text = strings.Repeat("x", MaxSearchTextLength+1) }, ErrInvalidSearch, "q"},
+		{"unknown type", func(s *TransactionSearch) { s.Type = "refund" }, ErrInvalidSearch, "type"},
+		{"unknown status", func(s *TransactionSearch) { s.Status = "pending" }, ErrInvalidSearch, "status"},
+		{"unknown sort", func(s *TransactionSearch) { s.Sort = "id" }, ErrInvalidSearch, "sort"},
+		{"cursor of another sort", func(s *TransactionSearch) { s.After = &SearchCursor{Sort: SortOldestFirst} }, ErrInvalidCursor, ""},
+		{"empty date range", func(s *TransactionSearch) { s.From, s.To = at, at }, ErrInvalidDateRange, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.modify(&s)
+			err := s.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantField != "" {
+				assert.Equal(t, tt.wantField, DetailsOf(err)["field"])
+			}
+		})
+	}
+}
+
+func TestTransactionSort_Less(t *testing.T) {
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	small := &Transaction{ID: 1, Amount: decimal.NewFromInt(5), CreatedAt: at.Add(time.Hour)}
+	large := &Transaction{ID: 2, Amount: decimal.NewFromInt(50), CreatedAt: at}
+	sameTime := &Transaction{ID: 3, Amount: decimal.NewFromInt(50), CreatedAt: at}
+
+	assert.True(t, SortNewestFirst.Less(small, large))
+	assert.True(t, SortOldestFirst.Less(large, small))
+	assert.True(t, SortLargestFirst.Less(large, small))
+	assert.True(t, SortSmallestFirst.Less(small, large))
+	assert.True(t, SortOldestFirst.Less(large, sameTime), "ties by ID")
+	assert.True(t, SortLargestFirst.Less(sameTime, large), "ties by ID, descending")
+}
+
+func TestSearchCursor(t *testing.T) {
+	txn := &Transaction{ID: 42, Amount: decimal.RequireFromString("12.50"), CreatedAt: time.Date(2026, 3, 1, 9, 30, 0, 123456000, time.UTC)}
+	for _, sort := range []TransactionSort{SortNewestFirst, SortOldestFirst, SortLargestFirst, SortSmallestFirst} {
+		t.Run(string(sort), func(t *testing.T) {
+			c, err := ParseSearchCursor(CursorAfter(sort, txn).String())
+			require.NoError(t, err)
+			assert.Equal(t, sort, c.Sort)
+			assert.Equal(t, int64(42), c.ID)
+			assert.False(t, c.Precedes(txn), "the next page starts after the cursor")
+			if sort.ByAmount() {
+				assert.True(t, c.Amount.Equal(txn.Amount))
+			} else {
+				assert.True(t, c.CreatedAt.Equal(txn.CreatedAt))
+			}
+		})
+	}
+
+	for _, token := range []string{"not base64!", "LWNyZWF0ZWRfYXQ", "aWR8MXwy"} {
+		_, err := ParseSearchCursor(token)
+		assert.ErrorIs(t, err, ErrInvalidCursor, token)
+	}
+}
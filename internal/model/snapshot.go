@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BusinessDateLayout formats business dates
+const BusinessDateLayout = time.DateOnly
+
+// SnapshotPeriod is the business day a balance snapshot covers: the
+// transactions created at or after Start and before End
+type SnapshotPeriod struct {
+	// Date is the business date, at midnight UTC
+	Date  time.Time
+	Start time.Time
+	End   time.Time
+}
+
+// NewSnapshotPeriod returns the business day containing t in loc
+func NewSnapshotPeriod(t time.Time, loc *time.Location) SnapshotPeriod {
+	y, m, d := t.In(loc).Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	return SnapshotPeriod{
+		Date:  time.Date(y, m, d, 0, 0, 0, 0, time.UTC),
+		Start: start,
+		End:   start.AddDate(0, 0, 1),
+	}
+}
+
+// Next returns the following business day
+func (p SnapshotPeriod) Next() SnapshotPeriod {
+	return NewSnapshotPeriod(p.End, p.Start.Location())
+}
+
+// BalanceSnapshot is an account's closing balance and activity for one
+// business day
+type BalanceSnapshot struct {
+	AccountID      int64           `json:"account_id"`
+	BusinessDate   time.Time       `json:"business_date"`
+	PeriodEnd      time.Time       `json:"period_end"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+	ClosingBalance decimal.Decimal `json:"closing_balance"`
+	// TotalDebits is the money that left the account during the day
+	TotalDebits decimal.Decimal `json:"total_debits"`
+	// TotalCredits is the money that entered the account during the day
+	TotalCredits decimal.Decimal `json:"total_credits"`
+	DebitCount   int             `json:"debit_count"`
+	CreditCount  int             `json:"credit_count"`
+}
+
+// SnapshotRun records that every account open at the end of a business day
+// has been snapshotted for it
+type SnapshotRun struct {
+	BusinessDate time.Time `json:"business_date"`
+	PeriodStart  time.Time `json:"period_start"`
+	PeriodEnd    time.Time `json:"period_end"`
+	Accounts     int       `json:"accounts"`
+	CompletedAt  time.Time `json:"completed_at"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSnapshotPeriod(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		loc    *time.Location
+		date   string
+		length time.Duration
+	}{
+		{"utc", time.Date(2024, 3, 1, 23, 59, 0, 0, time.UTC), time.UTC, "2024-03-01", 24 * time.Hour},
+		{"instant in another zone", time.Date(2024, 3, 1, 23, 30, 0, 0, time.UTC), berlin, "2024-03-02", 24 * time.Hour},
+		{"clocks go forward", time.Date(2024, 3, 31, 12, 0, 0, 0, berlin), berlin, "2024-03-31", 23 * time.Hour},
+		{"clocks go back", time.Date(2024, 10, 27, 12, 0, 0, 0, berlin), berlin, "2024-10-27", 25 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewSnapshotPeriod(tt.at, tt.loc)
+			assert.Equal(t, tt.date, p.Date.Format(BusinessDateLayout))
+			assert.Equal(t, tt.length, p.End.Sub(p.Start))
+			assert.Equal(t, p.End, p.Next().Start)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Statement is an account's activity over a period: the transactions
+// created at or after From and before To, with the balances around them
+type Statement struct {
+	AccountID      int64            `json:"account_id"`
+	Currency       string           `json:"currency"`
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	OpeningBalance decimal.Decimal  `json:"opening_balance"`
+	ClosingBalance decimal.Decimal  `json:"closing_balance"`
+	TotalDebits    decimal.Decimal  `json:"total_debits"`
+	TotalCredits   decimal.Decimal  `json:"total_credits"`
+	Entries        []StatementEntry `json:"entries"`
+	// GeneratedAt is when the statement was built
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// StatementEntry is one transaction as seen from the statement's account
+type StatementEntry struct {
+	TransactionID int64     `json:"transaction_id"`
+	BookedAt      time.Time `json:"booked_at"`
+	// CounterpartyAccountID is the other account of the transfer
+	CounterpartyAccountID int64 `json:"counterparty_account_id"`
+	// Amount is negative for debits and positive for credits
+	Amount decimal.Decimal `json:"amount"`
+	// Balance is the account's balance right after the transaction
+	Balance    decimal.Decimal `json:"balance"`
+	ReversalOf *int64          `json:"reversal_of"`
+}
+
+// Debit reports whether the entry took money out of the account
+func (e *StatementEntry) Debit() bool {
+	return e.Amount.IsNegative()
+}
+
+// NewStatementEntry returns txn as seen from accountID, one of its accounts
+func NewStatementEntry(txn *Transaction, accountID int64) StatementEntry {
+	entry := StatementEntry{
+		TransactionID:         txn.ID,
+		BookedAt:              txn.CreatedAt,
+		CounterpartyAccountID: txn.DestinationAccountID,
+		Amount:                txn.Amount.Neg(),
+		Balance:               txn.BalanceAfter(accountID),
+		ReversalOf:            txn.ReversalOf,
+	}
+	if txn.DestinationAccountID == accountID {
+		entry.CounterpartyAccountID = txn.SourceAccountID
+		entry.Amount = txn.Amount
+	}
+	return entry
+}
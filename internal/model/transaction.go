@@ -1,15 +1,82 @@
 package model
 
 import (
+	"fmt"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/shopspring/decimal"
 )
 
+// Transfer details limits: the lengths of an ISO 20022 end-to-end ID and of
+// unstructured remittance information, so details survive a payment file or
+// a bank statement unchanged
+const (
+	MaxReferenceLength   = 35
+	MaxDescriptionLength = 140
+)
+
 type Transaction struct {
 	ID                   int64           `json:"id"`
 	SourceAccountID      int64           `json:"source_account_id"`
 	DestinationAccountID int64           `json:"destination_account_id"`
 	Amount               decimal.Decimal `json:"amount"`
 	CreatedAt            time.Time       `json:"created_at"`
+	// ReversalOf is the ID of the transaction this one reverses, if any. A
+	// transaction can be reversed at most once.
+	ReversalOf *int64 `json:"reversal_of,omitempty"`
+	TransferDetails
+	// SourceBalanceAfter and DestinationBalanceAfter are the balances of the
+	// two accounts right after the transaction; they answer point-in-time
+	// balance queries without summing the history
+	SourceBalanceAfter      decimal.Decimal `json:"-"`
+	DestinationBalanceAfter decimal.Decimal `json:"-"`
+}
+
+// BalanceAfter returns the balance of accountID right after the
+// transaction, which must involve it
+func (t *Transaction) BalanceAfter(accountID int64) decimal.Decimal {
+	if accountID == t.SourceAccountID {
+		return t.SourceBalanceAfter
+	}
+	return t.DestinationBalanceAfter
+}
+
+// TransferDetails are the optional, caller-supplied fields of a transfer.
+// Like account metadata they are stored and returned but never interpreted.
+type TransferDetails struct {
+	// Reference is the caller's identifier for the transfer, such as an
+	// invoice number; it need not be unique
+	Reference   string   `json:"reference,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Metadata    Metadata `json:"metadata,omitempty"`
+}
+
+// Validate checks the details against their limits. Reference and
+// description must be valid UTF-8 without control characters.
+func (d TransferDetails) Validate() error {
+	if reason := textProblem(d.Reference, MaxReferenceLength); reason != "" {
+		return WithDetails(ErrInvalidReference, ErrorDetails{"field": "reference", "reason": reason})
+	}
+	if reason := textProblem(d.Description, MaxDescriptionLength); reason != "" {
+		return WithDetails(ErrInvalidDescription, ErrorDetails{"field": "description", "reason": reason})
+	}
+	return d.Metadata.Validate()
+}
+
+// textProblem describes what is wrong with a free-text field, if anything
+func textProblem(s string, maxLength int) string {
+	switch {
+	case !utf8.ValidString(s):
+		return "must be valid UTF-8"
+	case utf8.RuneCountInString(s) > maxLength:
+		return fmt.Sprintf("is limited to %d characters", maxLength)
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return "cannot contain control characters"
+		}
+	}
+	return ""
 }
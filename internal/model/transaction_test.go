@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -138,3 +139,55 @@ func TestTransaction_DifferentAccounts(t *testing.T) {
 		assert.Equal(t, transaction.SourceAccountID, transaction.DestinationAccountID)
 	})
 }
+
+func TestTransferDetails_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		details   TransferDetails
+		wantErr   error
+		wantField string
+	}{
+		{"empty", TransferDetails{}, nil, ""},
+		{"full", TransferDetails{Reference: strings.Repeat("R", MaxReferenceLength), Description: strings.Repeat("é", MaxDescriptionLength),
+			Metadata: Metadata{"invoice": "INV-1"}}, nil, ""},
+		{"long reference", TransferDetails{Reference: strings.Repeat("R", MaxReferenceLength+1)}, ErrInvalidReference, "reference"},
+		{"control character", TransferDetails{Reference: "INV\n1"}, ErrInvalidReference, "reference"},
+		{"invalid UTF-8", TransferDetails{Description: "\xff"}, ErrInvalidDescription, "description"},
+		{"long description", TransferDetails{Description: strings.Repeat("d", MaxDescriptionLength+1)}, ErrInvalidDescription, "description"},
+		{"bad metadata", TransferDetails{Metadata: Metadata{"": "x"}}, ErrInvalidMetadata, "metadata."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.details.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantField, DetailsOf(err)["field"])
+		})
+	}
+}
+
+func TestTransactionFilter_Match(t *testing.T) {
+	txn := &Transaction{SourceAccountID: 1, DestinationAccountID: 2, CreatedAt: time.Now(),
+		TransferDetails: TransferDetails{Reference: "INV-1", Metadata: Metadata{"order": "42", "channel": "web"}}}
+	tests := []struct {
+		name   string
+		filter TransactionFilter
+		want   bool
+	}{
+		{"no filter", TransactionFilter{}, true},
+		{"reference", TransactionFilter{Reference: "INV-1"}, true},
+		{"other reference", TransactionFilter{Reference: "INV-2"}, false},
+		{"metadata subset", TransactionFilter{Metadata: Metadata{"order": "42"}}, true},
+		{"metadata value differs", TransactionFilter{Metadata: Metadata{"order": "43"}}, false},
+		{"metadata key missing", TransactionFilter{Metadata: Metadata{"order": "42", "coupon": "X"}}, false},
+		{"combined", TransactionFilter{AccountID: 2, Reference: "INV-1", Metadata: Metadata{"channel": "web"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Match(txn))
+		})
+	}
+}
@@ -3,71 +3,203 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
 
 	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+	"github.com/hidimpu/transfersystem/internal/utils"
 
 	"github.com/shopspring/decimal"
 )
 
 type AccountRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *utils.Logger
 }
 
+var _ AccountStore = (*AccountRepository)(nil)
+
 func NewAccountRepository(db *sql.DB) *AccountRepository {
-	return &AccountRepository{db: db}
+	return &AccountRepository{db: db, logger: utils.GlobalLogger}
 }
 
 // Create creates a new account with proper validation
-func (r *AccountRepository) Create(ctx context.Context, acc *model.Account) error {
-	_, err := r.db.ExecContext(ctx, `INSERT INTO accounts(account_id, balance) VALUES($1, $2)`, acc.ID, acc.Balance)
-	return err
+func (r *AccountRepository) Create(ctx context.Context, acc *model.Account) (retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.insert", "INSERT", tracing.AttrAccountID.Int64(acc.ID))
+	defer func() { tracing.End(span, retErr) }()
+
+	metadata, err := metadataJSON(acc.Metadata)
+	if err != nil {
+		return err
+	}
+	return r.db.QueryRowContext(ctx, `INSERT INTO accounts(account_id, balance, opening_balance, metadata) VALUES($1, $2, $2, $3) RETURNING created_at`,
+		acc.ID, acc.Balance, metadata).Scan(&acc.CreatedAt)
 }
 
-// GetByID retrieves an account by ID with proper error handling
-func (r *AccountRepository) GetByID(ctx context.Context, id int64) (*model.Account, error) {
-	var acc model.Account
-	var balanceStr string
+// ImportAccounts streams accounts into a temporary table with COPY and
+// inserts those whose ID is free in a single statement, all in one database
+// transaction. It returns the IDs that were already taken. The transaction is
+// rolled back on a dry run, or when opts.AllOrNothing is set and an ID was
+// taken.
+func (r *AccountRepository) ImportAccounts(ctx context.Context, accounts []*model.Account, opts model.AccountImportOptions) (_ []int64, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.import", "COPY")
+	defer func() { tracing.End(span, retErr) }()
 
-	err := r.db.QueryRowContext(ctx, `SELECT account_id, balance FROM accounts WHERE account_id=$1`, id).
-		Scan(&acc.ID, &balanceStr)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, model.ErrAccountNotFound
+		return nil, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
 		}
+	}()
+
+	if _, err := tx.ExecContext(ctx, `
+		CREATE TEMP TABLE account_import (
+			account_id BIGINT NOT NULL,
+			balance DECIMAL(20,5) NOT NULL,
+			metadata JSONB
+		) ON COMMIT DROP`); err != nil {
 		return nil, err
 	}
 
-	balance, convErr := decimal.NewFromString(balanceStr)
-	if convErr != nil {
-		return nil, model.ErrFailedGetAccount
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("account_import", "account_id", "balance", "metadata"))
+	if err != nil {
+		return nil, err
+	}
+	for _, acc := range accounts {
+		metadata, err := metadataJSON(acc.Metadata)
+		if err != nil {
+			stmt.Close()
+			return nil, err
+		}
+		if _, err := stmt.ExecContext(ctx, acc.ID, acc.Balance.String(), metadata); err != nil {
+			stmt.Close()
+			return nil, err
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return nil, err
+	}
+	if err := stmt.Close(); err != nil {
+		return nil, err
 	}
-	acc.Balance = balance
 
-	return &acc, nil
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO accounts (account_id, balance, opening_balance, metadata)
+		SELECT account_id, balance, balance, metadata FROM account_import
+		ON CONFLICT (account_id) DO NOTHING
+		RETURNING account_id`)
+	if err != nil {
+		return nil, err
+	}
+	inserted := make(map[int64]bool, len(accounts))
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		inserted[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var taken []int64
+	for _, acc := range accounts {
+		if !inserted[acc.ID] {
+			taken = append(taken, acc.ID)
+		}
+	}
+	if opts.DryRun || (opts.AllOrNothing && len(taken) > 0) {
+		return taken, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	committed = true
+
+	r.logger.LogDebug(ctx, "ACCOUNT_IMPORT", fmt.Sprintf("Copied %d accounts, %d already existed", len(inserted), len(taken)))
+	return taken, nil
+}
+
+// GetByID retrieves an account by ID with proper error handling
+func (r *AccountRepository) GetByID(ctx context.Context, id int64) (_ *model.Account, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.select_by_id", "SELECT", tracing.AttrAccountID.Int64(id))
+	defer func() { tracing.End(span, retErr) }()
+
+	acc, err := scanAccount(r.db.QueryRowContext(ctx, `SELECT `+accountColumns+` FROM accounts WHERE account_id=$1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrAccountNotFound
+		}
+		return nil, err
+	}
+	return acc, nil
 }
 
 // GetByIDWithLock retrieves an account with FOR UPDATE lock for transactions
-func (r *AccountRepository) GetByIDWithLock(ctx context.Context, id int64, tx *sql.Tx) (*model.Account, error) {
-	var acc model.Account
-	var balanceStr string
+func (r *AccountRepository) GetByIDWithLock(ctx context.Context, id int64, tx *sql.Tx) (_ *model.Account, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.select_for_update", "SELECT", tracing.AttrAccountID.Int64(id))
+	defer func() { tracing.End(span, retErr) }()
 
-	err := tx.QueryRowContext(ctx, `SELECT account_id, balance FROM accounts WHERE account_id=$1 FOR UPDATE`, id).
-		Scan(&acc.ID, &balanceStr)
+	acc, err := scanAccount(tx.QueryRowContext(ctx, `SELECT `+accountColumns+` FROM accounts WHERE account_id=$1 FOR UPDATE`, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, model.ErrAccountNotFound
 		}
 		return nil, err
 	}
+	r.logger.LogDebug(ctx, "ACCOUNT_LOCK", fmt.Sprintf("Acquired row lock on account %d", id))
+	return acc, nil
+}
 
-	balance, convErr := decimal.NewFromString(balanceStr)
-	if convErr != nil {
-		return nil, model.ErrFailedGetAccount
+// LockAccountsTx locks every given account with a single
+// SELECT ... ORDER BY account_id FOR UPDATE. Because rows are always locked in
+// ascending ID order, two transactions locking overlapping sets of accounts
+// (e.g. A->B and B->A transfers) queue behind each other instead of
+// deadlocking. Accounts that do not exist are absent from the returned map.
+func (r *AccountRepository) LockAccountsTx(ctx context.Context, ids []int64, tx *sql.Tx) (_ map[int64]*model.Account, retErr error) {
+	ids = sortedUniqueIDs(ids)
+	ctx, span := tracing.StartDB(ctx, "accounts.select_many_for_update", "SELECT",
+		tracing.AttrAccountIDs.Int64Slice(ids))
+	defer func() { tracing.End(span, retErr) }()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+accountColumns+` FROM accounts
+		WHERE account_id = ANY($1)
+		ORDER BY account_id
+		FOR UPDATE`, pq.Array(ids))
+	if err != nil {
+		return nil, err
 	}
-	acc.Balance = balance
+	defer rows.Close()
 
-	return &acc, nil
+	accounts := make(map[int64]*model.Account, len(ids))
+	for rows.Next() {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts[acc.ID] = acc
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	r.logger.LogDebug(ctx, "ACCOUNT_LOCK", fmt.Sprintf("Acquired row locks on accounts %v", ids))
+	return accounts, nil
 }
 
 // UpdateBalanceTx updates account balance with row-level locking and validation
@@ -77,21 +209,38 @@ func (r *AccountRepository) UpdateBalanceTx(ctx context.Context, id int64, diff
 	if err != nil {
 		return err
 	}
+	return r.UpdateLockedBalanceTx(ctx, account, diff, tx)
+}
+
+// UpdateLockedBalanceTx applies diff to an account whose row is already
+// locked by tx (see LockAccountsTx), rejecting changes that would make the
+// balance negative. account.Balance is updated to the new balance.
+func (r *AccountRepository) UpdateLockedBalanceTx(ctx context.Context, account *model.Account, diff decimal.Decimal, tx *sql.Tx) (retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.update_balance", "UPDATE",
+		tracing.AttrAccountID.Int64(account.ID), tracing.AttrAmount.String(diff.String()))
+	defer func() { tracing.End(span, retErr) }()
 
 	// Calculate new balance
 	newBalance := account.Balance.Add(diff)
 	if newBalance.IsNegative() {
+		r.logger.LogDebug(ctx, "ACCOUNT_UPDATE_BALANCE", fmt.Sprintf("Rejected balance change %s on account %d", diff.String(), account.ID))
 		return model.ErrInsufficientFunds
 	}
 
 	// Update the balance
-	_, err = tx.ExecContext(ctx, `UPDATE accounts SET balance = balance + $1 WHERE account_id=$2`, diff, id)
-	return err
+	if _, err := tx.ExecContext(ctx, `UPDATE accounts SET balance = balance + $1 WHERE account_id=$2`, diff, account.ID); err != nil {
+		return err
+	}
+	account.Balance = newBalance
+	return nil
 }
 
 // GetAll retrieves all accounts (for admin purposes)
-func (r *AccountRepository) GetAll(ctx context.Context) ([]*model.Account, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT account_id, balance FROM accounts ORDER BY account_id`)
+func (r *AccountRepository) GetAll(ctx context.Context) (_ []*model.Account, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.select_all", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+accountColumns+` FROM accounts ORDER BY account_id`)
 	if err != nil {
 		return nil, err
 	}
@@ -99,31 +248,123 @@ func (r *AccountRepository) GetAll(ctx context.Context) ([]*model.Account, error
 
 	var accounts []*model.Account
 	for rows.Next() {
-		var acc model.Account
-		var balanceStr string
-		if err := rows.Scan(&acc.ID, &balanceStr); err != nil {
+		acc, err := scanAccount(rows)
+		if err != nil {
 			return nil, err
 		}
-		balance, convErr := decimal.NewFromString(balanceStr)
-		if convErr != nil {
-			return nil, model.ErrFailedGetAccount
+		accounts = append(accounts, acc)
+	}
+	return accounts, rows.Err()
+}
+
+// StreamAccounts calls fn for every account in ID order, reading them through
+// a server-side cursor
+func (r *AccountRepository) StreamAccounts(ctx context.Context, fn func(*model.Account) error) (retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.stream", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	return streamRows(ctx, r.db, `SELECT `+accountColumns+` FROM accounts ORDER BY account_id`, nil, func(rows *sql.Rows) error {
+		acc, err := scanAccount(rows)
+		if err != nil {
+			return err
 		}
-		acc.Balance = balance
-		accounts = append(accounts, &acc)
+		return fn(acc)
+	})
+}
+
+// BalancesAsOf returns the balance at asOf of each given account that was
+// open then. Each balance is the running balance recorded with the
+// account's latest transaction at or before asOf, or failing that the
+// closing balance of its latest snapshot ending by asOf, or its opening
+// balance. The snapshot also bounds the search for the transaction.
+func (r *AccountRepository) BalancesAsOf(ctx context.Context, ids []int64, asOf time.Time) (_ map[int64]decimal.Decimal, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.balances_as_of", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
 
+	// transactions.created_at is TIMESTAMP and accounts.created_at is
+	// TIMESTAMPTZ; separate parameters keep both comparisons index-friendly
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT a.account_id, COALESCE(latest.balance, snap.closing_balance, a.opening_balance)
+		FROM accounts a
+		LEFT JOIN LATERAL (
+			SELECT period_end, closing_balance FROM balance_snapshots
+			WHERE account_id = a.account_id AND period_end <= $2
+			ORDER BY business_date DESC
+			LIMIT 1
+		) snap ON TRUE
+		LEFT JOIN LATERAL (`+latestBalanceSQL("created_at <= $2 AND created_at >= COALESCE(snap.period_end, '-infinity')")+`
+		) latest ON TRUE
+		WHERE a.account_id = ANY($1) AND (a.created_at IS NULL OR a.created_at <= $3)`,
+		pq.Array(ids), toTimestamp(asOf), asOf)
+	if err != nil {
+		return nil, err
 	}
-	return accounts, nil
+	defer rows.Close()
+
+	balances := make(map[int64]decimal.Decimal, len(ids))
+	for rows.Next() {
+		var id int64
+		var balance decimal.Decimal
+		if err := rows.Scan(&id, &balance); err != nil {
+			return nil, err
+		}
+		balances[id] = balance
+	}
+	return balances, rows.Err()
+}
+
+// latestBalanceSQL selects the running balance recorded with the latest
+// transaction of account a matching cond, a condition on created_at, with
+// one index probe per side
+func latestBalanceSQL(cond string) string {
+	return `
+			SELECT balance FROM (
+				(SELECT created_at, id, source_balance_after AS balance FROM transactions
+				 WHERE source_account_id = a.account_id AND ` + cond + `
+				 ORDER BY created_at DESC, id DESC LIMIT 1)
+				UNION ALL
+				(SELECT created_at, id, destination_balance_after FROM transactions
+				 WHERE destination_account_id = a.account_id AND ` + cond + `
+				 ORDER BY created_at DESC, id DESC LIMIT 1)
+			) candidates
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1`
+}
+
+// SetStatus changes an account's status
+func (r *AccountRepository) SetStatus(ctx context.Context, id int64, status model.AccountStatus) (retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.update_status", "UPDATE", tracing.AttrAccountID.Int64(id))
+	defer func() { tracing.End(span, retErr) }()
+
+	res, err := r.db.ExecContext(ctx, `UPDATE accounts SET status=$1 WHERE account_id=$2`, string(status), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return model.ErrAccountNotFound
+	}
+	return nil
 }
 
 // Exists checks if an account exists
-func (r *AccountRepository) Exists(ctx context.Context, id int64) (bool, error) {
+func (r *AccountRepository) Exists(ctx context.Context, id int64) (_ bool, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.exists", "SELECT", tracing.AttrAccountID.Int64(id))
+	defer func() { tracing.End(span, retErr) }()
+
 	var exists bool
 	err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM accounts WHERE account_id=$1)`, id).Scan(&exists)
 	return exists, err
 }
 
 // GetBalance retrieves only the balance of an account (optimized for frequent checks)
-func (r *AccountRepository) GetBalance(ctx context.Context, id int64) (decimal.Decimal, error) {
+func (r *AccountRepository) GetBalance(ctx context.Context, id int64) (_ decimal.Decimal, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "accounts.select_balance", "SELECT", tracing.AttrAccountID.Int64(id))
+	defer func() { tracing.End(span, retErr) }()
+
 	var balanceStr string
 	err := r.db.QueryRowContext(ctx, `SELECT balance FROM accounts WHERE account_id=$1`, id).Scan(&balanceStr)
 	if err != nil {
@@ -139,3 +380,62 @@ func (r *AccountRepository) GetBalance(ctx context.Context, id int64) (decimal.D
 	}
 	return balance, nil
 }
+
+// accountColumns are the columns read by scanAccount, in order
+const accountColumns = `account_id, balance, opening_balance, status, metadata, created_at`
+
+// scanAccount reads one row of accountColumns
+func scanAccount(row interface{ Scan(...any) error }) (*model.Account, error) {
+	var acc model.Account
+	var balanceStr, openingStr, status string
+	var metadata []byte
+	var createdAt sql.NullTime
+	if err := row.Scan(&acc.ID, &balanceStr, &openingStr, &status, &metadata, &createdAt); err != nil {
+		return nil, err
+	}
+	acc.CreatedAt = createdAt.Time
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &acc.Metadata); err != nil {
+			return nil, model.ErrFailedGetAccount
+		}
+	}
+	balance, err := decimal.NewFromString(balanceStr)
+	if err != nil {
+		return nil, model.ErrFailedGetAccount
+	}
+	opening, err := decimal.NewFromString(openingStr)
+	if err != nil {
+		return nil, model.ErrFailedGetAccount
+	}
+	acc.Balance = balance
+	acc.OpeningBalance = opening
+	acc.Status = model.AccountStatus(status)
+	return &acc, nil
+}
+
+// metadataJSON encodes metadata for a JSONB column; empty metadata is NULL
+func metadataJSON(m model.Metadata) (any, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// sortedUniqueIDs returns ids in ascending order without duplicates
+func sortedUniqueIDs(ids []int64) []int64 {
+	out := make([]int64, 0, len(ids))
+	out = append(out, ids...)
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+
+	unique := out[:0]
+	for i, id := range out {
+		if i == 0 || id != out[i-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/db"
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestImportAccounts_Postgres(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+	repo := NewAccountRepository(conn)
+
+	ids := testAccountIDs(t, conn, 3)
+	taken, free, other := ids[0], ids[1], ids[2]
+	require.NoError(t, repo.Create(ctx, &model.Account{ID: taken, Balance: decimal.NewFromInt(5)}))
+
+	batch := func() []*model.Account {
+		return []*model.Account{
+			{ID: free, Balance: decimal.RequireFromString("10.50"), Metadata: model.Metadata{"partner_ref": "P-1"}},
+			{ID: taken, Balance: decimal.NewFromInt(99)},
+			{ID: other, Balance: decimal.Zero},
+		}
+	}
+	assertAbsent := func(t *testing.T) {
+		t.Helper()
+		for _, id := range []int64{free, other} {
+			_, err := repo.GetByID(ctx, id)
+			assert.ErrorIs(t, err, model.ErrAccountNotFound, "account %d", id)
+		}
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		got, err := repo.ImportAccounts(ctx, batch(), model.AccountImportOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{taken}, got)
+		assertAbsent(t)
+	})
+
+	t.Run("all or nothing", func(t *testing.T) {
+		got, err := repo.ImportAccounts(ctx, batch(), model.AccountImportOptions{AllOrNothing: true})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{taken}, got)
+		assertAbsent(t)
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		got, err := repo.ImportAccounts(ctx, batch(), model.AccountImportOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{taken}, got)
+
+		acc, err := repo.GetByID(ctx, free)
+		require.NoError(t, err)
+		assert.Equal(t, "10.50", acc.Balance.StringFixed(2))
+		assert.True(t, acc.OpeningBalance.Equal(acc.Balance))
+		assert.Equal(t, model.Metadata{"partner_ref": "P-1"}, acc.Metadata)
+		assert.False(t, acc.CreatedAt.IsZero())
+
+		acc, err = repo.GetByID(ctx, other)
+		require.NoError(t, err)
+		assert.True(t, acc.Balance.IsZero())
+		assert.Nil(t, acc.Metadata)
+
+		// The existing account is left untouched
+		balance, err := repo.GetBalance(ctx, taken)
+		require.NoError(t, err)
+		assert.Equal(t, "5.00", balance.StringFixed(2))
+	})
+}
+
+func TestBalanceHistoryBackfill_Postgres(t *testing.T) {
+	conn := openTestSchema(t)
+	ctx := context.Background()
+	migrator, err := db.NewMigrator(conn)
+	require.NoError(t, err)
+	_, err = migrator.To(ctx, 1)
+	require.NoError(t, err)
+
+	// Accounts 1 and 2 opened with 100 and 50; balances are after the three
+	// transfers below
+	_, err = conn.ExecContext(ctx, `INSERT INTO accounts (account_id, balance) VALUES (1, 75), (2, 75)`)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO transactions (source_account_id, destination_account_id, amount)
+		VALUES (1, 2, 30), (2, 1, 10), (1, 2, 5)`)
+	require.NoError(t, err)
+
+	_, err = migrator.To(ctx, 4)
+	require.NoError(t, err)
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT source_balance_after, destination_balance_after FROM transactions ORDER BY id`)
+	require.NoError(t, err)
+	defer rows.Close()
+	var got [][2]string
+	for rows.Next() {
+		var source, destination decimal.Decimal
+		require.NoError(t, rows.Scan(&source, &destination))
+		got = append(got, [2]string{source.StringFixed(2), destination.StringFixed(2)})
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, [][2]string{
+		{"70.00", "80.00"},
+		{"70.00", "80.00"},
+		{"75.00", "75.00"},
+	}, got)
+
+	// 0002 derived the opening balances from the same history
+	var opening1, opening2 decimal.Decimal
+	require.NoError(t, conn.QueryRowContext(ctx, `
+		SELECT (SELECT opening_balance FROM accounts WHERE account_id = 1),
+			(SELECT opening_balance FROM accounts WHERE account_id = 2)`).Scan(&opening1, &opening2))
+	assert.Equal(t, "100.00", opening1.StringFixed(2))
+	assert.Equal(t, "50.00", opening2.StringFixed(2))
+}
+
+func TestBalancesAsOf_Postgres(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+	repo := NewAccountRepository(conn)
+
+	opened := time.Now().Add(-10 * 24 * time.Hour).Truncate(time.Hour)
+	ids := createTestAccounts(t, conn, opened, "100", "0")
+	a, b := ids[0], ids[1]
+	first := opened.Add(time.Hour)
+	insertTestTransaction(t, conn, a, b, "30", first, "70", "30")
+	insertTestTransaction(t, conn, a, b, "20", opened.Add(25*time.Hour), "50", "50")
+
+	// A snapshot of a for the first day. Its closing balance is deliberately
+	// a cent off so the assertions show when it is the source.
+	_, err := conn.ExecContext(ctx, `
+		INSERT INTO balance_snapshots (account_id, business_date, period_end, opening_balance, closing_balance,
+			total_debits, total_credits, debit_count, credit_count)
+		VALUES ($1, $2, $3, 100, 69.99, 30, 0, 1, 0)`,
+		a, opened.Format(model.BusinessDateLayout), toTimestamp(opened.Add(24*time.Hour)))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		asOf time.Time
+		want map[int64]string
+	}{
+		{"before opening", opened.Add(-time.Minute), map[int64]string{}},
+		{"opening balances", opened.Add(30 * time.Minute), map[int64]string{a: "100.00", b: "0.00"}},
+		{"at a transaction", first, map[int64]string{a: "70.00", b: "30.00"}},
+		{"from the snapshot", opened.Add(24*time.Hour + 30*time.Minute), map[int64]string{a: "69.99", b: "30.00"}},
+		{"after the snapshot", opened.Add(26 * time.Hour), map[int64]string{a: "50.00", b: "50.00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balances, err := repo.BalancesAsOf(ctx, []int64{a, b, b + 1000}, tt.asOf)
+			require.NoError(t, err)
+			got := make(map[int64]string, len(balances))
+			for id, balance := range balances {
+				got[id] = balance.StringFixed(2)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// openTestSchema returns a connection to a fresh, empty schema of the
+// TEST_DB_URL database, for tests that migrate from scratch. The schema is
+// dropped when the test ends.
+func openTestSchema(t *testing.T) *sql.DB {
+	t.Helper()
+	conn := openTestDB(t)
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err := conn.Exec(`CREATE SCHEMA ` + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	dsn := os.Getenv("TEST_DB_URL")
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	scoped, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { scoped.Close() })
+	return scoped
+}
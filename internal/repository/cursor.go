@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// cursorBatchSize is the number of rows fetched per round trip by streamRows
+const cursorBatchSize = 1000
+
+// streamRows runs query through a server-side cursor inside a read-only
+// REPEATABLE READ transaction, so memory use stays flat however many rows
+// match and every row comes from the same snapshot. scan is called once per
+// row; streaming stops at the first error it returns.
+func streamRows(ctx context.Context, db *sql.DB, query string, args []any, scan func(*sql.Rows) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	// Nothing is written, so rolling back is how the cursor is released
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DECLARE export_cursor NO SCROLL CURSOR FOR `+query, args...); err != nil {
+		return err
+	}
+	fetch := fmt.Sprintf(`FETCH FORWARD %d FROM export_cursor`, cursorBatchSize)
+	for {
+		n, err := fetchBatch(ctx, tx, fetch, scan)
+		if err != nil {
+			return err
+		}
+		if n < cursorBatchSize {
+			return nil
+		}
+	}
+}
+
+// fetchBatch scans one FETCH worth of rows and returns how many there were
+func fetchBatch(ctx context.Context, tx *sql.Tx, fetch string, scan func(*sql.Rows) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
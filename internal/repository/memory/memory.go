@@ -0,0 +1,474 @@
+// Package memory is a concurrency-safe, in-memory implementation of the
+// repository interfaces. It is used by service tests and by the
+// --storage=memory development mode; nothing is persisted across restarts.
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+)
+
+// Store holds all accounts and transactions. Units of work are serialised by
+// a store-wide lock, which gives them the same all-or-nothing and isolation
+// guarantees as a SERIALIZABLE Postgres transaction.
+type Store struct {
+	mu           sync.RWMutex
+	accounts     map[int64]model.Account
+	transactions []model.Transaction
+	nextTxnID    int64
+	// snapshots and runs are keyed by business date
+	snapshots map[string]map[int64]model.BalanceSnapshot
+	runs      map[string]model.SnapshotRun
+	// External statements and lines are stored in ID order; matches are
+	// keyed by line, and matchedTxns maps matched transactions to their line
+	extStatements []model.ExternalStatement
+	extLines      []model.ExternalLine
+	extMatches    map[int64]model.ExternalMatch
+	matchedTxns   map[int64]int64
+	// Payment files are keyed by message ID, as are the payments booked
+	// for them
+	paymentFiles   map[string]model.PaymentFile
+	bookedPayments map[string]map[int]int64
+	now            func() time.Time
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{
+		accounts:  make(map[int64]model.Account),
+		nextTxnID: 1,
+		snapshots: make(map[string]map[int64]model.BalanceSnapshot),
+		runs:      make(map[string]model.SnapshotRun),
+
+		extMatches:  make(map[int64]model.ExternalMatch),
+		matchedTxns: make(map[int64]int64),
+
+		paymentFiles:   make(map[string]model.PaymentFile),
+		bookedPayments: make(map[string]map[int]int64),
+		now:            time.Now,
+	}
+}
+
+// Accounts returns the store's AccountStore
+func (s *Store) Accounts() repository.AccountStore {
+	return &accountStore{s: s}
+}
+
+// Transactions returns the store's TransactionStore
+func (s *Store) Transactions() repository.TransactionStore {
+	return &transactionStore{s: s}
+}
+
+// Snapshots returns the store's SnapshotStore
+func (s *Store) Snapshots() repository.SnapshotStore {
+	return &snapshotStore{s: s}
+}
+
+// Reconciliation returns the store's ReconciliationStore
+func (s *Store) Reconciliation() repository.ReconciliationStore {
+	return &reconciliationStore{s: s}
+}
+
+// PaymentFiles returns the store's PaymentFileStore
+func (s *Store) PaymentFiles() repository.PaymentFileStore {
+	return &paymentFileStore{s: s}
+}
+
+// UnitOfWork returns the store's UnitOfWork
+func (s *Store) UnitOfWork() repository.UnitOfWork {
+	return &unitOfWork{s: s}
+}
+
+// accountStore implements repository.AccountStore
+type accountStore struct {
+	s *Store
+}
+
+func (a *accountStore) Create(ctx context.Context, acc *model.Account) error {
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	if _, ok := a.s.accounts[acc.ID]; ok {
+		return model.ErrAccountExists
+	}
+	a.s.accounts[acc.ID] = model.Account{
+		ID:             acc.ID,
+		Balance:        acc.Balance,
+		Status:         model.AccountActive,
+		OpeningBalance: acc.Balance,
+		Metadata:       acc.Metadata,
+		CreatedAt:      a.s.now(),
+	}
+	acc.CreatedAt = a.s.accounts[acc.ID].CreatedAt
+	return nil
+}
+
+func (a *accountStore) ImportAccounts(ctx context.Context, accounts []*model.Account, opts model.AccountImportOptions) ([]int64, error) {
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	var taken []int64
+	for _, acc := range accounts {
+		if _, ok := a.s.accounts[acc.ID]; ok {
+			taken = append(taken, acc.ID)
+		}
+	}
+	if opts.DryRun || (opts.AllOrNothing && len(taken) > 0) {
+		return taken, nil
+	}
+	for _, acc := range accounts {
+		if _, ok := a.s.accounts[acc.ID]; ok {
+			continue
+		}
+		a.s.accounts[acc.ID] = model.Account{
+			ID:             acc.ID,
+			Balance:        acc.Balance,
+			Status:         model.AccountActive,
+			OpeningBalance: acc.Balance,
+			Metadata:       acc.Metadata,
+			CreatedAt:      a.s.now(),
+		}
+	}
+	return taken, nil
+}
+
+func (a *accountStore) GetByID(ctx context.Context, id int64) (*model.Account, error) {
+	a.s.mu.RLock()
+	defer a.s.mu.RUnlock()
+
+	acc, ok := a.s.accounts[id]
+	if !ok {
+		return nil, model.ErrAccountNotFound
+	}
+	return &acc, nil
+}
+
+func (a *accountStore) GetAll(ctx context.Context) ([]*model.Account, error) {
+	a.s.mu.RLock()
+	defer a.s.mu.RUnlock()
+
+	accounts := make([]*model.Account, 0, len(a.s.accounts))
+	for _, acc := range a.s.accounts {
+		acc := acc
+		accounts = append(accounts, &acc)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts, nil
+}
+
+// StreamAccounts calls fn on a snapshot taken under the read lock, so fn may
+// use the store itself
+func (a *accountStore) StreamAccounts(ctx context.Context, fn func(*model.Account) error) error {
+	accounts, _ := a.GetAll(ctx)
+	for _, acc := range accounts {
+		if err := fn(acc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BalancesAsOf replays the running balances recorded with each transaction,
+// which are stored in commit order
+func (a *accountStore) BalancesAsOf(ctx context.Context, ids []int64, asOf time.Time) (map[int64]decimal.Decimal, error) {
+	a.s.mu.RLock()
+	defer a.s.mu.RUnlock()
+
+	balances := make(map[int64]decimal.Decimal, len(ids))
+	for _, id := range ids {
+		if acc, ok := a.s.accounts[id]; ok && acc.OpenAt(asOf) {
+			balances[id] = acc.OpeningBalance
+		}
+	}
+	for _, txn := range a.s.transactions {
+		if txn.CreatedAt.After(asOf) {
+			continue
+		}
+		for _, id := range []int64{txn.SourceAccountID, txn.DestinationAccountID} {
+			if _, ok := balances[id]; ok {
+				balances[id] = txn.BalanceAfter(id)
+			}
+		}
+	}
+	return balances, nil
+}
+
+func (a *accountStore) Exists(ctx context.Context, id int64) (bool, error) {
+	a.s.mu.RLock()
+	defer a.s.mu.RUnlock()
+
+	_, ok := a.s.accounts[id]
+	return ok, nil
+}
+
+func (a *accountStore) GetBalance(ctx context.Context, id int64) (decimal.Decimal, error) {
+	a.s.mu.RLock()
+	defer a.s.mu.RUnlock()
+
+	acc, ok := a.s.accounts[id]
+	if !ok {
+		return decimal.Zero, model.ErrAccountNotFound
+	}
+	return acc.Balance, nil
+}
+
+func (a *accountStore) SetStatus(ctx context.Context, id int64, status model.AccountStatus) error {
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	acc, ok := a.s.accounts[id]
+	if !ok {
+		return model.ErrAccountNotFound
+	}
+	acc.Status = status
+	a.s.accounts[id] = acc
+	return nil
+}
+
+// transactionStore implements repository.TransactionStore
+type transactionStore struct {
+	s *Store
+}
+
+func (t *transactionStore) GetByID(ctx context.Context, id int64) (*model.Transaction, error) {
+	t.s.mu.RLock()
+	defer t.s.mu.RUnlock()
+
+	for i := range t.s.transactions {
+		if t.s.transactions[i].ID == id {
+			txn := t.s.transactions[i]
+			return &txn, nil
+		}
+	}
+	return nil, repository.ErrTransactionNotFound
+}
+
+func (t *transactionStore) GetByAccountID(ctx context.Context, accountID int64) ([]*model.Transaction, error) {
+	return t.filter(func(txn *model.Transaction) bool {
+		return txn.SourceAccountID == accountID || txn.DestinationAccountID == accountID
+	}, 0, 0), nil
+}
+
+func (t *transactionStore) GetAll(ctx context.Context) ([]*model.Transaction, error) {
+	return t.filter(func(*model.Transaction) bool { return true }, 0, 0), nil
+}
+
+// StreamTransactions calls fn on a snapshot taken under the read lock, oldest
+// first, so fn may use the store itself
+func (t *transactionStore) StreamTransactions(ctx context.Context, filter model.TransactionFilter, fn func(*model.Transaction) error) error {
+	t.s.mu.RLock()
+	var matched []*model.Transaction
+	for i := range t.s.transactions {
+		txn := t.s.transactions[i]
+		if filter.Match(&txn) {
+			matched = append(matched, &txn)
+		}
+	}
+	t.s.mu.RUnlock()
+
+	for _, txn := range matched {
+		if err := fn(txn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *transactionStore) GetTransactionHistory(ctx context.Context, filter model.TransactionFilter, limit, offset int) ([]*model.Transaction, error) {
+	return t.filter(filter.Match, limit, offset), nil
+}
+
+func (t *transactionStore) SearchTransactions(ctx context.Context, search model.TransactionSearch) ([]*model.TransactionHit, error) {
+	t.s.mu.RLock()
+	defer t.s.mu.RUnlock()
+
+	reversedBy := make(map[int64]int64)
+	for _, txn := range t.s.transactions {
+		if txn.ReversalOf != nil {
+			reversedBy[*txn.ReversalOf] = txn.ID
+		}
+	}
+	var hits []*model.TransactionHit
+	for i := range t.s.transactions {
+		txn := t.s.transactions[i]
+		var reversal *int64
+		if id, ok := reversedBy[txn.ID]; ok {
+			reversal = &id
+		}
+		hit := model.NewTransactionHit(&txn, reversal)
+		if search.Match(hit) && (search.After == nil || search.After.Precedes(&txn)) {
+			hits = append(hits, hit)
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return search.Sort.Less(hits[i].Transaction, hits[j].Transaction) })
+	if len(hits) > search.Limit {
+		hits = hits[:search.Limit]
+	}
+	return hits, nil
+}
+
+func (t *transactionStore) NetByAccount(ctx context.Context, since time.Time) (map[int64]decimal.Decimal, error) {
+	t.s.mu.RLock()
+	defer t.s.mu.RUnlock()
+
+	net := make(map[int64]decimal.Decimal)
+	for _, txn := range t.s.transactions {
+		if txn.CreatedAt.Before(since) {
+			continue
+		}
+		net[txn.DestinationAccountID] = net[txn.DestinationAccountID].Add(txn.Amount)
+		net[txn.SourceAccountID] = net[txn.SourceAccountID].Sub(txn.Amount)
+	}
+	return net, nil
+}
+
+// filter returns copies of matching transactions, newest first, applying
+// offset and (when positive) limit
+func (t *transactionStore) filter(match func(*model.Transaction) bool, limit, offset int) []*model.Transaction {
+	t.s.mu.RLock()
+	defer t.s.mu.RUnlock()
+
+	var out []*model.Transaction
+	skipped := 0
+	for i := len(t.s.transactions) - 1; i >= 0; i-- {
+		txn := t.s.transactions[i]
+		if !match(&txn) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		out = append(out, &txn)
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// unitOfWork implements repository.UnitOfWork
+type unitOfWork struct {
+	s *Store
+}
+
+// Run holds the store lock for the duration of fn and applies the staged
+// changes only if fn succeeds
+func (u *unitOfWork) Run(ctx context.Context, operation string, fn func(ctx context.Context, tx repository.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	tx := &memTx{s: u.s, balances: make(map[int64]decimal.Decimal)}
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+
+	for id, balance := range tx.balances {
+		acc := u.s.accounts[id]
+		acc.Balance = balance
+		u.s.accounts[id] = acc
+	}
+	u.s.transactions = append(u.s.transactions, tx.transactions...)
+	for _, m := range tx.matches {
+		u.s.applyMatch(m)
+	}
+	for _, p := range tx.payments {
+		u.s.bookedPayments[p.messageID][p.instruction] = p.transactionID
+	}
+	return nil
+}
+
+// memTx stages balance changes and new transactions until commit. It is
+// only used while the store lock is held.
+type memTx struct {
+	s            *Store
+	balances     map[int64]decimal.Decimal
+	transactions []model.Transaction
+	matches      []model.ExternalMatch
+	payments     []bookedPayment
+}
+
+// account returns the account with any staged balance applied
+func (t *memTx) account(id int64) (model.Account, bool) {
+	acc, ok := t.s.accounts[id]
+	if balance, staged := t.balances[id]; ok && staged {
+		acc.Balance = balance
+	}
+	return acc, ok
+}
+
+func (t *memTx) LockAccounts(ctx context.Context, ids []int64) (map[int64]*model.Account, error) {
+	accounts := make(map[int64]*model.Account, len(ids))
+	for _, id := range ids {
+		if acc, ok := t.account(id); ok {
+			accounts[id] = &acc
+		}
+	}
+	return accounts, nil
+}
+
+func (t *memTx) UpdateLockedBalance(ctx context.Context, account *model.Account, diff decimal.Decimal) error {
+	current, ok := t.account(account.ID)
+	if !ok {
+		return model.ErrAccountNotFound
+	}
+	newBalance := current.Balance.Add(diff)
+	if newBalance.IsNegative() {
+		return model.ErrInsufficientFunds
+	}
+	t.balances[account.ID] = newBalance
+	account.Balance = newBalance
+	return nil
+}
+
+func (t *memTx) CreateTransaction(ctx context.Context, txn *model.Transaction) error {
+	if txn.ReversalOf != nil && t.reversed(*txn.ReversalOf) {
+		return model.ErrAlreadyReversed
+	}
+	// IDs are consumed even if the unit of work rolls back, like BIGSERIAL
+	txn.ID = t.s.nextTxnID
+	t.s.nextTxnID++
+	txn.CreatedAt = t.s.now()
+	t.transactions = append(t.transactions, *txn)
+	return nil
+}
+
+func (t *memTx) MatchExternalLine(ctx context.Context, m *model.ExternalMatch) error {
+	if err := t.s.checkMatch(m, t.matches); err != nil {
+		return err
+	}
+	m.MatchedAt = t.s.now()
+	t.matches = append(t.matches, *m)
+	return nil
+}
+
+// reversed reports whether a committed or staged transaction reverses id
+func (t *memTx) reversed(id int64) bool {
+	for _, txns := range [][]model.Transaction{t.s.transactions, t.transactions} {
+		for _, txn := range txns {
+			if txn.ReversalOf != nil && *txn.ReversalOf == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *memTx) RecordPayment(ctx context.Context, messageID string, instruction int, transactionID int64) error {
+	if err := t.s.checkPayment(messageID, instruction, t.payments); err != nil {
+		return err
+	}
+	t.payments = append(t.payments, bookedPayment{messageID, instruction, transactionID})
+	return nil
+}
@@ -0,0 +1,347 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+)
+
+func seed(t *testing.T, s *Store, balances map[int64]int64) {
+	t.Helper()
+	for id, balance := range balances {
+		require.NoError(t, s.Accounts().Create(context.Background(), &model.Account{ID: id, Balance: decimal.NewFromInt(balance)}))
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	seed(t, s, map[int64]int64{2: 50, 1: 100})
+
+	assert.ErrorIs(t, s.Accounts().Create(ctx, &model.Account{ID: 1}), model.ErrAccountExists)
+
+	_, err := s.Accounts().GetByID(ctx, 3)
+	assert.ErrorIs(t, err, model.ErrAccountNotFound)
+
+	exists, err := s.Accounts().Exists(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	all, err := s.Accounts().GetAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, int64(1), all[0].ID)
+
+	// Returned accounts are copies
+	all[0].Balance = decimal.NewFromInt(-1)
+	balance, err := s.Accounts().GetBalance(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, balance.Equal(decimal.NewFromInt(100)))
+}
+
+func TestUnitOfWork_RollsBackOnError(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	seed(t, s, map[int64]int64{1: 100, 2: 0})
+	boom := errors.New("boom")
+
+	err := s.UnitOfWork().Run(ctx, "transfer", func(ctx context.Context, tx repository.Tx) error {
+		accounts, err := tx.LockAccounts(ctx, []int64{1, 2, 3})
+		require.NoError(t, err)
+		require.Len(t, accounts, 2, "missing accounts are absent from the map")
+
+		require.NoError(t, tx.UpdateLockedBalance(ctx, accounts[1], decimal.NewFromInt(-40)))
+		require.NoError(t, tx.UpdateLockedBalance(ctx, accounts[2], decimal.NewFromInt(40)))
+		require.NoError(t, tx.CreateTransaction(ctx, &model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(40)}))
+		return boom
+	})
+	assert.ErrorIs(t, err, boom)
+
+	balance, err := s.Accounts().GetBalance(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, balance.Equal(decimal.NewFromInt(100)))
+	all, err := s.Transactions().GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestUnitOfWork_RejectsNegativeBalance(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	seed(t, s, map[int64]int64{1: 10})
+
+	err := s.UnitOfWork().Run(ctx, "transfer", func(ctx context.Context, tx repository.Tx) error {
+		accounts, err := tx.LockAccounts(ctx, []int64{1})
+		require.NoError(t, err)
+		return tx.UpdateLockedBalance(ctx, accounts[1], decimal.NewFromInt(-11))
+	})
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+}
+
+func TestTransactions_HistoryIsNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	seed(t, s, map[int64]int64{1: 100, 2: 100, 3: 100})
+
+	for _, dst := range []int64{2, 3, 2} {
+		require.NoError(t, s.UnitOfWork().Run(ctx, "transfer", func(ctx context.Context, tx repository.Tx) error {
+			return tx.CreateTransaction(ctx, &model.Transaction{SourceAccountID: 1, DestinationAccountID: dst, Amount: decimal.NewFromInt(1)})
+		}))
+	}
+
+	history, err := s.Transactions().GetTransactionHistory(ctx, model.TransactionFilter{AccountID: 2}, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, []int64{3, 1}, []int64{history[0].ID, history[1].ID})
+
+	page, err := s.Transactions().GetTransactionHistory(ctx, model.TransactionFilter{AccountID: 1}, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, int64(2), page[0].ID)
+
+	txn, err := s.Transactions().GetByID(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), txn.DestinationAccountID)
+	_, err = s.Transactions().GetByID(ctx, 99)
+	assert.ErrorIs(t, err, repository.ErrTransactionNotFound)
+}
+
+func TestTransactions_StreamIsOldestFirstAndFiltered(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	seed(t, s, map[int64]int64{1: 100, 2: 100, 3: 100})
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := 0
+	s.now = func() time.Time { day++; return base.AddDate(0, 0, day) }
+
+	for _, dst := range []int64{2, 3, 2} {
+		require.NoError(t, s.UnitOfWork().Run(ctx, "transfer", func(ctx context.Context, tx repository.Tx) error {
+			return tx.CreateTransaction(ctx, &model.Transaction{SourceAccountID: 1, DestinationAccountID: dst, Amount: decimal.NewFromInt(1)})
+		}))
+	}
+
+	tests := []struct {
+		name   string
+		filter model.TransactionFilter
+		want   []int64
+	}{
+		{"everything", model.TransactionFilter{}, []int64{1, 2, 3}},
+		{"account", model.TransactionFilter{AccountID: 2}, []int64{1, 3}},
+		{"from is inclusive", model.TransactionFilter{From: base.AddDate(0, 0, 2)}, []int64{2, 3}},
+		{"to is exclusive", model.TransactionFilter{To: base.AddDate(0, 0, 2)}, []int64{1}},
+		{"combined", model.TransactionFilter{AccountID: 3, From: base.AddDate(0, 0, 1), To: base.AddDate(0, 0, 3)}, []int64{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []int64
+			require.NoError(t, s.Transactions().StreamTransactions(ctx, tt.filter, func(txn *model.Transaction) error {
+				ids = append(ids, txn.ID)
+				return nil
+			}))
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := s.Accounts().StreamAccounts(ctx, func(acc *model.Account) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls, "streaming stops at the first error")
+}
+
+func TestUnitOfWork_ConcurrentTransfersConserveMoney(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	seed(t, s, map[int64]int64{1: 1000, 2: 1000})
+
+	transfer := func(src, dst int64) error {
+		return s.UnitOfWork().Run(ctx, "transfer", func(ctx context.Context, tx repository.Tx) error {
+			accounts, err := tx.LockAccounts(ctx, []int64{src, dst})
+			if err != nil {
+				return err
+			}
+			if err := tx.UpdateLockedBalance(ctx, accounts[src], decimal.NewFromInt(-1)); err != nil {
+				return err
+			}
+			if err := tx.UpdateLockedBalance(ctx, accounts[dst], decimal.NewFromInt(1)); err != nil {
+				return err
+			}
+			return tx.CreateTransaction(ctx, &model.Transaction{SourceAccountID: src, DestinationAccountID: dst, Amount: decimal.NewFromInt(1)})
+		})
+	}
+
+	const perDirection = 200
+	var wg sync.WaitGroup
+	for i := 0; i < perDirection; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); assert.NoError(t, transfer(1, 2)) }()
+		go func() { defer wg.Done(); assert.NoError(t, transfer(2, 1)) }()
+	}
+	wg.Wait()
+
+	for _, id := range []int64{1, 2} {
+		balance, err := s.Accounts().GetBalance(ctx, id)
+		require.NoError(t, err)
+		assert.True(t, balance.Equal(decimal.NewFromInt(1000)), "account %d balance = %s", id, balance)
+	}
+	all, err := s.Transactions().GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2*perDirection)
+}
+
+func TestSnapshots_BatchesResumeAndTotals(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	day1 := model.NewSnapshotPeriod(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), time.UTC)
+	now := day1.Start.Add(-time.Hour)
+	s.now = func() time.Time { return now }
+	seed(t, s, map[int64]int64{1: 100, 2: 100, 3: 100})
+
+	transfer := func(at time.Time, src, dst, amount int64) {
+		t.Helper()
+		now = at
+		require.NoError(t, s.UnitOfWork().Run(ctx, "transfer", func(ctx context.Context, tx repository.Tx) error {
+			accs, err := tx.LockAccounts(ctx, []int64{src, dst})
+			if err != nil {
+				return err
+			}
+			if err := tx.UpdateLockedBalance(ctx, accs[src], decimal.NewFromInt(-amount)); err != nil {
+				return err
+			}
+			if err := tx.UpdateLockedBalance(ctx, accs[dst], decimal.NewFromInt(amount)); err != nil {
+				return err
+			}
+			return tx.CreateTransaction(ctx, &model.Transaction{SourceAccountID: src, DestinationAccountID: dst, Amount: decimal.NewFromInt(amount),
+				SourceBalanceAfter: accs[src].Balance, DestinationBalanceAfter: accs[dst].Balance})
+		}))
+	}
+	transfer(day1.Start.Add(-time.Minute), 1, 2, 10) // the day before
+	transfer(day1.Start, 1, 2, 20)                   // start is inclusive
+	transfer(day1.Start.Add(time.Hour), 2, 3, 5)
+	transfer(day1.End, 3, 1, 1) // end is exclusive
+	now = day1.End.Add(time.Hour)
+	seed(t, s, map[int64]int64{4: 100}) // opened after the day
+
+	snaps := s.Snapshots()
+	lastID, n, err := snaps.SnapshotAccounts(ctx, day1, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 2}, []int64{lastID, int64(n)})
+
+	resume, err := snaps.ResumePoint(ctx, day1.Date)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), resume)
+
+	lastID, n, err = snaps.SnapshotAccounts(ctx, day1, resume, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3, 1}, []int64{lastID, int64(n)}, "account 4 opened after the day")
+
+	// Repeating a batch keeps the existing snapshots
+	_, n, err = snaps.SnapshotAccounts(ctx, day1, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	run, err := snaps.CompleteRun(ctx, day1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, run.Accounts)
+
+	got, err := snaps.GetSnapshots(ctx, 2, day1.Date, day1.Date)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	snap := got[0]
+	assert.Equal(t, []string{"110", "125", "5", "20"}, []string{snap.OpeningBalance.String(), snap.ClosingBalance.String(), snap.TotalDebits.String(), snap.TotalCredits.String()})
+	assert.Equal(t, []int{1, 1}, []int{snap.DebitCount, snap.CreditCount})
+
+	closing, err := snaps.ClosingBalances(ctx, day1.Date)
+	require.NoError(t, err)
+	asOf, err := s.Accounts().BalancesAsOf(ctx, []int64{1, 2, 3}, day1.End.Add(-time.Nanosecond))
+	require.NoError(t, err)
+	assert.Equal(t, len(asOf), len(closing))
+	for id, balance := range asOf {
+		assert.True(t, balance.Equal(closing[id]), "account %d: as-of %s, closing %s", id, balance, closing[id])
+	}
+
+	byEnd, err := snaps.LatestSnapshot(ctx, 2, day1.End)
+	require.NoError(t, err)
+	assert.Equal(t, snap.ClosingBalance, byEnd.ClosingBalance)
+	_, err = snaps.LatestSnapshot(ctx, 2, day1.End.Add(-time.Nanosecond))
+	assert.ErrorIs(t, err, repository.ErrSnapshotNotFound)
+
+	latest, err := snaps.LatestRun(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, day1.Date, latest.BusinessDate)
+	_, err = snaps.GetRun(ctx, day1.Next().Date)
+	assert.ErrorIs(t, err, repository.ErrSnapshotRunNotFound)
+}
+
+func TestReconciliation_MatchesAreStagedInUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	seed(t, s, map[int64]int64{1: 100})
+	st := &model.ExternalStatement{AccountID: 1, Digest: "d", Lines: []*model.ExternalLine{
+		{LineNo: 1, Amount: decimal.NewFromInt(5)}, {LineNo: 2, Amount: decimal.NewFromInt(5)},
+	}}
+	require.NoError(t, s.Reconciliation().CreateStatement(ctx, st))
+	assert.ErrorIs(t, s.Reconciliation().CreateStatement(ctx, &model.ExternalStatement{AccountID: 1, Digest: "d"}), model.ErrStatementAlreadyImported)
+
+	boom := errors.New("boom")
+	err := s.UnitOfWork().Run(ctx, "adjust", func(ctx context.Context, tx repository.Tx) error {
+		require.NoError(t, tx.MatchExternalLine(ctx, &model.ExternalMatch{LineID: 1, TransactionID: 7}))
+		assert.ErrorIs(t, tx.MatchExternalLine(ctx, &model.ExternalMatch{LineID: 2, TransactionID: 7}), model.ErrTransactionAlreadyMatched)
+		return boom
+	})
+	assert.ErrorIs(t, err, boom)
+	line, err := s.Reconciliation().GetLine(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, model.LineUnmatched, line.Status, "rolled back")
+
+	require.NoError(t, s.UnitOfWork().Run(ctx, "adjust", func(ctx context.Context, tx repository.Tx) error {
+		return tx.MatchExternalLine(ctx, &model.ExternalMatch{LineID: 1, TransactionID: 7, Method: model.MatchAdjustment})
+	}))
+	line, err = s.Reconciliation().GetLine(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, model.LineMatched, line.Status)
+	assert.Equal(t, int64(7), line.Match.TransactionID)
+	assert.ErrorIs(t, s.Reconciliation().Match(ctx, &model.ExternalMatch{LineID: 2, TransactionID: 7}), model.ErrTransactionAlreadyMatched)
+}
+
+// Transactions booked in the same instant are paged by ID without repeats
+func TestTransactions_SearchPagesByCursor(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	seed(t, s, map[int64]int64{1: 100, 2: 100})
+	at := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return at }
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.UnitOfWork().Run(ctx, "transfer", func(ctx context.Context, tx repository.Tx) error {
+			return tx.CreateTransaction(ctx, &model.Transaction{SourceAccountID: 1, DestinationAccountID: 2, Amount: decimal.NewFromInt(1)})
+		}))
+	}
+
+	var ids []int64
+	search := model.TransactionSearch{Sort: model.SortNewestFirst, Limit: 2}
+	for {
+		hits, err := s.Transactions().SearchTransactions(ctx, search)
+		require.NoError(t, err)
+		if len(hits) == 0 {
+			break
+		}
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		search.After = model.CursorAfter(search.Sort, hits[len(hits)-1].Transaction)
+	}
+	assert.Equal(t, []int64{5, 4, 3, 2, 1}, ids)
+}
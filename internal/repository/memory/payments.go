@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// paymentFileStore implements repository.PaymentFileStore
+type paymentFileStore struct {
+	s *Store
+}
+
+// bookedPayment is a payment of a file staged by a unit of work
+type bookedPayment struct {
+	messageID     string
+	instruction   int
+	transactionID int64
+}
+
+func (ps *paymentFileStore) ClaimPaymentFile(ctx context.Context, messageID, digest string) (*model.PaymentFile, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+
+	f, ok := ps.s.paymentFiles[messageID]
+	if !ok {
+		f = model.PaymentFile{MessageID: messageID, Digest: digest, ReceivedAt: ps.s.now()}
+		ps.s.paymentFiles[messageID] = f
+		ps.s.bookedPayments[messageID] = make(map[int]int64)
+	}
+	return &f, nil
+}
+
+func (ps *paymentFileStore) BookedPayments(ctx context.Context, messageID string) (map[int]int64, error) {
+	ps.s.mu.RLock()
+	defer ps.s.mu.RUnlock()
+
+	booked := make(map[int]int64, len(ps.s.bookedPayments[messageID]))
+	for instruction, txnID := range ps.s.bookedPayments[messageID] {
+		booked[instruction] = txnID
+	}
+	return booked, nil
+}
+
+func (ps *paymentFileStore) CompletePaymentFile(ctx context.Context, messageID string, report []byte) ([]byte, error) {
+	ps.s.mu.Lock()
+	defer ps.s.mu.Unlock()
+
+	f, ok := ps.s.paymentFiles[messageID]
+	if !ok {
+		return nil, fmt.Errorf("payment file %q was never claimed", messageID)
+	}
+	if f.Report == nil {
+		f.Report = append([]byte(nil), report...)
+		ps.s.paymentFiles[messageID] = f
+	}
+	return f.Report, nil
+}
+
+// checkPayment applies the rules of repository.Tx.RecordPayment to the
+// committed payments and any staged alongside. The caller holds the lock.
+func (s *Store) checkPayment(messageID string, instruction int, staged []bookedPayment) error {
+	booked, ok := s.bookedPayments[messageID]
+	if !ok {
+		return fmt.Errorf("payment file %q was never claimed", messageID)
+	}
+	if _, ok := booked[instruction]; ok {
+		return model.ErrPaymentAlreadyBooked
+	}
+	for _, p := range staged {
+		if p.messageID == messageID && p.instruction == instruction {
+			return model.ErrPaymentAlreadyBooked
+		}
+	}
+	return nil
+}
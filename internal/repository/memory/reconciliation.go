@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// reconciliationStore implements repository.ReconciliationStore
+type reconciliationStore struct {
+	s *Store
+}
+
+func (rs *reconciliationStore) CreateStatement(ctx context.Context, st *model.ExternalStatement) error {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+
+	for _, existing := range rs.s.extStatements {
+		if existing.AccountID == st.AccountID && existing.Digest == st.Digest {
+			return model.ErrStatementAlreadyImported
+		}
+	}
+	st.ID = int64(len(rs.s.extStatements) + 1)
+	st.ImportedAt = rs.s.now()
+	stored := *st
+	stored.Lines = nil
+	rs.s.extStatements = append(rs.s.extStatements, stored)
+	for _, line := range st.Lines {
+		line.ID = int64(len(rs.s.extLines) + 1)
+		line.StatementID, line.AccountID = st.ID, st.AccountID
+		line.Status, line.Match, line.Candidates = model.LineUnmatched, nil, nil
+		rs.s.extLines = append(rs.s.extLines, *line)
+	}
+	return nil
+}
+
+func (rs *reconciliationStore) GetStatement(ctx context.Context, id int64) (*model.ExternalStatement, error) {
+	rs.s.mu.RLock()
+	if id <= 0 || id > int64(len(rs.s.extStatements)) {
+		rs.s.mu.RUnlock()
+		return nil, model.ErrExternalStatementNotFound
+	}
+	st := rs.s.extStatements[id-1]
+	rs.s.mu.RUnlock()
+
+	lines, err := rs.ListLines(ctx, model.ExternalLineFilter{StatementID: id})
+	if err != nil {
+		return nil, err
+	}
+	st.Lines = lines
+	return &st, nil
+}
+
+func (rs *reconciliationStore) GetLine(ctx context.Context, id int64) (*model.ExternalLine, error) {
+	rs.s.mu.RLock()
+	defer rs.s.mu.RUnlock()
+
+	if id <= 0 || id > int64(len(rs.s.extLines)) {
+		return nil, model.ErrExternalLineNotFound
+	}
+	return rs.s.externalLine(id), nil
+}
+
+// ListLines returns copies of the matching lines; lines are stored in
+// statement and file order already
+func (rs *reconciliationStore) ListLines(ctx context.Context, filter model.ExternalLineFilter) ([]*model.ExternalLine, error) {
+	rs.s.mu.RLock()
+	defer rs.s.mu.RUnlock()
+
+	lines := []*model.ExternalLine{}
+	for i := range rs.s.extLines {
+		line := rs.s.externalLine(rs.s.extLines[i].ID)
+		if filter.Match(line) {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+func (rs *reconciliationStore) MatchedTransactions(ctx context.Context, ids []int64) (map[int64]int64, error) {
+	rs.s.mu.RLock()
+	defer rs.s.mu.RUnlock()
+
+	matched := make(map[int64]int64)
+	for _, id := range ids {
+		if lineID, ok := rs.s.matchedTxns[id]; ok {
+			matched[id] = lineID
+		}
+	}
+	return matched, nil
+}
+
+func (rs *reconciliationStore) Match(ctx context.Context, m *model.ExternalMatch) error {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+
+	if err := rs.s.checkMatch(m, nil); err != nil {
+		return err
+	}
+	m.MatchedAt = rs.s.now()
+	rs.s.applyMatch(*m)
+	return nil
+}
+
+func (rs *reconciliationStore) Unmatch(ctx context.Context, lineID int64) error {
+	rs.s.mu.Lock()
+	defer rs.s.mu.Unlock()
+
+	if lineID <= 0 || lineID > int64(len(rs.s.extLines)) {
+		return model.ErrExternalLineNotFound
+	}
+	m, ok := rs.s.extMatches[lineID]
+	if !ok {
+		return model.ErrLineNotMatched
+	}
+	delete(rs.s.extMatches, lineID)
+	delete(rs.s.matchedTxns, m.TransactionID)
+	return nil
+}
+
+// externalLine returns a copy of a stored line with its match. The caller
+// holds the lock.
+func (s *Store) externalLine(id int64) *model.ExternalLine {
+	line := s.extLines[id-1]
+	line.Status = model.LineUnmatched
+	if m, ok := s.extMatches[id]; ok {
+		line.Status = model.LineMatched
+		line.Match = &m
+	}
+	return &line
+}
+
+// checkMatch applies the rules of repository.ReconciliationStore.Match to
+// the committed matches and any staged alongside m. The caller holds the
+// lock.
+func (s *Store) checkMatch(m *model.ExternalMatch, staged []model.ExternalMatch) error {
+	if m.LineID <= 0 || m.LineID > int64(len(s.extLines)) {
+		return model.ErrExternalLineNotFound
+	}
+	if _, ok := s.extMatches[m.LineID]; ok {
+		return model.ErrLineAlreadyMatched
+	}
+	if _, ok := s.matchedTxns[m.TransactionID]; ok {
+		return model.ErrTransactionAlreadyMatched
+	}
+	for _, other := range staged {
+		switch {
+		case other.LineID == m.LineID:
+			return model.ErrLineAlreadyMatched
+		case other.TransactionID == m.TransactionID:
+			return model.ErrTransactionAlreadyMatched
+		}
+	}
+	return nil
+}
+
+// applyMatch records a checked match. The caller holds the lock.
+func (s *Store) applyMatch(m model.ExternalMatch) {
+	s.extMatches[m.LineID] = m
+	s.matchedTxns[m.TransactionID] = m.LineID
+}
@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+)
+
+// snapshotStore implements repository.SnapshotStore
+type snapshotStore struct {
+	s *Store
+}
+
+func (ss *snapshotStore) SnapshotAccounts(ctx context.Context, period model.SnapshotPeriod, afterID int64, limit int) (int64, int, error) {
+	ss.s.mu.Lock()
+	defer ss.s.mu.Unlock()
+
+	var batch []int64
+	for id, acc := range ss.s.accounts {
+		if id > afterID && (acc.CreatedAt.IsZero() || acc.CreatedAt.Before(period.End)) {
+			batch = append(batch, id)
+		}
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i] < batch[j] })
+	if len(batch) > limit {
+		batch = batch[:limit]
+	}
+	if len(batch) == 0 {
+		return 0, 0, nil
+	}
+
+	key := dateKey(period.Date)
+	day, ok := ss.s.snapshots[key]
+	if !ok {
+		day = make(map[int64]model.BalanceSnapshot)
+		ss.s.snapshots[key] = day
+	}
+	for _, id := range batch {
+		if _, done := day[id]; !done {
+			day[id] = ss.snapshot(ss.s.accounts[id], period)
+		}
+	}
+	return batch[len(batch)-1], len(batch), nil
+}
+
+// snapshot replays the running balances and sums the movements of acc's
+// transactions up to the end of period
+func (ss *snapshotStore) snapshot(acc model.Account, period model.SnapshotPeriod) model.BalanceSnapshot {
+	snap := model.BalanceSnapshot{
+		AccountID:      acc.ID,
+		BusinessDate:   period.Date,
+		PeriodEnd:      period.End,
+		OpeningBalance: acc.OpeningBalance,
+		TotalDebits:    decimal.Zero,
+		TotalCredits:   decimal.Zero,
+	}
+	for _, txn := range ss.s.transactions {
+		if !txn.CreatedAt.Before(period.End) {
+			continue
+		}
+		if txn.SourceAccountID != acc.ID && txn.DestinationAccountID != acc.ID {
+			continue
+		}
+		if txn.CreatedAt.Before(period.Start) {
+			snap.OpeningBalance = txn.BalanceAfter(acc.ID)
+			continue
+		}
+		snap.ClosingBalance = txn.BalanceAfter(acc.ID)
+		if txn.SourceAccountID == acc.ID {
+			snap.TotalDebits = snap.TotalDebits.Add(txn.Amount)
+			snap.DebitCount++
+		} else {
+			snap.TotalCredits = snap.TotalCredits.Add(txn.Amount)
+			snap.CreditCount++
+		}
+	}
+	if snap.DebitCount+snap.CreditCount == 0 {
+		snap.ClosingBalance = snap.OpeningBalance
+	}
+	return snap
+}
+
+func (ss *snapshotStore) ResumePoint(ctx context.Context, date time.Time) (int64, error) {
+	ss.s.mu.RLock()
+	defer ss.s.mu.RUnlock()
+
+	var last int64
+	for id := range ss.s.snapshots[dateKey(date)] {
+		last = max(last, id)
+	}
+	return last, nil
+}
+
+func (ss *snapshotStore) CompleteRun(ctx context.Context, period model.SnapshotPeriod) (*model.SnapshotRun, error) {
+	ss.s.mu.Lock()
+	defer ss.s.mu.Unlock()
+
+	key := dateKey(period.Date)
+	run, ok := ss.s.runs[key]
+	if !ok {
+		run = model.SnapshotRun{
+			BusinessDate: period.Date,
+			PeriodStart:  period.Start,
+			PeriodEnd:    period.End,
+			Accounts:     len(ss.s.snapshots[key]),
+			CompletedAt:  ss.s.now(),
+		}
+		ss.s.runs[key] = run
+	}
+	return &run, nil
+}
+
+func (ss *snapshotStore) GetRun(ctx context.Context, date time.Time) (*model.SnapshotRun, error) {
+	ss.s.mu.RLock()
+	defer ss.s.mu.RUnlock()
+
+	run, ok := ss.s.runs[dateKey(date)]
+	if !ok {
+		return nil, repository.ErrSnapshotRunNotFound
+	}
+	return &run, nil
+}
+
+func (ss *snapshotStore) LatestRun(ctx context.Context) (*model.SnapshotRun, error) {
+	ss.s.mu.RLock()
+	defer ss.s.mu.RUnlock()
+
+	var latest model.SnapshotRun
+	for _, run := range ss.s.runs {
+		if run.BusinessDate.After(latest.BusinessDate) {
+			latest = run
+		}
+	}
+	if latest.BusinessDate.IsZero() {
+		return nil, repository.ErrSnapshotRunNotFound
+	}
+	return &latest, nil
+}
+
+func (ss *snapshotStore) ClosingBalances(ctx context.Context, date time.Time) (map[int64]decimal.Decimal, error) {
+	ss.s.mu.RLock()
+	defer ss.s.mu.RUnlock()
+
+	balances := make(map[int64]decimal.Decimal)
+	for id, snap := range ss.s.snapshots[dateKey(date)] {
+		balances[id] = snap.ClosingBalance
+	}
+	return balances, nil
+}
+
+func (ss *snapshotStore) GetSnapshots(ctx context.Context, accountID int64, from, to time.Time) ([]*model.BalanceSnapshot, error) {
+	ss.s.mu.RLock()
+	defer ss.s.mu.RUnlock()
+
+	var snapshots []*model.BalanceSnapshot
+	for _, day := range ss.s.snapshots {
+		if snap, ok := day[accountID]; ok && !snap.BusinessDate.Before(from) && !snap.BusinessDate.After(to) {
+			snapshots = append(snapshots, &snap)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].BusinessDate.Before(snapshots[j].BusinessDate) })
+	return snapshots, nil
+}
+
+func (ss *snapshotStore) LatestSnapshot(ctx context.Context, accountID int64, before time.Time) (*model.BalanceSnapshot, error) {
+	ss.s.mu.RLock()
+	defer ss.s.mu.RUnlock()
+
+	var latest *model.BalanceSnapshot
+	for _, day := range ss.s.snapshots {
+		if snap, ok := day[accountID]; ok && !snap.PeriodEnd.After(before) && (latest == nil || snap.PeriodEnd.After(latest.PeriodEnd)) {
+			latest = &snap
+		}
+	}
+	if latest == nil {
+		return nil, repository.ErrSnapshotNotFound
+	}
+	return latest, nil
+}
+
+// dateKey keys the snapshot maps by business date
+func dateKey(date time.Time) string {
+	return date.Format(model.BusinessDateLayout)
+}
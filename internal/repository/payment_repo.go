@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+)
+
+type PaymentFileRepository struct {
+	db *sql.DB
+}
+
+var _ PaymentFileStore = (*PaymentFileRepository)(nil)
+
+func NewPaymentFileRepository(db *sql.DB) *PaymentFileRepository {
+	return &PaymentFileRepository{db: db}
+}
+
+// ClaimPaymentFile inserts the file unless its message ID is taken, then
+// reads back whichever row holds the message ID. The primary key makes
+// concurrent claims of one message ID wait for each other.
+func (r *PaymentFileRepository) ClaimPaymentFile(ctx context.Context, messageID, digest string) (_ *model.PaymentFile, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "payment_files.claim", "INSERT")
+	defer func() { tracing.End(span, retErr) }()
+
+	if _, err := r.db.ExecContext(ctx, `
+		INSERT INTO payment_files (message_id, digest) VALUES ($1, $2)
+		ON CONFLICT (message_id) DO NOTHING`,
+		messageID, digest); err != nil {
+		return nil, err
+	}
+	f := model.PaymentFile{MessageID: messageID}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT digest, received_at, report FROM payment_files WHERE message_id = $1`, messageID,
+	).Scan(&f.Digest, &f.ReceivedAt, &f.Report)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// BookedPayments reads the payments booked so far for a file
+func (r *PaymentFileRepository) BookedPayments(ctx context.Context, messageID string) (_ map[int]int64, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "payment_file_transfers.select", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT instruction, transaction_id FROM payment_file_transfers WHERE message_id = $1`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	booked := make(map[int]int64)
+	for rows.Next() {
+		var instruction int
+		var txnID int64
+		if err := rows.Scan(&instruction, &txnID); err != nil {
+			return nil, err
+		}
+		booked[instruction] = txnID
+	}
+	return booked, rows.Err()
+}
+
+// CompletePaymentFile sets the report of a file that has none and returns
+// the report the file ends up with
+func (r *PaymentFileRepository) CompletePaymentFile(ctx context.Context, messageID string, report []byte) (_ []byte, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "payment_files.complete", "UPDATE")
+	defer func() { tracing.End(span, retErr) }()
+
+	var stored []byte
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE payment_files
+		SET report = COALESCE(report, $2), completed_at = COALESCE(completed_at, now())
+		WHERE message_id = $1
+		RETURNING report`,
+		messageID, report,
+	).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("payment file %q was never claimed", messageID)
+	}
+	return stored, err
+}
+
+// RecordPaymentTx records the transaction booked for a payment inside tx.
+// The primary key rejects a payment booked already.
+func (r *PaymentFileRepository) RecordPaymentTx(ctx context.Context, messageID string, instruction int, transactionID int64, tx *sql.Tx) (retErr error) {
+	ctx, span := tracing.StartDB(ctx, "payment_file_transfers.insert", "INSERT", tracing.AttrTransactionID.Int64(transactionID))
+	defer func() { tracing.End(span, retErr) }()
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO payment_file_transfers (message_id, instruction, transaction_id) VALUES ($1, $2, $3)`,
+		messageID, instruction, transactionID)
+	var pgErr *pq.Error
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return model.ErrPaymentAlreadyBooked
+	}
+	return err
+}
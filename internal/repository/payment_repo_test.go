@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/db"
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestPaymentFiles_Postgres(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+	repo := NewPaymentFileRepository(conn)
+	accounts, txns := NewAccountRepository(conn), NewTransactionRepository(conn)
+	runner := db.NewTxRunner(conn, db.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: 20 * time.Millisecond})
+	uow := NewPostgresUnitOfWork(runner, accounts, txns, NewReconciliationRepository(conn), repo, sql.LevelSerializable)
+
+	ids := createTestAccounts(t, conn, time.Now().Add(-time.Hour), "100", "0")
+	messageID := fmt.Sprintf("TEST-%d", ids[0])
+	t.Cleanup(func() {
+		// testAccountIDs deletes the rows referencing the transactions
+		conn.Exec(`DELETE FROM payment_files WHERE message_id = $1`, messageID)
+	})
+
+	digest, other := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	file, err := repo.ClaimPaymentFile(ctx, messageID, digest)
+	require.NoError(t, err)
+	assert.Equal(t, digest, file.Digest)
+	assert.Nil(t, file.Report)
+	again, err := repo.ClaimPaymentFile(ctx, messageID, other)
+	require.NoError(t, err)
+	assert.Equal(t, digest, again.Digest, "the first file keeps the message ID")
+	assert.True(t, file.ReceivedAt.Equal(again.ReceivedAt))
+
+	book := func(instruction int) (int64, error) {
+		var txnID int64
+		err := uow.Run(ctx, "payment", func(ctx context.Context, tx Tx) error {
+			locked, err := tx.LockAccounts(ctx, ids)
+			if err != nil {
+				return err
+			}
+			src, dst := locked[ids[0]], locked[ids[1]]
+			if err := tx.UpdateLockedBalance(ctx, src, decimal.NewFromInt(-10)); err != nil {
+				return err
+			}
+			if err := tx.UpdateLockedBalance(ctx, dst, decimal.NewFromInt(10)); err != nil {
+				return err
+			}
+			txn := &model.Transaction{SourceAccountID: src.ID, DestinationAccountID: dst.ID, Amount: decimal.NewFromInt(10),
+				SourceBalanceAfter: src.Balance, DestinationBalanceAfter: dst.Balance}
+			if err := tx.CreateTransaction(ctx, txn); err != nil {
+				return err
+			}
+			txnID = txn.ID
+			return tx.RecordPayment(ctx, messageID, instruction, txn.ID)
+		})
+		return txnID, err
+	}
+	first, err := book(0)
+	require.NoError(t, err)
+	third, err := book(2)
+	require.NoError(t, err)
+
+	// Booking a payment twice rolls the second transfer back
+	_, err = book(0)
+	assert.ErrorIs(t, err, model.ErrPaymentAlreadyBooked)
+	balance, err := accounts.GetBalance(ctx, ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "80.00", balance.StringFixed(2))
+
+	booked, err := repo.BookedPayments(ctx, messageID)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int64{0: first, 2: third}, booked)
+
+	report, err := repo.CompletePaymentFile(ctx, messageID, []byte("<first/>"))
+	require.NoError(t, err)
+	assert.Equal(t, "<first/>", string(report))
+	report, err = repo.CompletePaymentFile(ctx, messageID, []byte("<second/>"))
+	require.NoError(t, err)
+	assert.Equal(t, "<first/>", string(report), "the first report is kept")
+	file, err = repo.ClaimPaymentFile(ctx, messageID, digest)
+	require.NoError(t, err)
+	assert.Equal(t, "<first/>", string(file.Report))
+
+	_, err = repo.CompletePaymentFile(ctx, messageID+"-unknown", []byte("<r/>"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/db"
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// openTestDB connects to the database named by TEST_DB_URL and migrates it
+// to the latest version, skipping the test when the variable is unset. Point
+// it at a disposable database.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL not set; skipping Postgres repository test")
+	}
+
+	conn, err := db.InitDB(config.DatabaseConfig{
+		URL:            dbURL,
+		MaxOpenConns:   8,
+		MaxIdleConns:   8,
+		ConnectTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	migrator, err := db.NewMigrator(conn)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return conn
+}
+
+// testAccountIDs returns n account IDs unique to this run and deletes the
+// accounts with those IDs, and everything referencing them, when the test
+// ends
+func testAccountIDs(t *testing.T, conn *sql.DB, n int) []int64 {
+	t.Helper()
+	require.Less(t, n, 100)
+	base := time.Now().UnixNano() % 1_000_000_000_000
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = base*100 + int64(i) + 1
+	}
+	t.Cleanup(func() {
+		arr := pq.Array(ids)
+		conn.Exec(`DELETE FROM external_statement_lines WHERE account_id = ANY($1)`, arr)
+		conn.Exec(`DELETE FROM external_statements WHERE account_id = ANY($1)`, arr)
+		conn.Exec(`DELETE FROM balance_snapshots WHERE account_id = ANY($1)`, arr)
+		conn.Exec(`
+			DELETE FROM payment_file_transfers WHERE transaction_id IN (
+				SELECT id FROM transactions WHERE source_account_id = ANY($1) OR destination_account_id = ANY($1))`, arr)
+		conn.Exec(`DELETE FROM transactions WHERE source_account_id = ANY($1) OR destination_account_id = ANY($1)`, arr)
+		conn.Exec(`DELETE FROM accounts WHERE account_id = ANY($1)`, arr)
+	})
+	return ids
+}
+
+// createTestAccounts creates one account per opening balance, opened at
+// openedAt, with IDs from testAccountIDs
+func createTestAccounts(t *testing.T, conn *sql.DB, openedAt time.Time, balances ...string) []int64 {
+	t.Helper()
+	ctx := context.Background()
+	ids := testAccountIDs(t, conn, len(balances))
+	repo := NewAccountRepository(conn)
+	for i, balance := range balances {
+		require.NoError(t, repo.Create(ctx, &model.Account{ID: ids[i], Balance: decimal.RequireFromString(balance)}))
+	}
+	_, err := conn.ExecContext(ctx, `UPDATE accounts SET created_at = $1 WHERE account_id = ANY($2)`, openedAt, pq.Array(ids))
+	require.NoError(t, err)
+	return ids
+}
+
+// insertTestTransaction records a transfer created at the given time with
+// the running balances of both accounts after it, without touching the
+// account balances, and returns its ID
+func insertTestTransaction(t *testing.T, conn *sql.DB, from, to int64, amount string, at time.Time, sourceAfter, destinationAfter string) int64 {
+	t.Helper()
+	var id int64
+	err := conn.QueryRowContext(context.Background(), `
+		INSERT INTO transactions (source_account_id, destination_account_id, amount, created_at,
+			source_balance_after, destination_balance_after)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`,
+		from, to, amount, toTimestamp(at), sourceAfter, destinationAfter).Scan(&id)
+	require.NoError(t, err)
+	return id
+}
+
+// setTestTransferDetails sets the reference and description of a
+// transaction inserted by insertTestTransaction
+func setTestTransferDetails(t *testing.T, conn *sql.DB, id int64, reference, description string) {
+	t.Helper()
+	_, err := conn.ExecContext(context.Background(), `UPDATE transactions SET reference = $2, description = $3 WHERE id = $1`,
+		id, reference, description)
+	require.NoError(t, err)
+}
+
+// setLocalZone makes loc the process time zone until the test ends
+func setLocalZone(t *testing.T, loc *time.Location) {
+	t.Helper()
+	saved := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = saved })
+}
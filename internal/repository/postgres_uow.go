@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/db"
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// PostgresUnitOfWork runs units of work in Postgres transactions through a
+// db.TxRunner, so serialization failures and deadlocks are retried
+type PostgresUnitOfWork struct {
+	runner      *db.TxRunner
+	accountRepo *AccountRepository
+	txnRepo     *TransactionRepository
+	reconRepo   *ReconciliationRepository
+	paymentRepo *PaymentFileRepository
+	opts        *sql.TxOptions
+}
+
+// NewPostgresUnitOfWork creates a unit of work that opens transactions with
+// the given isolation level
+func NewPostgresUnitOfWork(runner *db.TxRunner, accountRepo *AccountRepository, txnRepo *TransactionRepository, reconRepo *ReconciliationRepository,
+	paymentRepo *PaymentFileRepository, isolation sql.IsolationLevel) *PostgresUnitOfWork {
+	return &PostgresUnitOfWork{
+		runner:      runner,
+		accountRepo: accountRepo,
+		txnRepo:     txnRepo,
+		reconRepo:   reconRepo,
+		paymentRepo: paymentRepo,
+		opts:        &sql.TxOptions{Isolation: isolation, ReadOnly: false},
+	}
+}
+
+// Run executes fn in a database transaction. Exhausting the retry budget is
+// reported as ErrConflict.
+func (u *PostgresUnitOfWork) Run(ctx context.Context, operation string, fn func(ctx context.Context, tx Tx) error) error {
+	err := u.runner.Run(ctx, operation, u.opts, func(ctx context.Context, tx *sql.Tx) error {
+		return fn(ctx, &postgresTx{tx: tx, accountRepo: u.accountRepo, txnRepo: u.txnRepo, reconRepo: u.reconRepo, paymentRepo: u.paymentRepo})
+	})
+	if errors.Is(err, db.ErrRetryBudgetExhausted) {
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+	return err
+}
+
+// postgresTx adapts the *Tx repository methods to the Tx interface
+type postgresTx struct {
+	tx          *sql.Tx
+	accountRepo *AccountRepository
+	txnRepo     *TransactionRepository
+	reconRepo   *ReconciliationRepository
+	paymentRepo *PaymentFileRepository
+}
+
+func (t *postgresTx) LockAccounts(ctx context.Context, ids []int64) (map[int64]*model.Account, error) {
+	return t.accountRepo.LockAccountsTx(ctx, ids, t.tx)
+}
+
+func (t *postgresTx) UpdateLockedBalance(ctx context.Context, account *model.Account, diff decimal.Decimal) error {
+	return t.accountRepo.UpdateLockedBalanceTx(ctx, account, diff, t.tx)
+}
+
+func (t *postgresTx) CreateTransaction(ctx context.Context, txn *model.Transaction) error {
+	return t.txnRepo.CreateTransaction(ctx, txn, t.tx)
+}
+
+func (t *postgresTx) MatchExternalLine(ctx context.Context, m *model.ExternalMatch) error {
+	return t.reconRepo.MatchTx(ctx, m, t.tx)
+}
+
+func (t *postgresTx) RecordPayment(ctx context.Context, messageID string, instruction int, transactionID int64) error {
+	return t.paymentRepo.RecordPaymentTx(ctx, messageID, instruction, transactionID, t.tx)
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+)
+
+type ReconciliationRepository struct {
+	db *sql.DB
+}
+
+var _ ReconciliationStore = (*ReconciliationRepository)(nil)
+
+func NewReconciliationRepository(db *sql.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{db: db}
+}
+
+// CreateStatement inserts the statement and copies its lines in one
+// database transaction, then reads back the IDs the lines were given
+func (r *ReconciliationRepository) CreateStatement(ctx context.Context, st *model.ExternalStatement) (retErr error) {
+	ctx, span := tracing.StartDB(ctx, "external_statements.insert", "INSERT", tracing.AttrAccountID.Int64(st.AccountID))
+	defer func() { tracing.End(span, retErr) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO external_statements (account_id, format, reference, digest)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, imported_at`,
+		st.AccountID, st.Format, st.Reference, st.Digest,
+	).Scan(&st.ID, &st.ImportedAt)
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return model.ErrStatementAlreadyImported
+		}
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("external_statement_lines",
+		"statement_id", "account_id", "line_no", "booked_at", "amount", "reference", "description"))
+	if err != nil {
+		return err
+	}
+	for _, line := range st.Lines {
+		if _, err := stmt.ExecContext(ctx, st.ID, st.AccountID, line.LineNo, line.BookedAt, line.Amount.String(),
+			line.Reference, line.Description); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT line_no, id FROM external_statement_lines WHERE statement_id = $1`, st.ID)
+	if err != nil {
+		return err
+	}
+	ids := make(map[int]int64, len(st.Lines))
+	for rows.Next() {
+		var lineNo int
+		var id int64
+		if err := rows.Scan(&lineNo, &id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids[lineNo] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+
+	for _, line := range st.Lines {
+		line.ID, line.StatementID, line.AccountID = ids[line.LineNo], st.ID, st.AccountID
+		line.Status = model.LineUnmatched
+	}
+	return nil
+}
+
+// GetStatement returns a statement with its lines in file order
+func (r *ReconciliationRepository) GetStatement(ctx context.Context, id int64) (_ *model.ExternalStatement, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "external_statements.select", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	var st model.ExternalStatement
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, account_id, format, reference, digest, imported_at
+		FROM external_statements WHERE id = $1`, id,
+	).Scan(&st.ID, &st.AccountID, &st.Format, &st.Reference, &st.Digest, &st.ImportedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrExternalStatementNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if st.Lines, err = r.ListLines(ctx, model.ExternalLineFilter{StatementID: id}); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// GetLine returns one line
+func (r *ReconciliationRepository) GetLine(ctx context.Context, id int64) (_ *model.ExternalLine, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "external_statement_lines.select_by_id", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	line, err := scanExternalLine(r.db.QueryRowContext(ctx, `
+		SELECT `+externalLineColumns+` FROM external_statement_lines WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrExternalLineNotFound
+	}
+	return line, err
+}
+
+// ListLines returns the lines matching filter by statement, then in file
+// order
+func (r *ReconciliationRepository) ListLines(ctx context.Context, filter model.ExternalLineFilter) (_ []*model.ExternalLine, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "external_statement_lines.select", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	var conds []string
+	var args []any
+	if filter.AccountID != 0 {
+		args = append(args, filter.AccountID)
+		conds = append(conds, fmt.Sprintf("account_id = $%d", len(args)))
+	}
+	if filter.StatementID != 0 {
+		args = append(args, filter.StatementID)
+		conds = append(conds, fmt.Sprintf("statement_id = $%d", len(args)))
+	}
+	switch filter.Status {
+	case model.LineMatched:
+		conds = append(conds, "transaction_id IS NOT NULL")
+	case model.LineUnmatched:
+		conds = append(conds, "transaction_id IS NULL")
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+externalLineColumns+` FROM external_statement_lines`+where+`
+		ORDER BY statement_id, line_no`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []*model.ExternalLine{}
+	for rows.Next() {
+		line, err := scanExternalLine(rows)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// MatchedTransactions returns which of the given transactions are matched,
+// mapped to their line
+func (r *ReconciliationRepository) MatchedTransactions(ctx context.Context, ids []int64) (_ map[int64]int64, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "external_statement_lines.select_matched", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT transaction_id, id FROM external_statement_lines WHERE transaction_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matched := make(map[int64]int64)
+	for rows.Next() {
+		var txnID, lineID int64
+		if err := rows.Scan(&txnID, &lineID); err != nil {
+			return nil, err
+		}
+		matched[txnID] = lineID
+	}
+	return matched, rows.Err()
+}
+
+// Match records m outside of a unit of work
+func (r *ReconciliationRepository) Match(ctx context.Context, m *model.ExternalMatch) error {
+	return r.match(ctx, r.db, m)
+}
+
+// MatchTx records m inside tx
+func (r *ReconciliationRepository) MatchTx(ctx context.Context, m *model.ExternalMatch, tx *sql.Tx) error {
+	return r.match(ctx, tx, m)
+}
+
+// queryer is the part of *sql.DB and *sql.Tx that match needs
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// match sets the line's transaction if it has none. The unique constraint
+// on transaction_id rejects a transaction already matched to another line.
+func (r *ReconciliationRepository) match(ctx context.Context, q queryer, m *model.ExternalMatch) (retErr error) {
+	ctx, span := tracing.StartDB(ctx, "external_statement_lines.match", "UPDATE", tracing.AttrTransactionID.Int64(m.TransactionID))
+	defer func() { tracing.End(span, retErr) }()
+
+	err := q.QueryRowContext(ctx, `
+		UPDATE external_statement_lines
+		SET transaction_id = $2, match_method = $3, match_confidence = $4, matched_at = now()
+		WHERE id = $1 AND transaction_id IS NULL
+		RETURNING matched_at`,
+		m.LineID, m.TransactionID, string(m.Method), m.Confidence,
+	).Scan(&m.MatchedAt)
+	var pgErr *pq.Error
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		return model.ErrTransactionAlreadyMatched
+	case errors.Is(err, sql.ErrNoRows):
+		var exists bool
+		if err := q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM external_statement_lines WHERE id = $1)`, m.LineID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return model.ErrExternalLineNotFound
+		}
+		return model.ErrLineAlreadyMatched
+	}
+	return err
+}
+
+// Unmatch clears the line's transaction
+func (r *ReconciliationRepository) Unmatch(ctx context.Context, lineID int64) (retErr error) {
+	ctx, span := tracing.StartDB(ctx, "external_statement_lines.unmatch", "UPDATE")
+	defer func() { tracing.End(span, retErr) }()
+
+	var matched bool
+	err := r.db.QueryRowContext(ctx, `
+		WITH line AS (SELECT id, transaction_id IS NOT NULL AS matched FROM external_statement_lines WHERE id = $1),
+		cleared AS (
+			UPDATE external_statement_lines l
+			SET transaction_id = NULL, match_method = NULL, match_confidence = NULL, matched_at = NULL
+			FROM line WHERE l.id = line.id AND line.matched
+		)
+		SELECT matched FROM line`, lineID).Scan(&matched)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ErrExternalLineNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return model.ErrLineNotMatched
+	}
+	return nil
+}
+
+// externalLineColumns are the columns read by scanExternalLine, in order
+const externalLineColumns = `id, statement_id, account_id, line_no, booked_at, amount, reference, description,
+	transaction_id, match_method, match_confidence, matched_at`
+
+// scanExternalLine reads one row of externalLineColumns
+func scanExternalLine(row interface{ Scan(...any) error }) (*model.ExternalLine, error) {
+	var line model.ExternalLine
+	var txnID sql.NullInt64
+	var method sql.NullString
+	var confidence sql.NullInt32
+	var matchedAt sql.NullTime
+	err := row.Scan(&line.ID, &line.StatementID, &line.AccountID, &line.LineNo, &line.BookedAt, &line.Amount,
+		&line.Reference, &line.Description, &txnID, &method, &confidence, &matchedAt)
+	if err != nil {
+		return nil, err
+	}
+	line.Status = model.LineUnmatched
+	if txnID.Valid {
+		line.Status = model.LineMatched
+		line.Match = &model.ExternalMatch{
+			LineID:        line.ID,
+			TransactionID: txnID.Int64,
+			Method:        model.MatchMethod(method.String),
+			Confidence:    int(confidence.Int32),
+			MatchedAt:     matchedAt.Time,
+		}
+	}
+	return &line, nil
+}
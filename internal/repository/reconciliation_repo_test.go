@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestReconciliationMatch_Postgres(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+	repo := NewReconciliationRepository(conn)
+
+	opened := time.Now().Add(-48 * time.Hour).Truncate(time.Hour)
+	ids := createTestAccounts(t, conn, opened, "100", "0")
+	a, b := ids[0], ids[1]
+	first := insertTestTransaction(t, conn, a, b, "10", opened.Add(time.Hour), "90", "10")
+	second := insertTestTransaction(t, conn, a, b, "20", opened.Add(2*time.Hour), "70", "30")
+
+	st := &model.ExternalStatement{AccountID: b, Format: "csv", Digest: strings.Repeat("0", 64), Lines: []*model.ExternalLine{
+		{LineNo: 1, BookedAt: opened.Add(time.Hour), Amount: decimal.NewFromInt(10)},
+		{LineNo: 2, BookedAt: opened.Add(2 * time.Hour), Amount: decimal.NewFromInt(20)},
+	}}
+	require.NoError(t, repo.CreateStatement(ctx, st))
+	line1, line2 := st.Lines[0].ID, st.Lines[1].ID
+
+	// The same file again for the account
+	dup := *st
+	dup.Lines = []*model.ExternalLine{{LineNo: 1, BookedAt: opened, Amount: decimal.NewFromInt(1)}}
+	assert.ErrorIs(t, repo.CreateStatement(ctx, &dup), model.ErrStatementAlreadyImported)
+
+	m := &model.ExternalMatch{LineID: line1, TransactionID: first, Method: model.MatchAuto, Confidence: 90}
+	require.NoError(t, repo.Match(ctx, m))
+	assert.False(t, m.MatchedAt.IsZero())
+
+	tests := []struct {
+		name    string
+		match   model.ExternalMatch
+		wantErr error
+	}{
+		{"line already matched", model.ExternalMatch{LineID: line1, TransactionID: second}, model.ErrLineAlreadyMatched},
+		{"transaction matched to another line", model.ExternalMatch{LineID: line2, TransactionID: first}, model.ErrTransactionAlreadyMatched},
+		{"unknown line", model.ExternalMatch{LineID: line2 + 1_000_000, TransactionID: second}, model.ErrExternalLineNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.match
+			m.Method, m.Confidence = model.MatchManual, 100
+			assert.ErrorIs(t, repo.Match(ctx, &m), tt.wantErr)
+		})
+	}
+
+	line, err := repo.GetLine(ctx, line1)
+	require.NoError(t, err)
+	assert.Equal(t, model.LineMatched, line.Status)
+	require.NotNil(t, line.Match)
+	assert.Equal(t, first, line.Match.TransactionID)
+	assert.Equal(t, model.MatchAuto, line.Match.Method)
+	assert.Equal(t, 90, line.Match.Confidence)
+
+	matched, err := repo.MatchedTransactions(ctx, []int64{first, second})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int64{first: line1}, matched)
+
+	// Unmatching frees both the line and the transaction
+	require.NoError(t, repo.Unmatch(ctx, line1))
+	assert.ErrorIs(t, repo.Unmatch(ctx, line1), model.ErrLineNotMatched)
+	assert.ErrorIs(t, repo.Unmatch(ctx, line2+1_000_000), model.ErrExternalLineNotFound)
+	require.NoError(t, repo.Match(ctx, &model.ExternalMatch{LineID: line2, TransactionID: first, Method: model.MatchManual, Confidence: 100}))
+
+	unmatched, err := repo.ListLines(ctx, model.ExternalLineFilter{StatementID: st.ID, Status: model.LineUnmatched})
+	require.NoError(t, err)
+	require.Len(t, unmatched, 1)
+	assert.Equal(t, line1, unmatched[0].ID)
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// ErrTransactionNotFound is returned when a transaction ID does not exist
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// ErrSnapshotRunNotFound is returned when no snapshot run has completed for
+// a business date
+var ErrSnapshotRunNotFound = errors.New("snapshot run not found")
+
+// ErrSnapshotNotFound is returned when an account has no balance snapshot
+// early enough
+var ErrSnapshotNotFound = errors.New("balance snapshot not found")
+
+// ErrConflict is returned by UnitOfWork.Run when the unit of work could not
+// complete because of concurrent updates, even after retrying. Callers may
+// safely resubmit the same operation.
+var ErrConflict = errors.New("unit of work conflicted with concurrent updates")
+
+// AccountStore provides access to accounts outside of a unit of work
+type AccountStore interface {
+	// Create inserts a new account, returning model.ErrAccountExists (or the
+	// driver's unique-violation error) if the ID is taken
+	Create(ctx context.Context, acc *model.Account) error
+	// GetByID returns model.ErrAccountNotFound if the account does not exist
+	GetByID(ctx context.Context, id int64) (*model.Account, error)
+	GetAll(ctx context.Context) ([]*model.Account, error)
+	// StreamAccounts calls fn for every account in ID order without loading
+	// them all at once, stopping at the first error fn returns
+	StreamAccounts(ctx context.Context, fn func(*model.Account) error) error
+	Exists(ctx context.Context, id int64) (bool, error)
+	GetBalance(ctx context.Context, id int64) (decimal.Decimal, error)
+	// BalancesAsOf returns the balance at asOf of each given account that
+	// existed then; other IDs are absent from the map
+	BalancesAsOf(ctx context.Context, ids []int64, asOf time.Time) (map[int64]decimal.Decimal, error)
+	// SetStatus returns model.ErrAccountNotFound if the account does not exist
+	SetStatus(ctx context.Context, id int64, status model.AccountStatus) error
+	// ImportAccounts creates, atomically, every account whose ID is free and
+	// returns the IDs that were already taken. Nothing is created on a dry
+	// run, or when opts.AllOrNothing is set and an ID was taken.
+	ImportAccounts(ctx context.Context, accounts []*model.Account, opts model.AccountImportOptions) ([]int64, error)
+}
+
+// TransactionStore provides read access to recorded transactions
+type TransactionStore interface {
+	// GetByID returns ErrTransactionNotFound if the transaction does not exist
+	GetByID(ctx context.Context, id int64) (*model.Transaction, error)
+	GetByAccountID(ctx context.Context, accountID int64) ([]*model.Transaction, error)
+	GetAll(ctx context.Context) ([]*model.Transaction, error)
+	// StreamTransactions calls fn for every transaction matching filter,
+	// oldest first, without loading them all at once, stopping at the first
+	// error fn returns
+	StreamTransactions(ctx context.Context, filter model.TransactionFilter, fn func(*model.Transaction) error) error
+	// GetTransactionHistory returns a page of the transactions matching
+	// filter, newest first
+	GetTransactionHistory(ctx context.Context, filter model.TransactionFilter, limit, offset int) ([]*model.Transaction, error)
+	// SearchTransactions returns up to search.Limit transactions matching
+	// search, in its sort order, starting after search.After when set
+	SearchTransactions(ctx context.Context, search model.TransactionSearch) ([]*model.TransactionHit, error)
+	// NetByAccount returns credits minus debits per account of the
+	// transactions created at or after since (all of them when since is
+	// zero), omitting accounts without such transactions
+	NetByAccount(ctx context.Context, since time.Time) (map[int64]decimal.Decimal, error)
+}
+
+// SnapshotStore records end-of-day balance snapshots
+type SnapshotStore interface {
+	// SnapshotAccounts snapshots for period, in ID order, up to limit of the
+	// accounts with an ID above afterID that were open before period.End,
+	// leaving existing snapshots untouched. It returns how many accounts the
+	// batch covered and the highest of their IDs.
+	SnapshotAccounts(ctx context.Context, period model.SnapshotPeriod, afterID int64, limit int) (lastID int64, n int, err error)
+	// ResumePoint returns the highest account ID snapshotted for date, or 0
+	ResumePoint(ctx context.Context, date time.Time) (int64, error)
+	// CompleteRun records that every account has been snapshotted for
+	// period and returns the run; completing a run twice keeps the first
+	CompleteRun(ctx context.Context, period model.SnapshotPeriod) (*model.SnapshotRun, error)
+	// GetRun returns ErrSnapshotRunNotFound if date has no completed run
+	GetRun(ctx context.Context, date time.Time) (*model.SnapshotRun, error)
+	// LatestRun returns the completed run with the latest business date, or
+	// ErrSnapshotRunNotFound if there is none
+	LatestRun(ctx context.Context) (*model.SnapshotRun, error)
+	// ClosingBalances returns the closing balance of every account
+	// snapshotted for date
+	ClosingBalances(ctx context.Context, date time.Time) (map[int64]decimal.Decimal, error)
+	// GetSnapshots returns an account's snapshots for the business dates
+	// from through to, inclusive, oldest first
+	GetSnapshots(ctx context.Context, accountID int64, from, to time.Time) ([]*model.BalanceSnapshot, error)
+	// LatestSnapshot returns an account's snapshot with the latest period
+	// end at or before before, or ErrSnapshotNotFound if there is none
+	LatestSnapshot(ctx context.Context, accountID int64, before time.Time) (*model.BalanceSnapshot, error)
+}
+
+// ReconciliationStore records external statements and how their lines are
+// matched with transactions
+type ReconciliationStore interface {
+	// CreateStatement records st and its lines, assigning their IDs, or
+	// returns model.ErrStatementAlreadyImported if a file with the same
+	// digest was already imported for the account
+	CreateStatement(ctx context.Context, st *model.ExternalStatement) error
+	// GetStatement returns model.ErrExternalStatementNotFound if the
+	// statement does not exist
+	GetStatement(ctx context.Context, id int64) (*model.ExternalStatement, error)
+	// GetLine returns model.ErrExternalLineNotFound if the line does not exist
+	GetLine(ctx context.Context, id int64) (*model.ExternalLine, error)
+	// ListLines returns the lines matching filter by statement, then in
+	// file order
+	ListLines(ctx context.Context, filter model.ExternalLineFilter) ([]*model.ExternalLine, error)
+	// MatchedTransactions returns which of the given transactions are
+	// matched, mapped to the ID of their line
+	MatchedTransactions(ctx context.Context, ids []int64) (map[int64]int64, error)
+	// Match pairs a line with a transaction and sets m.MatchedAt. It returns
+	// model.ErrExternalLineNotFound, model.ErrLineAlreadyMatched, or
+	// model.ErrTransactionAlreadyMatched if the transaction is matched to
+	// another line.
+	Match(ctx context.Context, m *model.ExternalMatch) error
+	// Unmatch clears a line's match, returning model.ErrExternalLineNotFound
+	// or model.ErrLineNotMatched
+	Unmatch(ctx context.Context, lineID int64) error
+}
+
+// PaymentFileStore records the payment files executed, so each is executed
+// once
+type PaymentFileStore interface {
+	// ClaimPaymentFile records a file under its message ID unless one is
+	// already recorded, and returns the recorded file: the new one, or the
+	// earlier one with its digest and, once completed, its report
+	ClaimPaymentFile(ctx context.Context, messageID, digest string) (*model.PaymentFile, error)
+	// BookedPayments returns the transaction booked for each payment of a
+	// file, by the payment's position in the file
+	BookedPayments(ctx context.Context, messageID string) (map[int]int64, error)
+	// CompletePaymentFile stores the status report of a file and returns
+	// the stored one; completing a file twice keeps the first report
+	CompletePaymentFile(ctx context.Context, messageID string, report []byte) ([]byte, error)
+}
+
+// Tx is the set of operations available inside a unit of work. Everything
+// done through a Tx is committed together or not at all.
+type Tx interface {
+	// LockAccounts locks the given accounts for the rest of the unit of work
+	// in a deterministic order. Missing accounts are absent from the map.
+	LockAccounts(ctx context.Context, ids []int64) (map[int64]*model.Account, error)
+	// UpdateLockedBalance applies diff to an account returned by
+	// LockAccounts, returning model.ErrInsufficientFunds if the balance would
+	// become negative. account.Balance is updated to the new balance.
+	UpdateLockedBalance(ctx context.Context, account *model.Account, diff decimal.Decimal) error
+	// CreateTransaction records txn, including the balances after it of the
+	// two accounts, and assigns its ID and timestamp,
+	// returning model.ErrAlreadyReversed if txn.ReversalOf has already been
+	// reversed
+	CreateTransaction(ctx context.Context, txn *model.Transaction) error
+	// MatchExternalLine is ReconciliationStore.Match within the unit of
+	// work, so a transaction booked for a line is matched with it or not
+	// booked at all
+	MatchExternalLine(ctx context.Context, m *model.ExternalMatch) error
+	// RecordPayment records that transactionID books the payment at
+	// position instruction of a claimed payment file, returning
+	// model.ErrPaymentAlreadyBooked if that payment was booked already
+	RecordPayment(ctx context.Context, messageID string, instruction int, transactionID int64) error
+}
+
+// UnitOfWork runs a function atomically against the store. fn may be invoked
+// more than once (e.g. after a serialization failure), so it must not have
+// side effects outside tx. If fn returns an error nothing is persisted.
+type UnitOfWork interface {
+	Run(ctx context.Context, operation string, fn func(ctx context.Context, tx Tx) error) error
+}
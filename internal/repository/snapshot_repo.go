@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+)
+
+type SnapshotRepository struct {
+	db *sql.DB
+}
+
+var _ SnapshotStore = (*SnapshotRepository)(nil)
+
+func NewSnapshotRepository(db *sql.DB) *SnapshotRepository {
+	return &SnapshotRepository{db: db}
+}
+
+// SnapshotAccounts snapshots a batch of accounts in one statement. Opening
+// and closing balances are the running balances recorded with each
+// account's last transaction before the period's start and end, so every
+// snapshot is computed independently of the others.
+func (r *SnapshotRepository) SnapshotAccounts(ctx context.Context, period model.SnapshotPeriod, afterID int64, limit int) (_ int64, _ int, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "balance_snapshots.insert", "INSERT")
+	defer func() { tracing.End(span, retErr) }()
+
+	// accounts.created_at is TIMESTAMPTZ, so the period end is passed again
+	// as $5 rather than compared through the TIMESTAMP-typed $3
+	var lastID int64
+	var n int
+	err := r.db.QueryRowContext(ctx, `
+		WITH batch AS (
+			SELECT account_id, opening_balance FROM accounts
+			WHERE account_id > $4 AND (created_at IS NULL OR created_at < $5)
+			ORDER BY account_id
+			LIMIT $6
+		), inserted AS (
+			INSERT INTO balance_snapshots (account_id, business_date, period_end, opening_balance, closing_balance,
+				total_debits, total_credits, debit_count, credit_count)
+			SELECT a.account_id, $1, $3,
+				COALESCE(opening.balance, a.opening_balance), COALESCE(closing.balance, a.opening_balance),
+				debits.total, credits.total, debits.n, credits.n
+			FROM batch a
+			LEFT JOIN LATERAL (`+latestBalanceSQL("created_at < $2")+`
+			) opening ON TRUE
+			LEFT JOIN LATERAL (`+latestBalanceSQL("created_at < $3")+`
+			) closing ON TRUE
+			CROSS JOIN LATERAL (
+				SELECT COALESCE(SUM(amount), 0) AS total, COUNT(*) AS n FROM transactions
+				WHERE source_account_id = a.account_id AND created_at >= $2 AND created_at < $3
+			) debits
+			CROSS JOIN LATERAL (
+				SELECT COALESCE(SUM(amount), 0) AS total, COUNT(*) AS n FROM transactions
+				WHERE destination_account_id = a.account_id AND created_at >= $2 AND created_at < $3
+			) credits
+			ON CONFLICT (business_date, account_id) DO NOTHING
+		)
+		SELECT COALESCE(MAX(account_id), 0), COUNT(*) FROM batch`,
+		businessDate(period.Date), toTimestamp(period.Start), toTimestamp(period.End), afterID, period.End, limit,
+	).Scan(&lastID, &n)
+	if err != nil {
+		return 0, 0, err
+	}
+	return lastID, n, nil
+}
+
+// ResumePoint returns the highest account ID snapshotted for date
+func (r *SnapshotRepository) ResumePoint(ctx context.Context, date time.Time) (_ int64, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "balance_snapshots.select_resume_point", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(account_id), 0) FROM balance_snapshots WHERE business_date = $1`,
+		businessDate(date)).Scan(&id)
+	return id, err
+}
+
+// CompleteRun records the run with the number of accounts snapshotted for
+// its business date
+func (r *SnapshotRepository) CompleteRun(ctx context.Context, period model.SnapshotPeriod) (_ *model.SnapshotRun, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "snapshot_runs.insert", "INSERT")
+	defer func() { tracing.End(span, retErr) }()
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO snapshot_runs (business_date, period_start, period_end, accounts)
+		SELECT $1, $2, $3, COUNT(*) FROM balance_snapshots WHERE business_date = $1
+		ON CONFLICT (business_date) DO NOTHING`,
+		businessDate(period.Date), toTimestamp(period.Start), toTimestamp(period.End))
+	if err != nil {
+		return nil, err
+	}
+	return r.GetRun(ctx, period.Date)
+}
+
+// GetRun returns the completed run for date
+func (r *SnapshotRepository) GetRun(ctx context.Context, date time.Time) (_ *model.SnapshotRun, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "snapshot_runs.select", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	return scanSnapshotRun(r.db.QueryRowContext(ctx, `
+		SELECT `+snapshotRunColumns+` FROM snapshot_runs WHERE business_date = $1`, businessDate(date)))
+}
+
+// LatestRun returns the completed run with the latest business date
+func (r *SnapshotRepository) LatestRun(ctx context.Context) (_ *model.SnapshotRun, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "snapshot_runs.select_latest", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	return scanSnapshotRun(r.db.QueryRowContext(ctx, `
+		SELECT `+snapshotRunColumns+` FROM snapshot_runs ORDER BY business_date DESC LIMIT 1`))
+}
+
+// ClosingBalances returns the closing balances snapshotted for date
+func (r *SnapshotRepository) ClosingBalances(ctx context.Context, date time.Time) (_ map[int64]decimal.Decimal, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "balance_snapshots.select_closing", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT account_id, closing_balance FROM balance_snapshots WHERE business_date = $1`, businessDate(date))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[int64]decimal.Decimal)
+	for rows.Next() {
+		var id int64
+		var balance decimal.Decimal
+		if err := rows.Scan(&id, &balance); err != nil {
+			return nil, err
+		}
+		balances[id] = balance
+	}
+	return balances, rows.Err()
+}
+
+// GetSnapshots returns an account's snapshots between two business dates
+func (r *SnapshotRepository) GetSnapshots(ctx context.Context, accountID int64, from, to time.Time) (_ []*model.BalanceSnapshot, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "balance_snapshots.select", "SELECT", tracing.AttrAccountID.Int64(accountID))
+	defer func() { tracing.End(span, retErr) }()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+snapshotColumns+`
+		FROM balance_snapshots
+		WHERE account_id = $1 AND business_date BETWEEN $2 AND $3
+		ORDER BY business_date`, accountID, businessDate(from), businessDate(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []*model.BalanceSnapshot
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// LatestSnapshot returns an account's last snapshot ending by before
+func (r *SnapshotRepository) LatestSnapshot(ctx context.Context, accountID int64, before time.Time) (_ *model.BalanceSnapshot, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "balance_snapshots.select_latest", "SELECT", tracing.AttrAccountID.Int64(accountID))
+	defer func() { tracing.End(span, retErr) }()
+
+	s, err := scanSnapshot(r.db.QueryRowContext(ctx, `
+		SELECT `+snapshotColumns+`
+		FROM balance_snapshots
+		WHERE account_id = $1 AND period_end <= $2
+		ORDER BY period_end DESC LIMIT 1`, accountID, toTimestamp(before)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSnapshotNotFound
+	}
+	return s, err
+}
+
+// snapshotColumns are the columns read by scanSnapshot, in order
+const snapshotColumns = `account_id, business_date, period_end, opening_balance, closing_balance,
+			total_debits, total_credits, debit_count, credit_count`
+
+func scanSnapshot(row interface{ Scan(...any) error }) (*model.BalanceSnapshot, error) {
+	var s model.BalanceSnapshot
+	err := row.Scan(&s.AccountID, &s.BusinessDate, &s.PeriodEnd, &s.OpeningBalance, &s.ClosingBalance,
+		&s.TotalDebits, &s.TotalCredits, &s.DebitCount, &s.CreditCount)
+	if err != nil {
+		return nil, err
+	}
+	s.PeriodEnd = fromTimestamp(s.PeriodEnd)
+	return &s, nil
+}
+
+// snapshotRunColumns are the columns read by scanSnapshotRun, in order
+const snapshotRunColumns = `business_date, period_start, period_end, accounts, completed_at`
+
+func scanSnapshotRun(row *sql.Row) (*model.SnapshotRun, error) {
+	var run model.SnapshotRun
+	err := row.Scan(&run.BusinessDate, &run.PeriodStart, &run.PeriodEnd, &run.Accounts, &run.CompletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSnapshotRunNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	run.PeriodStart = fromTimestamp(run.PeriodStart)
+	run.PeriodEnd = fromTimestamp(run.PeriodEnd)
+	return &run, nil
+}
+
+// businessDate formats a business date for a DATE parameter
+func businessDate(date time.Time) string {
+	return date.Format(model.BusinessDateLayout)
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestSnapshotAccounts_Postgres(t *testing.T) {
+	conn := openTestDB(t)
+	ctx := context.Background()
+	repo := NewSnapshotRepository(conn)
+
+	period := model.NewSnapshotPeriod(time.Now().AddDate(0, 0, -30), time.UTC)
+	ids := createTestAccounts(t, conn, period.Start.Add(-time.Hour), "100", "50", "0", "0")
+	a, b, c, late := ids[0], ids[1], ids[2], ids[3]
+	_, err := conn.ExecContext(ctx, `UPDATE accounts SET created_at = $1 WHERE account_id = $2`, period.End.Add(time.Hour), late)
+	require.NoError(t, err)
+
+	insertTestTransaction(t, conn, a, b, "10", period.Start.Add(-30*time.Minute), "90", "60")
+	insertTestTransaction(t, conn, a, b, "20", period.Start.Add(time.Hour), "70", "80")
+	insertTestTransaction(t, conn, b, a, "5", period.Start.Add(2*time.Hour), "75", "75")
+	insertTestTransaction(t, conn, a, c, "1", period.End, "74", "1")
+
+	// A first batch stops after b; the run resumes from there
+	lastID, n, err := repo.SnapshotAccounts(ctx, period, a-1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, b, lastID)
+	assert.Equal(t, 2, n)
+	resume, err := repo.ResumePoint(ctx, period.Date)
+	require.NoError(t, err)
+	assert.Equal(t, b, resume)
+
+	// The account opened after the period is skipped
+	lastID, n, err = repo.SnapshotAccounts(ctx, period, resume, 1)
+	require.NoError(t, err)
+	assert.Equal(t, c, lastID)
+	assert.Equal(t, 1, n)
+
+	type summary struct {
+		opening, closing, debits, credits string
+		debitCount, creditCount           int
+	}
+	snapshotOf := func(t *testing.T, id int64) []summary {
+		t.Helper()
+		snapshots, err := repo.GetSnapshots(ctx, id, period.Date, period.Date)
+		require.NoError(t, err)
+		var got []summary
+		for _, s := range snapshots {
+			assert.True(t, period.End.Equal(s.PeriodEnd), "period end %s", s.PeriodEnd)
+			got = append(got, summary{s.OpeningBalance.StringFixed(2), s.ClosingBalance.StringFixed(2),
+				s.TotalDebits.StringFixed(2), s.TotalCredits.StringFixed(2), s.DebitCount, s.CreditCount})
+		}
+		return got
+	}
+	assert.Equal(t, []summary{{"90.00", "75.00", "20.00", "5.00", 1, 1}}, snapshotOf(t, a))
+	assert.Equal(t, []summary{{"60.00", "75.00", "5.00", "20.00", 1, 1}}, snapshotOf(t, b))
+	assert.Equal(t, []summary{{"0.00", "0.00", "0.00", "0.00", 0, 0}}, snapshotOf(t, c))
+	assert.Empty(t, snapshotOf(t, late))
+
+	latest, err := repo.LatestSnapshot(ctx, a, period.End)
+	require.NoError(t, err)
+	assert.Equal(t, "75.00", latest.ClosingBalance.StringFixed(2))
+	_, err = repo.LatestSnapshot(ctx, a, period.End.Add(-time.Nanosecond))
+	assert.ErrorIs(t, err, ErrSnapshotNotFound, "the day had not ended")
+
+	// Snapshotting again leaves existing rows untouched
+	_, err = conn.ExecContext(ctx, `
+		UPDATE balance_snapshots SET closing_balance = 1 WHERE account_id = $1 AND business_date = $2`,
+		a, businessDate(period.Date))
+	require.NoError(t, err)
+	lastID, n, err = repo.SnapshotAccounts(ctx, period, a-1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, a, lastID)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "1.00", snapshotOf(t, a)[0].closing)
+}
@@ -0,0 +1,19 @@
+package repository
+
+import "time"
+
+// TIMESTAMP columns such as transactions.created_at hold the application's
+// local wall-clock time, which is how lib/pq writes time.Now(). Instants from
+// other zones are converted with toTimestamp before being compared with
+// them, and values read back with fromTimestamp.
+
+// toTimestamp returns t in the zone TIMESTAMP columns are written in
+func toTimestamp(t time.Time) time.Time {
+	return t.In(time.Local)
+}
+
+// fromTimestamp reads the wall clock of a scanned TIMESTAMP value as local
+// time
+func fromTimestamp(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+}
@@ -3,58 +3,107 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+
 	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+	"github.com/hidimpu/transfersystem/internal/utils"
 )
 
 type TransactionRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *utils.Logger
 }
 
+var _ TransactionStore = (*TransactionRepository)(nil)
+
 func NewTransactionRepository(db *sql.DB) *TransactionRepository {
-	return &TransactionRepository{db: db}
+	return &TransactionRepository{db: db, logger: utils.GlobalLogger}
 }
 
 // CreateTransaction creates a new transaction record with proper locking
-func (r *TransactionRepository) CreateTransaction(ctx context.Context, txn *model.Transaction, tx *sql.Tx) error {
+func (r *TransactionRepository) CreateTransaction(ctx context.Context, txn *model.Transaction, tx *sql.Tx) (retErr error) {
+	ctx, span := tracing.StartDB(ctx, "transactions.insert", "INSERT",
+		tracing.AttrSourceAccountID.Int64(txn.SourceAccountID),
+		tracing.AttrDestinationAccountID.Int64(txn.DestinationAccountID))
+	defer func() {
+		span.SetAttributes(tracing.AttrTransactionID.Int64(txn.ID))
+		tracing.End(span, retErr)
+	}()
+
 	query := `
-        INSERT INTO transactions (source_account_id, destination_account_id, amount, created_at)
-        VALUES ($1, $2, $3, $4)
-        RETURNING id;
+        INSERT INTO transactions (source_account_id, destination_account_id, amount, created_at, reversal_of,
+                                  source_balance_after, destination_balance_after, reference, description, metadata)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id, created_at;
     `
-	err := tx.QueryRowContext(
+	var reversalOf sql.NullInt64
+	if txn.ReversalOf != nil {
+		reversalOf = sql.NullInt64{Int64: *txn.ReversalOf, Valid: true}
+	}
+	metadata, err := metadataJSON(txn.Metadata)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(
 		ctx,
 		query,
 		txn.SourceAccountID,
 		txn.DestinationAccountID,
 		txn.Amount,
 		time.Now(),
-	).Scan(&txn.ID)
+		reversalOf,
+		txn.SourceBalanceAfter,
+		txn.DestinationBalanceAfter,
+		sql.NullString{String: txn.Reference, Valid: txn.Reference != ""},
+		sql.NullString{String: txn.Description, Valid: txn.Description != ""},
+		metadata,
+	).Scan(&txn.ID, &txn.CreatedAt)
+	if err != nil {
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.Constraint == "transactions_reversal_of_key" {
+			return model.ErrAlreadyReversed
+		}
+		return err
+	}
+	txn.CreatedAt = fromTimestamp(txn.CreatedAt)
 
-	return err
+	r.logger.LogDebug(ctx, "TRANSACTION_INSERT", fmt.Sprintf("Recorded transaction %d", txn.ID))
+	return nil
 }
 
 // GetByID retrieves a transaction by ID
-func (r *TransactionRepository) GetByID(ctx context.Context, id int64) (*model.Transaction, error) {
-	var txn model.Transaction
-	err := r.db.QueryRowContext(ctx, `
-		SELECT id, source_account_id, destination_account_id, amount, created_at 
-		FROM transactions WHERE id = $1`, id).Scan(
-		&txn.ID, &txn.SourceAccountID, &txn.DestinationAccountID, &txn.Amount, &txn.CreatedAt)
+func (r *TransactionRepository) GetByID(ctx context.Context, id int64) (_ *model.Transaction, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "transactions.select_by_id", "SELECT", tracing.AttrTransactionID.Int64(id))
+	defer func() { tracing.End(span, retErr) }()
+
+	txn, err := scanTransaction(r.db.QueryRowContext(ctx, `
+		SELECT `+transactionColumns+`
+		FROM transactions WHERE id = $1`, id))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransactionNotFound
 		}
 		return nil, err
 	}
-	return &txn, nil
+	return txn, nil
 }
 
 // GetByAccountID retrieves all transactions for a specific account
-func (r *TransactionRepository) GetByAccountID(ctx context.Context, accountID int64) ([]*model.Transaction, error) {
+func (r *TransactionRepository) GetByAccountID(ctx context.Context, accountID int64) (_ []*model.Transaction, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "transactions.select_by_account", "SELECT", tracing.AttrAccountID.Int64(accountID))
+	defer func() { tracing.End(span, retErr) }()
+
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, source_account_id, destination_account_id, amount, created_at 
+		SELECT `+transactionColumns+`
 		FROM transactions 
 		WHERE source_account_id = $1 OR destination_account_id = $1 
 		ORDER BY created_at DESC`, accountID)
@@ -63,21 +112,16 @@ func (r *TransactionRepository) GetByAccountID(ctx context.Context, accountID in
 	}
 	defer rows.Close()
 
-	var transactions []*model.Transaction
-	for rows.Next() {
-		var txn model.Transaction
-		if err := rows.Scan(&txn.ID, &txn.SourceAccountID, &txn.DestinationAccountID, &txn.Amount, &txn.CreatedAt); err != nil {
-			return nil, err
-		}
-		transactions = append(transactions, &txn)
-	}
-	return transactions, nil
+	return scanTransactions(rows)
 }
 
 // GetAll retrieves all transactions (for admin purposes)
-func (r *TransactionRepository) GetAll(ctx context.Context) ([]*model.Transaction, error) {
+func (r *TransactionRepository) GetAll(ctx context.Context) (_ []*model.Transaction, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "transactions.select_all", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, source_account_id, destination_account_id, amount, created_at 
+		SELECT `+transactionColumns+`
 		FROM transactions 
 		ORDER BY created_at DESC`)
 	if err != nil {
@@ -85,37 +129,266 @@ func (r *TransactionRepository) GetAll(ctx context.Context) ([]*model.Transactio
 	}
 	defer rows.Close()
 
-	var transactions []*model.Transaction
+	return scanTransactions(rows)
+}
+
+// StreamTransactions calls fn for every transaction matching filter, oldest
+// first, reading them through a server-side cursor
+func (r *TransactionRepository) StreamTransactions(ctx context.Context, filter model.TransactionFilter, fn func(*model.Transaction) error) (retErr error) {
+	ctx, span := tracing.StartDB(ctx, "transactions.stream", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	where, args := transactionFilterSQL(filter)
+	return streamRows(ctx, r.db, `SELECT `+transactionColumns+` FROM transactions`+where+` ORDER BY id`, args, func(rows *sql.Rows) error {
+		txn, err := scanTransaction(rows)
+		if err != nil {
+			return err
+		}
+		return fn(txn)
+	})
+}
+
+// transactionFilterSQL builds the WHERE clause and arguments for filter
+func transactionFilterSQL(filter model.TransactionFilter) (string, []any) {
+	conds, args := transactionFilterConds(filter, nil)
+	return whereClause(conds), args
+}
+
+// transactionFilterConds appends the conditions of filter to args and
+// returns them with the extended args
+func transactionFilterConds(filter model.TransactionFilter, args []any) ([]string, []any) {
+	var conds []string
+	if filter.AccountID != 0 {
+		args = append(args, filter.AccountID)
+		conds = append(conds, fmt.Sprintf("(source_account_id = $%[1]d OR destination_account_id = $%[1]d)", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, toTimestamp(filter.From))
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, toTimestamp(filter.To))
+		conds = append(conds, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.Reference != "" {
+		args = append(args, filter.Reference)
+		conds = append(conds, fmt.Sprintf("reference = $%d", len(args)))
+	}
+	if len(filter.Metadata) > 0 {
+		// A map of strings always encodes
+		metadata, _ := metadataJSON(filter.Metadata)
+		args = append(args, metadata)
+		conds = append(conds, fmt.Sprintf("metadata @> $%d::jsonb", len(args)))
+	}
+	return conds, args
+}
+
+// whereClause joins conds into a WHERE clause, empty when there are none
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// SearchTransactions returns a page of the transactions matching search, in
+// its sort order, each with the ID of its reversal
+func (r *TransactionRepository) SearchTransactions(ctx context.Context, search model.TransactionSearch) (_ []*model.TransactionHit, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "transactions.search", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	conds, args := transactionSearchConds(search)
+	key, direction := "created_at", "ASC"
+	if search.Sort.ByAmount() {
+		key = "amount"
+	}
+	if search.Sort.Descending() {
+		direction = "DESC"
+	}
+	args = append(args, search.Limit)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+transactionColumns+`,
+			(SELECT r.id FROM transactions r WHERE r.reversal_of = t.id)
+		FROM transactions t`+whereClause(conds)+`
+		ORDER BY `+key+` `+direction+`, id `+direction+`
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []*model.TransactionHit
 	for rows.Next() {
-		var txn model.Transaction
-		if err := rows.Scan(&txn.ID, &txn.SourceAccountID, &txn.DestinationAccountID, &txn.Amount, &txn.CreatedAt); err != nil {
+		var reversedBy sql.NullInt64
+		txn, err := scanTransaction(withExtraColumns{rows, []any{&reversedBy}})
+		if err != nil {
 			return nil, err
 		}
-		transactions = append(transactions, &txn)
+		var id *int64
+		if reversedBy.Valid {
+			id = &reversedBy.Int64
+		}
+		hits = append(hits, model.NewTransactionHit(txn, id))
+	}
+	return hits, rows.Err()
+}
+
+// transactionSearchConds builds the conditions and arguments for search,
+// over transactions aliased t. The text filter is an ILIKE that the trigram
+// indexes on reference and description serve; the cursor is a row
+// comparison on the sort key and ID that the keyset indexes serve.
+func transactionSearchConds(search model.TransactionSearch) ([]string, []any) {
+	conds, args := transactionFilterConds(model.TransactionFilter{
+		AccountID: search.AccountID,
+		From:      search.From,
+		To:        search.To,
+		Reference: search.Reference,
+	}, nil)
+	if search.CounterpartyID != 0 {
+		args = append(args, search.CounterpartyID)
+		conds = append(conds, fmt.Sprintf("(source_account_id = $%[1]d OR destination_account_id = $%[1]d)", len(args)))
+	}
+	if search.MinAmount.Valid {
+		args = append(args, search.MinAmount.Decimal)
+		conds = append(conds, fmt.Sprintf("amount >= $%d", len(args)))
+	}
+	if search.MaxAmount.Valid {
+		args = append(args, search.MaxAmount.Decimal)
+		conds = append(conds, fmt.Sprintf("amount <= $%d", len(args)))
+	}
+	if search.Text != "" {
+		args = append(args, "%"+likeEscaper.Replace(search.Text)+"%")
+		conds = append(conds, fmt.Sprintf("(reference ILIKE $%[1]d OR description ILIKE $%[1]d)", len(args)))
+	}
+	switch search.Type {
+	case model.TransactionTransfer:
+		conds = append(conds, "reversal_of IS NULL")
+	case model.TransactionReversal:
+		conds = append(conds, "reversal_of IS NOT NULL")
+	}
+	switch search.Status {
+	case model.TransactionBooked:
+		conds = append(conds, "NOT EXISTS (SELECT 1 FROM transactions r WHERE r.reversal_of = t.id)")
+	case model.TransactionReversed:
+		conds = append(conds, "EXISTS (SELECT 1 FROM transactions r WHERE r.reversal_of = t.id)")
+	}
+	if c := search.After; c != nil {
+		key, op := "created_at", ">"
+		var value any = toTimestamp(c.CreatedAt)
+		if c.Sort.ByAmount() {
+			key, value = "amount", c.Amount
+		}
+		if c.Sort.Descending() {
+			op = "<"
+		}
+		args = append(args, value, c.ID)
+		conds = append(conds, fmt.Sprintf("(%s, id) %s ($%d, $%d)", key, op, len(args)-1, len(args)))
 	}
-	return transactions, nil
+	return conds, args
+}
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// withExtraColumns scans the columns read by scanTransaction followed by
+// extra
+type withExtraColumns struct {
+	rows  *sql.Rows
+	extra []any
+}
+
+func (w withExtraColumns) Scan(dest ...any) error {
+	return w.rows.Scan(append(dest, w.extra...)...)
 }
 
-// GetTransactionHistory retrieves transaction history with pagination
-func (r *TransactionRepository) GetTransactionHistory(ctx context.Context, accountID int64, limit, offset int) ([]*model.Transaction, error) {
+// GetTransactionHistory retrieves a page of the transactions matching
+// filter, newest first
+func (r *TransactionRepository) GetTransactionHistory(ctx context.Context, filter model.TransactionFilter, limit, offset int) (_ []*model.Transaction, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "transactions.select_history", "SELECT", tracing.AttrAccountID.Int64(filter.AccountID))
+	defer func() { tracing.End(span, retErr) }()
+
+	where, args := transactionFilterSQL(filter)
+	args = append(args, limit, offset)
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, source_account_id, destination_account_id, amount, created_at 
-		FROM transactions 
-		WHERE source_account_id = $1 OR destination_account_id = $1 
-		ORDER BY created_at DESC 
-		LIMIT $2 OFFSET $3`, accountID, limit, offset)
+		SELECT `+transactionColumns+`
+		FROM transactions`+where+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanTransactions(rows)
+}
+
+// NetByAccount returns, for every account with transactions created at or
+// after since, the sum of its credits minus the sum of its debits
+func (r *TransactionRepository) NetByAccount(ctx context.Context, since time.Time) (_ map[int64]decimal.Decimal, retErr error) {
+	ctx, span := tracing.StartDB(ctx, "transactions.net_by_account", "SELECT")
+	defer func() { tracing.End(span, retErr) }()
+
+	where, args := transactionFilterSQL(model.TransactionFilter{From: since})
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT account_id, SUM(amount) FROM (
+			SELECT destination_account_id AS account_id, amount FROM transactions`+where+`
+			UNION ALL
+			SELECT source_account_id, -amount FROM transactions`+where+`
+		) movements
+		GROUP BY account_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	net := make(map[int64]decimal.Decimal)
+	for rows.Next() {
+		var id int64
+		var sum decimal.Decimal
+		if err := rows.Scan(&id, &sum); err != nil {
+			return nil, err
+		}
+		net[id] = sum
+	}
+	return net, rows.Err()
+}
+
+// transactionColumns are the columns read by scanTransaction, in order
+const transactionColumns = `id, source_account_id, destination_account_id, amount, created_at, reversal_of,
+	source_balance_after, destination_balance_after, reference, description, metadata`
+
+// scanTransaction reads one row of transactionColumns
+func scanTransaction(row interface{ Scan(...any) error }) (*model.Transaction, error) {
+	var txn model.Transaction
+	var reversalOf sql.NullInt64
+	var reference, description sql.NullString
+	var metadata []byte
+	if err := row.Scan(&txn.ID, &txn.SourceAccountID, &txn.DestinationAccountID, &txn.Amount, &txn.CreatedAt, &reversalOf,
+		&txn.SourceBalanceAfter, &txn.DestinationBalanceAfter, &reference, &description, &metadata); err != nil {
+		return nil, err
+	}
+	txn.CreatedAt = fromTimestamp(txn.CreatedAt)
+	if reversalOf.Valid {
+		txn.ReversalOf = &reversalOf.Int64
+	}
+	txn.Reference, txn.Description = reference.String, description.String
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &txn.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return &txn, nil
+}
+
+// scanTransactions reads every remaining row of transactionColumns
+func scanTransactions(rows *sql.Rows) ([]*model.Transaction, error) {
 	var transactions []*model.Transaction
 	for rows.Next() {
-		var txn model.Transaction
-		if err := rows.Scan(&txn.ID, &txn.SourceAccountID, &txn.DestinationAccountID, &txn.Amount, &txn.CreatedAt); err != nil {
+		txn, err := scanTransaction(rows)
+		if err != nil {
 			return nil, err
 		}
-		transactions = append(transactions, &txn)
+		transactions = append(transactions, txn)
 	}
-	return transactions, nil
+	return transactions, rows.Err()
 }
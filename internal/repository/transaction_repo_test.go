@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// searchAll pages through search with the given page size, carrying the
+// cursor through its token as the API does, and returns the IDs found
+func searchAll(t *testing.T, repo *TransactionRepository, search model.TransactionSearch, pageSize int) []int64 {
+	t.Helper()
+	ctx := context.Background()
+	search.Limit = pageSize
+	var ids []int64
+	for page := 0; ; page++ {
+		require.Less(t, page, 20, "paging does not end")
+		hits, err := repo.SearchTransactions(ctx, search)
+		require.NoError(t, err)
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		if len(hits) < pageSize {
+			return ids
+		}
+		cursor, err := model.ParseSearchCursor(model.CursorAfter(search.Sort, hits[len(hits)-1].Transaction).String())
+		require.NoError(t, err)
+		search.After = cursor
+	}
+}
+
+func TestSearchTransactions_Postgres(t *testing.T) {
+	conn := openTestDB(t)
+	repo := NewTransactionRepository(conn)
+
+	opened := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	ids := createTestAccounts(t, conn, opened, "1000", "0", "0")
+	a, b, c := ids[0], ids[1], ids[2]
+	at := func(h int) time.Time { return opened.Add(time.Duration(h) * time.Hour) }
+
+	// t2 and t3 share a timestamp and t3 and t4 an amount, so both sorts
+	// need the ID to break ties
+	t1 := insertTestTransaction(t, conn, a, b, "50", at(1), "950", "50")
+	t2 := insertTestTransaction(t, conn, a, c, "10", at(2), "940", "10")
+	t3 := insertTestTransaction(t, conn, a, b, "30", at(2), "910", "80")
+	t4 := insertTestTransaction(t, conn, b, a, "30", at(3), "50", "940")
+	t5 := insertTestTransaction(t, conn, a, c, "5", at(4), "935", "15")
+	setTestTransferDetails(t, conn, t1, "INV-2026-001", "Invoice for March")
+	setTestTransferDetails(t, conn, t2, "REF_7", "100% refund")
+	setTestTransferDetails(t, conn, t3, "INV-2026-002", "")
+	setTestTransferDetails(t, conn, t4, "", "Repayment of INVOICE 1")
+
+	t.Run("keyset pages", func(t *testing.T) {
+		tests := []struct {
+			sort model.TransactionSort
+			want []int64
+		}{
+			{model.SortOldestFirst, []int64{t1, t2, t3, t4, t5}},
+			{model.SortNewestFirst, []int64{t5, t4, t3, t2, t1}},
+			{model.SortSmallestFirst, []int64{t5, t2, t3, t4, t1}},
+			{model.SortLargestFirst, []int64{t1, t4, t3, t2, t5}},
+		}
+		for _, tt := range tests {
+			t.Run(string(tt.sort), func(t *testing.T) {
+				for _, pageSize := range []int{1, 2, 10} {
+					got := searchAll(t, repo, model.TransactionSearch{AccountID: a, Sort: tt.sort}, pageSize)
+					assert.Equal(t, tt.want, got, "page size %d", pageSize)
+				}
+			})
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			search model.TransactionSearch
+			want   []int64
+		}{
+			{"text in reference or description", model.TransactionSearch{Text: "invoice"}, []int64{t1, t4}},
+			{"text is case-insensitive", model.TransactionSearch{Text: "inv-2026"}, []int64{t1, t3}},
+			{"percent is literal", model.TransactionSearch{Text: "0%"}, []int64{t2}},
+			{"underscore is literal", model.TransactionSearch{Text: "F_7"}, []int64{t2}},
+			{"underscore matches no other character", model.TransactionSearch{Text: "V_2026"}, nil},
+			{"counterparty", model.TransactionSearch{CounterpartyID: c}, []int64{t2, t5}},
+			{"amount range", model.TransactionSearch{MinAmount: decimal.NewNullDecimal(decimal.NewFromInt(10)),
+				MaxAmount: decimal.NewNullDecimal(decimal.NewFromInt(30))}, []int64{t2, t3, t4}},
+			{"time range", model.TransactionSearch{From: at(2), To: at(3)}, []int64{t2, t3}},
+			{"text with cursor", model.TransactionSearch{Text: "inv", After: &model.SearchCursor{Sort: model.SortOldestFirst, CreatedAt: at(1), ID: t1}},
+				[]int64{t3, t4}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				search := tt.search
+				search.AccountID, search.Sort, search.Limit = a, model.SortOldestFirst, 10
+				hits, err := repo.SearchTransactions(context.Background(), search)
+				require.NoError(t, err)
+				var got []int64
+				for _, hit := range hits {
+					got = append(got, hit.ID)
+				}
+				assert.Equal(t, tt.want, got)
+			})
+		}
+	})
+
+	t.Run("text search uses the trigram indexes", func(t *testing.T) {
+		ctx := context.Background()
+		tx, err := conn.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+		_, err = tx.ExecContext(ctx, `SET LOCAL enable_seqscan = off`)
+		require.NoError(t, err)
+
+		conds, args := transactionSearchConds(model.TransactionSearch{Text: "invoice"})
+		rows, err := tx.QueryContext(ctx, `EXPLAIN SELECT id FROM transactions t`+whereClause(conds), args...)
+		require.NoError(t, err)
+		defer rows.Close()
+		var plan string
+		for rows.Next() {
+			var line string
+			require.NoError(t, rows.Scan(&line))
+			plan += line + "\n"
+		}
+		require.NoError(t, rows.Err())
+		assert.Contains(t, plan, "idx_transactions_reference_trgm")
+		assert.Contains(t, plan, "idx_transactions_description_trgm")
+	})
+}
+
+func TestSearchTransactions_NonUTCZone_Postgres(t *testing.T) {
+	// created_at holds local wall-clock time; outside UTC a timestamp read
+	// back without fromTimestamp is off by the zone offset, and so is any
+	// cursor built from it
+	setLocalZone(t, time.FixedZone("UTC+3", 3*60*60))
+	conn := openTestDB(t)
+	repo := NewTransactionRepository(conn)
+
+	opened := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	ids := createTestAccounts(t, conn, opened, "100", "0")
+	a, b := ids[0], ids[1]
+	times := []time.Time{opened.Add(time.Hour), opened.Add(2 * time.Hour), opened.Add(5 * time.Hour)}
+	var want []int64
+	for _, at := range times {
+		want = append(want, insertTestTransaction(t, conn, a, b, "1", at, "0", "0"))
+	}
+
+	hits, err := repo.SearchTransactions(context.Background(), model.TransactionSearch{AccountID: a, Sort: model.SortOldestFirst, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, hits, len(times))
+	for i, hit := range hits {
+		assert.True(t, times[i].Equal(hit.CreatedAt), "created_at %s, want %s", hit.CreatedAt, times[i])
+	}
+
+	for _, sort := range []model.TransactionSort{model.SortOldestFirst, model.SortNewestFirst} {
+		got := searchAll(t, repo, model.TransactionSearch{AccountID: a, Sort: sort}, 1)
+		if sort.Descending() {
+			assert.Equal(t, []int64{want[2], want[1], want[0]}, got, sort)
+		} else {
+			assert.Equal(t, want, got, sort)
+		}
+	}
+
+	// A time range is applied in the same zone
+	hits, err = repo.SearchTransactions(context.Background(), model.TransactionSearch{AccountID: a, Sort: model.SortOldestFirst,
+		From: times[1].UTC(), To: times[2].UTC(), Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, want[1], hits[0].ID)
+}
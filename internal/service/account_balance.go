@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+)
+
+func (s *accountService) GetBalanceAsOf(ctx context.Context, id int64, asOf time.Time) (_ *model.AccountBalance, retErr error) {
+	ctx, span := tracing.Start(ctx, "AccountService.GetBalanceAsOf", tracing.AttrAccountID.Int64(id))
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
+	if err := validateAsOf(asOf); err != nil {
+		return nil, err
+	}
+	// Distinguishes accounts that never existed from those opened later
+	account, err := s.GetAccountByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !account.OpenAt(asOf) {
+		return nil, model.WithDetails(model.ErrAccountNotOpen, model.ErrorDetails{
+			"account_id": id,
+			"created_at": account.CreatedAt.UTC().Format(time.RFC3339Nano),
+		})
+	}
+
+	balances, err := s.accountRepo.BalancesAsOf(ctx, []int64{id}, asOf)
+	if err != nil {
+		s.logger.LogError(ctx, "ACCOUNT_BALANCE_AS_OF", "QUERY_ERROR", fmt.Sprintf("Failed to compute balance of account %d", id), err)
+		return nil, model.ErrFailedGetAccount
+	}
+	balance, ok := balances[id]
+	if !ok {
+		// Deleted between the two reads
+		return nil, model.WithDetails(model.ErrAccountNotFound, model.ErrorDetails{"account_id": id})
+	}
+	return &model.AccountBalance{AccountID: id, Balance: balance, AsOf: asOf}, nil
+}
+
+func (s *accountService) GetBalancesAsOf(ctx context.Context, ids []int64, asOf time.Time) (_ *model.BalancesAsOf, retErr error) {
+	ctx, span := tracing.Start(ctx, "AccountService.GetBalancesAsOf")
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
+	if err := validateAsOf(asOf); err != nil {
+		return nil, err
+	}
+	if len(ids) > model.MaxBalanceQueryAccounts {
+		return nil, model.WithDetails(model.ErrTooManyAccounts, model.ErrorDetails{
+			"field": "account_ids",
+			"limit": model.MaxBalanceQueryAccounts,
+		})
+	}
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, model.WithDetails(model.ErrAccountIDRequired, model.ErrorDetails{"field": "account_ids", "account_id": id})
+		}
+	}
+
+	balances, err := s.accountRepo.BalancesAsOf(ctx, ids, asOf)
+	if err != nil {
+		s.logger.LogError(ctx, "ACCOUNT_BALANCE_AS_OF", "QUERY_ERROR", fmt.Sprintf("Failed to compute balances of %d account(s)", len(ids)), err)
+		return nil, model.ErrFailedGetAccount
+	}
+
+	result := &model.BalancesAsOf{
+		AsOf:     asOf,
+		Balances: make([]model.AccountBalance, 0, len(balances)),
+		NotFound: []int64{},
+	}
+	for _, id := range ids {
+		balance, ok := balances[id]
+		if !ok {
+			result.NotFound = append(result.NotFound, id)
+			continue
+		}
+		result.Balances = append(result.Balances, model.AccountBalance{AccountID: id, Balance: balance, AsOf: asOf})
+	}
+	s.logger.LogInfo(ctx, "ACCOUNT_BALANCE_AS_OF", fmt.Sprintf("Computed %d balance(s) as of %s, %d not found",
+		len(result.Balances), asOf.Format(time.RFC3339), len(result.NotFound)))
+	return result, nil
+}
+
+// validateAsOf rejects points in time that have not happened yet, whose
+// balance could still change
+func validateAsOf(asOf time.Time) error {
+	if asOf.After(time.Now()) {
+		return model.WithDetails(model.ErrAsOfInFuture, model.ErrorDetails{"field": "as_of"})
+	}
+	return nil
+}
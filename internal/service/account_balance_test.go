@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestGetBalanceAsOf(t *testing.T) {
+	ctx := context.Background()
+	accounts, svc, _ := newMemoryServices(t, config.TransferConfig{})
+	opened := time.Now()
+
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(100)))
+	afterFirst := time.Now()
+	require.NoError(t, svc.Transfer(ctx, 2, 1, decimal.NewFromInt(30)))
+	_, err := svc.Reverse(ctx, 2, false)
+	require.NoError(t, err)
+	afterAll := time.Now()
+	require.NoError(t, accounts.CreateAccount(ctx, &model.Account{ID: 3, Balance: decimal.NewFromInt(5)}))
+
+	tests := []struct {
+		name    string
+		id      int64
+		asOf    time.Time
+		want    string
+		wantErr error
+	}{
+		{"before any transfer", 1, opened, "1000", nil},
+		{"after the first transfer", 1, afterFirst, "900", nil},
+		{"after the first transfer, other side", 2, afterFirst, "1100", nil},
+		{"after the reversal", 2, afterAll, "1100", nil},
+		{"not yet open", 3, afterAll, "", model.ErrAccountNotOpen},
+		{"unknown account", 9, afterAll, "", model.ErrAccountNotFound},
+		{"future", 1, time.Now().Add(time.Hour), "", model.ErrAsOfInFuture},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := accounts.GetBalanceAsOf(ctx, tt.id, tt.asOf)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Balance.String())
+		})
+	}
+}
+
+func TestGetBalancesAsOf(t *testing.T) {
+	ctx := context.Background()
+	accounts, svc, _ := newMemoryServices(t, config.TransferConfig{})
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromFloat(0.12345)))
+
+	got, err := accounts.GetBalancesAsOf(ctx, []int64{2, 7, 1, 2}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, got.Balances, 2)
+	assert.Equal(t, int64(1), got.Balances[0].AccountID)
+	assert.Equal(t, "999.87655", got.Balances[0].Balance.String())
+	assert.Equal(t, "1000.12345", got.Balances[1].Balance.String())
+	assert.Equal(t, []int64{7}, got.NotFound)
+
+	_, err = accounts.GetBalancesAsOf(ctx, make([]int64, model.MaxBalanceQueryAccounts+1), time.Now())
+	assert.ErrorIs(t, err, model.ErrTooManyAccounts)
+	_, err = accounts.GetBalancesAsOf(ctx, []int64{1, 0}, time.Now())
+	assert.ErrorIs(t, err, model.ErrAccountIDRequired)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+)
+
+func (s *accountService) ImportAccounts(ctx context.Context, rows []model.AccountImportRow, opts model.AccountImportOptions) (_ *model.AccountImportReport, retErr error) {
+	ctx, span := tracing.Start(ctx, "AccountService.ImportAccounts")
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
+	report := &model.AccountImportReport{
+		Rows:         len(rows),
+		AllOrNothing: opts.AllOrNothing,
+		DryRun:       opts.DryRun,
+		Errors:       []model.ImportRowError{},
+	}
+	reject := func(row *model.AccountImportRow, err error) {
+		report.Errors = append(report.Errors, model.ImportRowError{
+			Line:      row.Line,
+			AccountID: row.Account.ID,
+			Code:      model.CodeOf(err),
+			Message:   err.Error(),
+			Details:   model.DetailsOf(err),
+		})
+	}
+
+	valid := make([]*model.Account, 0, len(rows))
+	lineOf := make(map[int64]int, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		if row.Err != nil {
+			reject(row, row.Err)
+			continue
+		}
+		if err := s.validateNewAccount(ctx, &row.Account); err != nil {
+			reject(row, err)
+			continue
+		}
+		if first, dup := lineOf[row.Account.ID]; dup {
+			reject(row, model.WithDetails(model.ErrAccountExists, model.ErrorDetails{"account_id": row.Account.ID, "first_line": first}))
+			continue
+		}
+		lineOf[row.Account.ID] = row.Line
+		valid = append(valid, &row.Account)
+	}
+
+	// Already-taken IDs are still looked up when the import is bound to be
+	// rejected, so the report lists every failing row
+	storeOpts := opts
+	storeOpts.DryRun = opts.DryRun || (opts.AllOrNothing && len(report.Errors) > 0)
+	var taken []int64
+	if len(valid) > 0 {
+		var err error
+		taken, err = s.accountRepo.ImportAccounts(ctx, valid, storeOpts)
+		if err != nil {
+			s.logger.LogError(ctx, "ACCOUNT_IMPORT", "IMPORT_ERROR", fmt.Sprintf("Failed to import %d accounts", len(valid)), err)
+			return nil, model.ErrFailedCreateAccount
+		}
+	}
+	for _, id := range taken {
+		row := &model.AccountImportRow{Line: lineOf[id], Account: model.Account{ID: id}}
+		reject(row, model.WithDetails(model.ErrAccountExists, model.ErrorDetails{"account_id": id}))
+	}
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Line < report.Errors[j].Line })
+
+	report.Failed = len(report.Errors)
+	if opts.AllOrNothing && report.Failed > 0 {
+		s.logger.LogWarning(ctx, "ACCOUNT_IMPORT", fmt.Sprintf("Rejected import of %d rows: %d failed", report.Rows, report.Failed))
+		return report, model.WithDetails(model.ErrImportRejected, model.ErrorDetails{"rows": report.Rows, "failed": report.Failed})
+	}
+	report.Created = len(valid) - len(taken)
+
+	if opts.DryRun {
+		s.logger.LogInfo(ctx, "ACCOUNT_IMPORT", fmt.Sprintf("Dry run of %d rows: %d would be created, %d failed", report.Rows, report.Created, report.Failed))
+	} else {
+		s.logger.LogInfo(ctx, "ACCOUNT_IMPORT", fmt.Sprintf("Imported %d rows: %d created, %d failed", report.Rows, report.Created, report.Failed))
+	}
+	return report, nil
+}
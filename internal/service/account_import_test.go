@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// importRows builds rows on consecutive lines starting at 2, as after a CSV
+// header
+func importRows(accounts ...model.Account) []model.AccountImportRow {
+	rows := make([]model.AccountImportRow, len(accounts))
+	for i, acc := range accounts {
+		rows[i] = model.AccountImportRow{Line: i + 2, Account: acc}
+	}
+	return rows
+}
+
+func account(id, balance int64) model.Account {
+	return model.Account{ID: id, Balance: decimal.NewFromInt(balance)}
+}
+
+func TestImportAccounts(t *testing.T) {
+	rows := func() []model.AccountImportRow {
+		rows := importRows(
+			account(10, 100),
+			account(1, 5),   // taken by the seed data
+			account(11, -1), // negative balance
+			account(12, 0),
+			account(10, 7), // repeats line 2
+			model.Account{ID: 13, Balance: decimal.NewFromInt(1), Metadata: model.Metadata{"": "x"}},
+		)
+		rows = append(rows, model.AccountImportRow{Line: 8, Err: model.ErrInvalidBalance})
+		return rows
+	}
+	wantErrors := []struct {
+		line int
+		code string
+	}{
+		{3, "ACCOUNT_EXISTS"},
+		{4, "NEGATIVE_BALANCE"},
+		{6, "ACCOUNT_EXISTS"},
+		{7, "INVALID_METADATA"},
+		{8, "INVALID_BALANCE"},
+	}
+
+	tests := []struct {
+		name        string
+		opts        model.AccountImportOptions
+		wantCreated int
+		wantErr     error
+		wantStored  bool
+	}{
+		{name: "valid rows are created", wantCreated: 2, wantStored: true},
+		{name: "dry run", opts: model.AccountImportOptions{DryRun: true}, wantCreated: 2},
+		{name: "all or nothing", opts: model.AccountImportOptions{AllOrNothing: true}, wantErr: model.ErrImportRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			accounts, _, _ := newMemoryServices(t, config.TransferConfig{})
+
+			report, err := accounts.ImportAccounts(ctx, rows(), tt.opts)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NotNil(t, report)
+			assert.Equal(t, 7, report.Rows)
+			assert.Equal(t, tt.wantCreated, report.Created)
+			assert.Equal(t, len(wantErrors), report.Failed)
+			require.Len(t, report.Errors, len(wantErrors))
+			for i, want := range wantErrors {
+				assert.Equal(t, want.line, report.Errors[i].Line)
+				assert.Equal(t, want.code, report.Errors[i].Code, "line %d", want.line)
+			}
+			assert.Equal(t, model.ErrorDetails{"account_id": int64(10), "first_line": 2}, report.Errors[2].Details)
+
+			acc, err := accounts.GetAccountByID(ctx, 10)
+			if tt.wantStored {
+				require.NoError(t, err)
+				assert.Equal(t, "100", acc.Balance.String(), "the first occurrence wins")
+			} else {
+				assert.ErrorIs(t, err, model.ErrAccountNotFound)
+			}
+			acc, err = accounts.GetAccountByID(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, "1000", acc.Balance.String(), "existing accounts are untouched")
+		})
+	}
+}
+
+func TestImportAccounts_AllOrNothingCommitsCleanImports(t *testing.T) {
+	ctx := context.Background()
+	accounts, _, store := newMemoryServices(t, config.TransferConfig{})
+
+	rows := importRows(account(10, 1), account(11, 2))
+	rows[1].Account.Metadata = model.Metadata{"partner_ref": "A-17"}
+	report, err := accounts.ImportAccounts(ctx, rows, model.AccountImportOptions{AllOrNothing: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Created)
+	assert.Empty(t, report.Errors)
+
+	acc, err := accounts.GetAccountByID(ctx, 11)
+	require.NoError(t, err)
+	assert.Equal(t, model.Metadata{"partner_ref": "A-17"}, acc.Metadata)
+	assert.Equal(t, model.AccountActive, acc.Status)
+
+	all, err := store.Accounts().GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+}
+
+func TestCreateAccount_ValidatesMetadata(t *testing.T) {
+	ctx := context.Background()
+	accounts, _, _ := newMemoryServices(t, config.TransferConfig{})
+
+	err := accounts.CreateAccount(ctx, &model.Account{ID: 3, Metadata: model.Metadata{"note": strings.Repeat("x", model.MaxMetadataValueLength+1)}})
+	assert.ErrorIs(t, err, model.ErrInvalidMetadata)
+	assert.Equal(t, "metadata.note", model.DetailsOf(err)["field"])
+}
@@ -4,89 +4,182 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/lib/pq"
 
 	"github.com/hidimpu/transfersystem/internal/model"
 	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/tracing"
 	"github.com/hidimpu/transfersystem/internal/utils"
 )
 
 type AccountService interface {
 	CreateAccount(ctx context.Context, account *model.Account) error
 	GetAccountByID(ctx context.Context, id int64) (*model.Account, error)
+	// ValidateAccount runs every check CreateAccount would, including that
+	// the ID is free, without creating anything
+	ValidateAccount(ctx context.Context, account *model.Account) error
+	// SetAccountStatus freezes or unfreezes an account and returns it as
+	// updated. Setting the current status is a no-op.
+	SetAccountStatus(ctx context.Context, id int64, status model.AccountStatus) (*model.Account, error)
+	// ImportAccounts validates every row with the rules of CreateAccount and
+	// creates the valid ones in a single batch, reporting each rejected row.
+	// With opts.AllOrNothing nothing is created if any row fails, and the
+	// report comes with ErrImportRejected.
+	ImportAccounts(ctx context.Context, rows []model.AccountImportRow, opts model.AccountImportOptions) (*model.AccountImportReport, error)
+	// GetBalanceAsOf returns an account's balance at asOf, which must not
+	// be in the future. An account opened after asOf yields
+	// ErrAccountNotOpen.
+	GetBalanceAsOf(ctx context.Context, id int64, asOf time.Time) (*model.AccountBalance, error)
+	// GetBalancesAsOf returns the balances at asOf of up to
+	// model.MaxBalanceQueryAccounts accounts, listing those that did not
+	// exist then as not found
+	GetBalancesAsOf(ctx context.Context, ids []int64, asOf time.Time) (*model.BalancesAsOf, error)
 }
 
 type accountService struct {
-	accountRepo *repository.AccountRepository
+	accountRepo repository.AccountStore
 	logger      *utils.Logger
 }
 
-func NewAccountService(repo *repository.AccountRepository) AccountService {
+func NewAccountService(repo repository.AccountStore) AccountService {
 	return &accountService{
 		accountRepo: repo,
 		logger:      utils.GlobalLogger,
 	}
 }
 
-func (s *accountService) CreateAccount(ctx context.Context, account *model.Account) error {
+func (s *accountService) CreateAccount(ctx context.Context, account *model.Account) (retErr error) {
+	ctx, span := tracing.Start(ctx, "AccountService.CreateAccount", tracing.AttrAccountID.Int64(account.ID))
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
 	// Log account creation attempt
-	s.logger.LogAccount("CREATE_ATTEMPT", account.ID, account.Balance.String(), false)
+	s.logger.LogAccount(ctx, "CREATE_ATTEMPT", account.ID, account.Balance.String(), false)
 
 	// Validate account data
-	if account.ID <= 0 {
-		s.logger.LogWarning("ACCOUNT_VALIDATION", fmt.Sprintf("Invalid account ID: %d", account.ID))
-		return model.ErrAccountIDRequired
-	}
-	if account.Balance.IsNegative() {
-		s.logger.LogWarning("ACCOUNT_VALIDATION", fmt.Sprintf("Negative balance: %s", account.Balance.String()))
-		return model.ErrNegativeBalance
+	if err := s.validateNewAccount(ctx, account); err != nil {
+		return err
 	}
 
 	err := s.accountRepo.Create(ctx, account)
 	if err != nil {
 		var pgErr *pq.Error
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			s.logger.LogWarning("ACCOUNT_CREATE", fmt.Sprintf("Account %d already exists", account.ID))
-			return model.ErrAccountExists
+			s.logger.LogWarning(ctx, "ACCOUNT_CREATE", fmt.Sprintf("Account %d already exists", account.ID))
+			return model.WithDetails(model.ErrAccountExists, model.ErrorDetails{"account_id": account.ID})
 		}
 
 		if errors.Is(err, model.ErrAccountExists) {
-			s.logger.LogWarning("ACCOUNT_CREATE", fmt.Sprintf("Account %d already exists", account.ID))
-			return model.ErrAccountExists
+			s.logger.LogWarning(ctx, "ACCOUNT_CREATE", fmt.Sprintf("Account %d already exists", account.ID))
+			return model.WithDetails(model.ErrAccountExists, model.ErrorDetails{"account_id": account.ID})
 		}
 
-		s.logger.LogError("ACCOUNT_CREATE", "CREATE_ERROR", fmt.Sprintf("Failed to create account %d", account.ID), err)
+		s.logger.LogError(ctx, "ACCOUNT_CREATE", "CREATE_ERROR", fmt.Sprintf("Failed to create account %d", account.ID), err)
 		return model.ErrFailedCreateAccount
 	}
 
 	// Log successful account creation
-	s.logger.LogAccount("CREATE_SUCCESS", account.ID, account.Balance.String(), true)
+	s.logger.LogAccount(ctx, "CREATE_SUCCESS", account.ID, account.Balance.String(), true)
 	return nil
 }
 
-func (s *accountService) GetAccountByID(ctx context.Context, id int64) (*model.Account, error) {
+func (s *accountService) GetAccountByID(ctx context.Context, id int64) (_ *model.Account, retErr error) {
+	ctx, span := tracing.Start(ctx, "AccountService.GetAccountByID", tracing.AttrAccountID.Int64(id))
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
 	// Log account retrieval attempt
-	s.logger.LogAccount("GET_ATTEMPT", id, "N/A", false)
+	s.logger.LogAccount(ctx, "GET_ATTEMPT", id, "N/A", false)
 
 	// Validate account ID
 	if id <= 0 {
-		s.logger.LogWarning("ACCOUNT_VALIDATION", fmt.Sprintf("Invalid account ID: %d", id))
+		s.logger.LogWarning(ctx, "ACCOUNT_VALIDATION", fmt.Sprintf("Invalid account ID: %d", id))
 		return nil, model.ErrAccountIDRequired
 	}
 
 	account, err := s.accountRepo.GetByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, model.ErrAccountNotFound) {
-			s.logger.LogWarning("ACCOUNT_GET", fmt.Sprintf("Account not found: %d", id))
-			return nil, model.ErrAccountNotFound
+			s.logger.LogWarning(ctx, "ACCOUNT_GET", fmt.Sprintf("Account not found: %d", id))
+			return nil, model.WithDetails(model.ErrAccountNotFound, model.ErrorDetails{"account_id": id})
 		}
 
-		s.logger.LogError("ACCOUNT_GET", "GET_ERROR", fmt.Sprintf("Failed to retrieve account %d", id), err)
+		s.logger.LogError(ctx, "ACCOUNT_GET", "GET_ERROR", fmt.Sprintf("Failed to retrieve account %d", id), err)
 		return nil, model.ErrFailedGetAccount
 	}
 
 	// Log successful account retrieval
-	s.logger.LogAccount("GET_SUCCESS", id, account.Balance.String(), true)
+	s.logger.LogAccount(ctx, "GET_SUCCESS", id, account.Balance.String(), true)
+	return account, nil
+}
+
+func (s *accountService) SetAccountStatus(ctx context.Context, id int64, status model.AccountStatus) (_ *model.Account, retErr error) {
+	ctx, span := tracing.Start(ctx, "AccountService.SetAccountStatus", tracing.AttrAccountID.Int64(id))
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
+	if !status.Valid() {
+		s.logger.LogWarning(ctx, "ACCOUNT_VALIDATION", fmt.Sprintf("Invalid account status: %q", status))
+		return nil, model.WithDetails(model.ErrInvalidStatus, model.ErrorDetails{"field": "status", "status": string(status)})
+	}
+
+	account, err := s.GetAccountByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if account.Status == status {
+		return account, nil
+	}
+
+	if err := s.accountRepo.SetStatus(ctx, id, status); err != nil {
+		if errors.Is(err, model.ErrAccountNotFound) {
+			return nil, model.WithDetails(model.ErrAccountNotFound, model.ErrorDetails{"account_id": id})
+		}
+		s.logger.LogError(ctx, "ACCOUNT_STATUS", "UPDATE_ERROR", fmt.Sprintf("Failed to set account %d to %s", id, status), err)
+		return nil, model.ErrFailedUpdateAccount
+	}
+
+	s.logger.LogInfo(ctx, "ACCOUNT_STATUS", fmt.Sprintf("Account %d is now %s (was %s)", id, status, account.Status))
+	account.Status = status
 	return account, nil
 }
+
+func (s *accountService) ValidateAccount(ctx context.Context, account *model.Account) error {
+	if err := s.validateNewAccount(ctx, account); err != nil {
+		return err
+	}
+	exists, err := s.accountRepo.Exists(ctx, account.ID)
+	if err != nil {
+		s.logger.LogError(ctx, "ACCOUNT_VALIDATION", "DB_ERROR", fmt.Sprintf("Failed to check account %d", account.ID), err)
+		return model.ErrFailedGetAccount
+	}
+	if exists {
+		return model.WithDetails(model.ErrAccountExists, model.ErrorDetails{"account_id": account.ID})
+	}
+	return nil
+}
+
+// validateNewAccount checks the fields of an account about to be created
+func (s *accountService) validateNewAccount(ctx context.Context, account *model.Account) error {
+	if account.ID <= 0 {
+		s.logger.LogWarning(ctx, "ACCOUNT_VALIDATION", fmt.Sprintf("Invalid account ID: %d", account.ID))
+		return model.ErrAccountIDRequired
+	}
+	if account.Balance.IsNegative() {
+		s.logger.LogWarning(ctx, "ACCOUNT_VALIDATION", fmt.Sprintf("Negative balance: %s", account.Balance.String()))
+		return model.ErrNegativeBalance
+	}
+	if err := account.Metadata.Validate(); err != nil {
+		s.logger.LogWarning(ctx, "ACCOUNT_VALIDATION", fmt.Sprintf("Invalid metadata for account %d: %v", account.ID, model.DetailsOf(err)["reason"]))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+)
+
+func TestSetAccountStatus_FrozenAccountsCannotTransfer(t *testing.T) {
+	ctx := context.Background()
+	accounts, svc, _ := newMemoryServices(t, config.TransferConfig{})
+
+	acc, err := accounts.SetAccountStatus(ctx, 2, model.AccountFrozen)
+	require.NoError(t, err)
+	assert.Equal(t, model.AccountFrozen, acc.Status)
+
+	// Frozen accounts can neither receive nor send
+	for _, ids := range [][2]int64{{1, 2}, {2, 1}} {
+		err = svc.Transfer(ctx, ids[0], ids[1], decimal.NewFromInt(1))
+		assert.ErrorIs(t, err, model.ErrAccountFrozen)
+		assert.Equal(t, model.ErrorDetails{"account_id": int64(2)}, model.DetailsOf(err))
+	}
+
+	// Unfreezing restores transfers; repeating a status is a no-op
+	_, err = accounts.SetAccountStatus(ctx, 2, model.AccountActive)
+	require.NoError(t, err)
+	acc, err = accounts.SetAccountStatus(ctx, 2, model.AccountActive)
+	require.NoError(t, err)
+	assert.Equal(t, model.AccountActive, acc.Status)
+	assert.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(1)))
+
+	_, err = accounts.SetAccountStatus(ctx, 2, "closed")
+	assert.ErrorIs(t, err, model.ErrInvalidStatus)
+	_, err = accounts.SetAccountStatus(ctx, 99, model.AccountFrozen)
+	assert.ErrorIs(t, err, model.ErrAccountNotFound)
+}
+
+func TestValidateAccount_DoesNotCreate(t *testing.T) {
+	ctx := context.Background()
+	accounts, _, _ := newMemoryServices(t, config.TransferConfig{})
+
+	assert.NoError(t, accounts.ValidateAccount(ctx, &model.Account{ID: 3, Balance: decimal.NewFromInt(1)}))
+	assert.ErrorIs(t, accounts.ValidateAccount(ctx, &model.Account{ID: 1}), model.ErrAccountExists)
+	assert.ErrorIs(t, accounts.ValidateAccount(ctx, &model.Account{ID: 0}), model.ErrAccountIDRequired)
+	assert.ErrorIs(t, accounts.ValidateAccount(ctx, &model.Account{ID: 3, Balance: decimal.NewFromInt(-1)}), model.ErrNegativeBalance)
+
+	_, err := accounts.GetAccountByID(ctx, 3)
+	assert.ErrorIs(t, err, model.ErrAccountNotFound, "validation must not create the account")
+}
+
+func TestReverse(t *testing.T) {
+	ctx := context.Background()
+	accounts, svc, _ := newMemoryServices(t, config.TransferConfig{})
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(300)))
+	original, err := svc.GetTransactionByID(ctx, 1)
+	require.NoError(t, err)
+
+	balances := func() (decimal.Decimal, decimal.Decimal) {
+		src, err := accounts.GetAccountByID(ctx, 1)
+		require.NoError(t, err)
+		dst, err := accounts.GetAccountByID(ctx, 2)
+		require.NoError(t, err)
+		return src.Balance, dst.Balance
+	}
+
+	// A dry run reports the reversal without applying it
+	planned, err := svc.Reverse(ctx, original.ID, true)
+	require.NoError(t, err)
+	assert.Zero(t, planned.ID)
+	assert.Equal(t, int64(2), planned.SourceAccountID)
+	src, dst := balances()
+	assert.True(t, src.Equal(decimal.NewFromInt(700)), src.String())
+	assert.True(t, dst.Equal(decimal.NewFromInt(1300)), dst.String())
+
+	// Reversals are allowed on frozen accounts
+	_, err = accounts.SetAccountStatus(ctx, 2, model.AccountFrozen)
+	require.NoError(t, err)
+
+	reversal, err := svc.Reverse(ctx, original.ID, false)
+	require.NoError(t, err)
+	assert.NotZero(t, reversal.ID)
+	require.NotNil(t, reversal.ReversalOf)
+	assert.Equal(t, original.ID, *reversal.ReversalOf)
+	src, dst = balances()
+	assert.True(t, src.Equal(decimal.NewFromInt(1000)), src.String())
+	assert.True(t, dst.Equal(decimal.NewFromInt(1000)), dst.String())
+
+	// ...but only once, and only for transactions that exist
+	_, err = svc.Reverse(ctx, original.ID, false)
+	assert.ErrorIs(t, err, model.ErrAlreadyReversed)
+	_, err = svc.Reverse(ctx, original.ID, true)
+	assert.ErrorIs(t, err, model.ErrAlreadyReversed)
+	_, err = svc.Reverse(ctx, 99, false)
+	assert.ErrorIs(t, err, model.ErrTransactionNotFound)
+}
+
+func TestReverse_NeverOverdraws(t *testing.T) {
+	ctx := context.Background()
+	_, svc, _ := newMemoryServices(t, config.TransferConfig{})
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(500)))
+	require.NoError(t, svc.Transfer(ctx, 2, 1, decimal.NewFromInt(1500)))
+
+	_, err := svc.Reverse(ctx, 1, false)
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	_, svc, store := newMemoryServices(t, config.TransferConfig{})
+	ledger := NewLedgerService(store.Accounts(), store.Transactions(), store.Snapshots())
+
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromFloat(12.345)))
+	_, err := svc.Reverse(ctx, 1, false)
+	require.NoError(t, err)
+	require.NoError(t, svc.Transfer(ctx, 2, 1, decimal.NewFromInt(40)))
+
+	report, err := ledger.Reconcile(ctx, model.ReconcileOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Balanced(), "%+v", report.Discrepancies)
+	assert.Equal(t, 2, report.AccountsChecked)
+	assert.True(t, report.TotalBalance.Equal(decimal.NewFromInt(2000)))
+
+	// A balance change without a ledger entry is reported
+	err = store.UnitOfWork().Run(ctx, "tamper", func(ctx context.Context, tx repository.Tx) error {
+		accs, err := tx.LockAccounts(ctx, []int64{2})
+		if err != nil {
+			return err
+		}
+		return tx.UpdateLockedBalance(ctx, accs[2], decimal.NewFromInt(5))
+	})
+	require.NoError(t, err)
+
+	report, err = ledger.Reconcile(ctx, model.ReconcileOptions{})
+	require.NoError(t, err)
+	require.Len(t, report.Discrepancies, 1)
+	d := report.Discrepancies[0]
+	assert.Equal(t, int64(2), d.AccountID)
+	assert.True(t, d.Expected.Equal(decimal.NewFromInt(960)), d.Expected.String())
+	assert.True(t, d.Difference.Equal(decimal.NewFromInt(5)), d.Difference.String())
+}
+
+func TestExportTransactions(t *testing.T) {
+	ctx := context.Background()
+	_, svc, store := newMemoryServices(t, config.TransferConfig{})
+	ledger := NewLedgerService(store.Accounts(), store.Transactions(), store.Snapshots())
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(10)))
+	require.NoError(t, svc.Transfer(ctx, 2, 1, decimal.NewFromInt(20)))
+
+	var ids []int64
+	collect := func(txn *model.Transaction) error {
+		ids = append(ids, txn.ID)
+		return nil
+	}
+	require.NoError(t, ledger.ExportTransactions(ctx, model.TransactionFilter{AccountID: 1}, collect))
+	assert.Equal(t, []int64{1, 2}, ids, "oldest first")
+
+	tests := []struct {
+		name   string
+		filter model.TransactionFilter
+		want   error
+	}{
+		{"unknown account", model.TransactionFilter{AccountID: 99}, model.ErrAccountNotFound},
+		{"negative account", model.TransactionFilter{AccountID: -1}, model.ErrInvalidAccountIDs},
+		{"empty range", model.TransactionFilter{From: time.Now(), To: time.Now().Add(-time.Hour)}, model.ErrInvalidDateRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids = nil
+			assert.ErrorIs(t, ledger.ExportTransactions(ctx, tt.filter, collect), tt.want)
+			assert.Empty(t, ids)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// LedgerService checks the stored account balances against the transaction
+// ledger
+type LedgerService struct {
+	accountRepo repository.AccountStore
+	txnRepo     repository.TransactionStore
+	snapshots   repository.SnapshotStore
+	logger      *utils.Logger
+}
+
+func NewLedgerService(accRepo repository.AccountStore, txnRepo repository.TransactionStore, snapshots repository.SnapshotStore) *LedgerService {
+	return &LedgerService{
+		accountRepo: accRepo,
+		txnRepo:     txnRepo,
+		snapshots:   snapshots,
+		logger:      utils.GlobalLogger,
+	}
+}
+
+// Reconcile verifies that every account's balance equals its starting
+// balance plus the net of the transactions recorded since. The starting
+// point is the latest completed balance snapshot, or with opts.Full (or
+// without snapshots) each account's opening balance. Transfers committed
+// while it runs may show up as discrepancies; run it again to confirm.
+func (s *LedgerService) Reconcile(ctx context.Context, opts model.ReconcileOptions) (_ *model.LedgerReconciliation, retErr error) {
+	ctx, span := tracing.Start(ctx, "LedgerService.Reconcile")
+	defer func() { tracing.End(span, retErr) }()
+
+	report := &model.LedgerReconciliation{
+		TotalBalance:  decimal.Zero,
+		Discrepancies: []model.BalanceDiscrepancy{},
+	}
+
+	var start map[int64]decimal.Decimal
+	var since time.Time
+	if !opts.Full {
+		run, err := s.snapshots.LatestRun(ctx)
+		switch {
+		case err == nil:
+			if start, err = s.snapshots.ClosingBalances(ctx, run.BusinessDate); err != nil {
+				return nil, fmt.Errorf("loading snapshot balances: %w", err)
+			}
+			since = run.PeriodEnd
+			report.Snapshot = run.BusinessDate.Format(model.BusinessDateLayout)
+		case !errors.Is(err, repository.ErrSnapshotRunNotFound):
+			return nil, fmt.Errorf("loading latest snapshot run: %w", err)
+		}
+	}
+
+	net, err := s.txnRepo.NetByAccount(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("summing transactions: %w", err)
+	}
+
+	err = s.accountRepo.StreamAccounts(ctx, func(acc *model.Account) error {
+		report.AccountsChecked++
+		report.TotalBalance = report.TotalBalance.Add(acc.Balance)
+		// Accounts opened after the snapshot start from their opening balance
+		expected, ok := start[acc.ID]
+		if !ok {
+			expected = acc.OpeningBalance
+		}
+		expected = expected.Add(net[acc.ID])
+		if acc.Balance.Equal(expected) {
+			return nil
+		}
+		report.Discrepancies = append(report.Discrepancies, model.BalanceDiscrepancy{
+			AccountID:  acc.ID,
+			Balance:    acc.Balance,
+			Expected:   expected,
+			Difference: acc.Balance.Sub(expected),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading accounts: %w", err)
+	}
+
+	if report.Balanced() {
+		s.logger.LogInfo(ctx, "LEDGER_RECONCILE", fmt.Sprintf("%d account(s) reconciled", report.AccountsChecked))
+	} else {
+		s.logger.LogWarning(ctx, "LEDGER_RECONCILE", fmt.Sprintf("%d of %d account(s) do not reconcile", len(report.Discrepancies), report.AccountsChecked))
+	}
+	return report, nil
+}
+
+// ExportAccounts calls fn for every account in ID order, stopping at the
+// first error fn returns
+func (s *LedgerService) ExportAccounts(ctx context.Context, fn func(*model.Account) error) (retErr error) {
+	ctx, span := tracing.Start(ctx, "LedgerService.ExportAccounts")
+	defer func() { tracing.End(span, retErr) }()
+
+	count := 0
+	err := s.accountRepo.StreamAccounts(ctx, func(acc *model.Account) error {
+		count++
+		return fn(acc)
+	})
+	if err != nil {
+		s.logger.LogError(ctx, "LEDGER_EXPORT", "EXPORT_ERROR", fmt.Sprintf("Account export stopped after %d row(s)", count), err)
+		return err
+	}
+	s.logger.LogInfo(ctx, "LEDGER_EXPORT", fmt.Sprintf("Exported %d account(s)", count))
+	return nil
+}
+
+// ExportTransactions validates filter and calls fn for every matching
+// transaction, oldest first, stopping at the first error fn returns. A
+// filter on an unknown account returns model.ErrAccountNotFound before fn
+// is called.
+func (s *LedgerService) ExportTransactions(ctx context.Context, filter model.TransactionFilter, fn func(*model.Transaction) error) (retErr error) {
+	ctx, span := tracing.Start(ctx, "LedgerService.ExportTransactions")
+	defer func() { tracing.End(span, retErr) }()
+
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+	if filter.AccountID != 0 {
+		exists, err := s.accountRepo.Exists(ctx, filter.AccountID)
+		if err != nil {
+			return fmt.Errorf("checking account: %w", err)
+		}
+		if !exists {
+			return model.WithDetails(model.ErrAccountNotFound, model.ErrorDetails{"account_id": filter.AccountID})
+		}
+	}
+
+	count := 0
+	err := s.txnRepo.StreamTransactions(ctx, filter, func(txn *model.Transaction) error {
+		count++
+		return fn(txn)
+	})
+	if err != nil {
+		s.logger.LogError(ctx, "LEDGER_EXPORT", "EXPORT_ERROR", fmt.Sprintf("Transaction export stopped after %d row(s)", count), err)
+		return err
+	}
+	s.logger.LogInfo(ctx, "LEDGER_EXPORT", fmt.Sprintf("Exported %d transaction(s)", count))
+	return nil
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// PaymentService executes payment files, each at most once
+type PaymentService struct {
+	files     repository.PaymentFileStore
+	transfers *TransactionService
+	logger    *utils.Logger
+}
+
+// NewPaymentService creates the payment service. Payments are booked
+// through transfers, with its limits and checks.
+func NewPaymentService(files repository.PaymentFileStore, transfers *TransactionService) *PaymentService {
+	return &PaymentService{
+		files:     files,
+		transfers: transfers,
+		logger:    utils.GlobalLogger,
+	}
+}
+
+// ReportWriter renders a payment report, e.g. as a pain.002
+type ReportWriter func(w io.Writer, report *model.PaymentReport) error
+
+// ExecutePayments books every instruction of a payment batch as its own
+// transfer, in file order, and returns the status report write renders
+// for the outcome of each. Transfers are referenced by the instruction's
+// end-to-end ID. Transfers commit one by one, so a rejected instruction
+// does not undo the ones before it. Instructions the parser could not read
+// are rejected with their parse error, and a batch with Err set is
+// rejected as a whole without booking anything.
+//
+// A batch is identified by its message ID. Each booked payment is recorded
+// in its transfer's unit of work, so a batch interrupted part way resumes
+// where it stopped when submitted again, and the report of a completed
+// batch is stored and returned as is on every resubmission. A different
+// file under a message ID already used is rejected with
+// model.ErrDuplicatePaymentFile.
+func (s *PaymentService) ExecutePayments(ctx context.Context, batch *model.PaymentBatch, write ReportWriter) (_ []byte, retErr error) {
+	ctx, span := tracing.Start(ctx, "PaymentService.ExecutePayments")
+	defer func() { tracing.End(span, retErr) }()
+
+	file, err := s.files.ClaimPaymentFile(ctx, batch.MessageID, batch.Digest)
+	if err != nil {
+		s.logger.LogError(ctx, "PAYMENT_BATCH", "CLAIM_ERROR", "Failed to record payment file "+batch.MessageID, err)
+		return nil, fmt.Errorf("%w: %w", model.ErrFailedPaymentFile, err)
+	}
+	if file.Digest != batch.Digest {
+		return nil, model.WithDetails(model.ErrDuplicatePaymentFile, model.ErrorDetails{
+			"message_id":  batch.MessageID,
+			"received_at": file.ReceivedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	if file.Report != nil {
+		s.logger.LogInfo(ctx, "PAYMENT_BATCH", fmt.Sprintf("Payment batch %s already executed; replaying its report", batch.MessageID))
+		return file.Report, nil
+	}
+	booked, err := s.files.BookedPayments(ctx, batch.MessageID)
+	if err != nil {
+		s.logger.LogError(ctx, "PAYMENT_BATCH", "CLAIM_ERROR", "Failed to read payments booked for "+batch.MessageID, err)
+		return nil, fmt.Errorf("%w: %w", model.ErrFailedPaymentFile, err)
+	}
+
+	report := &model.PaymentReport{
+		MessageID: utils.NewRequestID(),
+		Batch:     batch,
+		Results:   make([]model.PaymentResult, 0, len(batch.Payments)),
+	}
+	for i, p := range batch.Payments {
+		err := batch.Err
+		if err == nil {
+			err = p.Err
+		}
+		if _, ok := booked[i]; err == nil && !ok {
+			err = s.pay(ctx, batch.MessageID, i, p)
+		}
+		report.Results = append(report.Results, model.PaymentResult{Instruction: p, Err: err})
+		if err != nil {
+			report.Rejected++
+		} else {
+			report.Accepted++
+		}
+	}
+	report.CreatedAt = time.Now()
+	s.logger.LogInfo(ctx, "PAYMENT_BATCH", fmt.Sprintf("Payment batch %s: %d accepted, %d rejected (%d booked earlier)",
+		batch.MessageID, report.Accepted, report.Rejected, len(booked)))
+
+	var buf bytes.Buffer
+	if err := write(&buf, report); err != nil {
+		return nil, err
+	}
+	// A concurrent submission of the same file may have completed first;
+	// the report it stored is the one every submission gets
+	stored, err := s.files.CompletePaymentFile(ctx, batch.MessageID, buf.Bytes())
+	if err != nil {
+		// Every payment is booked and recorded, so a resubmission books
+		// nothing and reports the same outcome
+		s.logger.LogError(ctx, "PAYMENT_BATCH", "COMPLETE_ERROR", "Failed to store the report of "+batch.MessageID, err)
+		return buf.Bytes(), nil
+	}
+	return stored, nil
+}
+
+// pay books the payment at position instruction of a batch and records it
+// against the batch. A payment a concurrent submission of the batch booked
+// first counts as booked.
+func (s *PaymentService) pay(ctx context.Context, messageID string, instruction int, p model.PaymentInstruction) error {
+	_, err := s.transfers.transfer(ctx, p.SourceAccountID, p.DestinationAccountID, p.Amount, p.TransferDetails(),
+		func(ctx context.Context, tx repository.Tx, txn *model.Transaction) error {
+			return tx.RecordPayment(ctx, messageID, instruction, txn.ID)
+		})
+	if errors.Is(err, model.ErrPaymentAlreadyBooked) {
+		return nil
+	}
+	return err
+}
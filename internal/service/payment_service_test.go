@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// reportRecorder is a ReportWriter that keeps the reports it renders and
+// writes their message ID
+type reportRecorder struct {
+	reports []*model.PaymentReport
+	err     error
+}
+
+func (rr *reportRecorder) write(w io.Writer, report *model.PaymentReport) error {
+	if rr.err != nil {
+		return rr.err
+	}
+	rr.reports = append(rr.reports, report)
+	_, err := io.WriteString(w, report.MessageID)
+	return err
+}
+
+func payment(src, dst int64, amount string) model.PaymentInstruction {
+	return model.PaymentInstruction{SourceAccountID: src, DestinationAccountID: dst, Amount: decimal.RequireFromString(amount)}
+}
+
+func TestExecutePayments(t *testing.T) {
+	ctx := context.Background()
+	accounts, txns, store := newMemoryServices(t, config.TransferConfig{})
+	svc := NewPaymentService(store.PaymentFiles(), txns)
+	unreadable := payment(1, 2, "1")
+	unreadable.Err = model.ErrInvalidAccountIDs
+
+	salary := payment(1, 2, "600")
+	salary.EndToEndID, salary.RemittanceInfo = "E2E-1", "Salary March 2026"
+	refund := payment(2, 1, "0.5")
+	refund.EndToEndID = "NOTPROVIDED"
+
+	var rec reportRecorder
+	out, err := svc.ExecutePayments(ctx, &model.PaymentBatch{MessageID: "M", Digest: "d", Payments: []model.PaymentInstruction{
+		salary,
+		payment(1, 2, "600"), // only 400 left
+		payment(1, 9, "1"),
+		unreadable,
+		refund,
+	}}, rec.write)
+	require.NoError(t, err)
+	require.Len(t, rec.reports, 1)
+	report := rec.reports[0]
+	assert.Equal(t, report.MessageID, string(out))
+	assert.Len(t, report.MessageID, 32)
+	assert.False(t, report.CreatedAt.IsZero())
+	assert.Equal(t, 2, report.Accepted)
+	assert.Equal(t, 3, report.Rejected)
+	require.Len(t, report.Results, 5)
+	assert.NoError(t, report.Results[0].Err)
+	assert.ErrorIs(t, report.Results[1].Err, model.ErrInsufficientFunds)
+	assert.ErrorIs(t, report.Results[2].Err, model.ErrDestAccountNotFound)
+	assert.ErrorIs(t, report.Results[3].Err, model.ErrInvalidAccountIDs)
+	assert.NoError(t, report.Results[4].Err)
+
+	acc, err := accounts.GetAccountByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "400.5", acc.Balance.String(), "accepted payments stay booked")
+	booked, err := txns.GetTransactionHistory(ctx, model.TransactionFilter{AccountID: 1}, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, booked, 2)
+	assert.Equal(t, model.TransferDetails{}, booked[0].TransferDetails, "NOTPROVIDED is no reference")
+	assert.Equal(t, model.TransferDetails{Reference: "E2E-1", Description: "Salary March 2026"}, booked[1].TransferDetails)
+
+	// A batch with an error books nothing
+	rec = reportRecorder{}
+	_, err = svc.ExecutePayments(ctx, &model.PaymentBatch{
+		MessageID: "N",
+		Payments:  []model.PaymentInstruction{payment(1, 2, "1")},
+		Err:       model.ErrInvalidControlSum,
+	}, rec.write)
+	require.NoError(t, err)
+	assert.Equal(t, 0, rec.reports[0].Accepted)
+	assert.ErrorIs(t, rec.reports[0].Results[0].Err, model.ErrInvalidControlSum)
+	acc, err = accounts.GetAccountByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "400.5", acc.Balance.String())
+}
+
+func TestExecutePayments_OncePerMessageID(t *testing.T) {
+	ctx := context.Background()
+	accounts, txns, store := newMemoryServices(t, config.TransferConfig{})
+	svc := NewPaymentService(store.PaymentFiles(), txns)
+	batch := func() *model.PaymentBatch {
+		return &model.PaymentBatch{MessageID: "M", Digest: "d", Payments: []model.PaymentInstruction{
+			payment(1, 2, "100"),
+			payment(1, 2, "5000"),
+			payment(2, 1, "1"),
+		}}
+	}
+	assertBalance := func(t *testing.T, want string) {
+		t.Helper()
+		acc, err := accounts.GetAccountByID(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, want, acc.Balance.String())
+	}
+
+	// The report cannot be written, as when the process dies half way: the
+	// booked payments stay booked and the file is left incomplete
+	failing := reportRecorder{err: errors.New("disk full")}
+	_, err := svc.ExecutePayments(ctx, batch(), failing.write)
+	require.Error(t, err)
+	assertBalance(t, "901")
+
+	// Submitting it again books nothing more
+	var rec reportRecorder
+	first, err := svc.ExecutePayments(ctx, batch(), rec.write)
+	require.NoError(t, err)
+	require.Len(t, rec.reports, 1)
+	assert.Equal(t, 2, rec.reports[0].Accepted)
+	assert.ErrorIs(t, rec.reports[0].Results[1].Err, model.ErrInsufficientFunds)
+	assertBalance(t, "901")
+
+	// Once complete, the stored report is replayed without executing anything
+	again, err := svc.ExecutePayments(ctx, batch(), rec.write)
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+	assert.Len(t, rec.reports, 1)
+	assertBalance(t, "901")
+	history, err := txns.GetTransactionHistory(ctx, model.TransactionFilter{AccountID: 1}, 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	// Another file under the same message ID
+	other := batch()
+	other.Digest = "e"
+	_, err = svc.ExecutePayments(ctx, other, rec.write)
+	assert.ErrorIs(t, err, model.ErrDuplicatePaymentFile)
+	assert.Equal(t, "M", model.DetailsOf(err)["message_id"])
+	assertBalance(t, "901")
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/db"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+)
+
+// postgresFixture wires the services to the database named by TEST_DB_URL
+type postgresFixture struct {
+	conn     *sql.DB
+	accounts *repository.AccountRepository
+	txns     *repository.TransactionRepository
+	uow      repository.UnitOfWork
+	transfer *TransactionService
+	// ids are the accounts created for the test
+	ids []int64
+}
+
+// newPostgresFixture migrates the TEST_DB_URL database and creates one
+// account per balance, skipping the test when the variable is unset. The
+// accounts and their transactions are deleted when the test ends.
+func newPostgresFixture(t *testing.T, balances ...int64) *postgresFixture {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL not set; skipping Postgres test")
+	}
+
+	ctx := context.Background()
+	conn, err := db.InitDB(config.DatabaseConfig{
+		URL:            dbURL,
+		MaxOpenConns:   8,
+		MaxIdleConns:   8,
+		ConnectTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	migrator, err := db.NewMigrator(conn)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	f := &postgresFixture{
+		conn:     conn,
+		accounts: repository.NewAccountRepository(conn),
+		txns:     repository.NewTransactionRepository(conn),
+	}
+	runner := db.NewTxRunner(conn, db.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: 20 * time.Millisecond})
+	f.uow = repository.NewPostgresUnitOfWork(runner, f.accounts, f.txns, repository.NewReconciliationRepository(conn),
+		repository.NewPaymentFileRepository(conn), sql.LevelSerializable)
+	f.transfer = NewTransactionService(f.uow, f.accounts, f.txns, config.TransferConfig{})
+
+	// Unique IDs so reruns against the same database do not collide
+	base := time.Now().UnixNano() % 1_000_000_000_000
+	for i, balance := range balances {
+		id := base*100 + int64(i) + 1
+		require.NoError(t, f.accounts.Create(ctx, &model.Account{ID: id, Balance: decimal.NewFromInt(balance)}))
+		f.ids = append(f.ids, id)
+	}
+	t.Cleanup(func() {
+		ids := pq.Array(f.ids)
+		conn.Exec(`DELETE FROM transactions WHERE source_account_id = ANY($1) OR destination_account_id = ANY($1)`, ids)
+		conn.Exec(`DELETE FROM accounts WHERE account_id = ANY($1)`, ids)
+	})
+	return f
+}
+
+// setLocalZone makes loc the process time zone until the test ends
+func setLocalZone(t *testing.T, loc *time.Location) {
+	t.Helper()
+	saved := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = saved })
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/matching"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// ReconciliationService matches the statements a partner bank sends for
+// the accounts it holds for us against the ledger accounts mirroring them
+type ReconciliationService struct {
+	recon       repository.ReconciliationStore
+	accountRepo repository.AccountStore
+	txnRepo     repository.TransactionStore
+	transfers   *TransactionService
+	opts        matching.Options
+	logger      *utils.Logger
+}
+
+// NewReconciliationService creates the reconciliation service. Adjusting
+// entries are booked through transfers, with its limits and checks. cfg is
+// expected to have been validated by config.Load.
+func NewReconciliationService(recon repository.ReconciliationStore, accRepo repository.AccountStore, txnRepo repository.TransactionStore,
+	transfers *TransactionService, cfg config.ReconciliationConfig) *ReconciliationService {
+	return &ReconciliationService{
+		recon:       recon,
+		accountRepo: accRepo,
+		txnRepo:     txnRepo,
+		transfers:   transfers,
+		opts: matching.Options{
+			DateWindowDays:      cfg.DateWindowDays,
+			AutoMatchConfidence: cfg.AutoMatchConfidence,
+		},
+		logger: utils.GlobalLogger,
+	}
+}
+
+// ImportStatement records an external statement of the account mirrored by
+// accountID, then matches what it can of its lines. It returns the
+// statement as stored, with the outcome of matching on every line.
+func (s *ReconciliationService) ImportStatement(ctx context.Context, accountID int64, st *model.ExternalStatement) (_ *model.ExternalStatement, retErr error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.ImportStatement", tracing.AttrAccountID.Int64(accountID))
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
+	if accountID <= 0 {
+		return nil, model.ErrAccountIDRequired
+	}
+	exists, err := s.accountRepo.Exists(ctx, accountID)
+	if err != nil {
+		s.logger.LogError(ctx, "RECONCILIATION_IMPORT", "DB_ERROR", fmt.Sprintf("Failed to validate account %d", accountID), err)
+		return nil, model.ErrFailedGetAccount
+	}
+	if !exists {
+		return nil, model.WithDetails(model.ErrAccountNotFound, model.ErrorDetails{"account_id": accountID})
+	}
+
+	st.AccountID = accountID
+	if err := s.recon.CreateStatement(ctx, st); err != nil {
+		if errors.Is(err, model.ErrStatementAlreadyImported) {
+			return nil, model.WithDetails(model.ErrStatementAlreadyImported, model.ErrorDetails{"account_id": accountID, "digest": st.Digest})
+		}
+		s.logger.LogError(ctx, "RECONCILIATION_IMPORT", "DB_ERROR", fmt.Sprintf("Failed to record statement of account %d", accountID), err)
+		return nil, model.ErrFailedReconciliation
+	}
+	s.logger.LogInfo(ctx, "RECONCILIATION_IMPORT", fmt.Sprintf("Imported external statement %d of account %d with %d lines", st.ID, accountID, len(st.Lines)))
+
+	if _, err := s.MatchStatement(ctx, st.ID); err != nil {
+		return nil, err
+	}
+	return s.Statement(ctx, st.ID)
+}
+
+// Statement returns an external statement with the state of its lines
+func (s *ReconciliationService) Statement(ctx context.Context, statementID int64) (*model.ExternalStatement, error) {
+	st, err := s.recon.GetStatement(ctx, statementID)
+	if err != nil {
+		return nil, s.storeError(ctx, err, model.ErrorDetails{"statement_id": statementID})
+	}
+	return st, nil
+}
+
+// MatchStatement runs the matching engine over the unmatched lines of a
+// statement. Lines are matched without review when their best candidate
+// scores at least the configured confidence and no other candidate is as
+// good; the rest are left for review. Running it again picks up
+// transactions booked since.
+func (s *ReconciliationService) MatchStatement(ctx context.Context, statementID int64) (_ *model.MatchReport, retErr error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.MatchStatement")
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
+	st, err := s.recon.GetStatement(ctx, statementID)
+	if err != nil {
+		return nil, s.storeError(ctx, err, model.ErrorDetails{"statement_id": statementID})
+	}
+	var unmatched []*model.ExternalLine
+	for _, line := range st.Lines {
+		if line.Status == model.LineUnmatched {
+			unmatched = append(unmatched, line)
+		}
+	}
+	report := &model.MatchReport{StatementID: statementID, Unmatched: len(unmatched)}
+	if len(unmatched) == 0 {
+		return report, nil
+	}
+
+	entries, err := s.candidateEntries(ctx, st.AccountID, unmatched)
+	if err != nil {
+		return nil, err
+	}
+	picks := matching.AutoMatch(unmatched, entries, s.opts)
+	for _, line := range unmatched {
+		c, ok := picks[line.ID]
+		if !ok {
+			continue
+		}
+		err := s.recon.Match(ctx, &model.ExternalMatch{
+			LineID:        line.ID,
+			TransactionID: c.TransactionID,
+			Method:        model.MatchAuto,
+			Confidence:    c.Confidence,
+		})
+		// A reviewer may have matched the line or the transaction meanwhile
+		if errors.Is(err, model.ErrLineAlreadyMatched) || errors.Is(err, model.ErrTransactionAlreadyMatched) {
+			s.logger.LogWarning(ctx, "RECONCILIATION_MATCH", fmt.Sprintf("Skipped line %d: %v", line.ID, err))
+			continue
+		}
+		if err != nil {
+			return nil, s.storeError(ctx, err, model.ErrorDetails{"line_id": line.ID})
+		}
+		report.Matched++
+		report.Unmatched--
+	}
+	s.logger.LogInfo(ctx, "RECONCILIATION_MATCH", fmt.Sprintf("Statement %d: matched %d lines, %d unmatched", statementID, report.Matched, report.Unmatched))
+	return report, nil
+}
+
+// Lines lists the external lines of an account for review. Unmatched lines
+// come with their candidates, best first, so a reviewer can link one.
+func (s *ReconciliationService) Lines(ctx context.Context, accountID int64, status model.ExternalLineStatus) (_ []*model.ExternalLine, retErr error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.Lines", tracing.AttrAccountID.Int64(accountID))
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
+	if accountID <= 0 {
+		return nil, model.ErrAccountIDRequired
+	}
+	lines, err := s.recon.ListLines(ctx, model.ExternalLineFilter{AccountID: accountID, Status: status})
+	if err != nil {
+		return nil, s.storeError(ctx, err, nil)
+	}
+	var unmatched []*model.ExternalLine
+	for _, line := range lines {
+		if line.Status == model.LineUnmatched {
+			unmatched = append(unmatched, line)
+		}
+	}
+	if len(unmatched) == 0 {
+		return lines, nil
+	}
+	entries, err := s.candidateEntries(ctx, accountID, unmatched)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range unmatched {
+		line.Candidates = matching.Candidates(line, entries, s.opts.DateWindowDays)
+	}
+	return lines, nil
+}
+
+// Link matches a line with a transaction chosen by a reviewer. The
+// transaction must move the line's amount in the same direction on the
+// line's account; dates are not checked.
+func (s *ReconciliationService) Link(ctx context.Context, lineID, transactionID int64) (_ *model.ExternalLine, retErr error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.Link", tracing.AttrTransactionID.Int64(transactionID))
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
+	line, err := s.recon.GetLine(ctx, lineID)
+	if err != nil {
+		return nil, s.storeError(ctx, err, model.ErrorDetails{"line_id": lineID})
+	}
+	txn, err := s.txnRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTransactionNotFound) {
+			return nil, model.WithDetails(model.ErrTransactionNotFound, model.ErrorDetails{"transaction_id": transactionID})
+		}
+		s.logger.LogError(ctx, "RECONCILIATION_LINK", "DB_ERROR", fmt.Sprintf("Failed to load transaction %d", transactionID), err)
+		return nil, model.ErrFailedReconciliation
+	}
+	if txn.SourceAccountID != line.AccountID && txn.DestinationAccountID != line.AccountID {
+		return nil, model.WithDetails(model.ErrMatchMismatch, model.ErrorDetails{
+			"reason": "transaction does not involve the line's account", "account_id": line.AccountID, "transaction_id": transactionID,
+		})
+	}
+	if entry := model.NewStatementEntry(txn, line.AccountID); !entry.Amount.Equal(line.Amount) {
+		return nil, model.WithDetails(model.ErrMatchMismatch, model.ErrorDetails{
+			"reason": "amounts differ", "line_amount": line.Amount.String(), "transaction_amount": entry.Amount.String(),
+		})
+	}
+
+	err = s.recon.Match(ctx, &model.ExternalMatch{LineID: lineID, TransactionID: transactionID, Method: model.MatchManual, Confidence: 100})
+	if err != nil {
+		return nil, s.storeError(ctx, err, model.ErrorDetails{"line_id": lineID, "transaction_id": transactionID})
+	}
+	s.logger.LogInfo(ctx, "RECONCILIATION_LINK", fmt.Sprintf("Linked line %d to transaction %d", lineID, transactionID))
+	return s.line(ctx, lineID)
+}
+
+// Unlink clears the match of a line, whatever made it, so it can be
+// matched again. An adjusting entry booked for the line stays booked.
+func (s *ReconciliationService) Unlink(ctx context.Context, lineID int64) (*model.ExternalLine, error) {
+	if err := s.recon.Unmatch(ctx, lineID); err != nil {
+		return nil, s.storeError(ctx, err, model.ErrorDetails{"line_id": lineID})
+	}
+	s.logger.LogInfo(ctx, "RECONCILIATION_UNLINK", fmt.Sprintf("Unlinked line %d", lineID))
+	return s.line(ctx, lineID)
+}
+
+// Adjust books the transfer a line is missing from the ledger and matches
+// the line with it, in one unit of work. The transfer moves the line's
+// amount between its account and counterpartyID: into the account for a
+// credit on the external statement, out of it for a debit. It is subject
+// to the same checks as any transfer.
+func (s *ReconciliationService) Adjust(ctx context.Context, lineID, counterpartyID int64) (_ *model.ExternalLine, retErr error) {
+	ctx, span := tracing.Start(ctx, "ReconciliationService.Adjust", tracing.AttrAccountID.Int64(counterpartyID))
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
+	line, err := s.recon.GetLine(ctx, lineID)
+	if err != nil {
+		return nil, s.storeError(ctx, err, model.ErrorDetails{"line_id": lineID})
+	}
+	if line.Status == model.LineMatched {
+		return nil, model.WithDetails(model.ErrLineAlreadyMatched, model.ErrorDetails{"line_id": lineID})
+	}
+	srcID, dstID := counterpartyID, line.AccountID
+	if line.Amount.IsNegative() {
+		srcID, dstID = line.AccountID, counterpartyID
+	}
+	amount := line.Amount.Abs()
+	if err := s.transfers.validateTransferRequest(ctx, srcID, dstID, amount); err != nil {
+		return nil, err
+	}
+
+	var txnID int64
+	err = s.transfers.uow.Run(ctx, "reconciliation_adjustment", func(ctx context.Context, tx repository.Tx) error {
+		txn := &model.Transaction{SourceAccountID: srcID, DestinationAccountID: dstID, Amount: amount}
+		if err := s.transfers.move(ctx, tx, txn, true); err != nil {
+			return err
+		}
+		txnID = txn.ID
+		return tx.MatchExternalLine(ctx, &model.ExternalMatch{
+			LineID:        lineID,
+			TransactionID: txn.ID,
+			Method:        model.MatchAdjustment,
+			Confidence:    100,
+		})
+	})
+	if err != nil {
+		var accountErr model.AccountError
+		if errors.As(err, &accountErr) {
+			return nil, model.WithDetails(accountErr, model.ErrorDetails{"line_id": lineID})
+		}
+		return nil, transferError(err)
+	}
+	s.logger.LogInfo(ctx, "RECONCILIATION_ADJUST", fmt.Sprintf("Booked adjusting transaction %d for line %d", txnID, lineID))
+	return s.line(ctx, lineID)
+}
+
+// line reloads a line after it changed
+func (s *ReconciliationService) line(ctx context.Context, lineID int64) (*model.ExternalLine, error) {
+	line, err := s.recon.GetLine(ctx, lineID)
+	if err != nil {
+		return nil, s.storeError(ctx, err, model.ErrorDetails{"line_id": lineID})
+	}
+	return line, nil
+}
+
+// candidateEntries returns the account's transactions that could be the
+// counterpart of one of lines: booked within the date window of the
+// earliest and latest line and not matched yet
+func (s *ReconciliationService) candidateEntries(ctx context.Context, accountID int64, lines []*model.ExternalLine) ([]model.StatementEntry, error) {
+	from, to := lines[0].BookedAt, lines[0].BookedAt
+	for _, line := range lines[1:] {
+		if line.BookedAt.Before(from) {
+			from = line.BookedAt
+		}
+		if line.BookedAt.After(to) {
+			to = line.BookedAt
+		}
+	}
+	window := time.Duration(s.opts.DateWindowDays+1) * 24 * time.Hour
+	filter := model.TransactionFilter{AccountID: accountID, From: from.Add(-window), To: to.Add(window)}
+
+	var txns []*model.Transaction
+	err := s.txnRepo.StreamTransactions(ctx, filter, func(txn *model.Transaction) error {
+		txns = append(txns, txn)
+		return nil
+	})
+	if err != nil {
+		s.logger.LogError(ctx, "RECONCILIATION_MATCH", "QUERY_ERROR", fmt.Sprintf("Failed to read transactions of account %d", accountID), err)
+		return nil, model.ErrFailedReconciliation
+	}
+	ids := make([]int64, len(txns))
+	for i, txn := range txns {
+		ids[i] = txn.ID
+	}
+	matched, err := s.recon.MatchedTransactions(ctx, ids)
+	if err != nil {
+		return nil, s.storeError(ctx, err, nil)
+	}
+
+	entries := make([]model.StatementEntry, 0, len(txns))
+	for _, txn := range txns {
+		if _, ok := matched[txn.ID]; !ok {
+			entries = append(entries, model.NewStatementEntry(txn, accountID))
+		}
+	}
+	return entries, nil
+}
+
+// storeError passes the typed errors of a ReconciliationStore on with
+// details and logs anything else as a failure
+func (s *ReconciliationService) storeError(ctx context.Context, err error, details model.ErrorDetails) error {
+	var accountErr model.AccountError
+	if errors.As(err, &accountErr) {
+		if details == nil {
+			return accountErr
+		}
+		return model.WithDetails(accountErr, details)
+	}
+	s.logger.LogError(ctx, "RECONCILIATION", "DB_ERROR", "Reconciliation store failed", err)
+	return model.ErrFailedReconciliation
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestReconciliation(t *testing.T) {
+	ctx := context.Background()
+	accounts, svc, store := newMemoryServices(t, config.TransferConfig{})
+	recon := NewReconciliationService(store.Reconciliation(), store.Accounts(), store.Transactions(), svc,
+		config.ReconciliationConfig{DateWindowDays: 3, AutoMatchConfidence: 80})
+
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(100))) // 1
+	require.NoError(t, svc.Transfer(ctx, 2, 1, decimal.NewFromInt(50)))  // 2
+	require.NoError(t, svc.Transfer(ctx, 2, 1, decimal.NewFromInt(50)))  // 3
+	now := time.Now()
+	statement := func() *model.ExternalStatement {
+		return &model.ExternalStatement{Format: "csv", Digest: "d1", Lines: []*model.ExternalLine{
+			{LineNo: 1, BookedAt: now, Amount: decimal.NewFromInt(-100), Reference: "TXN-1"},
+			{LineNo: 2, BookedAt: now, Amount: decimal.NewFromInt(50)},
+			{LineNo: 3, BookedAt: now.AddDate(0, 0, -1), Amount: decimal.NewFromInt(25), Reference: "fee refund"},
+		}}
+	}
+
+	st, err := recon.ImportStatement(ctx, 1, statement())
+	require.NoError(t, err)
+	require.Len(t, st.Lines, 3)
+	assert.Equal(t, model.LineMatched, st.Lines[0].Status)
+	assert.Equal(t, model.ExternalMatch{LineID: st.Lines[0].ID, TransactionID: 1, Method: model.MatchAuto, Confidence: 100,
+		MatchedAt: st.Lines[0].Match.MatchedAt}, *st.Lines[0].Match)
+	assert.Equal(t, model.LineUnmatched, st.Lines[1].Status, "two transactions fit equally well")
+	assert.Equal(t, model.LineUnmatched, st.Lines[2].Status)
+
+	_, err = recon.ImportStatement(ctx, 1, statement())
+	assert.ErrorIs(t, err, model.ErrStatementAlreadyImported)
+
+	lines, err := recon.Lines(ctx, 1, model.LineUnmatched)
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	require.Len(t, lines[0].Candidates, 2)
+	assert.Equal(t, int64(2), lines[0].Candidates[0].TransactionID)
+	assert.Equal(t, 80, lines[0].Candidates[0].Confidence)
+	assert.Empty(t, lines[1].Candidates)
+
+	line, err := recon.Link(ctx, st.Lines[1].ID, 3)
+	require.NoError(t, err)
+	assert.Equal(t, model.MatchManual, line.Match.Method)
+	_, err = recon.Link(ctx, st.Lines[1].ID, 2)
+	assert.ErrorIs(t, err, model.ErrLineAlreadyMatched)
+
+	line, err = recon.Adjust(ctx, st.Lines[2].ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, model.MatchAdjustment, line.Match.Method)
+	adjustment, err := store.Transactions().GetByID(ctx, line.Match.TransactionID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), adjustment.SourceAccountID, "a credit line is money into the account")
+	assert.Equal(t, int64(1), adjustment.DestinationAccountID)
+	acc, err := accounts.GetAccountByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "1025", acc.Balance.String())
+
+	line, err = recon.Unlink(ctx, st.Lines[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.LineUnmatched, line.Status)
+	assert.Nil(t, line.Match)
+	_, err = recon.Unlink(ctx, st.Lines[1].ID)
+	assert.ErrorIs(t, err, model.ErrLineNotMatched)
+
+	// With transaction 3 free again the line is still ambiguous
+	report, err := recon.MatchStatement(ctx, st.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.MatchReport{StatementID: st.ID, Matched: 0, Unmatched: 1}, *report)
+
+	tests := []struct {
+		name    string
+		link    func() error
+		wantErr error
+	}{
+		{"wrong amount", func() error { _, err := recon.Link(ctx, st.Lines[1].ID, 1); return err }, model.ErrMatchMismatch},
+		{"line already matched", func() error { _, err := recon.Link(ctx, st.Lines[2].ID, 4); return err }, model.ErrLineAlreadyMatched},
+		{"unknown transaction", func() error { _, err := recon.Link(ctx, st.Lines[1].ID, 99); return err }, model.ErrTransactionNotFound},
+		{"unknown line", func() error { _, err := recon.Link(ctx, 99, 2); return err }, model.ErrExternalLineNotFound},
+		{"adjust matched line", func() error { _, err := recon.Adjust(ctx, st.Lines[0].ID, 2); return err }, model.ErrLineAlreadyMatched},
+		{"adjust from unknown account", func() error { _, err := recon.Adjust(ctx, st.Lines[1].ID, 9); return err }, model.ErrSourceAccountNotFound},
+		{"unknown statement", func() error { _, err := recon.MatchStatement(ctx, 99); return err }, model.ErrExternalStatementNotFound},
+		{"import for unknown account", func() error { _, err := recon.ImportStatement(ctx, 9, statement()); return err }, model.ErrAccountNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.link(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// ErrBusinessDayOpen is returned when snapshotting a business day that has
+// not ended yet
+var ErrBusinessDayOpen = errors.New("business day has not ended yet")
+
+// snapshotRetryDelay is how long the scheduled job waits after a failure
+const snapshotRetryDelay = time.Minute
+
+// SnapshotService records each account's end-of-day balances. Runs are
+// idempotent per business date and resume where an interrupted run stopped.
+type SnapshotService struct {
+	store     repository.SnapshotStore
+	loc       *time.Location
+	delay     time.Duration
+	batchSize int
+	now       func() time.Time
+	logger    *utils.Logger
+}
+
+func NewSnapshotService(store repository.SnapshotStore, cfg config.SnapshotConfig) *SnapshotService {
+	loc, _ := cfg.Location() // validated by config.Load
+	return &SnapshotService{
+		store:     store,
+		loc:       loc,
+		delay:     cfg.Delay,
+		batchSize: cfg.BatchSize,
+		now:       time.Now,
+		logger:    utils.GlobalLogger,
+	}
+}
+
+// Period returns the business day of the calendar date of date
+func (s *SnapshotService) Period(date time.Time) model.SnapshotPeriod {
+	y, m, d := date.Date()
+	return model.NewSnapshotPeriod(time.Date(y, m, d, 12, 0, 0, 0, s.loc), s.loc)
+}
+
+// Run snapshots every account open at the end of the business day date and
+// records the run. A completed run is returned as is.
+func (s *SnapshotService) Run(ctx context.Context, date time.Time) (_ *model.SnapshotRun, retErr error) {
+	ctx, span := tracing.Start(ctx, "SnapshotService.Run")
+	defer func() { tracing.End(span, retErr) }()
+
+	period, err := s.endedPeriod(date)
+	if err != nil {
+		return nil, err
+	}
+	day := period.Date.Format(model.BusinessDateLayout)
+
+	run, err := s.store.GetRun(ctx, period.Date)
+	if err == nil {
+		return run, nil
+	}
+	if !errors.Is(err, repository.ErrSnapshotRunNotFound) {
+		return nil, fmt.Errorf("loading snapshot run: %w", err)
+	}
+
+	afterID, err := s.store.ResumePoint(ctx, period.Date)
+	if err != nil {
+		return nil, fmt.Errorf("finding resume point: %w", err)
+	}
+	if afterID > 0 {
+		s.logger.LogInfo(ctx, "BALANCE_SNAPSHOT", fmt.Sprintf("Resuming snapshot of %s after account %d", day, afterID))
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		lastID, n, err := s.store.SnapshotAccounts(ctx, period, afterID, s.batchSize)
+		if err != nil {
+			s.logger.LogError(ctx, "BALANCE_SNAPSHOT", "SNAPSHOT_ERROR", fmt.Sprintf("Snapshot of %s stopped after account %d", day, afterID), err)
+			return nil, err
+		}
+		if n < s.batchSize {
+			break
+		}
+		afterID = lastID
+	}
+
+	run, err = s.store.CompleteRun(ctx, period)
+	if err != nil {
+		return nil, fmt.Errorf("completing snapshot run: %w", err)
+	}
+	s.logger.LogInfo(ctx, "BALANCE_SNAPSHOT", fmt.Sprintf("Snapshotted %d account(s) for %s", run.Accounts, day))
+	return run, nil
+}
+
+// endedPeriod returns the business day of date, or ErrBusinessDayOpen if it
+// has not ended yet
+func (s *SnapshotService) endedPeriod(date time.Time) (model.SnapshotPeriod, error) {
+	period := s.Period(date)
+	if s.now().Before(period.End) {
+		return period, fmt.Errorf("%w: %s ends at %s", ErrBusinessDayOpen,
+			period.Date.Format(model.BusinessDateLayout), period.End.Format(time.RFC3339))
+	}
+	return period, nil
+}
+
+// Due returns the business days that Run for date, or CatchUp when date is
+// zero, would snapshot, without writing anything. Days already completed
+// are left out.
+func (s *SnapshotService) Due(ctx context.Context, date time.Time) (_ []model.SnapshotPeriod, retErr error) {
+	ctx, span := tracing.Start(ctx, "SnapshotService.Due")
+	defer func() { tracing.End(span, retErr) }()
+
+	if date.IsZero() {
+		return s.catchUpPeriods(ctx)
+	}
+	period, err := s.endedPeriod(date)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.store.GetRun(ctx, period.Date)
+	switch {
+	case err == nil:
+		return nil, nil
+	case !errors.Is(err, repository.ErrSnapshotRunNotFound):
+		return nil, fmt.Errorf("loading snapshot run: %w", err)
+	}
+	return []model.SnapshotPeriod{period}, nil
+}
+
+// CatchUp runs every business day that ended at least the configured delay
+// ago and follows the latest completed run, or only the latest such day if
+// nothing has run yet
+func (s *SnapshotService) CatchUp(ctx context.Context) (_ []*model.SnapshotRun, retErr error) {
+	ctx, span := tracing.Start(ctx, "SnapshotService.CatchUp")
+	defer func() { tracing.End(span, retErr) }()
+
+	periods, err := s.catchUpPeriods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var runs []*model.SnapshotRun
+	for _, p := range periods {
+		run, err := s.Run(ctx, p.Date)
+		if err != nil {
+			return runs, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// catchUpPeriods returns the business days CatchUp runs, oldest first
+func (s *SnapshotService) catchUpPeriods(ctx context.Context) ([]model.SnapshotPeriod, error) {
+	lastEnded := s.lastEnded()
+	next := lastEnded
+	latest, err := s.store.LatestRun(ctx)
+	switch {
+	case err == nil:
+		next = s.Period(latest.BusinessDate).Next()
+	case !errors.Is(err, repository.ErrSnapshotRunNotFound):
+		return nil, fmt.Errorf("loading latest snapshot run: %w", err)
+	}
+
+	var periods []model.SnapshotPeriod
+	for p := next; !p.Date.After(lastEnded.Date); p = p.Next() {
+		periods = append(periods, p)
+	}
+	return periods, nil
+}
+
+// lastEnded returns the latest business day that ended at least the
+// configured delay ago
+func (s *SnapshotService) lastEnded() model.SnapshotPeriod {
+	today := model.NewSnapshotPeriod(s.now().Add(-s.delay), s.loc)
+	return model.NewSnapshotPeriod(today.Start.Add(-time.Nanosecond), s.loc)
+}
+
+// Schedule is a lifecycle worker that catches up at startup and again once
+// each business day has ended, retrying failed runs after a minute
+func (s *SnapshotService) Schedule(ctx context.Context) error {
+	for {
+		wait := s.lastEnded().Next().End.Add(s.delay).Sub(s.now())
+		if _, err := s.CatchUp(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			s.logger.LogError(ctx, "BALANCE_SNAPSHOT", "SNAPSHOT_ERROR", "Scheduled snapshot failed; retrying", err)
+			wait = snapshotRetryDelay
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+)
+
+func TestSnapshotService_RunIsIdempotentAndCatchesUp(t *testing.T) {
+	ctx := context.Background()
+	_, svc, store := newMemoryServices(t, config.TransferConfig{})
+	cfg := config.Default().Snapshot
+	cfg.BatchSize = 1
+	snapshots := NewSnapshotService(store.Snapshots(), cfg)
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(25)))
+
+	today := time.Now().UTC()
+	_, err := snapshots.Run(ctx, today)
+	assert.ErrorIs(t, err, ErrBusinessDayOpen)
+
+	end := snapshots.Period(today).End
+	snapshots.now = func() time.Time { return end }
+	run, err := snapshots.Run(ctx, today)
+	require.NoError(t, err)
+	assert.Equal(t, 2, run.Accounts)
+	assert.Equal(t, end, run.PeriodEnd)
+
+	again, err := snapshots.Run(ctx, today)
+	require.NoError(t, err)
+	assert.Equal(t, run, again, "a completed run is not repeated")
+
+	// Catching up waits for the configured delay after each day ends
+	snapshots.now = func() time.Time { return end.AddDate(0, 0, 2) }
+	due, err := snapshots.Due(ctx, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, snapshots.Period(today).Next().Date, due[0].Date)
+	due, err = snapshots.Due(ctx, today)
+	require.NoError(t, err)
+	assert.Empty(t, due, "a completed day is not due")
+
+	runs, err := snapshots.CatchUp(ctx)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, snapshots.Period(today).Next().Date, runs[0].BusinessDate)
+
+	snapshots.now = func() time.Time { return end.AddDate(0, 0, 2).Add(cfg.Delay) }
+	runs, err = snapshots.CatchUp(ctx)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, snapshots.Period(today).Next().Next().Date, runs[0].BusinessDate)
+}
+
+func TestReconcile_StartsFromLatestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	_, svc, store := newMemoryServices(t, config.TransferConfig{})
+	ledger := NewLedgerService(store.Accounts(), store.Transactions(), store.Snapshots())
+	snapshots := NewSnapshotService(store.Snapshots(), config.Default().Snapshot)
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(25)))
+
+	today := time.Now().UTC()
+	end := snapshots.Period(today).End
+	snapshots.now = func() time.Time { return end }
+	_, err := snapshots.Run(ctx, today)
+	require.NoError(t, err)
+
+	report, err := ledger.Reconcile(ctx, model.ReconcileOptions{})
+	require.NoError(t, err)
+	assert.True(t, report.Balanced(), "%+v", report.Discrepancies)
+	assert.Equal(t, snapshots.Period(today).Date.Format(model.BusinessDateLayout), report.Snapshot)
+
+	err = store.UnitOfWork().Run(ctx, "tamper", func(ctx context.Context, tx repository.Tx) error {
+		accs, err := tx.LockAccounts(ctx, []int64{1})
+		if err != nil {
+			return err
+		}
+		return tx.UpdateLockedBalance(ctx, accs[1], decimal.NewFromInt(-3))
+	})
+	require.NoError(t, err)
+
+	for _, opts := range []model.ReconcileOptions{{}, {Full: true}} {
+		report, err := ledger.Reconcile(ctx, opts)
+		require.NoError(t, err)
+		require.Len(t, report.Discrepancies, 1, "%+v", opts)
+		assert.True(t, report.Discrepancies[0].Difference.Equal(decimal.NewFromInt(-3)))
+		assert.Equal(t, opts.Full, report.Snapshot == "")
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/tracing"
+	"github.com/hidimpu/transfersystem/internal/utils"
+)
+
+// statementPageSize is how many transactions are read per history page
+const statementPageSize = 500
+
+// StatementService builds account statements from the transaction history
+type StatementService struct {
+	accountRepo repository.AccountStore
+	txnRepo     repository.TransactionStore
+	snapshots   repository.SnapshotStore
+	currency    string
+	now         func() time.Time
+	logger      *utils.Logger
+}
+
+func NewStatementService(accRepo repository.AccountStore, txnRepo repository.TransactionStore, snapshots repository.SnapshotStore, currency string) *StatementService {
+	return &StatementService{
+		accountRepo: accRepo,
+		txnRepo:     txnRepo,
+		snapshots:   snapshots,
+		currency:    currency,
+		now:         time.Now,
+		logger:      utils.GlobalLogger,
+	}
+}
+
+// Statement returns the activity of an account from from (inclusive) to to
+// (exclusive). A to in the future is moved to now, so the closing balance
+// of a statement ending now is the account's current balance.
+//
+// The opening balance is the closing balance of the account's latest
+// balance snapshot ending by from, updated by the transactions between the
+// snapshot and from. Without a snapshot it is the account's opening balance.
+// The history up to to is read newest first; the first transaction before
+// from carries the opening balance, so nothing older is read.
+func (s *StatementService) Statement(ctx context.Context, accountID int64, from, to time.Time) (_ *model.Statement, retErr error) {
+	ctx, span := tracing.Start(ctx, "StatementService.Statement", tracing.AttrAccountID.Int64(accountID))
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
+	if accountID <= 0 {
+		return nil, model.ErrAccountIDRequired
+	}
+	now := s.now()
+	to = minTime(to, now)
+	if err := (model.TransactionFilter{AccountID: accountID, From: from, To: to}).Validate(); err != nil {
+		return nil, err
+	}
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, model.ErrAccountNotFound) {
+			return nil, model.WithDetails(model.ErrAccountNotFound, model.ErrorDetails{"account_id": accountID})
+		}
+		s.logger.LogError(ctx, "ACCOUNT_STATEMENT", "QUERY_ERROR", fmt.Sprintf("Failed to load account %d", accountID), err)
+		return nil, model.ErrFailedGetAccount
+	}
+	if !account.CreatedAt.IsZero() && !account.CreatedAt.Before(to) {
+		return nil, model.WithDetails(model.ErrAccountNotOpen, model.ErrorDetails{
+			"account_id": accountID,
+			"created_at": account.CreatedAt.UTC().Format(time.RFC3339Nano),
+		})
+	}
+
+	statement := &model.Statement{
+		AccountID:      accountID,
+		Currency:       s.currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: account.OpeningBalance,
+		TotalDebits:    decimal.Zero,
+		TotalCredits:   decimal.Zero,
+		Entries:        []model.StatementEntry{},
+		GeneratedAt:    now,
+	}
+	history := model.TransactionFilter{AccountID: accountID, To: to}
+	snapshot, err := s.snapshots.LatestSnapshot(ctx, accountID, from)
+	switch {
+	case err == nil:
+		statement.OpeningBalance = snapshot.ClosingBalance
+		history.From = snapshot.PeriodEnd
+	case !errors.Is(err, repository.ErrSnapshotNotFound):
+		s.logger.LogError(ctx, "ACCOUNT_STATEMENT", "QUERY_ERROR", fmt.Sprintf("Failed to read snapshots of account %d", accountID), err)
+		return nil, model.ErrFailedStatement
+	}
+
+	// Transfers committed while paging shift later pages; seen skips the
+	// transactions that are read twice as a result
+	seen := make(map[int64]bool)
+pages:
+	for offset := 0; ; offset += statementPageSize {
+		page, err := s.txnRepo.GetTransactionHistory(ctx, history, statementPageSize, offset)
+		if err != nil {
+			s.logger.LogError(ctx, "ACCOUNT_STATEMENT", "QUERY_ERROR", fmt.Sprintf("Failed to read history of account %d", accountID), err)
+			return nil, model.ErrFailedStatement
+		}
+		for _, txn := range page {
+			if seen[txn.ID] {
+				continue
+			}
+			seen[txn.ID] = true
+			if txn.CreatedAt.Before(from) {
+				statement.OpeningBalance = txn.BalanceAfter(accountID)
+				break pages
+			}
+			statement.Entries = append(statement.Entries, model.NewStatementEntry(txn, accountID))
+		}
+		if len(page) < statementPageSize {
+			break
+		}
+	}
+	slices.Reverse(statement.Entries)
+
+	statement.ClosingBalance = statement.OpeningBalance
+	for _, entry := range statement.Entries {
+		if entry.Debit() {
+			statement.TotalDebits = statement.TotalDebits.Sub(entry.Amount)
+		} else {
+			statement.TotalCredits = statement.TotalCredits.Add(entry.Amount)
+		}
+		statement.ClosingBalance = entry.Balance
+	}
+	s.logger.LogInfo(ctx, "ACCOUNT_STATEMENT", fmt.Sprintf("Statement of account %d with %d entries", accountID, len(statement.Entries)))
+	return statement, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
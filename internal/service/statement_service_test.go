@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+)
+
+// historyCounter is a TransactionStore that counts the transactions
+// history pages return
+type historyCounter struct {
+	repository.TransactionStore
+	read int
+}
+
+func (h *historyCounter) GetTransactionHistory(ctx context.Context, filter model.TransactionFilter, limit, offset int) ([]*model.Transaction, error) {
+	page, err := h.TransactionStore.GetTransactionHistory(ctx, filter, limit, offset)
+	h.read += len(page)
+	return page, err
+}
+
+func TestStatement(t *testing.T) {
+	ctx := context.Background()
+	accounts, svc, store := newMemoryServices(t, config.TransferConfig{})
+	statements := NewStatementService(store.Accounts(), store.Transactions(), store.Snapshots(), "EUR")
+	opened := time.Now()
+
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(100)))
+	from := time.Now()
+	require.NoError(t, svc.Transfer(ctx, 2, 1, decimal.NewFromFloat(30.12345)))
+	_, err := svc.Reverse(ctx, 2, false)
+	require.NoError(t, err)
+	to := time.Now()
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(1)))
+
+	st, err := statements.Statement(ctx, 1, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", st.Currency)
+	assert.Equal(t, "900", st.OpeningBalance.String(), "balance after the last transfer before from")
+	assert.Equal(t, "900", st.ClosingBalance.String())
+	assert.Equal(t, "30.12345", st.TotalDebits.String())
+	assert.Equal(t, "30.12345", st.TotalCredits.String())
+	require.Len(t, st.Entries, 2)
+	assert.Equal(t, model.StatementEntry{
+		TransactionID: 2, BookedAt: st.Entries[0].BookedAt, CounterpartyAccountID: 2,
+		Amount: decimal.NewFromFloat(30.12345), Balance: decimal.RequireFromString("930.12345"),
+	}, st.Entries[0])
+	assert.Equal(t, "-30.12345", st.Entries[1].Amount.String())
+	assert.Equal(t, int64(2), *st.Entries[1].ReversalOf)
+
+	// A statement ending now closes at the balance GET /accounts/{id} shows
+	st, err = statements.Statement(ctx, 2, opened, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	acc, err := accounts.GetAccountByID(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, acc.Balance.Equal(st.ClosingBalance), "%s != %s", acc.Balance, st.ClosingBalance)
+	assert.Equal(t, "1000", st.OpeningBalance.String())
+	assert.Len(t, st.Entries, 4)
+
+	tests := []struct {
+		name    string
+		id      int64
+		from    time.Time
+		to      time.Time
+		wantErr error
+	}{
+		{"unknown account", 9, from, to, model.ErrAccountNotFound},
+		{"invalid account", 0, from, to, model.ErrAccountIDRequired},
+		{"empty period", 1, to, from, model.ErrInvalidDateRange},
+		{"before the account opened", 1, opened.AddDate(-1, 0, 0), opened.AddDate(0, 0, -1), model.ErrAccountNotOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := statements.Statement(ctx, tt.id, tt.from, tt.to)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestStatement_PagesThroughHistory(t *testing.T) {
+	ctx := context.Background()
+	_, svc, store := newMemoryServices(t, config.TransferConfig{})
+	statements := NewStatementService(store.Accounts(), store.Transactions(), store.Snapshots(), "USD")
+	opened := time.Now()
+
+	n := statementPageSize + 20
+	for i := 0; i < n; i++ {
+		require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(1)))
+	}
+	st, err := statements.Statement(ctx, 1, opened, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	require.Len(t, st.Entries, n)
+	for i, e := range st.Entries {
+		require.Equal(t, int64(i+1), e.TransactionID, "entries are oldest first")
+	}
+	assert.Equal(t, "1000", st.OpeningBalance.String())
+	assert.Equal(t, decimal.NewFromInt(int64(1000-n)).String(), st.ClosingBalance.String())
+}
+
+func TestStatement_ReadsOnlyThePeriod(t *testing.T) {
+	ctx := context.Background()
+	_, svc, store := newMemoryServices(t, config.TransferConfig{})
+	history := &historyCounter{TransactionStore: store.Transactions()}
+	statements := NewStatementService(store.Accounts(), history, store.Snapshots(), "USD")
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(10)))
+	}
+	period := model.SnapshotPeriod{Date: time.Now().UTC(), End: time.Now()}
+	_, _, err := store.Snapshots().SnapshotAccounts(ctx, period, 0, 10)
+	require.NoError(t, err)
+	from := time.Now()
+	require.NoError(t, svc.Transfer(ctx, 2, 1, decimal.NewFromInt(5)))
+	to := time.Now()
+	for i := 0; i < 2*statementPageSize; i++ {
+		require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.RequireFromString("0.01")))
+	}
+
+	st, err := statements.Statement(ctx, 1, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, "970", st.OpeningBalance.String(), "closing balance of the snapshot")
+	assert.Equal(t, "975", st.ClosingBalance.String())
+	require.Len(t, st.Entries, 1)
+	assert.Equal(t, 1, history.read, "neither the transactions after to nor those before the snapshot are read")
+}
+
+// TestStatement_NonUTCZone_Postgres checks the period edges against the
+// instants transactions were created at when the process does not run in
+// UTC, since transactions.created_at holds local wall-clock times
+func TestStatement_NonUTCZone_Postgres(t *testing.T) {
+	setLocalZone(t, time.FixedZone("UTC+3", 3*60*60))
+	f := newPostgresFixture(t, 100, 0)
+	ctx := context.Background()
+	statements := NewStatementService(f.accounts, f.txns, repository.NewSnapshotRepository(f.conn), "EUR")
+	a, b := f.ids[0], f.ids[1]
+
+	require.NoError(t, f.transfer.Transfer(ctx, a, b, decimal.NewFromInt(10)))
+	from := time.Now()
+	require.NoError(t, f.transfer.Transfer(ctx, a, b, decimal.NewFromInt(20)))
+	to := time.Now()
+	require.NoError(t, f.transfer.Transfer(ctx, a, b, decimal.NewFromInt(5)))
+
+	st, err := statements.Statement(ctx, a, from.UTC(), to.UTC())
+	require.NoError(t, err)
+	assert.Equal(t, "90", st.OpeningBalance.String(), "the transfer before from supplies the opening balance")
+	assert.Equal(t, "70", st.ClosingBalance.String())
+	require.Len(t, st.Entries, 1)
+	assert.Equal(t, "-20", st.Entries[0].Amount.String())
+	assert.True(t, !st.Entries[0].BookedAt.Before(from) && st.Entries[0].BookedAt.Before(to),
+		"booked at %s, want within [%s, %s)", st.Entries[0].BookedAt, from, to)
+}
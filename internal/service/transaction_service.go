@@ -2,163 +2,330 @@ package service
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/metrics"
 	"github.com/hidimpu/transfersystem/internal/model"
 	"github.com/hidimpu/transfersystem/internal/repository"
+	"github.com/hidimpu/transfersystem/internal/tracing"
 	"github.com/hidimpu/transfersystem/internal/utils"
 
 	"github.com/shopspring/decimal"
 )
 
 type TransactionService struct {
-	db          *sql.DB
-	accountRepo *repository.AccountRepository
-	txnRepo     *repository.TransactionRepository
+	uow         repository.UnitOfWork
+	accountRepo repository.AccountStore
+	txnRepo     repository.TransactionStore
 	logger      *utils.Logger
+	maxAmount   decimal.Decimal
 }
 
-func NewTransactionService(db *sql.DB, accRepo *repository.AccountRepository, txnRepo *repository.TransactionRepository) *TransactionService {
+// NewTransactionService creates the transfer service. cfg is expected to have
+// been validated by config.Load; an invalid limit is treated as no limit.
+// Isolation is a property of the unit of work, not of the service.
+func NewTransactionService(uow repository.UnitOfWork, accRepo repository.AccountStore, txnRepo repository.TransactionStore, cfg config.TransferConfig) *TransactionService {
+	maxAmount, _ := cfg.Limit()
+
 	return &TransactionService{
-		db:          db,
+		uow:         uow,
 		accountRepo: accRepo,
 		txnRepo:     txnRepo,
 		logger:      utils.GlobalLogger,
+		maxAmount:   maxAmount,
 	}
 }
 
-// Transfer handles concurrency and atomicity via DB transactions and row locks.
-func (s *TransactionService) Transfer(ctx context.Context, srcID, dstID int64, amount decimal.Decimal) (retErr error) {
+// Transfer handles concurrency and atomicity via a unit of work that locks
+// both accounts before moving funds.
+func (s *TransactionService) Transfer(ctx context.Context, srcID, dstID int64, amount decimal.Decimal) error {
+	_, err := s.TransferWithDetails(ctx, srcID, dstID, amount, model.TransferDetails{})
+	return err
+}
+
+// TransferWithDetails is Transfer with a reference, description and
+// metadata recorded on the transaction, which it returns
+func (s *TransactionService) TransferWithDetails(ctx context.Context, srcID, dstID int64, amount decimal.Decimal, details model.TransferDetails) (*model.Transaction, error) {
+	return s.transfer(ctx, srcID, dstID, amount, details, nil)
+}
+
+// transfer is TransferWithDetails. When record is set it runs in the
+// transfer's unit of work once the transaction is recorded, so what it
+// records commits with the transfer or not at all.
+func (s *TransactionService) transfer(ctx context.Context, srcID, dstID int64, amount decimal.Decimal, details model.TransferDetails,
+	record func(ctx context.Context, tx repository.Tx, txn *model.Transaction) error) (_ *model.Transaction, retErr error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.Transfer",
+		tracing.AttrSourceAccountID.Int64(srcID),
+		tracing.AttrDestinationAccountID.Int64(dstID),
+		tracing.AttrAmount.String(amount.String()),
+	)
+	defer func() {
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
+	}()
+
 	// Log transfer attempt
-	s.logger.LogTransfer("ATTEMPT", srcID, dstID, amount.String(), false)
+	s.logger.LogTransfer(ctx, "ATTEMPT", srcID, dstID, amount.String(), false)
+	defer func() { metrics.ObserveTransfer(retErr) }()
 
 	// Business logic validation
+	if err := details.Validate(); err != nil {
+		s.logger.LogWarning(ctx, "TRANSFER_VALIDATION", fmt.Sprintf("Invalid %v: %v", model.DetailsOf(err)["field"], model.DetailsOf(err)["reason"]))
+		return nil, err
+	}
 	if err := s.validateTransferRequest(ctx, srcID, dstID, amount); err != nil {
-		s.logger.LogError("TRANSFER_VALIDATION", "VALIDATION_ERROR", err.Error(), err)
-		return err
+		s.logger.LogError(ctx, "TRANSFER_VALIDATION", "VALIDATION_ERROR", err.Error(), err)
+		return nil, err
 	}
 
-	// Start database transaction with proper isolation
-	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelSerializable, // Highest isolation level for financial transactions
-		ReadOnly:  false,
+	// Run the debit, credit and ledger insert as one unit of work. The unit of
+	// work may re-run it from scratch on serialization failures and deadlocks,
+	// so each attempt records a fresh transaction.
+	var txn *model.Transaction
+	err := s.uow.Run(ctx, "transfer", func(ctx context.Context, tx repository.Tx) error {
+		txn = &model.Transaction{
+			SourceAccountID:      srcID,
+			DestinationAccountID: dstID,
+			Amount:               amount,
+			TransferDetails:      details,
+		}
+		if err := s.move(ctx, tx, txn, true); err != nil {
+			return err
+		}
+		if record != nil {
+			return record(ctx, tx, txn)
+		}
+		return nil
 	})
 	if err != nil {
-		s.logger.LogError("TRANSFER_DB", "DB_CONNECTION_ERROR", "Failed to begin transaction", err)
-		return model.ErrServiceUnavailable
+		return nil, transferError(err)
 	}
+
+	// Log successful transfer
+	s.logger.LogTransfer(ctx, "SUCCESS", srcID, dstID, amount.String(), true)
+	return txn, nil
+}
+
+// errDryRun rolls back a unit of work that was only run to check that it
+// would succeed
+var errDryRun = errors.New("dry run")
+
+// Reverse moves the amount of a recorded transaction back from its
+// destination to its source and records the reversal, linked to the
+// original. A transaction can be reversed once. Reversals are allowed on
+// frozen accounts, since freezing is often the step before reversing, but
+// never overdraw the destination. With dryRun the reversal is checked inside
+// a unit of work that is then rolled back; the returned transaction has no ID.
+func (s *TransactionService) Reverse(ctx context.Context, transactionID int64, dryRun bool) (_ *model.Transaction, retErr error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.Reverse", tracing.AttrTransactionID.Int64(transactionID))
 	defer func() {
-		if retErr != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				s.logger.LogError("TRANSFER_DB", "TRANSACTION_ROLLBACK_ERROR", "Rollback failed", rbErr)
-			}
-			s.logger.LogError("TRANSFER_DB", "TRANSACTION_ROLLBACK", "Transaction rolled back", retErr)
-		} else {
-			if cmErr := tx.Commit(); cmErr != nil {
-				// Commit errors are serious; surface as service-unavailable
-				s.logger.LogError("TRANSFER_DB", "TRANSACTION_COMMIT_ERROR", "Commit failed", cmErr)
-				retErr = model.ErrServiceUnavailable
-			}
-			if retErr == nil {
-				s.logger.LogInfo("TRANSFER_DB", "Transaction committed successfully")
-			}
-		}
+		span.SetAttributes(tracing.AttrOutcome.String(outcome(retErr)))
+		tracing.End(span, retErr)
 	}()
 
-	// Debit source account with row-level locking
-	if err = s.accountRepo.UpdateBalanceTx(ctx, srcID, amount.Neg(), tx); err != nil {
-		if strings.Contains(err.Error(), "insufficient funds") {
-			s.logger.LogError("TRANSFER_DEBIT", "INSUFFICIENT_FUNDS", fmt.Sprintf("Account %d has insufficient funds", srcID), err)
-			return model.ErrInsufficientFunds
+	original, err := s.txnRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTransactionNotFound) {
+			return nil, model.WithDetails(model.ErrTransactionNotFound, model.ErrorDetails{"transaction_id": transactionID})
+		}
+		s.logger.LogError(ctx, "TRANSFER_REVERSE", "DB_ERROR", fmt.Sprintf("Failed to load transaction %d", transactionID), err)
+		return nil, model.ErrServiceUnavailable
+	}
+
+	reversal := &model.Transaction{
+		SourceAccountID:      original.DestinationAccountID,
+		DestinationAccountID: original.SourceAccountID,
+		Amount:               original.Amount,
+		ReversalOf:           &original.ID,
+	}
+	err = s.uow.Run(ctx, "reversal", func(ctx context.Context, tx repository.Tx) error {
+		if err := s.move(ctx, tx, reversal, false); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
 		}
-		s.logger.LogError("TRANSFER_DEBIT", "DEBIT_ERROR", fmt.Sprintf("Failed to debit account %d", srcID), err)
-		return model.ErrFailedDebit
+		return nil
+	})
+	if dryRun && errors.Is(err, errDryRun) {
+		reversal.ID, reversal.CreatedAt = 0, time.Time{}
+		return reversal, nil
+	}
+	if err != nil {
+		if errors.Is(err, model.ErrAlreadyReversed) {
+			return nil, model.WithDetails(model.ErrAlreadyReversed, model.ErrorDetails{"transaction_id": transactionID})
+		}
+		return nil, transferError(err)
+	}
+
+	s.logger.LogInfo(ctx, "TRANSFER_REVERSE", fmt.Sprintf("Reversed transaction %d as %d", transactionID, reversal.ID))
+	return reversal, nil
+}
+
+// move locks both accounts, moves txn.Amount from source to destination and
+// records txn, all within tx. Frozen accounts are rejected when
+// enforceFreeze is set.
+func (s *TransactionService) move(ctx context.Context, tx repository.Tx, txn *model.Transaction, enforceFreeze bool) error {
+	srcID, dstID := txn.SourceAccountID, txn.DestinationAccountID
+
+	// Lock both accounts up front in ascending ID order so that concurrent
+	// opposite-direction transfers cannot deadlock
+	accounts, err := tx.LockAccounts(ctx, []int64{srcID, dstID})
+	if err != nil {
+		s.logger.LogError(ctx, "TRANSFER_LOCK", "LOCK_ERROR", fmt.Sprintf("Failed to lock accounts %d and %d", srcID, dstID), err)
+		return fmt.Errorf("%w: %w", model.ErrFailedDebit, err)
+	}
+	src, ok := accounts[srcID]
+	if !ok {
+		return model.ErrSourceAccountNotFound
+	}
+	dst, ok := accounts[dstID]
+	if !ok {
+		return model.ErrDestAccountNotFound
 	}
 
-	// Credit destination account with row-level locking
-	if err = s.accountRepo.UpdateBalanceTx(ctx, dstID, amount, tx); err != nil {
-		s.logger.LogError("TRANSFER_CREDIT", "CREDIT_ERROR", fmt.Sprintf("Failed to credit account %d", dstID), err)
-		return model.ErrFailedCredit
+	// Checked under the row locks so a concurrent freeze cannot be missed
+	if enforceFreeze {
+		for _, acc := range []*model.Account{src, dst} {
+			if acc.Status == model.AccountFrozen {
+				s.logger.LogWarning(ctx, "TRANSFER_VALIDATION", fmt.Sprintf("Account %d is frozen", acc.ID))
+				return model.WithDetails(model.ErrAccountFrozen, model.ErrorDetails{"account_id": acc.ID})
+			}
+		}
 	}
 
-	// Record the transaction
-	txn := &model.Transaction{
-		SourceAccountID:      srcID,
-		DestinationAccountID: dstID,
-		Amount:               amount,
+	// Debit source account (row already locked)
+	if err := tx.UpdateLockedBalance(ctx, src, txn.Amount.Neg()); err != nil {
+		if errors.Is(err, model.ErrInsufficientFunds) {
+			s.logger.LogError(ctx, "TRANSFER_DEBIT", "INSUFFICIENT_FUNDS", fmt.Sprintf("Account %d has insufficient funds", srcID), err)
+			return model.WithDetails(model.ErrInsufficientFunds, model.ErrorDetails{
+				"account_id":        srcID,
+				"available_balance": src.Balance.String(),
+				"requested_amount":  txn.Amount.String(),
+			})
+		}
+		s.logger.LogError(ctx, "TRANSFER_DEBIT", "DEBIT_ERROR", fmt.Sprintf("Failed to debit account %d", srcID), err)
+		return fmt.Errorf("%w: %w", model.ErrFailedDebit, err)
 	}
-	if err = s.txnRepo.CreateTransaction(ctx, txn, tx); err != nil {
-		s.logger.LogError("TRANSFER_RECORD", "RECORD_ERROR", "Failed to record transaction", err)
-		return model.ErrFailedRecordTxn
+
+	// Credit destination account (row already locked)
+	if err := tx.UpdateLockedBalance(ctx, dst, txn.Amount); err != nil {
+		s.logger.LogError(ctx, "TRANSFER_CREDIT", "CREDIT_ERROR", fmt.Sprintf("Failed to credit account %d", dstID), err)
+		return fmt.Errorf("%w: %w", model.ErrFailedCredit, err)
 	}
 
-	// Log successful transfer
-	s.logger.LogTransfer("SUCCESS", srcID, dstID, amount.String(), true)
+	// Record the transaction with the running balances it left behind
+	txn.SourceBalanceAfter, txn.DestinationBalanceAfter = src.Balance, dst.Balance
+	if err := tx.CreateTransaction(ctx, txn); err != nil {
+		if errors.Is(err, model.ErrAlreadyReversed) {
+			return err
+		}
+		s.logger.LogError(ctx, "TRANSFER_RECORD", "RECORD_ERROR", "Failed to record transaction", err)
+		return fmt.Errorf("%w: %w", model.ErrFailedRecordTxn, err)
+	}
 	return nil
 }
 
+// outcome returns the span/metric outcome label for a service result
+func outcome(err error) string {
+	return metrics.TransferOutcome(err)
+}
+
+// transferError maps an error returned by the transfer unit of work to the
+// TransferError reported to callers. A conflict that survived retries yields
+// the retryable ErrTransferConflict; errors without a typed cause (BEGIN or
+// COMMIT failures) surface as service-unavailable. Details attached to the
+// cause are kept.
+func transferError(err error) error {
+	if errors.Is(err, repository.ErrConflict) {
+		return model.ErrTransferConflict
+	}
+	var transferErr model.TransferError
+	if errors.As(err, &transferErr) {
+		if details := model.DetailsOf(err); details != nil {
+			return model.WithDetails(transferErr, details)
+		}
+		return transferErr
+	}
+	return model.ErrServiceUnavailable
+}
+
 // validateTransferRequest validates the transfer request before processing
 func (s *TransactionService) validateTransferRequest(ctx context.Context, srcID, dstID int64, amount decimal.Decimal) error {
 	// Check same account transfer FIRST (no database dependency)
 	if srcID == dstID {
-		s.logger.LogWarning("TRANSFER_VALIDATION", fmt.Sprintf("Same account transfer attempted: %d -> %d", srcID, dstID))
+		s.logger.LogWarning(ctx, "TRANSFER_VALIDATION", fmt.Sprintf("Same account transfer attempted: %d -> %d", srcID, dstID))
 		return model.ErrSameAccountTransfer
 	}
 
 	// Check amount validation (no database dependency)
 	if amount.LessThanOrEqual(decimal.Zero) {
-		s.logger.LogWarning("TRANSFER_VALIDATION", fmt.Sprintf("Invalid amount: %s", amount.String()))
+		s.logger.LogWarning(ctx, "TRANSFER_VALIDATION", fmt.Sprintf("Invalid amount: %s", amount.String()))
 		return model.ErrNegativeAmount
 	}
 
+	// Check configured per-transfer limit (no database dependency)
+	if s.maxAmount.IsPositive() && amount.GreaterThan(s.maxAmount) {
+		s.logger.LogWarning(ctx, "TRANSFER_VALIDATION", fmt.Sprintf("Amount %s exceeds limit %s", amount.String(), s.maxAmount.String()))
+		return model.WithDetails(model.ErrAmountExceedsLimit, model.ErrorDetails{"field": "amount", "limit": s.maxAmount.String()})
+	}
+
 	// Check account ID validation (no database dependency)
 	if srcID <= 0 || dstID <= 0 {
-		s.logger.LogWarning("TRANSFER_VALIDATION", fmt.Sprintf("Invalid account IDs: src=%d, dst=%d", srcID, dstID))
+		s.logger.LogWarning(ctx, "TRANSFER_VALIDATION", fmt.Sprintf("Invalid account IDs: src=%d, dst=%d", srcID, dstID))
 		return model.ErrInvalidAccountIDs
 	}
 
 	// Check if both accounts exist - handle database errors gracefully
 	srcExists, err := s.accountRepo.Exists(ctx, srcID)
 	if err != nil {
-		s.logger.LogError("TRANSFER_VALIDATION", "DB_ERROR", fmt.Sprintf("Failed to validate source account %d", srcID), err)
+		s.logger.LogError(ctx, "TRANSFER_VALIDATION", "DB_ERROR", fmt.Sprintf("Failed to validate source account %d", srcID), err)
 		return model.ErrSourceAccountNotFound
 	}
 	if !srcExists {
-		s.logger.LogWarning("TRANSFER_VALIDATION", fmt.Sprintf("Source account not found: %d", srcID))
-		return model.ErrSourceAccountNotFound
+		s.logger.LogWarning(ctx, "TRANSFER_VALIDATION", fmt.Sprintf("Source account not found: %d", srcID))
+		return model.WithDetails(model.ErrSourceAccountNotFound, model.ErrorDetails{"account_id": srcID})
 	}
 
 	dstExists, err := s.accountRepo.Exists(ctx, dstID)
 	if err != nil {
-		s.logger.LogError("TRANSFER_VALIDATION", "DB_ERROR", fmt.Sprintf("Failed to validate destination account %d", dstID), err)
+		s.logger.LogError(ctx, "TRANSFER_VALIDATION", "DB_ERROR", fmt.Sprintf("Failed to validate destination account %d", dstID), err)
 		return model.ErrDestAccountNotFound
 	}
 	if !dstExists {
-		s.logger.LogWarning("TRANSFER_VALIDATION", fmt.Sprintf("Destination account not found: %d", dstID))
-		return model.ErrDestAccountNotFound
+		s.logger.LogWarning(ctx, "TRANSFER_VALIDATION", fmt.Sprintf("Destination account not found: %d", dstID))
+		return model.WithDetails(model.ErrDestAccountNotFound, model.ErrorDetails{"account_id": dstID})
 	}
 
 	return nil
 }
 
-// GetTransactionHistory retrieves transaction history for an account
-func (s *TransactionService) GetTransactionHistory(ctx context.Context, accountID int64, limit, offset int) ([]*model.Transaction, error) {
+// GetTransactionHistory retrieves a page of an account's transactions,
+// newest first. filter.AccountID is required; its other fields narrow the
+// history further.
+func (s *TransactionService) GetTransactionHistory(ctx context.Context, filter model.TransactionFilter, limit, offset int) ([]*model.Transaction, error) {
+	accountID := filter.AccountID
 	if accountID <= 0 {
-		return nil, fmt.Errorf("invalid account ID")
+		return nil, model.ErrAccountIDRequired
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
 	}
 
 	// Check if account exists
 	exists, err := s.accountRepo.Exists(ctx, accountID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to validate account: %w", err)
+		s.logger.LogError(ctx, "TRANSACTION_HISTORY", "DB_ERROR", fmt.Sprintf("Failed to validate account %d", accountID), err)
+		return nil, model.ErrFailedGetAccount
 	}
 	if !exists {
-		return nil, fmt.Errorf("account not found")
+		return nil, model.ErrAccountNotFound
 	}
 
-	return s.txnRepo.GetTransactionHistory(ctx, accountID, limit, offset)
+	return s.txnRepo.GetTransactionHistory(ctx, filter, limit, offset)
 }
 
 // GetTransactionByID retrieves a specific transaction
@@ -170,7 +337,48 @@ func (s *TransactionService) GetTransactionByID(ctx context.Context, transaction
 	return s.txnRepo.GetByID(ctx, transactionID)
 }
 
-// GetAllTransactions retrieves all transactions (admin function)
-func (s *TransactionService) GetAllTransactions(ctx context.Context) ([]*model.Transaction, error) {
-	return s.txnRepo.GetAll(ctx)
+// SearchTransactions returns a page of the transactions of every account
+// matching search. An empty Sort means newest first and a zero Limit
+// DefaultSearchLimit. Accounts named in the search must exist.
+func (s *TransactionService) SearchTransactions(ctx context.Context, search model.TransactionSearch) (*model.TransactionPage, error) {
+	if search.Sort == "" {
+		search.Sort = model.SortNewestFirst
+	}
+	if search.Limit == 0 {
+		search.Limit = model.DefaultSearchLimit
+	}
+	if err := search.Validate(); err != nil {
+		return nil, err
+	}
+	for _, id := range []int64{search.AccountID, search.CounterpartyID} {
+		if id == 0 {
+			continue
+		}
+		exists, err := s.accountRepo.Exists(ctx, id)
+		if err != nil {
+			s.logger.LogError(ctx, "TRANSACTION_SEARCH", "DB_ERROR", fmt.Sprintf("Failed to validate account %d", id), err)
+			return nil, model.ErrFailedGetAccount
+		}
+		if !exists {
+			return nil, model.WithDetails(model.ErrAccountNotFound, model.ErrorDetails{"account_id": id})
+		}
+	}
+
+	// One row more than the page tells whether there is a next one
+	query := search
+	query.Limit++
+	hits, err := s.txnRepo.SearchTransactions(ctx, query)
+	if err != nil {
+		s.logger.LogError(ctx, "TRANSACTION_SEARCH", "DB_ERROR", "Failed to search transactions", err)
+		return nil, err
+	}
+	page := &model.TransactionPage{Transactions: hits}
+	if len(hits) > search.Limit {
+		page.Transactions = hits[:search.Limit]
+		page.NextCursor = model.CursorAfter(search.Sort, hits[search.Limit-1].Transaction).String()
+	}
+	if page.Transactions == nil {
+		page.Transactions = []*model.TransactionHit{}
+	}
+	return page, nil
 }
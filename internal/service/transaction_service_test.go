@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/db"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+)
+
+func TestTransferError(t *testing.T) {
+	serializationFailure := &pq.Error{Code: "40001"}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name:     "business error passes through",
+			err:      model.ErrInsufficientFunds,
+			expected: model.ErrInsufficientFunds,
+		},
+		{
+			name:     "wrapped step error keeps its type",
+			err:      fmt.Errorf("%w: %w", model.ErrFailedCredit, errors.New("connection reset")),
+			expected: model.ErrFailedCredit,
+		},
+		{
+			name:     "details on the cause are kept",
+			err:      fmt.Errorf("debit: %w", model.WithDetails(model.ErrInsufficientFunds, model.ErrorDetails{"available_balance": "5"})),
+			expected: model.WithDetails(model.ErrInsufficientFunds, model.ErrorDetails{"available_balance": "5"}),
+		},
+		{
+			name:     "conflict after retries is retryable",
+			err:      fmt.Errorf("%w: %w", repository.ErrConflict, fmt.Errorf("%w after 5 attempts: %w", db.ErrRetryBudgetExhausted, fmt.Errorf("%w: %w", model.ErrFailedDebit, serializationFailure))),
+			expected: model.ErrTransferConflict,
+		},
+		{
+			name:     "untyped commit failure",
+			err:      errors.New("commit failed"),
+			expected: model.ErrServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, transferError(tt.err))
+		})
+	}
+
+	assert.Equal(t, 503, model.ErrTransferConflict.HTTPStatus())
+	assert.True(t, model.ErrTransferConflict.Retryable())
+	assert.False(t, model.ErrInsufficientFunds.Retryable())
+}
+
+func TestTransferWithDetails(t *testing.T) {
+	ctx := context.Background()
+	_, svc, _ := newMemoryServices(t, config.TransferConfig{})
+
+	details := model.TransferDetails{Reference: "INV-7", Description: "Invoice 7", Metadata: model.Metadata{"order": "A-17"}}
+	txn, err := svc.TransferWithDetails(ctx, 1, 2, decimal.NewFromInt(10), details)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), txn.ID)
+	assert.Equal(t, details, txn.TransferDetails)
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromInt(5)))
+
+	tests := []struct {
+		name    string
+		filter  model.TransactionFilter
+		wantIDs []int64
+	}{
+		{"account only", model.TransactionFilter{AccountID: 2}, []int64{2, 1}},
+		{"reference", model.TransactionFilter{AccountID: 2, Reference: "INV-7"}, []int64{1}},
+		{"metadata", model.TransactionFilter{AccountID: 1, Metadata: model.Metadata{"order": "A-17"}}, []int64{1}},
+		{"other metadata value", model.TransactionFilter{AccountID: 1, Metadata: model.Metadata{"order": "A-18"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history, err := svc.GetTransactionHistory(ctx, tt.filter, 10, 0)
+			require.NoError(t, err)
+			var ids []int64
+			for _, txn := range history {
+				ids = append(ids, txn.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+
+	// Invalid details are rejected before anything is booked
+	_, err = svc.TransferWithDetails(ctx, 1, 2, decimal.NewFromInt(1), model.TransferDetails{Reference: strings.Repeat("R", 36)})
+	assert.ErrorIs(t, err, model.ErrInvalidReference)
+	_, err = svc.TransferWithDetails(ctx, 1, 2, decimal.NewFromInt(1), model.TransferDetails{Metadata: model.Metadata{"": "x"}})
+	assert.ErrorIs(t, err, model.ErrInvalidMetadata)
+	all, err := svc.SearchTransactions(ctx, model.TransactionSearch{})
+	require.NoError(t, err)
+	assert.Len(t, all.Transactions, 2)
+}
+
+func TestSearchTransactions(t *testing.T) {
+	ctx := context.Background()
+	accounts, svc, _ := newMemoryServices(t, config.TransferConfig{})
+	require.NoError(t, accounts.CreateAccount(ctx, &model.Account{ID: 3, Balance: decimal.Zero}))
+
+	transfer := func(src, dst int64, amount string, details model.TransferDetails) {
+		_, err := svc.TransferWithDetails(ctx, src, dst, decimal.RequireFromString(amount), details)
+		require.NoError(t, err)
+	}
+	transfer(1, 2, "100", model.TransferDetails{Reference: "INV-1", Description: "Invoice March"}) // 1
+	transfer(2, 1, "5", model.TransferDetails{Description: "coffee"})                              // 2
+	transfer(1, 3, "50", model.TransferDetails{Reference: "INV-2"})                                // 3
+	_, err := svc.Reverse(ctx, 1, false)                                                           // 4
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		search  model.TransactionSearch
+		wantIDs []int64
+	}{
+		{"everything, newest first", model.TransactionSearch{}, []int64{4, 3, 2, 1}},
+		{"counterparty", model.TransactionSearch{CounterpartyID: 3}, []int64{3}},
+		{"between two accounts", model.TransactionSearch{AccountID: 1, CounterpartyID: 2}, []int64{4, 2, 1}},
+		{"minimum amount", model.TransactionSearch{MinAmount: decimal.NewNullDecimal(decimal.NewFromInt(50))}, []int64{4, 3, 1}},
+		{"maximum amount", model.TransactionSearch{MaxAmount: decimal.NewNullDecimal(decimal.NewFromInt(5))}, []int64{2}},
+		{"text ignores case", model.TransactionSearch{Text: "INVOICE"}, []int64{1}},
+		{"text in reference", model.TransactionSearch{Text: "inv-"}, []int64{3, 1}},
+		{"exact reference", model.TransactionSearch{Reference: "INV-2"}, []int64{3}},
+		{"reversals", model.TransactionSearch{Type: model.TransactionReversal}, []int64{4}},
+		{"reversed", model.TransactionSearch{Status: model.TransactionReversed}, []int64{1}},
+		{"booked transfers", model.TransactionSearch{Type: model.TransactionTransfer, Status: model.TransactionBooked}, []int64{3, 2}},
+		{"smallest first", model.TransactionSearch{Sort: model.SortSmallestFirst}, []int64{2, 3, 1, 4}},
+		{"nothing", model.TransactionSearch{Text: "rent"}, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := svc.SearchTransactions(ctx, tt.search)
+			require.NoError(t, err)
+			ids := []int64{}
+			for _, hit := range page.Transactions {
+				ids = append(ids, hit.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+
+	page, err := svc.SearchTransactions(ctx, model.TransactionSearch{Status: model.TransactionReversed})
+	require.NoError(t, err)
+	require.Len(t, page.Transactions, 1)
+	assert.Equal(t, int64(4), *page.Transactions[0].ReversedBy)
+	assert.Equal(t, model.TransactionTransfer, page.Transactions[0].Type)
+
+	// Pages follow each other without gaps or repeats
+	var ids []int64
+	search := model.TransactionSearch{Sort: model.SortLargestFirst, Limit: 3}
+	for pages := 1; ; pages++ {
+		require.LessOrEqual(t, pages, 2)
+		page, err := svc.SearchTransactions(ctx, search)
+		require.NoError(t, err)
+		for _, hit := range page.Transactions {
+			ids = append(ids, hit.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		search.After, err = model.ParseSearchCursor(page.NextCursor)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int64{4, 1, 3, 2}, ids)
+
+	_, err = svc.SearchTransactions(ctx, model.TransactionSearch{AccountID: 9})
+	assert.ErrorIs(t, err, model.ErrAccountNotFound)
+	_, err = svc.SearchTransactions(ctx, model.TransactionSearch{Sort: model.SortOldestFirst, After: search.After})
+	assert.ErrorIs(t, err, model.ErrInvalidCursor)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/db"
+	"github.com/hidimpu/transfersystem/internal/metrics"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository"
+)
+
+// TestTransfer_OppositeDirectionsDoNotDeadlock hammers two accounts with
+// concurrent A->B and B->A transfers against a real Postgres. Set TEST_DB_URL
+// to a disposable database to run it.
+func TestTransfer_OppositeDirectionsDoNotDeadlock(t *testing.T) {
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL not set; skipping Postgres concurrency test")
+	}
+
+	ctx := context.Background()
+	conn, err := db.InitDB(config.DatabaseConfig{
+		URL:            dbURL,
+		MaxOpenConns:   32,
+		MaxIdleConns:   32,
+		ConnectTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	migrator, err := db.NewMigrator(conn)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	accountRepo := repository.NewAccountRepository(conn)
+	txnRepo := repository.NewTransactionRepository(conn)
+	runner := db.NewTxRunner(conn, db.RetryPolicy{MaxAttempts: 50, BaseDelay: time.Millisecond, MaxDelay: 20 * time.Millisecond})
+	uow := repository.NewPostgresUnitOfWork(runner, accountRepo, txnRepo, repository.NewReconciliationRepository(conn),
+		repository.NewPaymentFileRepository(conn), sql.LevelSerializable)
+	svc := NewTransactionService(uow, accountRepo, txnRepo, config.TransferConfig{})
+
+	// Unique IDs so reruns against the same database do not collide
+	base := time.Now().UnixNano() % 1_000_000_000_000
+	a, b := base*10+1, base*10+2
+	initial := decimal.NewFromInt(1000)
+	require.NoError(t, accountRepo.Create(ctx, &model.Account{ID: a, Balance: initial}))
+	require.NoError(t, accountRepo.Create(ctx, &model.Account{ID: b, Balance: initial}))
+	t.Cleanup(func() {
+		ids := pq.Array([]int64{a, b})
+		conn.Exec(`DELETE FROM transactions WHERE source_account_id = ANY($1) OR destination_account_id = ANY($1)`, ids)
+		conn.Exec(`DELETE FROM accounts WHERE account_id IN ($1, $2)`, a, b)
+	})
+
+	deadlocksBefore := testutil.ToFloat64(metrics.DBSerializationFailures.WithLabelValues("transfer", "40P01"))
+
+	const perDirection = 50
+	amount := decimal.NewFromInt(1)
+	errs := make(chan error, 2*perDirection)
+	var wg sync.WaitGroup
+	for i := 0; i < perDirection; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- svc.Transfer(ctx, a, b, amount)
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- svc.Transfer(ctx, b, a, amount)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, deadlocksBefore, testutil.ToFloat64(metrics.DBSerializationFailures.WithLabelValues("transfer", "40P01")),
+		"ordered locking must not produce deadlocks")
+
+	balanceA, err := accountRepo.GetBalance(ctx, a)
+	require.NoError(t, err)
+	balanceB, err := accountRepo.GetBalance(ctx, b)
+	require.NoError(t, err)
+	assert.True(t, balanceA.Equal(initial), "balance A = %s", balanceA)
+	assert.True(t, balanceB.Equal(initial), "balance B = %s", balanceB)
+}
@@ -1,86 +1,126 @@
 package service
 
 import (
-	"fmt"
+	"context"
 	"testing"
 
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/config"
+	"github.com/hidimpu/transfersystem/internal/model"
+	"github.com/hidimpu/transfersystem/internal/repository/memory"
 )
 
+// newMemoryServices wires both services to a fresh in-memory store seeded
+// with accounts 1 and 2 holding 1000.00 each
+func newMemoryServices(t *testing.T, cfg config.TransferConfig) (AccountService, *TransactionService, *memory.Store) {
+	t.Helper()
+	store := memory.NewStore()
+	accounts := NewAccountService(store.Accounts())
+	txns := NewTransactionService(store.UnitOfWork(), store.Accounts(), store.Transactions(), cfg)
+
+	ctx := context.Background()
+	for _, id := range []int64{1, 2} {
+		require.NoError(t, accounts.CreateAccount(ctx, &model.Account{ID: id, Balance: decimal.NewFromFloat(1000.00)}))
+	}
+	return accounts, txns, store
+}
+
 func TestValidateTransferRequest(t *testing.T) {
 	tests := []struct {
 		name        string
 		srcID       int64
 		dstID       int64
 		amount      decimal.Decimal
-		expectedErr string
+		expectedErr error
 	}{
 		{
-			name:        "valid transfer",
-			srcID:       1,
-			dstID:       2,
-			amount:      decimal.NewFromFloat(100.00),
-			expectedErr: "",
+			name:   "valid transfer",
+			srcID:  1,
+			dstID:  2,
+			amount: decimal.NewFromFloat(100.00),
 		},
 		{
 			name:        "same account transfer",
 			srcID:       1,
 			dstID:       1,
 			amount:      decimal.NewFromFloat(100.00),
-			expectedErr: "cannot transfer to same account",
+			expectedErr: model.ErrSameAccountTransfer,
 		},
 		{
 			name:        "negative amount",
 			srcID:       1,
 			dstID:       2,
 			amount:      decimal.NewFromFloat(-100.00),
-			expectedErr: "transfer amount must be positive",
+			expectedErr: model.ErrNegativeAmount,
 		},
 		{
 			name:        "zero amount",
 			srcID:       1,
 			dstID:       2,
 			amount:      decimal.Zero,
-			expectedErr: "transfer amount must be positive",
+			expectedErr: model.ErrNegativeAmount,
+		},
+		{
+			name:        "amount over limit",
+			srcID:       1,
+			dstID:       2,
+			amount:      decimal.NewFromFloat(500.01),
+			expectedErr: model.ErrAmountExceedsLimit,
 		},
 		{
 			name:        "invalid source account ID",
 			srcID:       0,
 			dstID:       2,
 			amount:      decimal.NewFromFloat(100.00),
-			expectedErr: "invalid account IDs",
+			expectedErr: model.ErrInvalidAccountIDs,
 		},
 		{
 			name:        "invalid destination account ID",
 			srcID:       1,
 			dstID:       0,
 			amount:      decimal.NewFromFloat(100.00),
-			expectedErr: "invalid account IDs",
+			expectedErr: model.ErrInvalidAccountIDs,
 		},
 		{
 			name:        "negative source account ID",
 			srcID:       -1,
 			dstID:       2,
 			amount:      decimal.NewFromFloat(100.00),
-			expectedErr: "invalid account IDs",
+			expectedErr: model.ErrInvalidAccountIDs,
 		},
 		{
 			name:        "negative destination account ID",
 			srcID:       1,
 			dstID:       -2,
 			amount:      decimal.NewFromFloat(100.00),
-			expectedErr: "invalid account IDs",
+			expectedErr: model.ErrInvalidAccountIDs,
+		},
+		{
+			name:        "unknown source account",
+			srcID:       3,
+			dstID:       2,
+			amount:      decimal.NewFromFloat(100.00),
+			expectedErr: model.ErrSourceAccountNotFound,
+		},
+		{
+			name:        "unknown destination account",
+			srcID:       1,
+			dstID:       3,
+			amount:      decimal.NewFromFloat(100.00),
+			expectedErr: model.ErrDestAccountNotFound,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateTransferRequest(tt.srcID, tt.dstID, tt.amount)
+			_, svc, _ := newMemoryServices(t, config.TransferConfig{MaxAmount: "500"})
+			err := svc.validateTransferRequest(context.Background(), tt.srcID, tt.dstID, tt.amount)
 
-			if tt.expectedErr != "" {
-				assert.Error(t, err)
-				assert.Contains(t, err.Error(), tt.expectedErr)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
 			} else {
 				assert.NoError(t, err)
 			}
@@ -93,41 +133,46 @@ func TestValidateAccount(t *testing.T) {
 		name        string
 		accountID   int64
 		balance     decimal.Decimal
-		expectedErr string
+		expectedErr error
 	}{
 		{
-			name:        "valid account",
-			accountID:   1,
-			balance:     decimal.NewFromFloat(1000.00),
-			expectedErr: "",
+			name:      "valid account",
+			accountID: 3,
+			balance:   decimal.NewFromFloat(1000.00),
 		},
 		{
 			name:        "zero account ID",
 			accountID:   0,
 			balance:     decimal.NewFromFloat(1000.00),
-			expectedErr: "account ID must be positive",
+			expectedErr: model.ErrAccountIDRequired,
 		},
 		{
 			name:        "negative account ID",
 			accountID:   -1,
 			balance:     decimal.NewFromFloat(1000.00),
-			expectedErr: "account ID must be positive",
+			expectedErr: model.ErrAccountIDRequired,
 		},
 		{
 			name:        "negative balance",
-			accountID:   1,
+			accountID:   3,
 			balance:     decimal.NewFromFloat(-100.00),
-			expectedErr: "account balance cannot be negative",
+			expectedErr: model.ErrNegativeBalance,
+		},
+		{
+			name:        "duplicate account",
+			accountID:   1,
+			balance:     decimal.NewFromFloat(1000.00),
+			expectedErr: model.ErrAccountExists,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validateAccount(tt.accountID, tt.balance)
+			svc, _, _ := newMemoryServices(t, config.TransferConfig{})
+			err := svc.CreateAccount(context.Background(), &model.Account{ID: tt.accountID, Balance: tt.balance})
 
-			if tt.expectedErr != "" {
-				assert.Error(t, err)
-				assert.Contains(t, err.Error(), tt.expectedErr)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
 			} else {
 				assert.NoError(t, err)
 			}
@@ -135,26 +180,37 @@ func TestValidateAccount(t *testing.T) {
 	}
 }
 
-// Helper functions for testing
-func validateTransferRequest(srcID, dstID int64, amount decimal.Decimal) error {
-	if srcID == dstID {
-		return fmt.Errorf("cannot transfer to same account")
-	}
-	if amount.LessThanOrEqual(decimal.Zero) {
-		return fmt.Errorf("transfer amount must be positive")
-	}
-	if srcID <= 0 || dstID <= 0 {
-		return fmt.Errorf("invalid account IDs")
-	}
-	return nil
-}
+func TestTransfer_Memory(t *testing.T) {
+	ctx := context.Background()
+	accounts, svc, _ := newMemoryServices(t, config.TransferConfig{})
 
-func validateAccount(accountID int64, balance decimal.Decimal) error {
-	if accountID <= 0 {
-		return fmt.Errorf("account ID must be positive")
-	}
-	if balance.IsNegative() {
-		return fmt.Errorf("account balance cannot be negative")
-	}
-	return nil
+	require.NoError(t, svc.Transfer(ctx, 1, 2, decimal.NewFromFloat(250.50)))
+
+	src, err := accounts.GetAccountByID(ctx, 1)
+	require.NoError(t, err)
+	dst, err := accounts.GetAccountByID(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, src.Balance.Equal(decimal.NewFromFloat(749.50)), "source balance = %s", src.Balance)
+	assert.True(t, dst.Balance.Equal(decimal.NewFromFloat(1250.50)), "destination balance = %s", dst.Balance)
+
+	history, err := svc.GetTransactionHistory(ctx, model.TransactionFilter{AccountID: 1}, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, int64(1), history[0].SourceAccountID)
+	assert.True(t, history[0].Amount.Equal(decimal.NewFromFloat(250.50)))
+
+	// Insufficient funds leaves both balances and the ledger untouched
+	err = svc.Transfer(ctx, 1, 2, decimal.NewFromFloat(10_000))
+	assert.ErrorIs(t, err, model.ErrInsufficientFunds)
+	assert.Equal(t, model.ErrorDetails{
+		"account_id":        int64(1),
+		"available_balance": "749.5",
+		"requested_amount":  "10000",
+	}, model.DetailsOf(err))
+	src, err = accounts.GetAccountByID(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, src.Balance.Equal(decimal.NewFromFloat(749.50)))
+	all, err := svc.SearchTransactions(ctx, model.TransactionSearch{})
+	require.NoError(t, err)
+	assert.Len(t, all.Transactions, 1)
 }
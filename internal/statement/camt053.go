@@ -0,0 +1,247 @@
+package statement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// camt053Namespace is the ISO 20022 BankToCustomerStatementV02 namespace
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+// Codes used by the camt.053 format
+const (
+	camtCredit  = "CRDT"
+	camtDebit   = "DBIT"
+	camtOpening = "OPBD" // opening booked balance
+	camtClosing = "CLBD" // closing booked balance
+	camtBooked  = "BOOK"
+	// Every entry is an internal book transfer: domain PMNT (payments),
+	// family RCDT or ICDT (received or issued credit transfer), sub-family
+	// BOOK
+	camtDomain       = "PMNT"
+	camtReceived     = "RCDT"
+	camtIssued       = "ICDT"
+	camtBookTransfer = "BOOK"
+	// camtMaxDigits is the totalDigits of ActiveOrHistoricCurrencyAndAmount
+	// and DecimalNumber
+	camtMaxDigits = 18
+)
+
+type camtDocument struct {
+	XMLName xml.Name `xml:"Document"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Stmt    struct {
+		GrpHdr camtGroupHeader `xml:"GrpHdr"`
+		Stmt   camtStatement   `xml:"Stmt"`
+	} `xml:"BkToCstmrStmt"`
+}
+
+type camtGroupHeader struct {
+	MsgID    string `xml:"MsgId"`
+	CreDtTm  string `xml:"CreDtTm"`
+	MsgPgntn struct {
+		PgNb      int  `xml:"PgNb"`
+		LastPgInd bool `xml:"LastPgInd"`
+	} `xml:"MsgPgntn"`
+}
+
+type camtStatement struct {
+	ID      string `xml:"Id"`
+	CreDtTm string `xml:"CreDtTm"`
+	FrToDt  struct {
+		FrDtTm string `xml:"FrDtTm"`
+		ToDtTm string `xml:"ToDtTm"`
+	} `xml:"FrToDt"`
+	Acct      camtAccount   `xml:"Acct"`
+	Bal       []camtBalance `xml:"Bal"`
+	TxsSummry struct {
+		TtlNtries struct {
+			NbOfNtries    int    `xml:"NbOfNtries"`
+			Sum           string `xml:"Sum"`
+			TtlNetNtryAmt string `xml:"TtlNetNtryAmt"`
+			CdtDbtInd     string `xml:"CdtDbtInd"`
+		} `xml:"TtlNtries"`
+		TtlCdtNtries camtTotal `xml:"TtlCdtNtries"`
+		TtlDbtNtries camtTotal `xml:"TtlDbtNtries"`
+	} `xml:"TxsSummry"`
+	Ntry []camtEntry `xml:"Ntry"`
+}
+
+type camtAccount struct {
+	ID  string `xml:"Id>Othr>Id"`
+	Ccy string `xml:"Ccy,omitempty"`
+}
+
+type camtAmount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Tp        string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amt       camtAmount `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	DtTm      string     `xml:"Dt>DtTm"`
+}
+
+type camtTotal struct {
+	NbOfNtries int    `xml:"NbOfNtries"`
+	Sum        string `xml:"Sum"`
+}
+
+type camtEntry struct {
+	NtryRef     string     `xml:"NtryRef"`
+	Amt         camtAmount `xml:"Amt"`
+	CdtDbtInd   string     `xml:"CdtDbtInd"`
+	RvslInd     bool       `xml:"RvslInd,omitempty"`
+	Sts         string     `xml:"Sts"`
+	BookgDt     string     `xml:"BookgDt>DtTm"`
+	ValDt       string     `xml:"ValDt>Dt"`
+	AcctSvcrRef string     `xml:"AcctSvcrRef"`
+	BkTxCd      struct {
+		Domain    string `xml:"Domn>Cd"`
+		Family    string `xml:"Domn>Fmly>Cd"`
+		SubFamily string `xml:"Domn>Fmly>SubFmlyCd"`
+	} `xml:"BkTxCd"`
+	TxDtls struct {
+		AcctSvcrRef string      `xml:"Refs>AcctSvcrRef"`
+		DbtrAcct    camtAccount `xml:"RltdPties>DbtrAcct"`
+		CdtrAcct    camtAccount `xml:"RltdPties>CdtrAcct"`
+	} `xml:"NtryDtls>TxDtls"`
+	AddtlNtryInf string `xml:"AddtlNtryInf,omitempty"`
+}
+
+// renderCAMT053 writes st as an ISO 20022 camt.053.001.02 statement. Every
+// transaction is one booked entry whose booking date is its creation time
+// and whose value date is that day in UTC. Amounts are unsigned with a
+// CRDT or DBIT indicator; reversals set RvslInd and name the reversed
+// transaction. The message is identified by the account and the time
+// the statement was generated, the statement by the account and period.
+//
+// Amounts, balances and sums are limited to 18 digits by the schema; a
+// statement with a larger one fails with model.ErrStatementUnrenderable,
+// before anything is written.
+func renderCAMT053(w io.Writer, st *model.Statement) error {
+	account := strconv.FormatInt(st.AccountID, 10)
+	var err error
+	amount := func(field string, d decimal.Decimal) string {
+		s, amountErr := camtAmountText(field, d)
+		if err == nil {
+			err = amountErr
+		}
+		return s
+	}
+
+	doc := camtDocument{Xmlns: camt053Namespace}
+	hdr := &doc.Stmt.GrpHdr
+	hdr.MsgID = account + "-" + st.GeneratedAt.UTC().Format("20060102150405")
+	hdr.CreDtTm = timestamp(st.GeneratedAt)
+	hdr.MsgPgntn.PgNb = 1
+	hdr.MsgPgntn.LastPgInd = true
+
+	s := &doc.Stmt.Stmt
+	s.ID = account + "-" + st.From.UTC().Format("20060102150405")
+	s.CreDtTm = hdr.CreDtTm
+	s.FrToDt.FrDtTm = timestamp(st.From)
+	s.FrToDt.ToDtTm = timestamp(st.To)
+	s.Acct = camtAccount{ID: account, Ccy: st.Currency}
+	s.Bal = []camtBalance{
+		camtBalanceOf(camtOpening, amount("opening_balance", st.OpeningBalance), st.OpeningBalance, st.Currency, st.From),
+		camtBalanceOf(camtClosing, amount("closing_balance", st.ClosingBalance), st.ClosingBalance, st.Currency, st.To),
+	}
+
+	credits, debits := 0, 0
+	for _, e := range st.Entries {
+		entry := camtEntry{
+			NtryRef:     strconv.FormatInt(e.TransactionID, 10),
+			Amt:         camtAmount{Ccy: st.Currency, Value: amount("amount", e.Amount)},
+			CdtDbtInd:   camtCredit,
+			RvslInd:     e.ReversalOf != nil,
+			Sts:         camtBooked,
+			BookgDt:     timestamp(e.BookedAt),
+			ValDt:       e.BookedAt.UTC().Format(time.DateOnly),
+			AcctSvcrRef: strconv.FormatInt(e.TransactionID, 10),
+		}
+		entry.BkTxCd.Domain = camtDomain
+		entry.BkTxCd.Family = camtReceived
+		entry.BkTxCd.SubFamily = camtBookTransfer
+		entry.TxDtls.AcctSvcrRef = entry.AcctSvcrRef
+		counterparty := camtAccount{ID: strconv.FormatInt(e.CounterpartyAccountID, 10)}
+		entry.TxDtls.DbtrAcct, entry.TxDtls.CdtrAcct = counterparty, camtAccount{ID: account}
+		if e.Debit() {
+			entry.CdtDbtInd = camtDebit
+			entry.BkTxCd.Family = camtIssued
+			entry.TxDtls.DbtrAcct, entry.TxDtls.CdtrAcct = entry.TxDtls.CdtrAcct, entry.TxDtls.DbtrAcct
+			debits++
+		} else {
+			credits++
+		}
+		if e.ReversalOf != nil {
+			entry.AddtlNtryInf = fmt.Sprintf("Reversal of transaction %d", *e.ReversalOf)
+		}
+		s.Ntry = append(s.Ntry, entry)
+	}
+
+	totals := &s.TxsSummry
+	net := st.TotalCredits.Sub(st.TotalDebits)
+	totals.TtlNtries.NbOfNtries = len(st.Entries)
+	totals.TtlNtries.Sum = amount("total", st.TotalCredits.Add(st.TotalDebits))
+	totals.TtlNtries.TtlNetNtryAmt = amount("net_total", net)
+	totals.TtlNtries.CdtDbtInd = creditDebit(net)
+	totals.TtlCdtNtries = camtTotal{NbOfNtries: credits, Sum: amount("total_credits", st.TotalCredits)}
+	totals.TtlDbtNtries = camtTotal{NbOfNtries: debits, Sum: amount("total_debits", st.TotalDebits)}
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// camtBalanceOf returns a balance of type code at t, amount being its
+// magnitude as text. camt.053 amounts are unsigned, so an overdrawn balance
+// is a DBIT.
+func camtBalanceOf(code, amount string, balance decimal.Decimal, currency string, t time.Time) camtBalance {
+	return camtBalance{
+		Tp:        code,
+		Amt:       camtAmount{Ccy: currency, Value: amount},
+		CdtDbtInd: creditDebit(balance),
+		DtTm:      timestamp(t),
+	}
+}
+
+// creditDebit returns the camt.053 indicator of a signed amount
+func creditDebit(d decimal.Decimal) string {
+	if d.IsNegative() {
+		return camtDebit
+	}
+	return camtCredit
+}
+
+// camtAmountText formats the magnitude of d, checking it against the
+// schema's 18 total digits. Leading zeros of the integer part and trailing
+// zeros of the fraction do not count.
+func camtAmountText(field string, d decimal.Decimal) (string, error) {
+	s := d.Abs().String()
+	digits := strings.TrimLeft(strings.Replace(s, ".", "", 1), "0")
+	if len(digits) > camtMaxDigits {
+		return "", unrenderable(FormatCAMT053, field, d.String())
+	}
+	return s, nil
+}
@@ -0,0 +1,140 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestRenderCAMT053(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, FormatCAMT053, sampleStatement()))
+	validateXSD(t, "testdata/camt.053.001.02.xsd", buf.Bytes())
+
+	var doc struct {
+		GrpHdr struct {
+			MsgID   string `xml:"MsgId"`
+			CreDtTm string `xml:"CreDtTm"`
+		} `xml:"BkToCstmrStmt>GrpHdr"`
+		Stmt struct {
+			ID   string `xml:"Id"`
+			Acct string `xml:"Acct>Id>Othr>Id"`
+			Bal  []struct {
+				Tp        string     `xml:"Tp>CdOrPrtry>Cd"`
+				Amt       camtAmount `xml:"Amt"`
+				CdtDbtInd string     `xml:"CdtDbtInd"`
+				DtTm      string     `xml:"Dt>DtTm"`
+			} `xml:"Bal"`
+			Ntry []struct {
+				NtryRef   string `xml:"NtryRef"`
+				Amt       string `xml:"Amt"`
+				CdtDbtInd string `xml:"CdtDbtInd"`
+				RvslInd   bool   `xml:"RvslInd"`
+				BookgDt   string `xml:"BookgDt>DtTm"`
+				ValDt     string `xml:"ValDt>Dt"`
+				Family    string `xml:"BkTxCd>Domn>Fmly>Cd"`
+				Debtor    string `xml:"NtryDtls>TxDtls>RltdPties>DbtrAcct>Id>Othr>Id"`
+				Creditor  string `xml:"NtryDtls>TxDtls>RltdPties>CdtrAcct>Id>Othr>Id"`
+			} `xml:"Ntry"`
+			TtlNtries string `xml:"TxsSummry>TtlNtries>NbOfNtries"`
+			TtlNet    string `xml:"TxsSummry>TtlNtries>TtlNetNtryAmt"`
+			TtlDbt    string `xml:"TxsSummry>TtlDbtNtries>Sum"`
+		} `xml:"BkToCstmrStmt>Stmt"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "1-20240402083000", doc.GrpHdr.MsgID)
+	assert.Equal(t, "2024-04-02T08:30:00Z", doc.GrpHdr.CreDtTm)
+	assert.Equal(t, "1-20240301000000", doc.Stmt.ID)
+	assert.Equal(t, "1", doc.Stmt.Acct)
+
+	require.Len(t, doc.Stmt.Bal, 2)
+	assert.Equal(t, "OPBD", doc.Stmt.Bal[0].Tp)
+	assert.Equal(t, "2024-03-01T00:00:00Z", doc.Stmt.Bal[0].DtTm)
+	assert.Equal(t, "CLBD", doc.Stmt.Bal[1].Tp)
+	assert.Equal(t, camtAmount{Ccy: "USD", Value: "100"}, doc.Stmt.Bal[1].Amt)
+	assert.Equal(t, "CRDT", doc.Stmt.Bal[1].CdtDbtInd)
+
+	require.Len(t, doc.Stmt.Ntry, 2)
+	credit, reversal := doc.Stmt.Ntry[0], doc.Stmt.Ntry[1]
+	assert.Equal(t, "7", credit.NtryRef)
+	assert.Equal(t, "30.12345", credit.Amt)
+	assert.Equal(t, "CRDT", credit.CdtDbtInd)
+	assert.False(t, credit.RvslInd)
+	assert.Equal(t, "RCDT", credit.Family)
+	assert.Equal(t, []string{"2", "1"}, []string{credit.Debtor, credit.Creditor})
+	assert.Equal(t, "30.12345", reversal.Amt, "amounts are unsigned")
+	assert.Equal(t, "DBIT", reversal.CdtDbtInd)
+	assert.True(t, reversal.RvslInd)
+	assert.Equal(t, "ICDT", reversal.Family)
+	assert.Equal(t, []string{"1", "2"}, []string{reversal.Debtor, reversal.Creditor})
+	assert.Equal(t, "2024-03-03T00:00:00Z", reversal.BookgDt, "booking times are UTC")
+	assert.Equal(t, "2024-03-03", reversal.ValDt)
+
+	assert.Equal(t, "2", doc.Stmt.TtlNtries)
+	assert.Equal(t, "0", doc.Stmt.TtlNet)
+	assert.Equal(t, "30.12345", doc.Stmt.TtlDbt)
+}
+
+func TestRenderCAMT053_Overdrawn(t *testing.T) {
+	st := sampleStatement()
+	st.OpeningBalance = decimal.RequireFromString("-0.5")
+	st.Entries = nil
+
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, FormatCAMT053, st))
+	validateXSD(t, "testdata/camt.053.001.02.xsd", buf.Bytes())
+	assert.Contains(t, buf.String(), `<Amt Ccy="USD">0.5</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>`)
+}
+
+func TestRenderCAMT053_Amounts(t *testing.T) {
+	st := sampleStatement()
+	largest := decimal.RequireFromString("1234567890123.12345")
+	st.Entries = []model.StatementEntry{
+		{TransactionID: 10, BookedAt: st.From, CounterpartyAccountID: 3, Amount: largest},
+	}
+	st.TotalCredits, st.TotalDebits = largest, decimal.Zero
+
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, FormatCAMT053, st))
+	validateXSD(t, "testdata/camt.053.001.02.xsd", buf.Bytes())
+	assert.Contains(t, buf.String(), `<Amt Ccy="USD">1234567890123.12345</Amt>`)
+
+	// 20 digits fit DECIMAL(20,5) but not the schema's 18
+	st.Entries[0].Amount = decimal.RequireFromString("123456789012345.12345")
+	buf.Reset()
+	err := Render(&buf, FormatCAMT053, st)
+	assert.ErrorIs(t, err, model.ErrStatementUnrenderable)
+	assert.Equal(t, "amount", model.DetailsOf(err)["field"])
+	assert.Zero(t, buf.Len(), "nothing is written for an unrenderable statement")
+
+	st = sampleStatement()
+	st.OpeningBalance = decimal.RequireFromString("-123456789012345.12345")
+	err = Render(&buf, FormatCAMT053, st)
+	assert.ErrorIs(t, err, model.ErrStatementUnrenderable)
+	assert.Equal(t, "opening_balance", model.DetailsOf(err)["field"])
+	assert.Zero(t, buf.Len())
+}
+
+// validateXSD validates doc against schema with xmllint, skipping the test
+// when xmllint is not installed
+func validateXSD(t *testing.T, schema string, doc []byte) {
+	t.Helper()
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Skip("xmllint not installed")
+	}
+	path := filepath.Join(t.TempDir(), "doc.xml")
+	require.NoError(t, os.WriteFile(path, doc, 0o600))
+	out, err := exec.Command(xmllint, "--noout", "--schema", schema, path).CombinedOutput()
+	require.NoError(t, err, "%s\n%s", out, doc)
+}
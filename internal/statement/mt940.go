@@ -0,0 +1,134 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// MT940 field limits
+const (
+	mt940DateLayout  = "060102"
+	mt940EntryLayout = "0102"
+	// mt940MaxAmount is the length of a 15d amount, comma included
+	mt940MaxAmount = 15
+	// mt940MaxReference is the length of a 16x reference
+	mt940MaxReference = 16
+	// mt940MaxAccount is the length of the 35x account identification
+	mt940MaxAccount = 35
+	// mt940TransactionType is the 1!a3!c identification of every entry:
+	// a non-SWIFT (N) transfer (TRF)
+	mt940TransactionType = "NTRF"
+	// mt940NoReference fills the account owner's reference, which
+	// transfers do not carry
+	mt940NoReference = "NONREF"
+)
+
+// renderMT940 writes st as a SWIFT MT940 customer statement: the text block
+// of one message, lines separated by CRLF and ended by "-". Balances and
+// entries carry a C or D mark with an unsigned amount; reversals are
+// marked RC (a debit reversing a credit) or RD. Dates are UTC. :20: is the
+// period's start date and :86: names the counterparty account.
+//
+// Amounts keep every stored decimal, with at least two. An amount or
+// transaction ID that does not fit its field fails the whole statement with
+// model.ErrStatementUnrenderable, before anything is written.
+func renderMT940(w io.Writer, st *model.Statement) error {
+	var b strings.Builder
+	line := func(tag, value string) {
+		b.WriteString(":" + tag + ":" + value + "\r\n")
+	}
+
+	account := strconv.FormatInt(st.AccountID, 10)
+	if len(account) > mt940MaxAccount {
+		return unrenderable(FormatMT940, "account", account)
+	}
+	line("20", "STMT"+st.From.UTC().Format(mt940DateLayout))
+	line("25", account)
+	line("28C", "1/1")
+
+	opening, err := mt940Balance(st.OpeningBalance, st.Currency, st.From)
+	if err != nil {
+		return err
+	}
+	line("60F", opening)
+
+	for _, e := range st.Entries {
+		mark := "C"
+		switch {
+		case e.Debit() && e.ReversalOf != nil:
+			mark = "RC" // a debit reversing a credit
+		case e.Debit():
+			mark = "D"
+		case e.ReversalOf != nil:
+			mark = "RD"
+		}
+		amount, err := mt940Amount(e.Amount)
+		if err != nil {
+			return err
+		}
+		ref := strconv.FormatInt(e.TransactionID, 10)
+		if len(ref) > mt940MaxReference {
+			return unrenderable(FormatMT940, "transaction_id", ref)
+		}
+		booked := e.BookedAt.UTC()
+		line("61", booked.Format(mt940DateLayout)+booked.Format(mt940EntryLayout)+mark+amount+
+			mt940TransactionType+mt940NoReference+"//"+ref)
+
+		direction := "FROM"
+		if e.Debit() {
+			direction = "TO"
+		}
+		narrative := fmt.Sprintf("TRANSFER %s ACCOUNT %d", direction, e.CounterpartyAccountID)
+		if e.ReversalOf != nil {
+			narrative = fmt.Sprintf("REVERSAL OF TRANSACTION %d\r\n%s", *e.ReversalOf, narrative)
+		}
+		line("86", narrative)
+	}
+
+	// The closing balance is dated on the last day the period covers
+	closing, err := mt940Balance(st.ClosingBalance, st.Currency, st.To.Add(-time.Nanosecond))
+	if err != nil {
+		return err
+	}
+	line("62F", closing)
+	b.WriteString("-\r\n")
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// mt940Balance formats a balance field: mark, date, currency and amount
+func mt940Balance(balance decimal.Decimal, currency string, t time.Time) (string, error) {
+	amount, err := mt940Amount(balance)
+	if err != nil {
+		return "", err
+	}
+	mark := "C"
+	if balance.IsNegative() {
+		mark = "D"
+	}
+	return mark + t.UTC().Format(mt940DateLayout) + currency + amount, nil
+}
+
+// mt940Amount formats the magnitude of d as a 15d amount: a comma as the
+// decimal separator, no sign and no grouping
+func mt940Amount(d decimal.Decimal) (string, error) {
+	s := strings.Replace(amountText(d.Abs()), ".", ",", 1)
+	if len(s) > mt940MaxAmount {
+		return "", unrenderable(FormatMT940, "amount", d.String())
+	}
+	return s, nil
+}
+
+// unrenderable reports a value that format f cannot hold
+func unrenderable(f Format, field, value string) error {
+	return model.WithDetails(fmt.Errorf("%w: %s %s does not fit", model.ErrStatementUnrenderable, field, value),
+		model.ErrorDetails{"format": string(f), "field": field, "value": value})
+}
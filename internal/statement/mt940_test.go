@@ -0,0 +1,85 @@
+package statement
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestRenderMT940(t *testing.T) {
+	want, err := os.ReadFile("testdata/statement.sta")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, FormatMT940, sampleStatement()))
+	assert.Equal(t, string(want), buf.String())
+
+	// Lines of an MT940 text block are at most 65 characters
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 65, line)
+	}
+}
+
+func TestRenderMT940_Overdrawn(t *testing.T) {
+	st := sampleStatement()
+	st.OpeningBalance = decimal.RequireFromString("-0.00001")
+	st.ClosingBalance = decimal.RequireFromString("-12.5")
+	reversalOf := int64(8)
+	st.Entries = []model.StatementEntry{
+		{TransactionID: 9, BookedAt: st.From, CounterpartyAccountID: 3, Amount: decimal.RequireFromString("-12.49999")},
+		{TransactionID: 10, BookedAt: st.From, CounterpartyAccountID: 3, Amount: decimal.RequireFromString("12.5"), ReversalOf: &reversalOf},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, FormatMT940, st))
+	out := buf.String()
+	assert.Contains(t, out, ":60F:D240301USD0,00001\r\n")
+	assert.Contains(t, out, ":61:2403010301D12,49999NTRFNONREF//9\r\n")
+	assert.Contains(t, out, ":61:2403010301RD12,50NTRFNONREF//10\r\n")
+	assert.Contains(t, out, ":62F:D240331USD12,50\r\n")
+}
+
+func TestMT940Amount(t *testing.T) {
+	tests := []struct {
+		amount  string
+		want    string
+		wantErr bool
+	}{
+		{amount: "100", want: "100,00"},
+		{amount: "-30.12345", want: "30,12345"},
+		{amount: "0.00001", want: "0,00001"},
+		{amount: "0", want: "0,00"},
+		{amount: "123456789.12345", want: "123456789,12345"},
+		// 16 characters: one over the 15d limit
+		{amount: "1234567890.12345", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount, func(t *testing.T) {
+			got, err := mt940Amount(decimal.RequireFromString(tt.amount))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, model.ErrStatementUnrenderable)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRenderMT940_AmountTooLong(t *testing.T) {
+	st := sampleStatement()
+	st.ClosingBalance = decimal.RequireFromString("123456789012345.12345")
+
+	var buf bytes.Buffer
+	err := Render(&buf, FormatMT940, st)
+	assert.ErrorIs(t, err, model.ErrStatementUnrenderable)
+	assert.Equal(t, "amount", model.DetailsOf(err)["field"])
+	assert.Zero(t, buf.Len(), "nothing is written for an unrenderable statement")
+}
@@ -0,0 +1,126 @@
+package statement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// ofxHeader opens an OFX 2.1.1 document
+const ofxHeader = xml.Header +
+	`<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+
+const (
+	// ofxDateLayout is an OFX datetime in UTC, with milliseconds
+	ofxDateLayout = "20060102150405.000[+0:UTC]"
+	// ofxBankID fills BANKACCTFROM's mandatory routing number: ledger
+	// accounts are identified by ACCTID alone
+	ofxBankID = "000000000"
+	// ofxMaxName is the length of STMTTRN's NAME
+	ofxMaxName = 32
+)
+
+type ofxDocument struct {
+	XMLName xml.Name `xml:"OFX"`
+	Signon  struct {
+		Status   ofxStatus `xml:"SONRS>STATUS"`
+		DTServer string    `xml:"SONRS>DTSERVER"`
+		Language string    `xml:"SONRS>LANGUAGE"`
+	} `xml:"SIGNONMSGSRSV1"`
+	Bank struct {
+		TrnUID string       `xml:"STMTTRNRS>TRNUID"`
+		Status ofxStatus    `xml:"STMTTRNRS>STATUS"`
+		Stmt   ofxStatement `xml:"STMTTRNRS>STMTRS"`
+	} `xml:"BANKMSGSRSV1"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxStatement struct {
+	CurDef   string           `xml:"CURDEF"`
+	BankID   string           `xml:"BANKACCTFROM>BANKID"`
+	AcctID   string           `xml:"BANKACCTFROM>ACCTID"`
+	AcctType string           `xml:"BANKACCTFROM>ACCTTYPE"`
+	DTStart  string           `xml:"BANKTRANLIST>DTSTART"`
+	DTEnd    string           `xml:"BANKTRANLIST>DTEND"`
+	Trns     []ofxTransaction `xml:"BANKTRANLIST>STMTTRN"`
+	Balance  struct {
+		Amount string `xml:"BALAMT"`
+		AsOf   string `xml:"DTASOF"`
+	} `xml:"LEDGERBAL"`
+}
+
+type ofxTransaction struct {
+	TrnType  string `xml:"TRNTYPE"`
+	DTPosted string `xml:"DTPOSTED"`
+	TrnAmt   string `xml:"TRNAMT"`
+	FitID    string `xml:"FITID"`
+	Name     string `xml:"NAME"`
+	Memo     string `xml:"MEMO,omitempty"`
+}
+
+// renderOFX writes st as an OFX 2.1.1 bank statement response. Each entry
+// is a STMTTRN with a signed TRNAMT, CREDIT or DEBIT, whose FITID is the
+// transaction ID; NAME is the counterparty account and MEMO names the
+// transaction a reversal reverses. LEDGERBAL is the closing balance at the
+// end of the period. Amounts keep every stored decimal, with at least two.
+func renderOFX(w io.Writer, st *model.Statement) error {
+	var doc ofxDocument
+	doc.Signon.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	doc.Signon.DTServer = ofxDate(st.GeneratedAt)
+	doc.Signon.Language = "ENG"
+	doc.Bank.TrnUID = "0"
+	doc.Bank.Status = ofxStatus{Code: 0, Severity: "INFO"}
+
+	s := &doc.Bank.Stmt
+	s.CurDef = st.Currency
+	s.BankID = ofxBankID
+	s.AcctID = strconv.FormatInt(st.AccountID, 10)
+	s.AcctType = "CHECKING"
+	s.DTStart = ofxDate(st.From)
+	s.DTEnd = ofxDate(st.To)
+	for _, e := range st.Entries {
+		trn := ofxTransaction{
+			TrnType:  "CREDIT",
+			DTPosted: ofxDate(e.BookedAt),
+			TrnAmt:   amountText(e.Amount),
+			FitID:    strconv.FormatInt(e.TransactionID, 10),
+			Name:     fmt.Sprintf("Account %d", e.CounterpartyAccountID),
+		}
+		if e.Debit() {
+			trn.TrnType = "DEBIT"
+		}
+		if len(trn.Name) > ofxMaxName {
+			trn.Name = trn.Name[:ofxMaxName]
+		}
+		if e.ReversalOf != nil {
+			trn.Memo = fmt.Sprintf("Reversal of transaction %d", *e.ReversalOf)
+		}
+		s.Trns = append(s.Trns, trn)
+	}
+	s.Balance.Amount = amountText(st.ClosingBalance)
+	s.Balance.AsOf = ofxDate(st.To)
+
+	if _, err := io.WriteString(w, ofxHeader); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ofxDate formats t as an OFX datetime in UTC
+func ofxDate(t time.Time) string {
+	return t.UTC().Format(ofxDateLayout)
+}
@@ -0,0 +1,45 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+func TestRenderOFX(t *testing.T) {
+	want, err := os.ReadFile("testdata/statement.ofx")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, FormatOFX, sampleStatement()))
+	assert.Equal(t, string(want), buf.String())
+}
+
+func TestRenderOFX_Amounts(t *testing.T) {
+	st := sampleStatement()
+	st.ClosingBalance = decimal.RequireFromString("-12.5")
+	st.Entries = []model.StatementEntry{
+		{TransactionID: 9, BookedAt: st.From, CounterpartyAccountID: 3, Amount: decimal.RequireFromString("-0.00001")},
+		{TransactionID: 10, BookedAt: st.From, CounterpartyAccountID: 3, Amount: decimal.RequireFromString("123456789012345.12345")},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, FormatOFX, st))
+	var doc ofxDocument
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	s := doc.Bank.Stmt
+	require.Len(t, s.Trns, 2)
+	assert.Equal(t, "DEBIT", s.Trns[0].TrnType)
+	assert.Equal(t, "-0.00001", s.Trns[0].TrnAmt)
+	assert.Equal(t, "CREDIT", s.Trns[1].TrnType)
+	assert.Equal(t, "123456789012345.12345", s.Trns[1].TrnAmt)
+	assert.Empty(t, s.Trns[1].Memo)
+	assert.Equal(t, "-12.50", s.Balance.Amount)
+}
@@ -0,0 +1,192 @@
+// Package statement renders account statements. Every format is built from
+// the same model.Statement, so they always agree on entries and balances.
+package statement
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// Format is the encoding of a statement
+type Format string
+
+const (
+	// FormatJSON is model.Statement as a JSON object
+	FormatJSON Format = "json"
+	// FormatCSV is one row per entry between an opening and a closing row
+	FormatCSV Format = "csv"
+	// FormatText is a fixed-width plain-text layout for printing
+	FormatText Format = "text"
+	// FormatCAMT053 is an ISO 20022 camt.053.001.02 XML statement
+	FormatCAMT053 Format = "camt053"
+	// FormatMT940 is a SWIFT MT940 customer statement message
+	FormatMT940 Format = "mt940"
+	// FormatOFX is an OFX 2.1.1 bank statement response
+	FormatOFX Format = "ofx"
+)
+
+// formats lists every format in the order it is documented
+var formats = []Format{FormatJSON, FormatCSV, FormatText, FormatCAMT053, FormatMT940, FormatOFX}
+
+// ParseFormat accepts a format name
+func ParseFormat(s string) (Format, error) {
+	for _, f := range formats {
+		if string(f) == strings.ToLower(s) {
+			return f, nil
+		}
+	}
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown statement format %q, want one of %s", s, strings.Join(names, ", "))
+}
+
+// ContentType returns the media type of f
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatText:
+		return "text/plain; charset=utf-8"
+	case FormatCAMT053:
+		return "application/xml"
+	case FormatMT940:
+		return "text/plain; charset=us-ascii"
+	case FormatOFX:
+		return "application/x-ofx"
+	default:
+		return "application/json"
+	}
+}
+
+// Extension returns the file extension of f, including the dot
+func (f Format) Extension() string {
+	switch f {
+	case FormatText:
+		return ".txt"
+	case FormatCAMT053:
+		return ".xml"
+	case FormatMT940:
+		return ".sta"
+	default:
+		return "." + string(f)
+	}
+}
+
+// Render writes st to w in format f
+func Render(w io.Writer, f Format, st *model.Statement) error {
+	buf := bufio.NewWriter(w)
+	var err error
+	switch f {
+	case FormatJSON:
+		err = json.NewEncoder(buf).Encode(st)
+	case FormatCSV:
+		err = renderCSV(buf, st)
+	case FormatText:
+		err = renderText(buf, st)
+	case FormatCAMT053:
+		err = renderCAMT053(buf, st)
+	case FormatMT940:
+		err = renderMT940(buf, st)
+	case FormatOFX:
+		err = renderOFX(buf, st)
+	default:
+		err = fmt.Errorf("unknown statement format %q", f)
+	}
+	if err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// renderCSV writes the columns type (opening, debit, credit or closing),
+// booked_at, transaction_id, counterparty_account_id, amount, balance and
+// reversal_of. The opening and closing rows carry the period bounds and
+// balances only.
+func renderCSV(w io.Writer, st *model.Statement) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"type", "booked_at", "transaction_id", "counterparty_account_id", "amount", "balance", "reversal_of"})
+	cw.Write([]string{"opening", timestamp(st.From), "", "", "", st.OpeningBalance.String(), ""})
+	for _, e := range st.Entries {
+		kind := "credit"
+		if e.Debit() {
+			kind = "debit"
+		}
+		reversalOf := ""
+		if e.ReversalOf != nil {
+			reversalOf = strconv.FormatInt(*e.ReversalOf, 10)
+		}
+		cw.Write([]string{
+			kind,
+			timestamp(e.BookedAt),
+			strconv.FormatInt(e.TransactionID, 10),
+			strconv.FormatInt(e.CounterpartyAccountID, 10),
+			e.Amount.String(),
+			e.Balance.String(),
+			reversalOf,
+		})
+	}
+	cw.Write([]string{"closing", timestamp(st.To), "", "", "", st.ClosingBalance.String(), ""})
+	cw.Flush()
+	return cw.Error()
+}
+
+// Column layout of the text format
+const (
+	textDateLayout = "2006-01-02 15:04:05"
+	textRow        = "%-19s  %11s  %12s  %20s  %20s  %20s\n"
+	// textSummary spans the date, transaction and counterparty columns
+	textSummary = "%-46s  %20s  %20s  %20s\n"
+)
+
+// renderText writes a fixed-width layout: a header, then one line per entry
+// between the opening and closing balances, then the period's totals.
+// Times are UTC. Reversals are marked with R after the transaction ID.
+func renderText(w io.Writer, st *model.Statement) error {
+	fmt.Fprintf(w, "STATEMENT OF ACCOUNT %d\n", st.AccountID)
+	fmt.Fprintf(w, "Currency: %s\n", st.Currency)
+	fmt.Fprintf(w, "Period:   %s to %s UTC\n\n", st.From.UTC().Format(textDateLayout), st.To.UTC().Format(textDateLayout))
+
+	fmt.Fprintf(w, textRow, "DATE", "TRANSACTION", "COUNTERPARTY", "DEBIT", "CREDIT", "BALANCE")
+	fmt.Fprintf(w, textSummary, "Opening balance", "", "", amountText(st.OpeningBalance))
+	for _, e := range st.Entries {
+		id := strconv.FormatInt(e.TransactionID, 10)
+		if e.ReversalOf != nil {
+			id += " R"
+		}
+		debit, credit := "", amountText(e.Amount)
+		if e.Debit() {
+			debit, credit = amountText(e.Amount.Neg()), ""
+		}
+		fmt.Fprintf(w, textRow, e.BookedAt.UTC().Format(textDateLayout), id,
+			strconv.FormatInt(e.CounterpartyAccountID, 10), debit, credit, amountText(e.Balance))
+	}
+	fmt.Fprintf(w, textSummary, "Closing balance", "", "", amountText(st.ClosingBalance))
+	_, err := fmt.Fprintf(w, textSummary, fmt.Sprintf("Totals (%d entries)", len(st.Entries)),
+		amountText(st.TotalDebits), amountText(st.TotalCredits), "")
+	return err
+}
+
+// amountText formats d with two decimals, or more when it has sub-cent
+// digits, so no stored precision is lost
+func amountText(d decimal.Decimal) string {
+	if d.Equal(d.Round(2)) {
+		return d.StringFixed(2)
+	}
+	return d.String()
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
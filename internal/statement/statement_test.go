@@ -0,0 +1,97 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hidimpu/transfersystem/internal/model"
+)
+
+// sampleStatement is account 1 over March 2024: a credit, then its reversal
+func sampleStatement() *model.Statement {
+	reversalOf := int64(7)
+	return &model.Statement{
+		AccountID:      1,
+		Currency:       "USD",
+		From:           time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:             time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		OpeningBalance: decimal.RequireFromString("100"),
+		ClosingBalance: decimal.RequireFromString("100"),
+		TotalDebits:    decimal.RequireFromString("30.12345"),
+		TotalCredits:   decimal.RequireFromString("30.12345"),
+		GeneratedAt:    time.Date(2024, 4, 2, 8, 30, 0, 0, time.UTC),
+		Entries: []model.StatementEntry{
+			{TransactionID: 7, BookedAt: time.Date(2024, 3, 2, 10, 15, 0, 0, time.UTC), CounterpartyAccountID: 2,
+				Amount: decimal.RequireFromString("30.12345"), Balance: decimal.RequireFromString("130.12345")},
+			{TransactionID: 8, BookedAt: time.Date(2024, 3, 3, 1, 0, 0, 0, time.FixedZone("CET", 3600)), CounterpartyAccountID: 2,
+				Amount: decimal.RequireFromString("-30.12345"), Balance: decimal.RequireFromString("100"), ReversalOf: &reversalOf},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		want   string
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			want: "type,booked_at,transaction_id,counterparty_account_id,amount,balance,reversal_of\n" +
+				"opening,2024-03-01T00:00:00Z,,,,100,\n" +
+				"credit,2024-03-02T10:15:00Z,7,2,30.12345,130.12345,\n" +
+				"debit,2024-03-03T00:00:00Z,8,2,-30.12345,100,7\n" +
+				"closing,2024-04-01T00:00:00Z,,,,100,\n",
+		},
+		{
+			name:   "text",
+			format: FormatText,
+			want: "STATEMENT OF ACCOUNT 1\n" +
+				"Currency: USD\n" +
+				"Period:   2024-03-01 00:00:00 to 2024-04-01 00:00:00 UTC\n" +
+				"\n" +
+				"DATE                 TRANSACTION  COUNTERPARTY                 DEBIT                CREDIT               BALANCE\n" +
+				"Opening balance                                                                                           100.00\n" +
+				"2024-03-02 10:15:00            7             2                                    30.12345             130.12345\n" +
+				"2024-03-03 00:00:00          8 R             2              30.12345                                      100.00\n" +
+				"Closing balance                                                                                           100.00\n" +
+				"Totals (2 entries)                                          30.12345              30.12345                      \n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Render(&buf, tt.format, sampleStatement()))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestRender_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, FormatJSON, sampleStatement()))
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "100", got["opening_balance"])
+	entries := got["entries"].([]any)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "-30.12345", entries[1].(map[string]any)["amount"])
+	assert.Nil(t, entries[0].(map[string]any)["reversal_of"])
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"json", "CSV", "text", "camt053", "MT940", "ofx"} {
+		_, err := ParseFormat(name)
+		assert.NoError(t, err, name)
+	}
+	_, err := ParseFormat("pdf")
+	assert.ErrorContains(t, err, "json, csv, text, camt053, mt940, ofx")
+}
@@ -1,60 +1,233 @@
 package utils
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"time"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Supported log output formats
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
 )
 
-// Logger provides structured logging functionality
+// LoggerOptions configures a Logger
+type LoggerOptions struct {
+	// Format is either LogFormatJSON or LogFormatText
+	Format string
+	// Level is the minimum level that is written
+	Level slog.Level
+	// Output defaults to os.Stdout
+	Output io.Writer
+	// Sampling maps an operation (e.g. "GET_ATTEMPT") to N, meaning only
+	// one in every N info, debug, transfer and account lines for that
+	// operation is written. Warnings and errors are never sampled.
+	Sampling map[string]int
+}
+
+// DefaultLoggerOptions returns JSON output at info level with GET_ATTEMPT sampled 1 in 10
+func DefaultLoggerOptions() LoggerOptions {
+	return LoggerOptions{
+		Format:   LogFormatJSON,
+		Level:    slog.LevelInfo,
+		Output:   os.Stdout,
+		Sampling: map[string]int{"GET_ATTEMPT": 10},
+	}
+}
+
+// Logger provides structured logging functionality on top of log/slog.
+// Every method takes the request context so that the request ID and trace
+// IDs it carries are attached to the log record.
 type Logger struct {
-	*log.Logger
+	*slog.Logger
+	sampler *sampler
 }
 
 // NewLogger creates a new logger instance
-func NewLogger() *Logger {
+func NewLogger(opts LoggerOptions) *Logger {
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	var handler slog.Handler
+	if strings.EqualFold(opts.Format, LogFormatText) {
+		handler = slog.NewTextHandler(out, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	}
+
 	return &Logger{
-		Logger: log.New(os.Stdout, "", log.LstdFlags),
+		Logger:  slog.New(&contextHandler{Handler: handler}),
+		sampler: newSampler(opts.Sampling),
 	}
 }
 
-// LogError logs an error with context
-func (l *Logger) LogError(operation, errorType, message string, err error) {
-	l.Printf("[ERROR] %s | Type: %s | Message: %s | Error: %v | Time: %s",
-		operation, errorType, message, err, time.Now().Format(time.RFC3339))
+// ParseLogLevel parses debug, info, warn or error (case-insensitive)
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+// ParseLogSampling parses a comma-separated list of operation=N pairs,
+// e.g. "GET_ATTEMPT=10,ATTEMPT=5"
+func ParseLogSampling(s string) (map[string]int, error) {
+	sampling := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		op, rate, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid log sampling entry %q", pair)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(rate))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid log sampling rate in %q", pair)
+		}
+		sampling[strings.TrimSpace(op)] = n
+	}
+	return sampling, nil
+}
+
+// LogError logs an error with context; errors are never sampled
+func (l *Logger) LogError(ctx context.Context, operation, errorType, message string, err error) {
+	l.ErrorContext(ctx, message,
+		slog.String("operation", operation),
+		slog.String("error_type", errorType),
+		slog.Any("error", err),
+	)
 }
 
 // LogInfo logs an informational message
-func (l *Logger) LogInfo(operation, message string) {
-	l.Printf("[INFO] %s | Message: %s | Time: %s",
-		operation, message, time.Now().Format(time.RFC3339))
+func (l *Logger) LogInfo(ctx context.Context, operation, message string) {
+	if !l.sampler.allow(operation) {
+		return
+	}
+	l.InfoContext(ctx, message, slog.String("operation", operation))
 }
 
-// LogWarning logs a warning message
-func (l *Logger) LogWarning(operation, message string) {
-	l.Printf("[WARN] %s | Message: %s | Time: %s",
-		operation, message, time.Now().Format(time.RFC3339))
+// LogDebug logs a debug message
+func (l *Logger) LogDebug(ctx context.Context, operation, message string) {
+	if !l.sampler.allow(operation) {
+		return
+	}
+	l.DebugContext(ctx, message, slog.String("operation", operation))
+}
+
+// LogWarning logs a warning message; warnings are never sampled
+func (l *Logger) LogWarning(ctx context.Context, operation, message string) {
+	l.WarnContext(ctx, message, slog.String("operation", operation))
 }
 
 // LogTransfer logs transfer-specific information
-func (l *Logger) LogTransfer(operation string, sourceID, destID int64, amount string, success bool) {
-	status := "SUCCESS"
-	if !success {
-		status = "FAILED"
+func (l *Logger) LogTransfer(ctx context.Context, operation string, sourceID, destID int64, amount string, success bool) {
+	if !l.sampler.allow(operation) {
+		return
 	}
-	l.Printf("[TRANSFER] %s | Source: %d | Destination: %d | Amount: %s | Status: %s | Time: %s",
-		operation, sourceID, destID, amount, status, time.Now().Format(time.RFC3339))
+	l.InfoContext(ctx, "transfer",
+		slog.String("operation", operation),
+		slog.Int64("source_account_id", sourceID),
+		slog.Int64("destination_account_id", destID),
+		slog.String("amount", amount),
+		slog.String("status", status(success)),
+	)
 }
 
 // LogAccount logs account-specific information
-func (l *Logger) LogAccount(operation string, accountID int64, balance string, success bool) {
-	status := "SUCCESS"
-	if !success {
-		status = "FAILED"
+func (l *Logger) LogAccount(ctx context.Context, operation string, accountID int64, balance string, success bool) {
+	if !l.sampler.allow(operation) {
+		return
 	}
-	l.Printf("[ACCOUNT] %s | AccountID: %d | Balance: %s | Status: %s | Time: %s",
-		operation, accountID, balance, status, time.Now().Format(time.RFC3339))
+	l.InfoContext(ctx, "account",
+		slog.String("operation", operation),
+		slog.Int64("account_id", accountID),
+		slog.String("balance", balance),
+		slog.String("status", status(success)),
+	)
+}
+
+func status(success bool) string {
+	if success {
+		return "SUCCESS"
+	}
+	return "FAILED"
 }
 
 // Global logger instance
-var GlobalLogger = NewLogger()
+var GlobalLogger = NewLogger(DefaultLoggerOptions())
+
+// SetGlobalLogger replaces GlobalLogger and the slog default logger. It must
+// be called before services and handlers are constructed, since they capture
+// GlobalLogger at construction time.
+func SetGlobalLogger(l *Logger) {
+	GlobalLogger = l
+	slog.SetDefault(l.Logger)
+}
+
+// contextHandler adds the request ID and trace identifiers found in the
+// record's context to every log line
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// sampler lets through one in every N lines per operation
+type sampler struct {
+	rates    map[string]uint64
+	counters sync.Map // operation -> *atomic.Uint64
+}
+
+func newSampler(rates map[string]int) *sampler {
+	s := &sampler{rates: make(map[string]uint64, len(rates))}
+	for op, n := range rates {
+		if n > 1 {
+			s.rates[op] = uint64(n)
+		}
+	}
+	return s
+}
+
+func (s *sampler) allow(operation string) bool {
+	rate, ok := s.rates[operation]
+	if !ok {
+		return true
+	}
+	counter, _ := s.counters.LoadOrStore(operation, new(atomic.Uint64))
+	n := counter.(*atomic.Uint64).Add(1)
+	return (n-1)%rate == 0
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger_JSONIncludesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(LoggerOptions{Format: LogFormatJSON, Level: slog.LevelInfo, Output: &buf})
+
+	ctx := WithRequestID(context.Background(), "req-123")
+	logger.LogTransfer(ctx, "SUCCESS", 1, 2, "10.5", true)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "req-123", line["request_id"])
+	assert.Equal(t, "SUCCESS", line["operation"])
+	assert.Equal(t, float64(1), line["source_account_id"])
+	assert.Equal(t, "10.5", line["amount"])
+	assert.Equal(t, "INFO", line["level"])
+}
+
+func TestLogger_LevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(LoggerOptions{Format: LogFormatText, Level: slog.LevelWarn, Output: &buf})
+
+	logger.LogInfo(context.Background(), "OP", "hidden")
+	logger.LogWarning(context.Background(), "OP", "shown")
+
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "shown")
+}
+
+func TestLogger_Sampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(LoggerOptions{
+		Format:   LogFormatJSON,
+		Level:    slog.LevelInfo,
+		Output:   &buf,
+		Sampling: map[string]int{"GET_ATTEMPT": 5},
+	})
+
+	for i := 0; i < 10; i++ {
+		logger.LogAccount(context.Background(), "GET_ATTEMPT", 1, "N/A", false)
+		logger.LogAccount(context.Background(), "GET_SUCCESS", 1, "1", true)
+	}
+
+	assert.Equal(t, 2, strings.Count(buf.String(), "GET_ATTEMPT"))
+	assert.Equal(t, 10, strings.Count(buf.String(), "GET_SUCCESS"))
+}
+
+func TestLogger_SamplingKeepsErrorsAndWarnings(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(LoggerOptions{
+		Format:   LogFormatJSON,
+		Level:    slog.LevelInfo,
+		Output:   &buf,
+		Sampling: map[string]int{"GET_ACCOUNT": 5},
+	})
+
+	for i := 0; i < 5; i++ {
+		logger.LogInfo(context.Background(), "GET_ACCOUNT", "lookup")
+		logger.LogError(context.Background(), "GET_ACCOUNT", "QUERY_ERROR", "query failed", errors.New("connection reset"))
+		logger.LogWarning(context.Background(), "GET_ACCOUNT", "slow query")
+	}
+
+	assert.Equal(t, 1, strings.Count(buf.String(), `"lookup"`))
+	assert.Equal(t, 5, strings.Count(buf.String(), `"query failed"`))
+	assert.Equal(t, 5, strings.Count(buf.String(), `"slow query"`))
+}
+
+func TestParseLogSampling(t *testing.T) {
+	sampling, err := ParseLogSampling("GET_ATTEMPT=10, ATTEMPT=2")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"GET_ATTEMPT": 10, "ATTEMPT": 2}, sampling)
+
+	_, err = ParseLogSampling("GET_ATTEMPT=0")
+	assert.Error(t, err)
+
+	_, err = ParseLogSampling("GET_ATTEMPT")
+	assert.Error(t, err)
+}
+
+func TestParseLogLevel(t *testing.T) {
+	level, err := ParseLogLevel("debug")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	_, err = ParseLogLevel("verbose")
+	assert.Error(t, err)
+}